@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/share"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// UserBlocklistList returns the blocked device fingerprints.
+// GET /api/self/v1/blocklist
+func UserBlocklistList(c *gin.Context) {
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(tool.ListBlockedFingerprints()))
+}
+
+// UserBlocklistAdd blocks a device by fingerprint and drops it from the scan list.
+// POST /api/self/v1/blocklist
+func UserBlocklistAdd(c *gin.Context) {
+	var request types.UserBlocklistAddRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
+		return
+	}
+	if request.Fingerprint == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("fingerprint is required"))
+		return
+	}
+	if err := tool.AddBlockedFingerprint(request.Fingerprint); err != nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to block device: "+err.Error()))
+		return
+	}
+	share.RemoveUserScanCurrent(request.Fingerprint)
+	c.JSON(http.StatusOK, tool.FastReturnSuccess())
+}
+
+// UserBlocklistDelete unblocks a device.
+// DELETE /api/self/v1/blocklist/:fingerprint
+func UserBlocklistDelete(c *gin.Context) {
+	fingerprint := c.Param("fingerprint")
+	if fingerprint == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("fingerprint is required"))
+		return
+	}
+	if err := tool.RemoveBlockedFingerprint(fingerprint); err != nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to unblock device: "+err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccess())
+}
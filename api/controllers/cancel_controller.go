@@ -17,8 +17,12 @@ func NewCancelController() *CancelController {
 	return &CancelController{}
 }
 
+// HandleCancel handles cancel request. The optional reason query (e.g. user-cancelled, timeout, error)
+// is stored with the session and forwarded in the upload_cancelled notification.
+// POST /api/localsend/v2/cancel
 func (ctrl *CancelController) HandleCancel(c *gin.Context) {
 	sessionId := c.Query("sessionId")
+	reason := c.Query("reason")
 
 	if sessionId == "" {
 		tool.DefaultLogger.Errorf("Missing required parameter: sessionId")
@@ -26,7 +30,7 @@ func (ctrl *CancelController) HandleCancel(c *gin.Context) {
 		return
 	}
 
-	tool.DefaultLogger.Infof("[Cancel] Received cancel request: sessionId=%s", sessionId)
+	tool.DefaultLogger.Infof("[Cancel] Received cancel request: sessionId=%s, reason=%q", sessionId, reason)
 
 	if err := defaults.DefaultOnCancel(sessionId); err != nil {
 		tool.DefaultLogger.Errorf("[Cancel] Cancel callback error: %v", err)
@@ -35,16 +39,16 @@ func (ctrl *CancelController) HandleCancel(c *gin.Context) {
 	}
 
 	models.RemoveUploadSession(sessionId)
+	models.SetCancelReason(sessionId, reason)
 	tool.DefaultLogger.Infof("[Cancel] Removed upload session: %s", sessionId)
 
 	// Also remove share session if exists (for download mode)
-	// in case to prevent bug.
-	if _, ok := models.GetShareSession(sessionId); ok {
-		models.RemoveShareSession(sessionId)
+	// in case to prevent bug. Persistent share sessions are kept.
+	if models.RemoveExpiringShareSession(sessionId) {
 		tool.DefaultLogger.Infof("[Cancel] Also removed share session: %s", sessionId)
 	}
 
-	if err := notify.SendUploadCancelledNotification(sessionId); err != nil {
+	if err := notify.SendUploadCancelledNotification(sessionId, models.GetCancelReason(sessionId)); err != nil {
 		tool.DefaultLogger.Warnf("[Cancel] Failed to send upload_cancelled notification: %v", err)
 	}
 	boardcast.ResumeScan()
@@ -74,16 +78,16 @@ func (ctrl *CancelController) HandleCancelV1Cancel(c *gin.Context) {
 
 	models.RemoveUploadSession(sessionId)
 	models.RemoveV1Session(remoteAddr)
+	models.SetCancelReason(sessionId, c.Query("reason"))
 	tool.DefaultLogger.Infof("[V1 Cancel] Removed upload session: %s and IP mapping for: %s", sessionId, remoteAddr)
 
 	// Also remove share session if exists (for download mode)
-	// in case to prevent bug.
-	if _, ok := models.GetShareSession(sessionId); ok {
-		models.RemoveShareSession(sessionId)
+	// in case to prevent bug. Persistent share sessions are kept.
+	if models.RemoveExpiringShareSession(sessionId) {
 		tool.DefaultLogger.Infof("[V1 Cancel] Also removed share session: %s", sessionId)
 	}
 
-	if err := notify.SendUploadCancelledNotification(sessionId); err != nil {
+	if err := notify.SendUploadCancelledNotification(sessionId, models.GetCancelReason(sessionId)); err != nil {
 		tool.DefaultLogger.Warnf("[V1 Cancel] Failed to send upload_cancelled notification: %v", err)
 	}
 	boardcast.ResumeScan()
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/api/models"
+	"github.com/moyoez/localsend-go/boardcast"
+	"github.com/moyoez/localsend-go/tool"
+)
+
+// UserRotateCert regenerates the TLS certificate, swaps it into the running server and announces the new fingerprint.
+// POST /api/self/v1/rotate-cert
+func UserRotateCert(c *gin.Context) {
+	selfDevice := models.GetSelfDevice()
+	if selfDevice == nil || selfDevice.Protocol != "https" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("rotate-cert requires https protocol"))
+		return
+	}
+
+	certDER, keyDER, fingerprint, err := tool.RotateCurrentTLSCert()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to rotate certificate: "+err.Error()))
+		return
+	}
+	cert, err := tool.TLSKeyPairFromDER(certDER, keyDER)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to load rotated certificate: "+err.Error()))
+		return
+	}
+	models.SetServerCertificate(&cert)
+
+	selfDevice.Fingerprint = fingerprint
+	models.SetSelfDevice(selfDevice)
+	boardcast.SetSelfFingerprint(fingerprint)
+
+	tool.DefaultLogger.Infof("[RotateCert] Certificate rotated, new fingerprint: %s", fingerprint)
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(map[string]any{"fingerprint": fingerprint}))
+}
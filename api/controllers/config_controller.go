@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// UserExportConfig returns the device config, favorites and blocklist as a JSON bundle.
+// GET /api/self/v1/export-config?includeKey=true
+// The bundle is returned as is (not wrapped), so it can be saved and posted back to import-config.
+// The TLS private key is only included with includeKey=true.
+func UserExportConfig(c *gin.Context) {
+	includeKey := false
+	if raw := c.Query("includeKey"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, tool.FastReturnError("includeKey must be true or false"))
+			return
+		}
+		includeKey = v
+	}
+	bundle := tool.ExportConfigBundle(includeKey)
+	if includeKey {
+		tool.DefaultLogger.Warnf("[Config] Exported config bundle including the TLS private key")
+	}
+	c.Header("Content-Disposition", `attachment; filename="localsend-config.json"`)
+	c.JSON(http.StatusOK, bundle)
+}
+
+// UserImportConfig restores a bundle produced by export-config and saves it to the config file.
+// POST /api/self/v1/import-config
+func UserImportConfig(c *gin.Context) {
+	var bundle types.ConfigBundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
+		return
+	}
+	result, err := tool.ImportConfigBundle(bundle)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Failed to import config: "+err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(result))
+}
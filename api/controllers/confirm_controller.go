@@ -14,6 +14,7 @@ import (
 // UserConfirmRecv handles confirm receive request
 // GET /api/self/v1/confirm-recv
 func UserConfirmRecv(c *gin.Context) {
+	tool.TouchActivity()
 	sessionId := strings.TrimSpace(c.Query("sessionId"))
 	confirmedRaw := strings.TrimSpace(c.Query("confirmed"))
 	if sessionId == "" {
@@ -71,6 +72,7 @@ func UserTextReceivedDismiss(c *gin.Context) {
 // UserConfirmDownload handles confirm download request
 // GET /api/self/v1/confirm-download?sessionId=xxx&clientKey=yyy&confirmed=true
 func UserConfirmDownload(c *gin.Context) {
+	tool.TouchActivity()
 	sessionId := strings.TrimSpace(c.Query("sessionId"))
 	clientKey := strings.TrimSpace(c.Query("clientKey"))
 	confirmedRaw := strings.TrimSpace(c.Query("confirmed"))
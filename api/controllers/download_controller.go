@@ -1,16 +1,26 @@
 package controllers
 
 import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/gin-gonic/gin"
 	"github.com/moyoez/localsend-go/api/models"
 	"github.com/moyoez/localsend-go/boardcast"
 	"github.com/moyoez/localsend-go/notify"
+	"github.com/moyoez/localsend-go/share"
 	"github.com/moyoez/localsend-go/tool"
 	"github.com/moyoez/localsend-go/types"
 )
@@ -36,59 +46,25 @@ func browserNameFromUA(ua string) string {
 }
 
 // HandlePrepareDownload handles prepare-download request (LocalSend protocol 5.2)
-// POST /api/localsend/v2/prepare-download?sessionId=xxx&pin=xxx
+// Optional fileIds (comma-separated or repeated) limits the response to the selected files.
+// A confirmed client gets a downloadToken; passing it back as token skips confirmation on retries.
+// POST /api/localsend/v2/prepare-download?sessionId=xxx&pin=xxx&fileIds=a,b&token=xxx
 func HandlePrepareDownload(c *gin.Context) {
+	tool.TouchActivity()
 	sessionId := c.Query("sessionId")
 	if sessionId == "" {
 		sessionId = c.Query("session") // alternative param from URL
 	}
-	// session to smaller case
-	sessionId = strings.ToLower(sessionId)
-
-	if sessionId == "1145141919810" {
-		// test playground for debuging num.
-		c.JSON(http.StatusOK, &types.PrepareUploadReverseProxyResp{
-			Info: types.DeviceInfoReverseMode{
-				Alias:       "Koi-NotAPowerDeck",
-				Version:     "2.0.0",
-				DeviceModel: "HomeBrewMachineNotMachine",
-				DeviceType:  "headless",
-			},
-			SessionId: sessionId,
-			Files: map[string]types.FileInfo{
-				"ohhh my god": {
-					ID:       "hellbomb ar(strike)med, clean the area.",
-					FileName: "do you like whtas' your see",
-					Size:     100,
-					FileType: "text/plain",
-					SHA256:   "good guy",
-				},
-				"thats": {
-					ID:       "sound not good to me.",
-					FileName: "wow! lt(strike)t store /com",
-					Size:     100,
-					FileType: "text/plain",
-					SHA256:   "linu(strike) drop tech!",
-				},
-				"too anime": {
-					ID:       "Cial(strike)o~",
-					FileName: "0721",
-					Size:     0721,
-					FileType: "text/plain",
-					SHA256:   "081010101",
-				},
-				"for me": {
-					ID:       "huh? ⬆️➡️⬇️⬇️⬇️",
-					FileName: "⬆️➡️⬇️⬇️⬇️ For super earth!!!",
-					Size:     500,
-					FileType: "text/plain",
-					SHA256:   "Helldi(strike)ers ready to go!",
-				},
-			},
-		})
+	sessionId = models.NormalizeShareSessionId(sessionId)
+
+	pin := c.Query("pin")
+
+	// A fingerprint passed here also identifies the client's later downloads that leave it out
+	tool.RecordDeviceAddress(c.ClientIP(), c.Query("fingerprint"))
+	if isBlockedDownloadClient(c) {
+		c.JSON(http.StatusForbidden, tool.FastReturnError("device blocked"))
 		return
 	}
-	pin := c.Query("pin")
 
 	if sessionId == "" {
 		c.JSON(http.StatusForbidden, tool.FastReturnError("Missing sessionId"))
@@ -124,7 +100,10 @@ func HandlePrepareDownload(c *gin.Context) {
 	}
 
 	if !session.AutoAccept {
-		if models.IsDownloadConfirmed(sessionId, clientKey) {
+		if models.IsValidDownloadToken(sessionId, c.Query("token")) {
+			tool.DefaultLogger.Infof("[PrepareDownload] Session %s resumed by client %s with download token", sessionId, clientKey)
+			models.MarkDownloadConfirmed(sessionId, clientKey)
+		} else if models.IsDownloadConfirmed(sessionId, clientKey) {
 			tool.DefaultLogger.Infof("[PrepareDownload] Session %s already confirmed for client %s, returning file list", sessionId, clientKey)
 			// fall through to return 200 + files below
 		} else if ch, hasPending := models.GetConfirmDownloadChannel(sessionId, clientKey); hasPending && ch != nil {
@@ -140,7 +119,7 @@ func HandlePrepareDownload(c *gin.Context) {
 			confirmCh := make(chan types.ConfirmResult, 1)
 			models.SetConfirmDownloadChannel(sessionId, clientKey, confirmCh)
 
-			files := models.GetShareSessionFiles(session)
+			files := selectShareFiles(models.GetShareSessionFiles(session), selectedFileIds(c))
 			maxFiles := min(len(files), notify.MaxNotifyFiles)
 			filesList := make([]types.FileInfo, 0, maxFiles)
 			for _, info := range files {
@@ -200,33 +179,110 @@ func HandlePrepareDownload(c *gin.Context) {
 		}
 	}
 
-	selfDevice := models.GetSelfDevice()
-	if selfDevice == nil {
-		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Device info not available"))
+	files := selectShareFiles(models.GetShareSessionFiles(session), selectedFileIds(c))
+	if len(files) == 0 {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("No matching files in session"))
 		return
 	}
-
-	files := models.GetShareSessionFiles(session)
 	response := &types.PrepareUploadReverseProxyResp{
-		Info: types.DeviceInfoReverseMode{
-			Alias:       selfDevice.Alias,
-			Version:     selfDevice.Version,
-			DeviceModel: selfDevice.DeviceModel,
-			DeviceType:  selfDevice.DeviceType,
-			Fingerprint: selfDevice.Fingerprint,
-			Download:    selfDevice.Download,
-		},
+		Info:      prepareDownloadDeviceInfo(models.GetSelfDevice()),
 		SessionId: sessionId,
 		Files:     files,
 	}
+	if !session.AutoAccept {
+		response.DownloadToken = models.DownloadTokenFor(sessionId, clientKey)
+	}
 
 	tool.DefaultLogger.Infof("[PrepareDownload] Returning file list for session %s, file count: %d", sessionId, len(files))
 	c.JSON(http.StatusOK, response)
 }
 
+// isBlockedDownloadClient reports whether a download request comes from a blocked device. Download clients
+// need not send a fingerprint, so the device last seen at the client's address and at the address its
+// download token was issued to are checked too (see tool.RecordDeviceAddress).
+func isBlockedDownloadClient(c *gin.Context) bool {
+	return tool.IsBlocked(c.Query("fingerprint")) ||
+		tool.IsBlockedAddress(c.ClientIP()) ||
+		tool.IsBlockedAddress(models.DownloadTokenOwner(c.Query("token")))
+}
+
+// Fallbacks for self device fields that strict download clients require, used when the self device is unset or incomplete
+const (
+	prepareDownloadDefaultAlias      = "LocalSend"
+	prepareDownloadDefaultVersion    = "2.0"
+	prepareDownloadDefaultDeviceType = "headless"
+)
+
+// prepareDownloadFingerprint stands in for an empty self fingerprint, stable for the lifetime of the process.
+var prepareDownloadFingerprint = sync.OnceValue(func() string {
+	return strings.ReplaceAll(tool.GenerateRandomUUID(), "-", "")
+})
+
+// prepareDownloadDeviceInfo returns the info of a prepare-download response with every field the protocol
+// requires populated: alias, version and fingerprint are never empty, and download is always set since the
+// download API is serving this request.
+func prepareDownloadDeviceInfo(self *types.VersionMessage) types.DeviceInfoReverseMode {
+	var info types.DeviceInfoReverseMode
+	if self != nil {
+		info = types.DeviceInfoReverseMode{
+			Alias:       self.Alias,
+			Version:     self.Version,
+			DeviceModel: self.DeviceModel,
+			DeviceType:  self.DeviceType,
+			Fingerprint: self.Fingerprint,
+		}
+	}
+	info.Download = true
+	if strings.TrimSpace(info.Alias) == "" {
+		info.Alias = prepareDownloadDefaultAlias
+	}
+	if info.Version == "" {
+		info.Version = cmp.Or(tool.GetProtocolVersion(), prepareDownloadDefaultVersion)
+	}
+	if info.DeviceType == "" {
+		info.DeviceType = prepareDownloadDefaultDeviceType
+	}
+	if info.Fingerprint == "" {
+		info.Fingerprint = prepareDownloadFingerprint()
+	}
+	return info
+}
+
+// selectedFileIds returns the fileIds selection from the query (comma-separated and/or repeated), or nil for all files.
+func selectedFileIds(c *gin.Context) []string {
+	var ids []string
+	for _, raw := range c.QueryArray("fileIds") {
+		for id := range strings.SplitSeq(raw, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// selectShareFiles filters files to the given ids; an empty selection returns all files. Unknown ids are ignored.
+func selectShareFiles(files map[string]types.FileInfo, ids []string) map[string]types.FileInfo {
+	if len(ids) == 0 {
+		return files
+	}
+	selected := make(map[string]types.FileInfo, len(ids))
+	for _, id := range ids {
+		if info, ok := files[id]; ok {
+			selected[id] = info
+		}
+	}
+	return selected
+}
+
 // HandleDownload handles download request (LocalSend protocol 5.3)
-// GET /api/localsend/v2/download?sessionId=xxx&fileId=xxx
+// Sessions without auto-accept require a confirmed client or the token from prepare-download.
+// Past the concurrent download limit it answers 503; files are served at no more than the download rate limit.
+// The optional "as" query overrides the file name in Content-Disposition; the shared file is not renamed.
+// GET /api/localsend/v2/download?sessionId=xxx&fileId=xxx&token=xxx[&as=name]
 func HandleDownload(c *gin.Context) {
+	// Served files may take longer than the idle window, so the download counts as in flight until it returns
+	defer tool.BeginTransfer()()
 	sessionId := c.Query("sessionId")
 	fileId := c.Query("fileId")
 
@@ -234,6 +290,10 @@ func HandleDownload(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing parameters"))
 		return
 	}
+	if isBlockedDownloadClient(c) {
+		c.JSON(http.StatusForbidden, tool.FastReturnError("device blocked"))
+		return
+	}
 
 	session, ok := models.GetShareSession(sessionId)
 	if !ok {
@@ -241,12 +301,33 @@ func HandleDownload(c *gin.Context) {
 		c.JSON(http.StatusForbidden, tool.FastReturnError("Session not found or expired"))
 		return
 	}
+	if !session.AutoAccept && !models.IsValidDownloadToken(sessionId, c.Query("token")) &&
+		!models.IsDownloadConfirmed(sessionId, c.ClientIP()) {
+		c.JSON(http.StatusForbidden, tool.FastReturnError("Download not confirmed"))
+		return
+	}
 
 	entry, ok := models.LookupShareFile(session, fileId)
 	if !ok {
 		c.JSON(http.StatusNotFound, tool.FastReturnError("File not found"))
 		return
 	}
+	nameOverride, err := downloadNameOverride(c.Query("as"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid file name: "+err.Error()))
+		return
+	}
+	if !models.AcquireDownloadSlot() {
+		tool.DefaultLogger.Infof("[Download] Refused %s: %d downloads active", c.ClientIP(), models.ActiveDownloads())
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusServiceUnavailable, tool.FastReturnError("Too many downloads in progress, try again later"))
+		return
+	}
+	defer models.ReleaseDownloadSlot()
+	if len(entry.CommandSource) > 0 {
+		serveCommandSource(c, sessionId, fileId, entry, nameOverride)
+		return
+	}
 
 	// Verify file exists
 	info, err := os.Stat(entry.LocalPath)
@@ -271,8 +352,21 @@ func HandleDownload(c *gin.Context) {
 	} else {
 		fileName = filepath.Base(fileName)
 	}
+	servedName := fileName
+	if nameOverride != "" {
+		servedName = nameOverride
+	}
 
-	c.Header("Content-Disposition", "attachment; filename=\""+fileName+"\"")
+	// Conditional GET: let browsers skip re-downloading unchanged files on reload
+	etag := shareFileETag(entry.FileInfo.SHA256, info)
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	if isNotModified(c.Request, etag, info.ModTime()) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=\""+servedName+"\"")
 	if entry.FileInfo.FileType != "" {
 		c.Header("Content-Type", entry.FileInfo.FileType)
 	} else {
@@ -282,5 +376,219 @@ func HandleDownload(c *gin.Context) {
 	tool.DefaultLogger.Infof("[Download] Serving file: sessionId=%s, fileId=%s, path=%s", sessionId, fileId, entry.LocalPath)
 	boardcast.PauseScan()
 	defer boardcast.ResumeScan()
-	c.File(entry.LocalPath)
+	if limit := models.DownloadRateLimit(); limit > 0 {
+		serveThrottledFile(c, entry.LocalPath, info.ModTime(), limit)
+	} else {
+		c.File(entry.LocalPath)
+	}
+	if status := c.Writer.Status(); status == http.StatusOK || status == http.StatusPartialContent {
+		tool.CountDownload(int64(max(c.Writer.Size(), 0)))
+	}
+	// Range requests of resuming clients would add one entry per part; only full downloads are recorded
+	if c.Writer.Status() == http.StatusOK {
+		recordDownloadHistory(c.ClientIP(), sessionId, fileName, info.Size())
+	}
+}
+
+// recordDownloadHistory adds a file downloaded from a share session to the transfer history.
+// Downloads carry no fingerprint, so only clients found among the discovered devices are recorded.
+func recordDownloadHistory(clientIP, sessionId, fileName string, size int64) {
+	peer, ok := share.FindUserScanCurrentByIP(clientIP)
+	if !ok {
+		return
+	}
+	tool.RecordTransferHistory(peer.Fingerprint, types.TransferHistoryEntry{
+		SessionId: sessionId,
+		Direction: types.AuditDirectionSend,
+		PeerAlias: peer.Alias,
+		Files:     1,
+		FileNames: []string{fileName},
+		Bytes:     size,
+		Result:    types.HistoryResultSuccess,
+	})
+}
+
+// HandleDownloadManifest returns the files of a share session ordered by fileId, with sizes, hashes and
+// the ETags /download serves, so a client can resume a multi-file download and skip files it already has.
+// Access is checked like /download; a PIN-protected session also needs the PIN unless the client holds a token.
+// GET /api/localsend/v2/manifest?sessionId=xxx&pin=xxx&token=xxx
+func HandleDownloadManifest(c *gin.Context) {
+	tool.TouchActivity()
+	sessionId := models.NormalizeShareSessionId(c.Query("sessionId"))
+	if sessionId == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing sessionId"))
+		return
+	}
+	if isBlockedDownloadClient(c) {
+		c.JSON(http.StatusForbidden, tool.FastReturnError("device blocked"))
+		return
+	}
+
+	session, ok := models.GetShareSession(sessionId)
+	if !ok {
+		tool.DefaultLogger.Infof("[Manifest] Session not found: %s", sessionId)
+		c.JSON(http.StatusForbidden, tool.FastReturnError("Session not found or expired"))
+		return
+	}
+	authorized := models.IsValidDownloadToken(sessionId, c.Query("token")) || models.IsDownloadConfirmed(sessionId, c.ClientIP())
+	if session.Pin != "" && !authorized {
+		pin := c.Query("pin")
+		if pin == "" {
+			c.JSON(http.StatusUnauthorized, tool.FastReturnError("PIN required"))
+			return
+		}
+		if pin != session.Pin {
+			c.JSON(http.StatusUnauthorized, tool.FastReturnError("Invalid PIN"))
+			return
+		}
+	}
+	if !session.AutoAccept && !authorized {
+		c.JSON(http.StatusForbidden, tool.FastReturnError("Download not confirmed"))
+		return
+	}
+
+	entries := models.GetShareSessionEntries(session)
+	manifest := types.DownloadManifest{SessionId: sessionId, Files: make([]types.DownloadManifestEntry, 0, len(entries))}
+	for _, fileId := range slices.Sorted(maps.Keys(entries)) {
+		entry := entries[fileId]
+		item := types.DownloadManifestEntry{
+			FileId:   fileId,
+			FileName: entry.FileInfo.FileName,
+			Size:     entry.FileInfo.Size,
+			FileType: entry.FileInfo.FileType,
+			SHA256:   entry.FileInfo.SHA256,
+		}
+		if len(entry.CommandSource) == 0 {
+			if info, err := os.Stat(entry.LocalPath); err == nil && !info.IsDir() {
+				item.Size = info.Size()
+				item.ETag = shareFileETag(entry.FileInfo.SHA256, info)
+			}
+		}
+		manifest.TotalSize += item.Size
+		manifest.Files = append(manifest.Files, item)
+	}
+	c.JSON(http.StatusOK, manifest)
+}
+
+// downloadNameOverride validates the "as" file name of a download and returns it sanitized, or "" when as is empty.
+// Control characters are rejected rather than stripped, so CR/LF cannot inject headers; quotes and path
+// separators become "_" since the name ends up in a quoted Content-Disposition filename.
+func downloadNameOverride(as string) (string, error) {
+	if as == "" {
+		return "", nil
+	}
+	if strings.ContainsFunc(as, unicode.IsControl) {
+		return "", fmt.Errorf("control characters not allowed")
+	}
+	name := tool.SanitizeFileName(strings.NewReplacer(`"`, "_", `\`, "_", "/", "_").Replace(as))
+	if name == "." || name == ".." {
+		return "", fmt.Errorf("reserved name")
+	}
+	return name, nil
+}
+
+// serveThrottledFile serves path like c.File, including range requests, but at no more than bytesPerSecond.
+func serveThrottledFile(c *gin.Context, path string, modTime time.Time, bytesPerSecond int64) {
+	file, err := os.Open(path)
+	if err != nil {
+		tool.DefaultLogger.Errorf("[Download] Failed to open file: %v", err)
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to read file"))
+		return
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			tool.DefaultLogger.Warnf("[Download] Failed to close file: %v", err)
+		}
+	}()
+	http.ServeContent(c.Writer, c.Request, filepath.Base(path), modTime, tool.NewThrottledReadSeeker(c.Request.Context(), file, bytesPerSecond))
+}
+
+// serveCommandSource runs the entry's command and streams its stdout with chunked transfer encoding.
+// The command is killed when the client disconnects.
+func serveCommandSource(c *gin.Context, sessionId, fileId string, entry types.ShareFileEntry, nameOverride string) {
+	cmd := exec.CommandContext(c.Request.Context(), entry.CommandSource[0], entry.CommandSource[1:]...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		tool.DefaultLogger.Errorf("[Download] Failed to prepare command: %v", err)
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to start command"))
+		return
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &limitedWriter{w: &stderr, n: 4096}
+	if err := cmd.Start(); err != nil {
+		tool.DefaultLogger.Errorf("[Download] Failed to start command %q: %v", entry.CommandSource[0], err)
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to start command"))
+		return
+	}
+
+	fileName := filepath.Base(entry.FileInfo.FileName)
+	if nameOverride != "" {
+		fileName = nameOverride
+	}
+	c.Header("Content-Disposition", "attachment; filename=\""+fileName+"\"")
+	c.Header("Content-Type", entry.FileInfo.FileType)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	tool.DefaultLogger.Infof("[Download] Streaming command output: sessionId=%s, fileId=%s, command=%s", sessionId, fileId, entry.CommandSource[0])
+	boardcast.PauseScan()
+	defer boardcast.ResumeScan()
+	written, copyErr := io.Copy(c.Writer, stdout)
+	waitErr := cmd.Wait()
+	switch {
+	case copyErr != nil:
+		tool.DefaultLogger.Warnf("[Download] Command stream for %s interrupted after %d bytes: %v", fileId, written, copyErr)
+	case waitErr != nil:
+		// headers are already sent, so the client only sees a truncated body
+		tool.DefaultLogger.Warnf("[Download] Command for %s failed after %d bytes: %v: %s", fileId, written, waitErr, strings.TrimSpace(stderr.String()))
+	default:
+		tool.DefaultLogger.Infof("[Download] Command output for %s done: %d bytes", fileId, written)
+	}
+	tool.CountDownload(written)
+}
+
+// limitedWriter keeps at most n bytes and discards the rest without failing the writer.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n > 0 {
+		keep := p[:min(len(p), l.n)]
+		l.n -= len(keep)
+		l.w.Write(keep)
+	}
+	return len(p), nil
+}
+
+// shareFileETag returns a strong ETag from the file's SHA-256 when known, otherwise from mtime and size.
+func shareFileETag(sha256 string, info os.FileInfo) string {
+	if sha256 != "" {
+		return "\"" + sha256 + "\""
+	}
+	return fmt.Sprintf("\"%x-%x\"", info.ModTime().UnixNano(), info.Size())
+}
+
+// isNotModified reports whether the request's If-None-Match / If-Modified-Since allow a 304 response.
+// If-None-Match takes precedence over If-Modified-Since (RFC 9110).
+func isNotModified(r *http.Request, etag string, modTime time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for candidate := range strings.SplitSeq(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		// HTTP dates have second precision
+		return !modTime.Truncate(time.Second).After(t)
+	}
+	return false
 }
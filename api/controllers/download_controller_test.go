@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/api/models"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+func TestHandlePrepareDownloadResponseShape(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/api/localsend/v2/prepare-download", HandlePrepareDownload)
+
+	previousSelf := models.GetSelfDevice()
+	t.Cleanup(func() { models.SetSelfDevice(previousSelf) })
+
+	const sessionId = "shape-session"
+	models.CacheShareSession(&types.ShareSession{
+		SessionId: sessionId,
+		Files: map[string]types.ShareFileEntry{
+			"file": {FileInfo: types.FileInfo{ID: "file", FileName: "notes.txt", Size: 5, FileType: "text/plain"}},
+		},
+		CreatedAt:  time.Now(),
+		AutoAccept: true,
+	})
+	t.Cleanup(func() { models.RemoveShareSession(sessionId) })
+
+	tests := []struct {
+		name            string
+		self            *types.VersionMessage
+		wantAlias       string
+		wantFingerprint string
+	}{
+		{name: "no self device", self: nil},
+		{name: "empty self device", self: &types.VersionMessage{}},
+		{name: "blank alias", self: &types.VersionMessage{Alias: "  ", Version: "2.1", Fingerprint: "abc"}, wantFingerprint: "abc"},
+		{
+			name: "complete self device",
+			self: &types.VersionMessage{
+				Alias: "Nice Orange", Version: "2.1", DeviceModel: "steamdeck", DeviceType: "desktop", Fingerprint: "abc",
+			},
+			wantAlias:       "Nice Orange",
+			wantFingerprint: "abc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			models.SetSelfDevice(tt.self)
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/localsend/v2/prepare-download?sessionId="+sessionId, nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status %d, body %s", recorder.Code, recorder.Body)
+			}
+
+			// Decode loosely so missing keys are caught, not hidden by zero values
+			var response struct {
+				Info      map[string]any            `json:"info"`
+				SessionId string                    `json:"sessionId"`
+				Files     map[string]map[string]any `json:"files"`
+			}
+			if err := sonic.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			for _, key := range []string{"alias", "version", "deviceType", "fingerprint"} {
+				if value, _ := response.Info[key].(string); value == "" {
+					t.Errorf("info.%s is empty: %v", key, response.Info)
+				}
+			}
+			if download, _ := response.Info["download"].(bool); !download {
+				t.Errorf("info.download = %v, want true", response.Info["download"])
+			}
+			if tt.wantAlias != "" && response.Info["alias"] != tt.wantAlias {
+				t.Errorf("info.alias = %v, want %q", response.Info["alias"], tt.wantAlias)
+			}
+			if tt.wantFingerprint != "" && response.Info["fingerprint"] != tt.wantFingerprint {
+				t.Errorf("info.fingerprint = %v, want %q", response.Info["fingerprint"], tt.wantFingerprint)
+			}
+			if response.SessionId != sessionId {
+				t.Errorf("sessionId = %q, want %q", response.SessionId, sessionId)
+			}
+			file, ok := response.Files["file"]
+			if !ok {
+				t.Fatalf("files = %v, want the shared file", response.Files)
+			}
+			for _, key := range []string{"id", "fileName", "size", "fileType"} {
+				if _, ok := file[key]; !ok {
+					t.Errorf("files.file.%s is missing: %v", key, file)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadsRejectBlockedDevice(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/api/localsend/v2/prepare-download", HandlePrepareDownload)
+	engine.GET("/api/localsend/v2/download", HandleDownload)
+	engine.GET("/api/localsend/v2/manifest", HandleDownloadManifest)
+
+	previousConfigPath := tool.ConfigPath
+	tool.ConfigPath = filepath.Join(t.TempDir(), "config.yaml")
+	t.Cleanup(func() { tool.ConfigPath = previousConfigPath })
+
+	const (
+		sessionId   = "blocked-session"
+		blockedFp   = "blocked-device"
+		blockedIP   = "192.0.2.10"
+		otherIP     = "192.0.2.20"
+		permittedIP = "192.0.2.30"
+		// prepare-download records the fingerprint it is given, so that case gets an address of its own
+		fingerprintIP = "192.0.2.40"
+	)
+	models.CacheShareSession(&types.ShareSession{
+		SessionId: sessionId,
+		Files: map[string]types.ShareFileEntry{
+			"file": {FileInfo: types.FileInfo{ID: "file", FileName: "notes.txt", Size: 5, FileType: "text/plain"}},
+		},
+		CreatedAt: time.Now(),
+	})
+	t.Cleanup(func() { models.RemoveShareSession(sessionId) })
+	blockedToken := models.DownloadTokenFor(sessionId, blockedIP)
+	permittedToken := models.DownloadTokenFor(sessionId, permittedIP)
+
+	tool.RecordDeviceAddress(blockedIP, blockedFp)
+	if err := tool.AddBlockedFingerprint(blockedFp); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tool.RemoveBlockedFingerprint(blockedFp) })
+
+	tests := []struct {
+		name       string
+		target     string
+		remoteIP   string
+		wantStatus int
+	}{
+		{name: "prepare-download from the blocked address", target: "/api/localsend/v2/prepare-download?sessionId=" + sessionId, remoteIP: blockedIP, wantStatus: http.StatusForbidden},
+		{name: "prepare-download with the blocked fingerprint", target: "/api/localsend/v2/prepare-download?sessionId=" + sessionId + "&fingerprint=" + blockedFp, remoteIP: fingerprintIP, wantStatus: http.StatusForbidden},
+		{name: "download from the blocked address", target: "/api/localsend/v2/download?sessionId=" + sessionId + "&fileId=file", remoteIP: blockedIP, wantStatus: http.StatusForbidden},
+		{name: "download with a token of the blocked device", target: "/api/localsend/v2/download?sessionId=" + sessionId + "&fileId=file&token=" + blockedToken, remoteIP: otherIP, wantStatus: http.StatusForbidden},
+		{name: "manifest from the blocked address", target: "/api/localsend/v2/manifest?sessionId=" + sessionId + "&token=" + permittedToken, remoteIP: blockedIP, wantStatus: http.StatusForbidden},
+		{name: "manifest with a token of the blocked device", target: "/api/localsend/v2/manifest?sessionId=" + sessionId + "&token=" + blockedToken, remoteIP: otherIP, wantStatus: http.StatusForbidden},
+		{name: "manifest of a permitted device", target: "/api/localsend/v2/manifest?sessionId=" + sessionId + "&token=" + permittedToken, remoteIP: permittedIP, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			request.RemoteAddr = tt.remoteIP + ":40000"
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, request)
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d, body %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
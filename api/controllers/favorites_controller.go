@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/share"
 	"github.com/moyoez/localsend-go/tool"
 	"github.com/moyoez/localsend-go/types"
 )
@@ -31,6 +32,12 @@ func UserFavoritesAdd(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to add favorite: "+err.Error()))
 		return
 	}
+	// Seed the last known address if the device is currently discovered
+	if item, ok := share.GetUserScanCurrent(request.Fingerprint); ok {
+		if err := tool.UpdateFavoriteAddress(request.Fingerprint, item.Ipaddress, item.Port); err != nil {
+			tool.DefaultLogger.Warnf("Failed to store favorite address: %v", err)
+		}
+	}
 	c.JSON(http.StatusOK, tool.FastReturnSuccess())
 }
 
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// UserHistory returns the completed transfers with one device, newest first.
+// GET /api/self/v1/history?fingerprint=xxx
+func UserHistory(c *gin.Context) {
+	fingerprint := strings.TrimSpace(c.Query("fingerprint"))
+	if fingerprint == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing required parameter: fingerprint"))
+		return
+	}
+	entries := tool.GetTransferHistory(fingerprint)
+	if entries == nil {
+		entries = []types.TransferHistoryEntry{}
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(types.TransferHistoryResponse{
+		Fingerprint: fingerprint,
+		Entries:     entries,
+	}))
+}
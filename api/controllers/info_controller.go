@@ -1,10 +1,15 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/moyoez/localsend-go/api/models"
+	"github.com/moyoez/localsend-go/tool"
 	"github.com/moyoez/localsend-go/types"
 )
 
@@ -22,11 +27,57 @@ func HandleLocalsendV1InfoGet(c *gin.Context) {
 func HandleLocalsendV2InfoGet(c *gin.Context) {
 	selfDevice := models.GetSelfDevice()
 	c.JSON(http.StatusOK, types.V2InfoResponse{
-		Alias:       selfDevice.Alias,
-		Version:     selfDevice.Version,
-		DeviceModel: selfDevice.DeviceModel,
-		DeviceType:  selfDevice.DeviceType,
-		Fingerprint: selfDevice.Fingerprint,
-		Download:    selfDevice.Download, // always false.
+		Alias:        selfDevice.Alias,
+		Version:      selfDevice.Version,
+		DeviceModel:  selfDevice.DeviceModel,
+		DeviceType:   selfDevice.DeviceType,
+		Fingerprint:  selfDevice.Fingerprint,
+		Download:     selfDevice.Download, // always false.
+		Note:         selfDevice.Note,
+		Capabilities: selfDevice.Capabilities,
 	})
 }
+
+// HandleLocalsendV2StorageInfo returns the free space of the upload folder's filesystem.
+// Only served when the storageInfo capability is enabled in the config.
+// GET /api/localsend/v2/storage-info
+func HandleLocalsendV2StorageInfo(c *gin.Context) {
+	selfDevice := models.GetSelfDevice()
+	if selfDevice == nil || !selfDevice.Capabilities[types.CapabilityStorageInfo] {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	uploadFolder := models.DefaultUploadFolder()
+	available, total, err := tool.DiskSpace(uploadFolder)
+	if err != nil {
+		tool.DefaultLogger.Warnf("[StorageInfo] Failed to read disk space of %s: %v", uploadFolder, err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, types.StorageInfo{Available: available, Total: total})
+}
+
+// HandleLocalsendV2Echo reads and discards the request body and reports how much arrived and how long
+// it took, so senders can measure throughput. Only served when the echo capability is enabled.
+// POST /api/localsend/v2/echo
+func HandleLocalsendV2Echo(c *gin.Context) {
+	selfDevice := models.GetSelfDevice()
+	if selfDevice == nil || !selfDevice.Capabilities[types.CapabilityEcho] {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	start := time.Now()
+	n, err := io.Copy(io.Discard, http.MaxBytesReader(c.Writer, c.Request.Body, types.EchoMaxBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, tool.FastReturnError(fmt.Sprintf("Body larger than %d bytes", int64(types.EchoMaxBytes))))
+			return
+		}
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Failed to read body: "+err.Error()))
+		return
+	}
+	elapsed := time.Since(start)
+	tool.DefaultLogger.Debugf("[Echo] Received %d bytes from %s in %s", n, c.ClientIP(), elapsed)
+	c.JSON(http.StatusOK, types.EchoResult{BytesReceived: n, ElapsedMs: elapsed.Milliseconds()})
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/tool"
+)
+
+// UserStreamLogs streams server log lines as Server-Sent Events ("log" events with a LogEntry),
+// starting with the recently buffered lines. Optional level (debug, info, warn, error) drops lower ones.
+// GET /api/self/v1/logs?level=warn
+func UserStreamLogs(c *gin.Context) {
+	minLevel := log.DebugLevel
+	if raw := c.Query("level"); raw != "" {
+		level, err := log.ParseLevel(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, tool.FastReturnError("level must be debug, info, warn or error"))
+			return
+		}
+		minLevel = level
+	}
+	history, lines, cancel := tool.SubscribeLogs(minLevel)
+	defer cancel()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	for _, entry := range history {
+		c.SSEvent("log", entry)
+	}
+	c.Writer.Flush()
+	for {
+		select {
+		case entry, ok := <-lines:
+			if !ok {
+				return
+			}
+			c.SSEvent("log", entry)
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
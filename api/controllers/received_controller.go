@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/api/models"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// UserDeleteReceived removes the files saved for a received session.
+// DELETE /api/self/v1/received?sessionId=xxx
+func UserDeleteReceived(c *gin.Context) {
+	sessionId := c.Query("sessionId")
+	if sessionId == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing required parameter: sessionId"))
+		return
+	}
+	if strings.ContainsAny(sessionId, `/\`) {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid sessionId"))
+		return
+	}
+	if models.IsUploadSessionActive(sessionId) {
+		c.JSON(http.StatusConflict, tool.FastReturnError("Session is still receiving files"))
+		return
+	}
+	uploadRoot, err := filepath.Abs(models.DefaultUploadFolder())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to resolve upload folder: "+err.Error()))
+		return
+	}
+
+	var targets []string
+	if !models.DoNotMakeSessionFolder {
+		targets = append(targets, filepath.Join(uploadRoot, sessionId))
+	}
+	targets = append(targets, models.GetReceivedSavePaths(sessionId)...)
+
+	removed := 0
+	for _, target := range targets {
+		targetAbs, ok := receivedPathUnder(uploadRoot, target)
+		if !ok {
+			tool.DefaultLogger.Warnf("[Received] Refusing to delete %s: outside upload folder", target)
+			continue
+		}
+		if _, err := os.Lstat(targetAbs); err != nil {
+			continue
+		}
+		if err := os.RemoveAll(targetAbs); err != nil {
+			c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to delete received files: "+err.Error()))
+			return
+		}
+		removed++
+	}
+	models.ForgetReceivedSavePaths(sessionId)
+	if removed == 0 {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("No received files found for session"))
+		return
+	}
+	tool.DefaultLogger.Infof("[Received] Deleted %d path(s) of session %s", removed, sessionId)
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(gin.H{"removed": removed}))
+}
+
+// UserCleanupReceived removes entries in the upload folder older than the given age.
+// POST /api/self/v1/cleanup-received?olderThan=7d
+// Entries belonging to sessions that are still receiving are kept.
+func UserCleanupReceived(c *gin.Context) {
+	olderThan, err := parseAge(c.DefaultQuery("olderThan", "7d"))
+	if err != nil || olderThan <= 0 {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid olderThan (e.g. 7d, 12h)"))
+		return
+	}
+	uploadRoot, err := filepath.Abs(models.DefaultUploadFolder())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to resolve upload folder: "+err.Error()))
+		return
+	}
+	entries, err := os.ReadDir(uploadRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(gin.H{"removed": 0}))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to read upload folder: "+err.Error()))
+		return
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	for _, entry := range entries {
+		if !models.DoNotMakeSessionFolder && models.IsUploadSessionActive(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(uploadRoot, entry.Name())); err != nil {
+			tool.DefaultLogger.Warnf("[Received] Failed to delete %s: %v", entry.Name(), err)
+			continue
+		}
+		if !models.DoNotMakeSessionFolder {
+			models.ForgetReceivedSavePaths(entry.Name())
+		}
+		removed++
+	}
+	tool.DefaultLogger.Infof("[Received] Cleanup removed %d entr(ies) older than %s", removed, olderThan)
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(gin.H{"removed": removed}))
+}
+
+// UserVerifyReceived re-hashes the saved files of a received session and compares them with the
+// SHA256 recorded when they were received, returning a per-file report.
+// Files received without a hash are reported as unverified.
+// POST /api/self/v1/verify-received?sessionId=xxx
+func UserVerifyReceived(c *gin.Context) {
+	sessionId := c.Query("sessionId")
+	if sessionId == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing required parameter: sessionId"))
+		return
+	}
+	if models.IsUploadSessionActive(sessionId) {
+		c.JSON(http.StatusConflict, tool.FastReturnError("Session is still receiving files"))
+		return
+	}
+	records := models.GetReceivedFiles(sessionId)
+	if len(records) == 0 {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("No received files found for session"))
+		return
+	}
+
+	fileIds := make([]string, 0, len(records))
+	for fileId := range records {
+		fileIds = append(fileIds, fileId)
+	}
+	slices.Sort(fileIds)
+
+	resp := types.VerifyReceivedResponse{SessionId: sessionId, Files: make([]types.VerifyReceivedFile, 0, len(fileIds))}
+	for _, fileId := range fileIds {
+		record := records[fileId]
+		result := types.VerifyReceivedFile{
+			FileId:         fileId,
+			SavePath:       record.SavePath,
+			ExpectedSHA256: record.SHA256,
+		}
+		actual, err := hashReceivedFile(record.SavePath)
+		switch {
+		case err != nil:
+			result.Status = types.VerifyStatusMissing
+			result.Error = err.Error()
+			resp.Missing++
+		case record.SHA256 == "":
+			result.Status = types.VerifyStatusUnverified
+			result.ActualSHA256 = actual
+			resp.Unverified++
+		case strings.EqualFold(actual, record.SHA256):
+			result.Status = types.VerifyStatusPass
+			result.ActualSHA256 = actual
+			resp.Passed++
+		default:
+			result.Status = types.VerifyStatusFail
+			result.ActualSHA256 = actual
+			resp.Failed++
+		}
+		resp.Files = append(resp.Files, result)
+	}
+	if resp.Failed > 0 || resp.Missing > 0 {
+		tool.DefaultLogger.Warnf("[Received] Verify of session %s: %d failed, %d missing", sessionId, resp.Failed, resp.Missing)
+	} else {
+		tool.DefaultLogger.Infof("[Received] Verify of session %s: %d passed, %d unverified", sessionId, resp.Passed, resp.Unverified)
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(resp))
+}
+
+// hashReceivedFile returns the hex SHA256 of the file at path, decrypting it first if it was stored encrypted.
+func hashReceivedFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	var src io.Reader = file
+	if tool.IsEncryptedFile(file) {
+		key := models.EncryptionKey()
+		if key == nil {
+			return "", fmt.Errorf("file is encrypted but no encryption key is set")
+		}
+		stat, err := file.Stat()
+		if err != nil {
+			return "", err
+		}
+		if src, err = tool.NewDecryptReader(file, stat.Size(), key); err != nil {
+			return "", err
+		}
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, src); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// receivedPathUnder returns the absolute form of target if it lies strictly inside root.
+func receivedPathUnder(root, target string) (string, bool) {
+	targetAbs, err := filepath.Abs(target)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, targetAbs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return targetAbs, true
+}
+
+// parseAge parses a duration that may also use a day suffix, e.g. "7d".
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid days: %w", err)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
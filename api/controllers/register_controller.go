@@ -43,7 +43,12 @@ func (ctrl *RegisterController) HandleRegister(c *gin.Context) {
 	}
 	tool.DefaultLogger.Infof("[Register] Received register request from %s (fingerprint: %s)", incoming.Alias, incoming.Fingerprint)
 
+	tool.RecordDeviceAddress(c.ClientIP(), incoming.Fingerprint)
 	if err := defaults.DefaultOnRegister(incoming); err != nil {
+		if err.Error() == "device blocked" {
+			c.JSON(http.StatusForbidden, tool.FastReturnError(err.Error()))
+			return
+		}
 		tool.DefaultLogger.Errorf("[Register] Register callback error: %v", err)
 		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Internal server error"))
 		return
@@ -60,25 +65,30 @@ func (ctrl *RegisterController) HandleRegister(c *gin.Context) {
 		share.SetUserScanCurrent(incoming.Fingerprint, types.UserScanCurrentItem{
 			Ipaddress: host,
 			VersionMessage: types.VersionMessage{
-				Alias:       incoming.Alias,
-				Version:     incoming.Version,
-				DeviceModel: incoming.DeviceModel,
-				DeviceType:  incoming.DeviceType,
-				Fingerprint: incoming.Fingerprint,
-				Port:        incoming.Port,
-				Protocol:    protocol,
-				Download:    incoming.Download,
-				Announce:    incoming.Announce,
+				Alias:        incoming.Alias,
+				Version:      incoming.Version,
+				DeviceModel:  incoming.DeviceModel,
+				DeviceType:   incoming.DeviceType,
+				Fingerprint:  incoming.Fingerprint,
+				Port:         incoming.Port,
+				Protocol:     protocol,
+				Download:     incoming.Download,
+				Announce:     incoming.Announce,
+				Capabilities: incoming.Capabilities,
+				Note:         incoming.Note,
 			},
 		})
 	}
 
 	c.JSON(http.StatusOK, types.CallbackVersionMessageHTTP{
-		Alias:       self.Alias,
-		Version:     self.Version,
-		DeviceModel: self.DeviceModel,
-		DeviceType:  self.DeviceType,
-		Fingerprint: self.Fingerprint,
-		Download:    self.Download,
+		Alias:        self.Alias,
+		Version:      self.Version,
+		DeviceModel:  self.DeviceModel,
+		DeviceType:   self.DeviceType,
+		Fingerprint:  self.Fingerprint,
+		Download:     self.Download,
+		Capabilities: self.Capabilities,
+		Note:         self.Note,
+		Peers:        boardcast.GossipPeers(incoming.Fingerprint),
 	})
 }
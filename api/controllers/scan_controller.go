@@ -1,15 +1,22 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/moyoez/localsend-go/boardcast"
 	"github.com/moyoez/localsend-go/share"
 	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/transfer"
 	"github.com/moyoez/localsend-go/types"
 )
 
+// speedTestDefaultSize is how much data UserSpeedTest sends when no size is given.
+const speedTestDefaultSize = 10 * 1000 * 1000
+
 // UserGetNetworkInfo returns local network interface information with IP addresses and segment numbers.
 // GET /api/self/v1/get-network-info
 func UserGetNetworkInfo(c *gin.Context) {
@@ -18,8 +25,46 @@ func UserGetNetworkInfo(c *gin.Context) {
 }
 
 // UserScanCurrent returns the current scanned devices.
-// GET /api/self/v1/scan-current
+// Optional type (comma-separated DeviceType, e.g. mobile,desktop) and download=true|false filter the list.
+// GET /api/self/v1/scan-current?type=mobile&download=true
 func UserScanCurrent(c *gin.Context) {
+	filter, err := parseScanFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(filter.devices()))
+}
+
+// scanFilter selects scan-list devices by DeviceType and download capability; zero value keeps all.
+type scanFilter struct {
+	types    map[string]bool
+	download *bool
+}
+
+// parseScanFilter reads the type and download query parameters.
+func parseScanFilter(c *gin.Context) (scanFilter, error) {
+	var filter scanFilter
+	for raw := range strings.SplitSeq(c.Query("type"), ",") {
+		if deviceType := strings.ToLower(strings.TrimSpace(raw)); deviceType != "" {
+			if filter.types == nil {
+				filter.types = make(map[string]bool)
+			}
+			filter.types[deviceType] = true
+		}
+	}
+	if raw := c.Query("download"); raw != "" {
+		download, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, fmt.Errorf("download must be true or false")
+		}
+		filter.download = &download
+	}
+	return filter, nil
+}
+
+// devices returns the cached scan-list devices that match the filter.
+func (f scanFilter) devices() []types.UserScanCurrentItem {
 	keys := share.ListUserScanCurrent()
 	values := make([]types.UserScanCurrentItem, 0)
 	for _, key := range keys {
@@ -27,28 +72,183 @@ func UserScanCurrent(c *gin.Context) {
 		if !ok {
 			continue
 		}
+		if f.types != nil && !f.types[strings.ToLower(item.DeviceType)] {
+			continue
+		}
+		if f.download != nil && item.Download != *f.download {
+			continue
+		}
 		values = append(values, item)
 	}
-	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(values))
+	return values
+}
+
+// UserDevice returns a single cached device from the scan list.
+// GET /api/self/v1/device?fingerprint=xxx
+func UserDevice(c *gin.Context) {
+	fingerprint := c.Query("fingerprint")
+	if fingerprint == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("fingerprint is required"))
+		return
+	}
+	item, ok := share.GetUserScanCurrent(fingerprint)
+	if !ok {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("Device not found"))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(item))
+}
+
+// UserSpeedTest measures the throughput to a scanned device by streaming size bytes (default 10 MB)
+// of random data to its /echo endpoint. The device must advertise the echo capability.
+// POST /api/self/v1/speed-test?fingerprint=xxx&size=xxx
+func UserSpeedTest(c *gin.Context) {
+	fingerprint := c.Query("fingerprint")
+	if fingerprint == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("fingerprint is required"))
+		return
+	}
+	size := int64(speedTestDefaultSize)
+	if sizeStr := c.Query("size"); sizeStr != "" {
+		n, err := strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil || n <= 0 || n > types.EchoMaxBytes {
+			c.JSON(http.StatusBadRequest, tool.FastReturnError(fmt.Sprintf("Invalid size (1-%d bytes)", int64(types.EchoMaxBytes))))
+			return
+		}
+		size = n
+	}
+	item, ok := share.GetUserScanCurrent(fingerprint)
+	if !ok {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("Device not found"))
+		return
+	}
+	if !item.Capabilities[types.CapabilityEcho] {
+		c.JSON(http.StatusConflict, tool.FastReturnError("Device does not support speed tests (echo capability)"))
+		return
+	}
+	targetAddr, err := targetUDPAddr(item)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid device address: "+err.Error()))
+		return
+	}
+	tool.TouchActivity()
+	result, err := transfer.MeasureThroughput(targetAddr, &item.VersionMessage, size)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, tool.FastReturnError("Speed test failed: "+err.Error()))
+		return
+	}
+	tool.DefaultLogger.Infof("[SpeedTest] %s: %d bytes in %dms (%.2f MB/s)", item.Alias, result.BytesSent, result.DurationMs, result.MBps)
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(result))
+}
+
+// UserDeviceLive probes a device via /api/localsend/v2/info and returns it in the same shape as UserDevice.
+// The scan list is not updated. port defaults to 53317.
+// GET /api/self/v1/device-live?ip=xxx&port=xxx
+func UserDeviceLive(c *gin.Context) {
+	ip := c.Query("ip")
+	if _, err := tool.ParseTargetIP(ip); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid ip: "+err.Error()))
+		return
+	}
+	port := 53317
+	if portStr := c.Query("port"); portStr != "" {
+		p, err := strconv.Atoi(portStr)
+		if err != nil || p <= 0 || p > 65535 {
+			c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid port"))
+			return
+		}
+		port = p
+	}
+	deviceInfo, protocol, err := transfer.FetchDeviceInfo(ip, port)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, tool.FastReturnError("Failed to fetch device info: "+err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(deviceItemFromInfo(ip, port, protocol, deviceInfo)))
+}
+
+// deviceItemFromInfo builds a scan-list item from a /info response.
+func deviceItemFromInfo(ip string, port int, protocol string, deviceInfo *types.CallbackLegacyVersionMessageHTTP) types.UserScanCurrentItem {
+	return types.UserScanCurrentItem{
+		Ipaddress: ip,
+		VersionMessage: types.VersionMessage{
+			Alias:        deviceInfo.Alias,
+			Version:      deviceInfo.Version,
+			DeviceModel:  deviceInfo.DeviceModel,
+			DeviceType:   deviceInfo.DeviceType,
+			Fingerprint:  deviceInfo.Fingerprint,
+			Port:         port,
+			Protocol:     protocol,
+			Download:     deviceInfo.Download,
+			Announce:     true,
+			Capabilities: deviceInfo.Capabilities,
+			Note:         deviceInfo.Note,
+		},
+	}
 }
 
 // UserScanNow triggers scan-now: HTTP scan only. Clears device list, runs HTTP scan, returns current devices; normal (mixed) auto scan continues in background.
+// Accepts the same type and download filters as scan-current. The response carries the devices
+// plus the mode that ran, IPs probed, duration and whether the HTTP sweep timed out.
 // GET /api/self/v1/scan-now
 func UserScanNow(c *gin.Context) {
+	filter, err := parseScanFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError(err.Error()))
+		return
+	}
 	share.ClearUserScanCurrent()
-	err := boardcast.ScanNow()
+	result, err := boardcast.ScanNow()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Scan failed: "+err.Error()))
 		return
 	}
-	keys := share.ListUserScanCurrent()
-	values := make([]types.UserScanCurrentItem, 0)
-	for _, key := range keys {
-		item, ok := share.GetUserScanCurrent(key)
-		if !ok {
-			continue
-		}
-		values = append(values, item)
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(types.UserScanNowResponse{
+		Devices:     filter.devices(),
+		Mode:        result.Mode,
+		ProbedCount: result.ProbedCount,
+		DurationMs:  result.DurationMs,
+		TimedOut:    result.TimedOut,
+	}))
+}
+
+// UserScanStatus reports progress of the current (or last) HTTP sweep.
+// GET /api/self/v1/scan-status
+func UserScanStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(boardcast.GetHTTPScanStatus()))
+}
+
+// UserScanControlGet reports the current scan pause state.
+// GET /api/self/v1/scan-control
+func UserScanControlGet(c *gin.Context) {
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(currentScanControlStatus()))
+}
+
+// UserScanControl pauses or resumes discovery on user request, independent of transfer pauses.
+// POST /api/self/v1/scan-control
+func UserScanControl(c *gin.Context) {
+	var request types.ScanControlRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
+		return
+	}
+	switch request.Action {
+	case "pause":
+		boardcast.SetScanUserPaused(true)
+	case "resume":
+		boardcast.SetScanUserPaused(false)
+	default:
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("action must be \"pause\" or \"resume\""))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(currentScanControlStatus()))
+}
+
+func currentScanControlStatus() types.ScanControlStatus {
+	userPaused, transferCount := boardcast.GetScanPauseState()
+	return types.ScanControlStatus{
+		Paused:        boardcast.IsScanPaused(),
+		UserPaused:    userPaused,
+		TransferCount: transferCount,
 	}
-	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(values))
 }
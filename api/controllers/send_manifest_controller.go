@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/api/models"
+	"github.com/moyoez/localsend-go/boardcast"
+	"github.com/moyoez/localsend-go/share"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/transfer"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// UserSendManifest reads a local manifest JSON (target fingerprint and file paths) and runs the whole
+// prepare-upload and upload flow in one call, answering like upload-batch with a per-file summary.
+// The session can be cancelled and followed through send-progress while it runs.
+// POST /api/self/v1/send-manifest
+func UserSendManifest(c *gin.Context) {
+	tool.TouchActivity()
+	var request types.UserSendManifestRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
+		return
+	}
+	if request.ManifestPath == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("manifestPath is required"))
+		return
+	}
+	data, err := os.ReadFile(request.ManifestPath)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Failed to read manifest: "+err.Error()))
+		return
+	}
+	var manifest types.SendManifest
+	if err := sonic.Unmarshal(data, &manifest); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid manifest: "+err.Error()))
+		return
+	}
+	if manifest.TargetTo == "" || len(manifest.Files) == 0 {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Manifest needs targetTo and at least one file"))
+		return
+	}
+	targetItem, ok := share.GetUserScanCurrent(manifest.TargetTo)
+	if !ok {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("Target device not found"))
+		return
+	}
+	selfDevice := models.GetSelfDevice()
+	if selfDevice == nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Local device information not configured"))
+		return
+	}
+
+	// Relative paths are resolved against the manifest's folder, so a manifest can travel with its files
+	manifestDir := filepath.Dir(request.ManifestPath)
+	filesMap := make(map[string]types.FileInfo, len(manifest.Files))
+	filePaths := make(map[string]string, len(manifest.Files))
+	for _, path := range manifest.Files {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(manifestDir, path)
+		}
+		path = filepath.Clean(path)
+		fileId := tool.GenerateFileID(path)
+		if _, duplicate := filePaths[fileId]; duplicate {
+			c.JSON(http.StatusBadRequest, tool.FastReturnError("Duplicate file in manifest: "+path))
+			return
+		}
+		fileInput := types.FileInput{ID: fileId, FileUrl: "file://" + path}
+		if err := tool.ProcessFileInput(&fileInput, true); err != nil {
+			c.JSON(http.StatusBadRequest, tool.FastReturnError(fmt.Sprintf("Failed to process file %s: %v", path, err)))
+			return
+		}
+		filesMap[fileInput.ID] = types.FileInfo{
+			ID:       fileInput.ID,
+			FileName: fileInput.FileName,
+			Size:     fileInput.SizeValue(),
+			FileType: fileInput.FileType,
+			SHA256:   fileInput.SHA256,
+			Preview:  fileInput.Preview,
+		}
+		filePaths[fileInput.ID] = path
+	}
+
+	targetAddr, err := targetUDPAddr(targetItem)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid target address: "+err.Error()))
+		return
+	}
+	prepareRequest := &types.PrepareUploadRequest{
+		Info: types.DeviceInfo{
+			Alias:       selfDevice.Alias,
+			Version:     selfDevice.Version,
+			DeviceModel: selfDevice.DeviceModel,
+			DeviceType:  selfDevice.DeviceType,
+			Fingerprint: selfDevice.Fingerprint,
+			Port:        selfDevice.Port,
+			Protocol:    targetItem.Protocol,
+			Download:    selfDevice.Download,
+		},
+		Files: filesMap,
+	}
+	prepareResponse, err := transfer.ReadyToUploadTo(targetAddr, &targetItem.VersionMessage, prepareRequest, manifest.Pin)
+	if err != nil {
+		errorMsgLower := strings.ToLower(err.Error())
+		switch {
+		case strings.Contains(errorMsgLower, "prepare-upload request rejected"):
+			c.JSON(http.StatusForbidden, tool.FastReturnError("Upload request rejected"))
+		case strings.Contains(errorMsgLower, "pin required") || strings.Contains(errorMsgLower, "invalid pin"):
+			c.JSON(http.StatusUnauthorized, tool.FastReturnError("PIN required / Invalid PIN"))
+		default:
+			c.JSON(http.StatusInternalServerError, tool.FastReturnError("Prepare upload failed: "+err.Error()))
+		}
+		return
+	}
+	if prepareResponse == nil {
+		// The receiver needs no upload (e.g. it took a text preview)
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	boardcast.PauseScan()
+	sessionId := prepareResponse.SessionId
+	sessionInfo := types.UserUploadSession{
+		Target:    targetItem,
+		SessionId: sessionId,
+		Tokens:    prepareResponse.Files,
+		Files:     filesMap,
+	}
+	UserUploadSessions.Set(sessionId, sessionInfo)
+	ctx := CreateUserUploadSessionContext(sessionId)
+	var totalBytes int64
+	for fileId := range prepareResponse.Files {
+		totalBytes += filesMap[fileId].Size
+	}
+	transfer.StartTransferStats(sessionId, totalBytes)
+
+	response := types.UserSendManifestResponse{
+		SessionId:   sessionId,
+		TargetAlias: targetItem.Alias,
+	}
+	for fileId, path := range filePaths {
+		if _, accepted := prepareResponse.Files[fileId]; !accepted {
+			response.NotAccepted = append(response.NotAccepted, path)
+		}
+	}
+	reason := "completed"
+	send := newBatchSend(sessionInfo, len(prepareResponse.Files))
+	for fileId, token := range prepareResponse.Files {
+		fileInfo := filesMap[fileId]
+		touchUserUploadSession(sessionId)
+		errMsg := ""
+		if err := sendManifestFile(ctx, targetAddr, targetItem, sessionId, fileId, token, filePaths[fileId]); err != nil {
+			errMsg = err.Error()
+		}
+		send.add(fileId, fileInfo.FileName, fileInfo.Size, errMsg)
+		if ctx.Err() != nil {
+			reason = "cancelled"
+			break
+		}
+	}
+	boardcast.ResumeScan()
+	CancelUserUploadSession(sessionId)
+
+	response.Result = send.result
+	send.finish(reason)
+	tool.DefaultLogger.Infof("[SendManifest] Sent %d/%d file(s) of %s to %s", response.Result.Success, response.Result.Total, request.ManifestPath, targetItem.Alias)
+	send.respond(c, "Manifest send completed with some failures", response)
+}
+
+// sendManifestFile streams one file of a manifest send to the receiver.
+func sendManifestFile(ctx context.Context, targetAddr *net.UDPAddr, targetItem types.UserScanCurrentItem, sessionId, fileId, token, path string) error {
+	if ctx.Err() != nil {
+		return errors.New("upload cancelled")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close file: %v", err)
+		}
+	}()
+	if err := transfer.UploadFileWithContext(ctx, targetAddr, &targetItem.VersionMessage, sessionId, fileId, token, file); err != nil {
+		if ctx.Err() != nil {
+			return errors.New("upload cancelled")
+		}
+		return err
+	}
+	return nil
+}
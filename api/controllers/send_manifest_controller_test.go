@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/api/models"
+	"github.com/moyoez/localsend-go/share"
+	"github.com/moyoez/localsend-go/types"
+)
+
+func TestUserSendManifestRejectsDuplicatePaths(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/api/self/v1/send-manifest", UserSendManifest)
+
+	previousSelf := models.GetSelfDevice()
+	models.SetSelfDevice(&types.VersionMessage{Alias: "Manifest Sender", Version: "2.0", Fingerprint: "manifest-sender"})
+	t.Cleanup(func() { models.SetSelfDevice(previousSelf) })
+	const target = "manifest-target"
+	share.SetUserScanCurrent(target, types.UserScanCurrentItem{
+		Ipaddress:      "127.0.0.1",
+		VersionMessage: types.VersionMessage{Alias: "Manifest Target", Fingerprint: target, Port: 1, Protocol: "http"},
+	})
+	t.Cleanup(func() { share.RemoveUserScanCurrent(target) })
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		files []string
+	}{
+		{name: "same relative path", files: []string{"a.txt", "a.txt"}},
+		{name: "relative and absolute path", files: []string{"a.txt", filepath.Join(dir, "a.txt")}},
+		{name: "unclean path", files: []string{"a.txt", "./sub/../a.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest, err := sonic.Marshal(types.SendManifest{TargetTo: target, Files: tt.files})
+			if err != nil {
+				t.Fatal(err)
+			}
+			manifestPath := filepath.Join(dir, "manifest.json")
+			if err := os.WriteFile(manifestPath, manifest, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			body, err := sonic.Marshal(types.UserSendManifestRequest{ManifestPath: manifestPath})
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/self/v1/send-manifest", strings.NewReader(string(body))))
+			if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "Duplicate file in manifest") {
+				t.Fatalf("status %d, body %s; want 400 for a duplicate path", recorder.Code, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestBatchSendRespond(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		errs       []string
+		wantStatus int
+	}{
+		{name: "all sent", errs: []string{"", ""}, wantStatus: http.StatusOK},
+		{name: "some failed", errs: []string{"", "Upload failed"}, wantStatus: http.StatusMultiStatus},
+		{name: "all failed", errs: []string{"Upload failed", "Upload cancelled"}, wantStatus: http.StatusInternalServerError},
+		{name: "nothing to send", errs: nil, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			send := newBatchSend(types.UserUploadSession{SessionId: "batch-send-" + tt.name}, len(tt.errs))
+			for i, errMsg := range tt.errs {
+				send.add(string(rune('a'+i)), "file.txt", 1, errMsg)
+			}
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			send.respond(c, "partial", send.result)
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", recorder.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -2,10 +2,16 @@ package controllers
 
 import (
 	"fmt"
+	"maps"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -18,22 +24,150 @@ import (
 // shareSessionSkipSHASingleFileThreshold: when single-file count exceeds this, skip SHA256 for single files (same as folders).
 const shareSessionSkipSHASingleFileThreshold = 50
 
+// commandSharesEnabled allows share entries backed by a command (FileInput.Command).
+var commandSharesEnabled atomic.Bool
+
+// SetCommandSharesEnabled sets whether share sessions may serve the stdout of a command.
+// Commands run with this process's permissions on every download, so this is off by default.
+func SetCommandSharesEnabled(v bool) {
+	commandSharesEnabled.Store(v)
+}
+
 // UserCreateShareSession creates a share session for the download API
 // POST /api/self/v1/create-share-session
+// With persistent=true the session never expires and files may be empty; use add-files/remove-files to change it.
+// A multipart/form-data body uploads the shared files as "files"; they are stored under share-uploads/<label>
+// and removed when the session closes.
 func UserCreateShareSession(c *gin.Context) {
 	var request types.CreateShareSessionRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
+	var uploads []*multipart.FileHeader
+	if c.ContentType() == gin.MIMEMultipartPOSTForm {
+		if err := c.ShouldBind(&request); err != nil {
+			c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
+			return
+		}
+		form, err := c.MultipartForm()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid multipart body: "+err.Error()))
+			return
+		}
+		uploads = form.File["files"]
+	} else if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
 		return
 	}
-	if len(request.Files) == 0 {
+	if len(request.Files) == 0 && len(uploads) == 0 && !request.Persistent {
 		c.JSON(http.StatusBadRequest, tool.FastReturnError("files is required and must not be empty"))
 		return
 	}
 
+	files, err := buildShareFileEntries(request.Files)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError(err.Error()))
+		return
+	}
+
+	sessionId := tool.GenerateShortSessionID()
+	var uploadDir string
+	if len(uploads) > 0 {
+		uploadDir, err = models.CreateShareUploadDir(request.Label, sessionId)
+		if err != nil {
+			tool.DefaultLogger.Errorf("[ShareSession] %v", err)
+			c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to store uploaded files"))
+			return
+		}
+		uploaded, err := saveShareUploads(c, uploadDir, uploads)
+		if err != nil {
+			if removeErr := os.RemoveAll(uploadDir); removeErr != nil {
+				tool.DefaultLogger.Warnf("[ShareSession] Failed to remove upload folder %s: %v", uploadDir, removeErr)
+			}
+			c.JSON(http.StatusBadRequest, tool.FastReturnError(err.Error()))
+			return
+		}
+		maps.Copy(files, uploaded)
+		tool.DefaultLogger.Infof("[ShareSession] Stored %d uploaded file(s) for session %s in %s", len(uploaded), sessionId, uploadDir)
+	}
+
+	session := &types.ShareSession{
+		SessionId:  sessionId,
+		Files:      files,
+		CreatedAt:  time.Now(),
+		Pin:        request.Pin,
+		AutoAccept: request.AutoAccept,
+		Persistent: request.Persistent,
+		UploadDir:  uploadDir,
+	}
+	models.CacheShareSession(session)
+
+	selfDeviceInfo := models.GetSelfDevice()
+	if selfDeviceInfo == nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Local device information not configured"))
+		return
+	}
+	protocol := selfDeviceInfo.Protocol
+	port := 53317
+	host := "localhost"
+	if infos := share.GetSelfNetworkInfos(); len(infos) > 0 {
+		host = infos[0].IPAddress
+	}
+	downloadUrl := fmt.Sprintf("%s://%s:%d/?session=%s", protocol, host, port, sessionId)
+
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(types.CreateShareSessionResponse{
+		SessionId:   sessionId,
+		DownloadUrl: downloadUrl,
+	}))
+}
+
+// UserCloseShareSession closes a share session
+// DELETE /api/self/v1/close-share-session?sessionId=xxx
+func UserCloseShareSession(c *gin.Context) {
+	sessionId := strings.TrimSpace(c.Query("sessionId"))
+	if sessionId == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing required parameter: sessionId"))
+		return
+	}
+	_, ok := models.GetShareSession(sessionId)
+	if !ok {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("Session not found or expired"))
+		return
+	}
+	models.RemoveShareSession(sessionId)
+	c.JSON(http.StatusOK, tool.FastReturnSuccess())
+}
+
+// UserListShareSessions lists every open share session
+// GET /api/self/v1/share-sessions
+func UserListShareSessions(c *gin.Context) {
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(models.ListShareSessions()))
+}
+
+// saveShareUploads writes multipart-uploaded files into dir and returns their share session entries.
+// Names are sanitized and made unique within dir, so several uploads with the same name are all kept.
+func saveShareUploads(c *gin.Context, dir string, uploads []*multipart.FileHeader) (map[string]types.ShareFileEntry, error) {
+	inputs := make(map[string]types.FileInput, len(uploads))
+	for _, header := range uploads {
+		target := tool.NextAvailablePath(dir, tool.SanitizeFileName(filepath.Base(header.Filename)))
+		if err := c.SaveUploadedFile(header, target); err != nil {
+			return nil, fmt.Errorf("Failed to save uploaded file %s: %v", header.Filename, err)
+		}
+		absPath, err := filepath.Abs(target)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to resolve uploaded file %s: %v", header.Filename, err)
+		}
+		fileId := tool.GenerateFileID(absPath)
+		inputs[fileId] = types.FileInput{
+			ID:      fileId,
+			FileUrl: (&url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}).String(),
+		}
+	}
+	return buildShareFileEntries(inputs)
+}
+
+// buildShareFileEntries resolves file:// inputs (files or folders) into share session entries.
+func buildShareFileEntries(inputs map[string]types.FileInput) (map[string]types.ShareFileEntry, error) {
 	// Count single files (non-dirs) to decide whether to skip SHA256 for single files when count is large
 	singleFileCount := 0
-	for _, fileInput := range request.Files {
+	for _, fileInput := range inputs {
 		if fileInput.FileUrl == "" {
 			continue
 		}
@@ -52,34 +186,37 @@ func UserCreateShareSession(c *gin.Context) {
 	skipSHAForSingleFiles := singleFileCount > shareSessionSkipSHASingleFileThreshold
 
 	files := make(map[string]types.ShareFileEntry)
-	for fileId, fileInput := range request.Files {
+	for fileId, fileInput := range inputs {
 		input := fileInput
+		if len(input.Command) > 0 {
+			entry, err := commandShareEntry(fileId, input)
+			if err != nil {
+				return nil, err
+			}
+			files[fileId] = entry
+			continue
+		}
 		if input.FileUrl == "" {
-			c.JSON(http.StatusBadRequest, tool.FastReturnError(fmt.Sprintf("fileUrl is required for %s", fileId)))
-			return
+			return nil, fmt.Errorf("fileUrl is required for %s", fileId)
 		}
 		parsedUrl, err := url.Parse(input.FileUrl)
 		if err != nil || parsedUrl.Scheme != "file" {
-			c.JSON(http.StatusBadRequest, tool.FastReturnError(fmt.Sprintf("Invalid fileUrl for %s: must be file:// path", fileId)))
-			return
+			return nil, fmt.Errorf("Invalid fileUrl for %s: must be file:// path", fileId)
 		}
 		localPath := parsedUrl.Path
 
 		info, err := os.Stat(localPath)
 		if err != nil {
 			if os.IsNotExist(err) {
-				c.JSON(http.StatusBadRequest, tool.FastReturnError(fmt.Sprintf("File or folder not found: %s", localPath)))
-				return
+				return nil, fmt.Errorf("File or folder not found: %s", localPath)
 			}
-			c.JSON(http.StatusBadRequest, tool.FastReturnError(fmt.Sprintf("Failed to access %s: %v", localPath, err)))
-			return
+			return nil, fmt.Errorf("Failed to access %s: %v", localPath, err)
 		}
 
 		if info.IsDir() {
-			fileInputMap, pathMap, err := tool.ProcessPathInput(localPath, false)
+			fileInputMap, pathMap, err := tool.ProcessPathInput(localPath, false, true)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, tool.FastReturnError(fmt.Sprintf("Invalid folder %s: %v", fileId, err)))
-				return
+				return nil, fmt.Errorf("Invalid folder %s: %v", fileId, err)
 			}
 			for id, inp := range fileInputMap {
 				entryPath := pathMap[id]
@@ -91,7 +228,7 @@ func UserCreateShareSession(c *gin.Context) {
 					FileInfo: types.FileInfo{
 						ID:       idVal,
 						FileName: inp.FileName,
-						Size:     inp.Size,
+						Size:     inp.SizeValue(),
 						FileType: inp.FileType,
 						SHA256:   inp.SHA256,
 						Preview:  inp.Preview,
@@ -103,8 +240,7 @@ func UserCreateShareSession(c *gin.Context) {
 		}
 
 		if err := tool.ProcessFileInput(&input, !skipSHAForSingleFiles); err != nil {
-			c.JSON(http.StatusBadRequest, tool.FastReturnError(fmt.Sprintf("Invalid file %s: %v", fileId, err)))
-			return
+			return nil, fmt.Errorf("Invalid file %s: %v", fileId, err)
 		}
 		fileIdVal := input.ID
 		if fileIdVal == "" {
@@ -114,7 +250,7 @@ func UserCreateShareSession(c *gin.Context) {
 			FileInfo: types.FileInfo{
 				ID:       fileIdVal,
 				FileName: input.FileName,
-				Size:     input.Size,
+				Size:     input.SizeValue(),
 				FileType: input.FileType,
 				SHA256:   input.SHA256,
 				Preview:  input.Preview,
@@ -122,49 +258,96 @@ func UserCreateShareSession(c *gin.Context) {
 			LocalPath: localPath,
 		}
 	}
+	return files, nil
+}
 
-	sessionId := tool.GenerateShortSessionID()
-	session := &types.ShareSession{
-		SessionId:  sessionId,
-		Files:      files,
-		CreatedAt:  time.Now(),
-		Pin:        request.Pin,
-		AutoAccept: request.AutoAccept,
+// commandShareEntry builds an entry that streams the stdout of input.Command. Its size is unknown,
+// so the declared size (0 when not given) is only a hint and no SHA-256 is provided.
+func commandShareEntry(fileId string, input types.FileInput) (types.ShareFileEntry, error) {
+	if !commandSharesEnabled.Load() {
+		return types.ShareFileEntry{}, fmt.Errorf("command sources are disabled for %s", fileId)
 	}
-	models.CacheShareSession(session)
+	if input.FileUrl != "" {
+		return types.ShareFileEntry{}, fmt.Errorf("fileUrl and command are mutually exclusive for %s", fileId)
+	}
+	if input.FileName == "" {
+		return types.ShareFileEntry{}, fmt.Errorf("fileName is required for command source %s", fileId)
+	}
+	if _, err := exec.LookPath(input.Command[0]); err != nil {
+		return types.ShareFileEntry{}, fmt.Errorf("Invalid command for %s: %v", fileId, err)
+	}
+	fileType := "application/octet-stream"
+	if input.FileType != "" {
+		normalized, err := tool.NormalizeMIMEType(input.FileType)
+		if err != nil {
+			return types.ShareFileEntry{}, fmt.Errorf("Invalid fileType for %s: %v", fileId, err)
+		}
+		fileType = normalized
+	}
+	idVal := input.ID
+	if idVal == "" {
+		idVal = fileId
+	}
+	return types.ShareFileEntry{
+		FileInfo: types.FileInfo{
+			ID:       idVal,
+			FileName: input.FileName,
+			Size:     input.SizeValue(),
+			FileType: fileType,
+			Preview:  input.Preview,
+		},
+		CommandSource: slices.Clone(input.Command),
+	}, nil
+}
 
-	selfDeviceInfo := models.GetSelfDevice()
-	if selfDeviceInfo == nil {
-		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Local device information not configured"))
+// UserShareSessionAddFiles adds files or folders to an open share session
+// POST /api/self/v1/share-session/:id/add-files
+func UserShareSessionAddFiles(c *gin.Context) {
+	sessionId := strings.TrimSpace(c.Param("id"))
+	var request types.ShareSessionAddFilesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
 		return
 	}
-	protocol := selfDeviceInfo.Protocol
-	port := 53317
-	host := "localhost"
-	if infos := share.GetSelfNetworkInfos(); len(infos) > 0 {
-		host = infos[0].IPAddress
+	if len(request.Files) == 0 {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("files is required and must not be empty"))
+		return
 	}
-	downloadUrl := fmt.Sprintf("%s://%s:%d/?session=%s", protocol, host, port, sessionId)
-
-	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(types.CreateShareSessionResponse{
-		SessionId:   sessionId,
-		DownloadUrl: downloadUrl,
-	}))
+	if _, ok := models.GetShareSession(sessionId); !ok {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("Session not found or expired"))
+		return
+	}
+	files, err := buildShareFileEntries(request.Files)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError(err.Error()))
+		return
+	}
+	if !models.AddShareSessionFiles(sessionId, files) {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("Session not found or expired"))
+		return
+	}
+	tool.DefaultLogger.Infof("[ShareSession] Added %d file(s) to session %s", len(files), sessionId)
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(gin.H{"added": len(files)}))
 }
 
-// UserCloseShareSession closes a share session
-// DELETE /api/self/v1/close-share-session?sessionId=xxx
-func UserCloseShareSession(c *gin.Context) {
-	sessionId := strings.TrimSpace(c.Query("sessionId"))
-	if sessionId == "" {
-		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing required parameter: sessionId"))
+// UserShareSessionRemoveFiles removes files from an open share session
+// POST /api/self/v1/share-session/:id/remove-files
+func UserShareSessionRemoveFiles(c *gin.Context) {
+	sessionId := strings.TrimSpace(c.Param("id"))
+	var request types.ShareSessionRemoveFilesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
 		return
 	}
-	_, ok := models.GetShareSession(sessionId)
+	if len(request.FileIds) == 0 {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("fileIds is required and must not be empty"))
+		return
+	}
+	removed, ok := models.RemoveShareSessionFiles(sessionId, request.FileIds)
 	if !ok {
 		c.JSON(http.StatusNotFound, tool.FastReturnError("Session not found or expired"))
 		return
 	}
-	models.RemoveShareSession(sessionId)
-	c.JSON(http.StatusOK, tool.FastReturnSuccess())
+	tool.DefaultLogger.Infof("[ShareSession] Removed %d file(s) from session %s", removed, sessionId)
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(gin.H{"removed": removed}))
 }
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/api/models"
+	"github.com/moyoez/localsend-go/types"
+)
+
+func TestMultipartShareSessionUsesLabelFolder(t *testing.T) {
+	t.Chdir(t.TempDir())
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/api/self/v1/create-share-session", UserCreateShareSession)
+	engine.DELETE("/api/self/v1/close-share-session", UserCloseShareSession)
+
+	previousSelf := models.GetSelfDevice()
+	models.SetSelfDevice(&types.VersionMessage{Alias: "Sharer", Version: "2.1", Fingerprint: "sharer", Protocol: "http"})
+	t.Cleanup(func() { models.SetSelfDevice(previousSelf) })
+
+	createSession := func(label string, files map[string]string) string {
+		t.Helper()
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		if err := writer.WriteField("label", label); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.WriteField("autoAccept", "true"); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range files {
+			part, err := writer.CreateFormFile("files", name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := part.Write([]byte(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatal(err)
+		}
+		request := httptest.NewRequest(http.MethodPost, "/api/self/v1/create-share-session", &body)
+		request.Header.Set("Content-Type", writer.FormDataContentType())
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("create-share-session: status %d, body %s", recorder.Code, recorder.Body)
+		}
+		var response struct {
+			Data types.CreateShareSessionResponse `json:"data"`
+		}
+		if err := sonic.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		t.Cleanup(func() { models.RemoveShareSession(response.Data.SessionId) })
+		return response.Data.SessionId
+	}
+	closeSession := func(sessionId string) {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/api/self/v1/close-share-session?sessionId="+sessionId, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("close-share-session: status %d, body %s", recorder.Code, recorder.Body)
+		}
+	}
+
+	first := createSession("../Trip", map[string]string{"a.txt": "first"})
+	second := createSession("../Trip", map[string]string{"b.txt": "second"})
+
+	session, ok := models.GetShareSession(first)
+	if !ok {
+		t.Fatal("first session not found")
+	}
+	if want := filepath.Join("share-uploads", ".._Trip"); session.UploadDir != want {
+		t.Fatalf("upload folder %q, want %q", session.UploadDir, want)
+	}
+	entries := models.GetShareSessionEntries(session)
+	if len(entries) != 1 {
+		t.Fatalf("session has %d files, want 1", len(entries))
+	}
+	for _, entry := range entries {
+		got, err := os.ReadFile(entry.LocalPath)
+		if err != nil || string(got) != "first" {
+			t.Fatalf("shared file %s = %q, %v; want %q", entry.LocalPath, got, err, "first")
+		}
+	}
+	secondSession, ok := models.GetShareSession(second)
+	if !ok {
+		t.Fatal("second session not found")
+	}
+	if secondSession.UploadDir == session.UploadDir {
+		t.Fatalf("sessions with the same label share the folder %s", session.UploadDir)
+	}
+
+	closeSession(first)
+	if _, err := os.Stat(session.UploadDir); !os.IsNotExist(err) {
+		t.Fatalf("upload folder %s still exists after close (err %v)", session.UploadDir, err)
+	}
+	if _, err := os.Stat(filepath.Join(secondSession.UploadDir, "b.txt")); err != nil {
+		t.Fatalf("closing the first session removed the second session's files: %v", err)
+	}
+	closeSession(second)
+	if _, err := os.Stat(secondSession.UploadDir); !os.IsNotExist(err) {
+		t.Fatalf("upload folder %s still exists after close (err %v)", secondSession.UploadDir, err)
+	}
+}
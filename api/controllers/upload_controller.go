@@ -1,7 +1,12 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/moyoez/localsend-go/api/defaults"
@@ -19,6 +24,7 @@ func NewUploadController() *UploadController {
 }
 
 func (ctrl *UploadController) HandlePrepareUpload(c *gin.Context) {
+	tool.TouchActivity()
 	pin := c.Query("pin")
 	body, err := c.GetRawData()
 	if err != nil {
@@ -33,31 +39,36 @@ func (ctrl *UploadController) HandlePrepareUpload(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body"))
 		return
 	}
+	if err := models.ValidatePrepareUploadRequest(request); err != nil {
+		tool.DefaultLogger.Warnf("[PrepareUpload] Rejected prepare-upload request: %v", err)
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
+		return
+	}
 
 	tool.DefaultLogger.Infof("[PrepareUpload] Received prepare-upload request from %s (pin: %s)", request.Info.Alias, pin)
 	tool.DefaultLogger.Infof("[PrepareUpload] Number of files: %d", len(request.Files))
 
-	response, callbackErr := defaults.DefaultOnPrepareUpload(request, pin)
+	response, callbackErr := defaults.DefaultOnPrepareUpload(request, pin, c.ClientIP())
 	if callbackErr != nil {
 		tool.DefaultLogger.Errorf("[PrepareUpload] Prepare-upload callback error: %v", callbackErr)
 		errorMsg := callbackErr.Error()
-		switch errorMsg {
-		case "PIN required", "Invalid PIN", "pin required", "invalid pin":
-			switch errorMsg {
-			case "pin required":
-				errorMsg = "PIN required"
-			case "invalid pin":
-				errorMsg = "Invalid PIN"
-			}
-			c.JSON(http.StatusUnauthorized, tool.FastReturnError(errorMsg))
+		switch {
+		case errors.Is(callbackErr, defaults.ErrPinRequired):
+			c.JSON(http.StatusUnauthorized, tool.FastReturnError("PIN required"))
 			return
-		case "rejected":
+		case errors.Is(callbackErr, defaults.ErrInvalidPin):
+			c.JSON(http.StatusUnauthorized, tool.FastReturnError("Invalid PIN"))
+			return
+		case errors.Is(callbackErr, defaults.ErrTooManyFiles):
+			c.JSON(http.StatusBadRequest, tool.FastReturnError(errorMsg))
+			return
+		case errorMsg == "rejected" || errorMsg == "device blocked" || errorMsg == "rejected during quiet hours":
 			c.JSON(http.StatusForbidden, tool.FastReturnError(errorMsg))
 			return
-		case "blocked by another session":
+		case errorMsg == "blocked by another session":
 			c.JSON(http.StatusConflict, tool.FastReturnError(errorMsg))
 			return
-		case "too many requests":
+		case errorMsg == "too many requests":
 			c.JSON(http.StatusTooManyRequests, tool.FastReturnError(errorMsg))
 			return
 		default:
@@ -105,7 +116,7 @@ func (ctrl *UploadController) HandlePrepareUpload(c *gin.Context) {
 				"totalSize":              totalSize,
 				"files":                  files,
 				"doNotMakeSessionFolder": models.DoNotMakeSessionFolder,
-				"uploadFolder":           models.DefaultUploadFolder,
+				"uploadFolder":           models.DefaultUploadFolder(),
 			}); err != nil {
 				tool.DefaultLogger.Errorf("[Notify] Failed to send upload_start notification: %v", err)
 			} else {
@@ -123,6 +134,7 @@ func (ctrl *UploadController) HandlePrepareUpload(c *gin.Context) {
 // POST /api/localsend/v1/send-request
 // V1 differs from V2: simpler device info, response has no sessionId
 func (ctrl *UploadController) HandlePrepareV1Upload(c *gin.Context) {
+	tool.TouchActivity()
 	body, err := c.GetRawData()
 	if err != nil {
 		tool.DefaultLogger.Errorf("[V1 SendRequest] Failed to read request body: %v", err)
@@ -136,23 +148,35 @@ func (ctrl *UploadController) HandlePrepareV1Upload(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body"))
 		return
 	}
+	if err := models.ValidatePrepareUploadRequest(request); err != nil {
+		tool.DefaultLogger.Warnf("[V1 SendRequest] Rejected send-request: %v", err)
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid request body: "+err.Error()))
+		return
+	}
 
 	remoteAddr := c.ClientIP()
 	tool.DefaultLogger.Infof("[V1 SendRequest] Received send-request from %s (IP: %s)", request.Info.Alias, remoteAddr)
 	tool.DefaultLogger.Infof("[V1 SendRequest] Number of files: %d", len(request.Files))
 
-	response, callbackErr := defaults.DefaultOnPrepareUpload(request, "")
+	response, callbackErr := defaults.DefaultOnPrepareUpload(request, "", remoteAddr)
 	if callbackErr != nil {
 		tool.DefaultLogger.Errorf("[V1 SendRequest] Callback error: %v", callbackErr)
 		errorMsg := callbackErr.Error()
-		switch errorMsg {
-		case "rejected":
+		switch {
+		case errors.Is(callbackErr, defaults.ErrPinRequired) || errors.Is(callbackErr, defaults.ErrInvalidPin):
+			// V1 has no PIN parameter, so a PIN-protected receiver can only refuse
+			c.JSON(http.StatusUnauthorized, tool.FastReturnError("PIN required"))
+			return
+		case errors.Is(callbackErr, defaults.ErrTooManyFiles):
+			c.JSON(http.StatusBadRequest, tool.FastReturnError(errorMsg))
+			return
+		case errorMsg == "rejected" || errorMsg == "device blocked" || errorMsg == "rejected during quiet hours":
 			c.JSON(http.StatusForbidden, tool.FastReturnError(errorMsg))
 			return
-		case "blocked by another session":
+		case errorMsg == "blocked by another session":
 			c.JSON(http.StatusConflict, tool.FastReturnError(errorMsg))
 			return
-		case "too many requests":
+		case errorMsg == "too many requests":
 			c.JSON(http.StatusTooManyRequests, tool.FastReturnError(errorMsg))
 			return
 		default:
@@ -161,8 +185,14 @@ func (ctrl *UploadController) HandlePrepareV1Upload(c *gin.Context) {
 		}
 	}
 
+	// Text-only message: no session, receiver already sent text_received and we return 204
+	if response == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
 	// Store IP -> sessionId mapping for V1 (since V1 doesn't use sessionId in subsequent requests)
-	if response != nil && response.SessionId != "" {
+	if response.SessionId != "" {
 		// Pause scanning during file transfer
 		boardcast.PauseScan()
 
@@ -196,7 +226,7 @@ func (ctrl *UploadController) HandlePrepareV1Upload(c *gin.Context) {
 				"totalSize":              totalSize,
 				"files":                  files,
 				"doNotMakeSessionFolder": models.DoNotMakeSessionFolder,
-				"uploadFolder":           models.DefaultUploadFolder,
+				"uploadFolder":           models.DefaultUploadFolder(),
 			}); err != nil {
 				tool.DefaultLogger.Errorf("[V1 Notify] Failed to send upload_start notification: %v", err)
 			} else {
@@ -232,9 +262,13 @@ func (ctrl *UploadController) HandleUploadV1Upload(c *gin.Context) {
 		c.JSON(http.StatusConflict, tool.FastReturnError("No active session"))
 		return
 	}
-	if models.IsSessionCancelled(sessionId) {
-		tool.DefaultLogger.Infof("[V1 Send] Upload session already cancelled: sessionId=%s", sessionId)
-		c.JSON(http.StatusConflict, tool.FastReturnError("Upload session cancelled"))
+	if !checkUploadSession(c, sessionId) {
+		return
+	}
+
+	if isDuplicateUpload(sessionId, fileId) {
+		tool.DefaultLogger.Infof("[V1 Send] Ignoring re-upload of completed file: fileId=%s, sessionId=%s", fileId, sessionId)
+		c.Status(http.StatusOK)
 		return
 	}
 
@@ -248,6 +282,7 @@ func (ctrl *UploadController) HandleUploadV1Upload(c *gin.Context) {
 		tool.DefaultLogger.Errorf("[V1 Send] Upload callback error: %v", uploadErr)
 
 		// Mark file as failed and check if all files are done
+		auditReceivedFile(sessionId, fileId, fileInfo, uploadErr)
 		remaining, isLast, stats := models.MarkFileUploadedAndCheckComplete(sessionId, fileId, false)
 		tool.DefaultLogger.Infof("[V1 Send] File failed: %s, remaining files: %d, isLast: %v", fileId, remaining, isLast)
 
@@ -273,7 +308,7 @@ func (ctrl *UploadController) HandleUploadV1Upload(c *gin.Context) {
 					"failedFiles":            stats.FailedFiles,
 					"failedFileIds":          stats.FailedFileIds,
 					"doNotMakeSessionFolder": models.DoNotMakeSessionFolder,
-					"uploadFolder":           models.DefaultUploadFolder,
+					"uploadFolder":           models.DefaultUploadFolder(),
 					"savePaths":              savePaths,
 					"savedFileNames":         savedFileNames,
 				}
@@ -281,11 +316,17 @@ func (ctrl *UploadController) HandleUploadV1Upload(c *gin.Context) {
 					tool.DefaultLogger.Errorf("[V1 Notify] Failed to send upload_end notification: %v", err)
 				}
 
+				recordReceiveHistory(sid, stats)
 				models.CleanupSessionStats(sid)
 				models.RemoveUploadSession(sid)
 			}(sessionId, stats, remoteAddr)
 		}
 
+		var integrityErr *types.IntegrityError
+		if errors.As(uploadErr, &integrityErr) {
+			c.JSON(http.StatusUnprocessableEntity, tool.FastReturnErrorWithData(integrityErr.Error(), integrityErr.Details()))
+			return
+		}
 		errorMsg := uploadErr.Error()
 		switch errorMsg {
 		case "Invalid token or IP address":
@@ -307,6 +348,7 @@ func (ctrl *UploadController) HandleUploadV1Upload(c *gin.Context) {
 	}
 	tool.DefaultLogger.Infof("[V1 Send] Successfully uploaded file: %s (sessionId=%s)", fileInfo.FileName, sessionId)
 
+	auditReceivedFile(sessionId, fileId, fileInfo, nil)
 	remaining, isLast, stats := models.MarkFileUploadedAndCheckComplete(sessionId, fileId, true)
 	tool.DefaultLogger.Infof("[V1 Send] File completed: %s, remaining files: %d, isLast: %v", fileInfo.FileName, remaining, isLast)
 
@@ -336,7 +378,7 @@ func (ctrl *UploadController) HandleUploadV1Upload(c *gin.Context) {
 				"failedFiles":            stats.FailedFiles,
 				"failedFileIds":          stats.FailedFileIds,
 				"doNotMakeSessionFolder": models.DoNotMakeSessionFolder,
-				"uploadFolder":           models.DefaultUploadFolder,
+				"uploadFolder":           models.DefaultUploadFolder(),
 				"savePath":               savePath,
 				"savePaths":              savePaths,
 				"savedFileNames":         savedFileNames,
@@ -344,6 +386,7 @@ func (ctrl *UploadController) HandleUploadV1Upload(c *gin.Context) {
 			if err := notify.SendUploadNotification(types.NotifyTypeUploadEnd, sid, fid, data); err != nil {
 				tool.DefaultLogger.Errorf("[V1 Notify] Failed to send upload_end notification: %v", err)
 			}
+			recordReceiveHistory(sid, stats)
 			models.CleanupSessionStats(sid)
 			models.RemoveUploadSession(sid)
 		}(sessionId, fileId, fileInfo, stats)
@@ -353,47 +396,55 @@ func (ctrl *UploadController) HandleUploadV1Upload(c *gin.Context) {
 }
 
 func (ctrl *UploadController) HandleUpload(c *gin.Context) {
+	logger := tool.LoggerFromContext(c.Request.Context())
 	sessionId := c.Query("sessionId")
 	fileId := c.Query("fileId")
 	token := c.Query("token")
 
 	if sessionId == "" || fileId == "" || token == "" {
-		tool.DefaultLogger.Errorf("Missing required parameters: sessionId=%s, fileId=%s, token=%s", sessionId, fileId, token)
+		logger.Errorf("Missing required parameters: sessionId=%s, fileId=%s, token=%s", sessionId, fileId, token)
 		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing parameters"))
 		return
 	}
-	if models.IsSessionCancelled(sessionId) {
-		tool.DefaultLogger.Infof("[Upload] Upload session already cancelled: sessionId=%s", sessionId)
-		c.JSON(http.StatusConflict, tool.FastReturnError("Upload session cancelled"))
+	if !checkUploadSession(c, sessionId) {
 		return
 	}
-
-	if !models.IsSessionValidated(sessionId) {
-		if !tool.QuerySessionIsValid(sessionId) {
-			tool.DefaultLogger.Errorf("Invalid sessionId: %s", sessionId)
-			c.JSON(http.StatusConflict, tool.FastReturnError("Blocked by another session"))
-			return
-		}
-		models.MarkSessionValidated(sessionId)
+	if isDuplicateUpload(sessionId, fileId) {
+		logger.Infof("[Upload] Ignoring re-upload of completed file: sessionId=%s, fileId=%s", sessionId, fileId)
+		c.Status(http.StatusOK)
+		return
 	}
 
 	remoteAddr := c.ClientIP()
-	tool.DefaultLogger.Infof("[Upload] Received upload request: sessionId=%s, fileId=%s, token=%s, remoteAddr=%s", sessionId, fileId, token, remoteAddr)
-	tool.DefaultLogger.Debugf("[Upload] Content-Type: %s", c.GetHeader("Content-Type"))
+	logger.Infof("[Upload] Received upload request: sessionId=%s, fileId=%s, token=%s, remoteAddr=%s", sessionId, fileId, token, remoteAddr)
+	logger.Debugf("[Upload] Content-Type: %s", c.GetHeader("Content-Type"))
+
+	if c.Query("chunkIndex") != "" {
+		ctrl.handleUploadChunk(c, sessionId, fileId, token, remoteAddr)
+		return
+	}
 
 	// Get file info before processing (needed for both success and failure cases)
 	fileInfo, hasFileInfo := models.LookupFileInfo(sessionId, fileId)
 
 	uploadErr := defaults.DefaultOnUpload(sessionId, fileId, token, c.Request.Body, remoteAddr)
+	finishReceivedFile(c, sessionId, fileId, fileInfo, hasFileInfo, uploadErr)
+}
+
+// finishReceivedFile records the outcome of a received file, sends progress/end notifications
+// and writes the HTTP response. Shared by plain and chunked uploads.
+func finishReceivedFile(c *gin.Context, sessionId, fileId string, fileInfo types.FileInfo, hasFileInfo bool, uploadErr error) {
+	logger := tool.LoggerFromContext(c.Request.Context())
 	if uploadErr != nil {
-		tool.DefaultLogger.Errorf("[Upload] Upload callback error: %v", uploadErr)
+		logger.Errorf("[Upload] Upload callback error: %v", uploadErr)
 
+		auditReceivedFile(sessionId, fileId, fileInfo, uploadErr)
 		remaining, isLast, stats := models.MarkFileUploadedAndCheckComplete(sessionId, fileId, false)
-		tool.DefaultLogger.Infof("[Upload] File failed: %s, remaining files: %d, isLast: %v", fileId, remaining, isLast)
+		logger.Infof("[Upload] File failed: %s, remaining files: %d, isLast: %v", fileId, remaining, isLast)
 
 		if !isLast && stats != nil {
 			if err := notify.SendUploadProgressNotification(sessionId, stats.TotalFiles, stats.SuccessFiles, stats.FailedFiles, ""); err != nil {
-				tool.DefaultLogger.Warnf("[Notify] Failed to send upload_progress: %v", err)
+				logger.Warnf("[Notify] Failed to send upload_progress: %v", err)
 			}
 		}
 		if isLast {
@@ -403,7 +454,7 @@ func (ctrl *UploadController) HandleUpload(c *gin.Context) {
 			go func(sid string, stats *types.SessionUploadStats) {
 				savePaths := models.GetSessionSavePaths(sid)
 				savedFileNames := tool.BuildSavedFileNames(savePaths)
-				tool.DefaultLogger.Infof("[Notify] Sending upload_end notification (all files processed): sessionId=%s, success=%d, failed=%d",
+				logger.Infof("[Notify] Sending upload_end notification (all files processed): sessionId=%s, success=%d, failed=%d",
 					sid, stats.SuccessFiles, stats.FailedFiles)
 				data := map[string]any{
 					"totalFiles":             stats.TotalFiles,
@@ -411,18 +462,24 @@ func (ctrl *UploadController) HandleUpload(c *gin.Context) {
 					"failedFiles":            stats.FailedFiles,
 					"failedFileIds":          stats.FailedFileIds,
 					"doNotMakeSessionFolder": models.DoNotMakeSessionFolder,
-					"uploadFolder":           models.DefaultUploadFolder,
+					"uploadFolder":           models.DefaultUploadFolder(),
 					"savePaths":              savePaths,
 					"savedFileNames":         savedFileNames,
 				}
 				if err := notify.SendUploadNotification(types.NotifyTypeUploadEnd, sid, "", data); err != nil {
-					tool.DefaultLogger.Errorf("[Notify] Failed to send upload_end notification: %v", err)
+					logger.Errorf("[Notify] Failed to send upload_end notification: %v", err)
 				}
+				recordReceiveHistory(sid, stats)
 				models.CleanupSessionStats(sid)
 				models.RemoveUploadSession(sid)
 			}(sessionId, stats)
 		}
 
+		var integrityErr *types.IntegrityError
+		if errors.As(uploadErr, &integrityErr) {
+			c.JSON(http.StatusUnprocessableEntity, tool.FastReturnErrorWithData(integrityErr.Error(), integrityErr.Details()))
+			return
+		}
 		errorMsg := uploadErr.Error()
 		switch errorMsg {
 		case "Invalid token or IP address":
@@ -438,18 +495,19 @@ func (ctrl *UploadController) HandleUpload(c *gin.Context) {
 	}
 
 	if !hasFileInfo {
-		tool.DefaultLogger.Errorf("[Upload] File info not found for sessionId=%s, fileId=%s", sessionId, fileId)
+		logger.Errorf("[Upload] File info not found for sessionId=%s, fileId=%s", sessionId, fileId)
 		c.JSON(http.StatusInternalServerError, tool.FastReturnError("File info not found"))
 		return
 	}
-	tool.DefaultLogger.Infof("[Upload] Successfully uploaded file: %s (sessionId=%s)", fileInfo.FileName, sessionId)
+	logger.Infof("[Upload] Successfully uploaded file: %s (sessionId=%s)", fileInfo.FileName, sessionId)
 
+	auditReceivedFile(sessionId, fileId, fileInfo, nil)
 	remaining, isLast, stats := models.MarkFileUploadedAndCheckComplete(sessionId, fileId, true)
-	tool.DefaultLogger.Infof("[Upload] File completed: %s, remaining files: %d, isLast: %v", fileInfo.FileName, remaining, isLast)
+	logger.Infof("[Upload] File completed: %s, remaining files: %d, isLast: %v", fileInfo.FileName, remaining, isLast)
 
 	if !isLast && stats != nil {
 		if err := notify.SendUploadProgressNotification(sessionId, stats.TotalFiles, stats.SuccessFiles, stats.FailedFiles, fileInfo.FileName); err != nil {
-			tool.DefaultLogger.Warnf("[Notify] Failed to send upload_progress: %v", err)
+			logger.Warnf("[Notify] Failed to send upload_progress: %v", err)
 		}
 	}
 	if isLast {
@@ -463,7 +521,7 @@ func (ctrl *UploadController) HandleUpload(c *gin.Context) {
 			if savePaths != nil {
 				savePath = savePaths[fid]
 			}
-			tool.DefaultLogger.Infof("[Notify] Sending upload_end notification (all files processed): sessionId=%s, success=%d, failed=%d",
+			logger.Infof("[Notify] Sending upload_end notification (all files processed): sessionId=%s, success=%d, failed=%d",
 				sid, stats.SuccessFiles, stats.FailedFiles)
 			data := map[string]any{
 				"fileName":               fileInfo.FileName,
@@ -473,16 +531,17 @@ func (ctrl *UploadController) HandleUpload(c *gin.Context) {
 				"failedFiles":            stats.FailedFiles,
 				"failedFileIds":          stats.FailedFileIds,
 				"doNotMakeSessionFolder": models.DoNotMakeSessionFolder,
-				"uploadFolder":           models.DefaultUploadFolder,
+				"uploadFolder":           models.DefaultUploadFolder(),
 				"savePath":               savePath,
 				"savePaths":              savePaths,
 				"savedFileNames":         savedFileNames,
 			}
 			if err := notify.SendUploadNotification(types.NotifyTypeUploadEnd, sid, fid, data); err != nil {
-				tool.DefaultLogger.Errorf("[Notify] Failed to send upload_end notification: %v", err)
+				logger.Errorf("[Notify] Failed to send upload_end notification: %v", err)
 			} else {
-				tool.DefaultLogger.Infof("[Notify] Successfully sent upload_end notification for session: %s", sid)
+				logger.Infof("[Notify] Successfully sent upload_end notification for session: %s", sid)
 			}
+			recordReceiveHistory(sid, stats)
 			models.CleanupSessionStats(sid)
 			models.RemoveUploadSession(sid)
 		}(sessionId, fileId, fileInfo, stats)
@@ -490,3 +549,173 @@ func (ctrl *UploadController) HandleUpload(c *gin.Context) {
 
 	c.Status(http.StatusOK)
 }
+
+// checkUploadSession rejects requests for cancelled or unknown sessions; it writes the response and returns false on rejection.
+func checkUploadSession(c *gin.Context, sessionId string) bool {
+	logger := tool.LoggerFromContext(c.Request.Context())
+	if models.IsSessionCancelled(sessionId) {
+		logger.Infof("[Upload] Upload session already cancelled: sessionId=%s", sessionId)
+		c.JSON(http.StatusConflict, tool.FastReturnError("Upload session cancelled"))
+		return false
+	}
+
+	if !models.IsSessionValidated(sessionId) {
+		if !tool.QuerySessionIsValid(sessionId) {
+			logger.Errorf("Invalid sessionId: %s", sessionId)
+			c.JSON(http.StatusConflict, tool.FastReturnError("Blocked by another session"))
+			return false
+		}
+		models.MarkSessionValidated(sessionId)
+	}
+	return true
+}
+
+// isDuplicateUpload reports whether fileId already completed successfully in the session and is no longer
+// pending, so a re-upload can be acknowledged without writing the file or counting it again.
+// Callers check the session with checkUploadSession first, so only the session's sender learns about completed files.
+func isDuplicateUpload(sessionId, fileId string) bool {
+	if _, pending := models.LookupFileInfo(sessionId, fileId); pending {
+		return false
+	}
+	receipt, ok := models.GetUploadReceipt(sessionId, fileId)
+	return ok && receipt.Success
+}
+
+// handleUploadChunk stores one chunk of a chunked upload. The file is not counted as received
+// until HandleUploadComplete verifies the assembled result, so a failed chunk can simply be resent.
+// POST /api/localsend/v2/upload?sessionId=xxx&fileId=xxx&token=xxx&chunkIndex=0&chunkTotal=4&chunkSize=xxx
+func (ctrl *UploadController) handleUploadChunk(c *gin.Context, sessionId, fileId, token, remoteAddr string) {
+	logger := tool.LoggerFromContext(c.Request.Context())
+	index, errIndex := strconv.Atoi(c.Query("chunkIndex"))
+	total, errTotal := strconv.Atoi(c.Query("chunkTotal"))
+	chunkSize, errSize := strconv.ParseInt(c.Query("chunkSize"), 10, 64)
+	if errIndex != nil || errTotal != nil || errSize != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid chunk parameters"))
+		return
+	}
+	// Parallel senders also give the byte range; it must match the slot the chunk index points at
+	if contentRange := c.GetHeader("Content-Range"); contentRange != "" {
+		var start, end, size int64
+		if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &size); err != nil ||
+			start != int64(index)*chunkSize || end < start || end-start+1 > chunkSize {
+			c.JSON(http.StatusBadRequest, tool.FastReturnError("Content-Range does not match chunk index"))
+			return
+		}
+	}
+
+	if err := defaults.DefaultOnUploadChunk(sessionId, fileId, token, index, total, chunkSize, c.Request.Body, remoteAddr); err != nil {
+		logger.Errorf("[Upload] Chunk %d/%d of fileId=%s failed: %v", index+1, total, fileId, err)
+		switch errorMsg := err.Error(); errorMsg {
+		case "invalid chunk parameters", "chunk layout does not match file size", "chunk size mismatch":
+			c.JSON(http.StatusBadRequest, tool.FastReturnError(errorMsg))
+		case "session cancelled", "upload cancelled":
+			c.JSON(http.StatusConflict, tool.FastReturnError(errorMsg))
+		default:
+			c.JSON(http.StatusInternalServerError, tool.FastReturnError(errorMsg))
+		}
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// HandleUploadChunks reports which chunks of a chunked upload the receiver already has, so the sender can resume.
+// GET /api/localsend/v2/upload-chunks?sessionId=xxx&fileId=xxx&token=xxx
+func (ctrl *UploadController) HandleUploadChunks(c *gin.Context) {
+	sessionId := c.Query("sessionId")
+	fileId := c.Query("fileId")
+	if sessionId == "" || fileId == "" || c.Query("token") == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing parameters"))
+		return
+	}
+	if !checkUploadSession(c, sessionId) {
+		return
+	}
+
+	status := types.UploadChunkStatus{Received: []int{}}
+	if progress, ok := models.LookupUploadChunkProgress(sessionId, fileId); ok {
+		status.Total = progress.Total
+		for index := range progress.Received {
+			status.Received = append(status.Received, index)
+		}
+		slices.Sort(status.Received)
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// HandleUploadComplete finishes a chunked upload: verifies the assembled file and records it like a plain upload.
+// POST /api/localsend/v2/upload-complete?sessionId=xxx&fileId=xxx&token=xxx&sha256=xxx
+func (ctrl *UploadController) HandleUploadComplete(c *gin.Context) {
+	sessionId := c.Query("sessionId")
+	fileId := c.Query("fileId")
+	token := c.Query("token")
+	if sessionId == "" || fileId == "" || token == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing parameters"))
+		return
+	}
+	if !checkUploadSession(c, sessionId) {
+		return
+	}
+	if isDuplicateUpload(sessionId, fileId) {
+		c.Status(http.StatusOK)
+		return
+	}
+
+	remoteAddr := c.ClientIP()
+	fileInfo, hasFileInfo := models.LookupFileInfo(sessionId, fileId)
+	uploadErr := defaults.DefaultOnUploadComplete(sessionId, fileId, token, c.Query("sha256"), remoteAddr)
+	// Missing chunks are recoverable: let the sender resend them instead of failing the file
+	if uploadErr != nil && (uploadErr.Error() == "no chunks received" || strings.HasPrefix(uploadErr.Error(), "missing chunks")) {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError(uploadErr.Error()))
+		return
+	}
+	finishReceivedFile(c, sessionId, fileId, fileInfo, hasFileInfo, uploadErr)
+}
+
+// recordReceiveHistory adds a finished receive session to the transfer history of its sender.
+func recordReceiveHistory(sessionId string, stats *types.SessionUploadStats) {
+	sender, _ := models.GetUploadSessionSender(sessionId)
+	tool.RecordTransferHistory(sender.Fingerprint, types.TransferHistoryEntry{
+		SessionId:   sessionId,
+		Direction:   types.AuditDirectionReceive,
+		PeerAlias:   sender.Alias,
+		Files:       stats.SuccessFiles,
+		FailedFiles: stats.FailedFiles,
+		FileNames:   stats.SuccessNames,
+		Bytes:       stats.SuccessBytes,
+		Result:      historyResult(stats.SuccessFiles, stats.FailedFiles),
+	})
+}
+
+// historyResult summarizes a finished transfer for the history.
+func historyResult(success, failed int) string {
+	switch {
+	case failed == 0:
+		return types.HistoryResultSuccess
+	case success == 0:
+		return types.HistoryResultFailed
+	default:
+		return types.HistoryResultPartial
+	}
+}
+
+// auditReceivedFile appends a receive-side audit record for a single file and counts it in tool.Stats when received.
+func auditReceivedFile(sessionId, fileId string, fileInfo types.FileInfo, uploadErr error) {
+	sender, _ := models.GetUploadSessionSender(sessionId)
+	event := types.AuditEvent{
+		SessionId:       sessionId,
+		Direction:       types.AuditDirectionReceive,
+		PeerAlias:       sender.Alias,
+		PeerFingerprint: sender.Fingerprint,
+		FileId:          fileId,
+		FileName:        fileInfo.FileName,
+		Size:            fileInfo.Size,
+		Result:          types.AuditResultSuccess,
+	}
+	if uploadErr != nil {
+		event.Result = types.AuditResultFailed
+		event.Error = uploadErr.Error()
+	} else {
+		tool.CountFileReceived(fileInfo.Size)
+	}
+	tool.WriteAuditEvent(event)
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/api/models"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+func newUploadTestRouter(t *testing.T) (*gin.Engine, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	folder := t.TempDir()
+	previousFolder := models.DefaultUploadFolder()
+	models.SetDefaultUploadFolder(folder)
+	t.Cleanup(func() { models.SetDefaultUploadFolder(previousFolder) })
+
+	models.SetConfirmRecvHandler(func(*types.PrepareUploadRequest, string) (bool, error) {
+		return true, nil
+	})
+	t.Cleanup(func() { models.SetConfirmRecvHandler(nil) })
+
+	ctrl := NewUploadController()
+	engine := gin.New()
+	engine.POST("/api/localsend/v2/prepare-upload", ctrl.HandlePrepareUpload)
+	engine.POST("/api/localsend/v2/upload", ctrl.HandleUpload)
+	return engine, folder
+}
+
+func serveUploadTest(engine *gin.Engine, method, target string, body []byte) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(method, target, bytes.NewReader(body)))
+	return recorder
+}
+
+func uploadTestURL(sessionId, fileId, token string) string {
+	query := url.Values{"sessionId": {sessionId}, "fileId": {fileId}, "token": {token}}
+	return "/api/localsend/v2/upload?" + query.Encode()
+}
+
+func TestHandleUploadDuplicate(t *testing.T) {
+	engine, folder := newUploadTestRouter(t)
+
+	first := []byte("first file")
+	request := types.PrepareUploadRequest{
+		Info: types.DeviceInfo{Alias: "Duplicate Sender", Version: "2.0", Fingerprint: "duplicate-sender", Port: 53317, Protocol: "http"},
+		Files: map[string]types.FileInfo{
+			"first":  {ID: "first", FileName: "first.txt", Size: int64(len(first)), FileType: "text/plain"},
+			"second": {ID: "second", FileName: "second.txt", Size: 1, FileType: "text/plain"},
+		},
+	}
+	payload, err := sonic.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := serveUploadTest(engine, http.MethodPost, "/api/localsend/v2/prepare-upload", payload)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("prepare-upload: status %d, body %s", recorder.Code, recorder.Body)
+	}
+	var response types.PrepareUploadResponse
+	if err := sonic.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("prepare-upload response: %v", err)
+	}
+	token := response.Files["first"]
+
+	if recorder := serveUploadTest(engine, http.MethodPost, uploadTestURL(response.SessionId, "first", token), first); recorder.Code != http.StatusOK {
+		t.Fatalf("upload: status %d, body %s", recorder.Code, recorder.Body)
+	}
+
+	recorder = serveUploadTest(engine, http.MethodPost, uploadTestURL(response.SessionId, "first", token), []byte("overwritten"))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("re-upload: status %d, want %d (body %s)", recorder.Code, http.StatusOK, recorder.Body)
+	}
+	got, err := os.ReadFile(filepath.Join(folder, response.SessionId, "first.txt"))
+	if err != nil {
+		t.Fatalf("read received file: %v", err)
+	}
+	if !bytes.Equal(got, first) {
+		t.Errorf("re-upload changed the received file to %q", got)
+	}
+	stats := models.GetSessionStats(response.SessionId)
+	if stats == nil || stats.SuccessFiles != 1 {
+		t.Errorf("session stats %+v, want the file counted once", stats)
+	}
+
+	// Once the session is gone the receipt remains, but the session check must reject the request first
+	models.RemoveUploadSession(response.SessionId)
+	recorder = serveUploadTest(engine, http.MethodPost, uploadTestURL(response.SessionId, "first", token), []byte("overwritten"))
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("re-upload after the session ended: status %d, want %d (body %s)", recorder.Code, http.StatusConflict, recorder.Body)
+	}
+}
+
+func TestHandlePrepareUploadPin(t *testing.T) {
+	engine, _ := newUploadTestRouter(t)
+	engine.POST("/api/localsend/v1/send-request", NewUploadController().HandlePrepareV1Upload)
+
+	previous := tool.GetProgramConfigStatus()
+	tool.SetProgramConfigStatus("1234", previous.AutoSave, previous.AutoSaveFromFavorites)
+	t.Cleanup(func() { tool.SetProgramConfigStatus(previous.Pin, previous.AutoSave, previous.AutoSaveFromFavorites) })
+
+	payload, err := sonic.Marshal(types.PrepareUploadRequest{
+		Info:  types.DeviceInfo{Alias: "Pin Sender", Version: "2.0", Fingerprint: "pin-sender", Port: 53317, Protocol: "http"},
+		Files: map[string]types.FileInfo{"a": {ID: "a", FileName: "a.txt", Size: 1, FileType: "text/plain"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "v2 without PIN", target: "/api/localsend/v2/prepare-upload", wantStatus: http.StatusUnauthorized, wantError: "PIN required"},
+		{name: "v2 with wrong PIN", target: "/api/localsend/v2/prepare-upload?pin=0000", wantStatus: http.StatusUnauthorized, wantError: "Invalid PIN"},
+		{name: "v2 with PIN", target: "/api/localsend/v2/prepare-upload?pin=1234", wantStatus: http.StatusOK},
+		{name: "v1", target: "/api/localsend/v1/send-request", wantStatus: http.StatusUnauthorized, wantError: "PIN required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serveUploadTest(engine, http.MethodPost, tt.target, payload)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d (body %s)", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if tt.wantError != "" && !strings.Contains(recorder.Body.String(), tt.wantError) {
+				t.Errorf("body %s, want error %q", recorder.Body, tt.wantError)
+			}
+			if recorder.Code == http.StatusOK {
+				var response types.PrepareUploadResponse
+				if err := sonic.Unmarshal(recorder.Body.Bytes(), &response); err == nil {
+					models.RemoveUploadSession(response.SessionId)
+					tool.DestorySession(response.SessionId)
+				}
+			}
+		})
+	}
+}
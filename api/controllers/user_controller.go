@@ -6,13 +6,29 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
+	"github.com/bytedance/sonic"
 	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/api/models"
+	"github.com/moyoez/localsend-go/notify"
 	"github.com/moyoez/localsend-go/share"
 	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
 )
 
-// UserGetImage returns an image file (Steam screenshot path validation).
+var (
+	imageServeRootsMu sync.RWMutex
+	imageServeRoots   []string // empty means default Steam userdata root
+	allowedImageTypes = map[string]string{
+		".jpg":  "image/jpeg",
+		".jpeg": "image/jpeg",
+		".png":  "image/png",
+		".webp": "image/webp",
+	}
+)
+
+// UserGetImage returns an image file located under one of the allowed image serve roots.
 // GET /api/self/v1/get-image?fileName=...
 func UserGetImage(c *gin.Context) {
 	fileName := c.Query("fileName")
@@ -28,43 +44,27 @@ func UserGetImage(c *gin.Context) {
 		}
 		fileName = parsedURL.Path
 	}
-	if !strings.HasSuffix(strings.ToLower(fileName), ".jpg") {
-		c.JSON(http.StatusForbidden, tool.FastReturnError("Only .jpg files are allowed"))
+	contentType, ok := allowedImageTypes[strings.ToLower(filepath.Ext(fileName))]
+	if !ok {
+		c.JSON(http.StatusForbidden, tool.FastReturnError("Only .jpg, .jpeg, .png and .webp files are allowed"))
 		return
 	}
-	if strings.HasPrefix(fileName, "~") {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to get home directory: "+err.Error()))
-			return
-		}
-		fileName = filepath.Join(homeDir, fileName[1:])
-	}
-	cleanPath := filepath.Clean(fileName)
-	absPath, err := filepath.Abs(cleanPath)
+	fileName, err := expandHomeDir(fileName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Invalid file path: "+err.Error()))
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to get home directory: "+err.Error()))
 		return
 	}
-	steamPathPattern := filepath.Join(".local", "share", "Steam", "userdata")
-	steamIndex := strings.Index(absPath, steamPathPattern)
-	if steamIndex == -1 {
-		c.JSON(http.StatusForbidden, tool.FastReturnError("Access to this path is forbidden: must be in Steam userdata directory"))
-		return
-	}
-	userdataPath := absPath[steamIndex+len(steamPathPattern):]
-	if !strings.HasPrefix(userdataPath, string(filepath.Separator)) {
-		c.JSON(http.StatusForbidden, tool.FastReturnError("Invalid path structure"))
+	absPath, err := filepath.Abs(filepath.Clean(fileName))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Invalid file path: "+err.Error()))
 		return
 	}
-	userdataPath = strings.TrimPrefix(userdataPath, string(filepath.Separator))
-	parts := strings.Split(userdataPath, string(filepath.Separator))
-	if len(parts) < 6 {
-		c.JSON(http.StatusForbidden, tool.FastReturnError("Invalid path structure: insufficient path depth"))
-		return
+	// Resolve symlinks so a link inside a root cannot point outside of it.
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		absPath = resolved
 	}
-	if parts[1] != "760" || parts[2] != "remote" || parts[4] != "screenshots" {
-		c.JSON(http.StatusForbidden, tool.FastReturnError("Invalid path structure: must follow userdata/*/760/remote/*/screenshots/*.jpg"))
+	if !isUnderImageServeRoot(absPath) {
+		c.JSON(http.StatusForbidden, tool.FastReturnError("Access to this path is forbidden: must be under an allowed image root"))
 		return
 	}
 	image, err := os.ReadFile(absPath)
@@ -76,11 +76,154 @@ func UserGetImage(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to read image: "+err.Error()))
 		return
 	}
-	c.Data(http.StatusOK, "image/jpeg", image)
+	c.Data(http.StatusOK, contentType, image)
+}
+
+// expandHomeDir replaces a leading "~" with the current user's home directory.
+func expandHomeDir(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, path[1:]), nil
+}
+
+// SetImageServeRoots sets the base directories UserGetImage is allowed to serve from.
+// An empty list restores the default (Steam userdata directory).
+func SetImageServeRoots(roots []string) {
+	cleaned := make([]string, 0, len(roots))
+	for _, root := range roots {
+		root = strings.TrimSpace(root)
+		if root == "" {
+			continue
+		}
+		expanded, err := expandHomeDir(root)
+		if err != nil {
+			tool.DefaultLogger.Warnf("Skipping image serve root %s: %v", root, err)
+			continue
+		}
+		abs, err := filepath.Abs(filepath.Clean(expanded))
+		if err != nil {
+			tool.DefaultLogger.Warnf("Skipping image serve root %s: %v", root, err)
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = resolved
+		}
+		cleaned = append(cleaned, abs)
+	}
+	imageServeRootsMu.Lock()
+	defer imageServeRootsMu.Unlock()
+	imageServeRoots = cleaned
+}
+
+// getImageServeRoots returns the configured image serve roots, or the Steam userdata directory by default.
+func getImageServeRoots() []string {
+	imageServeRootsMu.RLock()
+	roots := imageServeRoots
+	imageServeRootsMu.RUnlock()
+	if len(roots) > 0 {
+		return roots
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	steamUserdataRoot := filepath.Join(homeDir, ".local", "share", "Steam", "userdata")
+	if resolved, err := filepath.EvalSymlinks(steamUserdataRoot); err == nil {
+		steamUserdataRoot = resolved
+	}
+	return []string{steamUserdataRoot}
+}
+
+// isUnderImageServeRoot reports whether absPath lies strictly below one of the image serve roots.
+func isUnderImageServeRoot(absPath string) bool {
+	for _, root := range getImageServeRoots() {
+		rel, err := filepath.Rel(root, absPath)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// UserUploadReceipt returns the receiver-side completion receipt for a file.
+// Only files received by this instance are known; across devices the receiver must support this endpoint.
+// GET /api/self/v1/upload-receipt?sessionId=...&fileId=...
+func UserUploadReceipt(c *gin.Context) {
+	sessionId := c.Query("sessionId")
+	fileId := c.Query("fileId")
+	if sessionId == "" || fileId == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing parameters"))
+		return
+	}
+	receipt, ok := models.GetUploadReceipt(sessionId, fileId)
+	if !ok {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("Receipt not found (file not completed yet or unknown)"))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(receipt))
 }
 
 // UserGetNetworkInterfaces returns the list of network interfaces.
-// GET /api/self/v1/get-network-interfaces
+// GET /api/self/v1/get-network-interfaces?all=true
+// By default only interfaces usable for discovery are listed; all=true also includes down,
+// loopback and non-multicast interfaces.
 func UserGetNetworkInterfaces(c *gin.Context) {
+	if c.Query("all") == "true" {
+		c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(share.GetAllNetworkInfos()))
+		return
+	}
 	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(share.GetSelfNetworkInfos()))
 }
+
+// UserTestNotify sends a test notification through the Unix socket and reports the listener's response.
+// POST /api/self/v1/test-notify
+func UserTestNotify(c *gin.Context) {
+	if !notify.UseNotify {
+		c.JSON(http.StatusConflict, tool.FastReturnError("Notify is disabled (-skipNotify)"))
+		return
+	}
+	response, err := notify.SendTestNotification()
+	data := map[string]any{
+		"socketPath": notify.DefaultUnixSocketPath,
+		"response":   response,
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, tool.FastReturnErrorWithData("Test notification failed: "+err.Error(), data))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(data))
+}
+
+// UserStats returns the runtime counters since startup (files and bytes transferred, downloads, discovery).
+// GET /api/self/v1/stats
+func UserStats(c *gin.Context) {
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(tool.Stats()))
+}
+
+// UserNotifyPayload returns the full notification behind the payloadRef of a summarized oversized notification.
+// Payloads are kept for a few minutes after the summary is sent.
+// GET /api/self/v1/notify-payload?id=
+func UserNotifyPayload(c *gin.Context) {
+	ref := strings.TrimSpace(c.Query("id"))
+	if ref == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing required parameter: id"))
+		return
+	}
+	payload, ok := notify.GetNotifyPayload(ref)
+	if !ok {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("Notification payload not found or expired"))
+		return
+	}
+	var notification types.Notification
+	if err := sonic.Unmarshal(payload, &notification); err != nil {
+		c.JSON(http.StatusInternalServerError, tool.FastReturnError("Failed to parse notification payload: "+err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(notification))
+}
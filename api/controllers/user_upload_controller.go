@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	ttlworker "github.com/FloatTech/ttl"
@@ -31,13 +33,58 @@ import (
 // prepareUploadSkipSHASingleFileThreshold: when single-file count exceeds this, skip SHA256 for single files (same as share session / folders).
 const prepareUploadSkipSHASingleFileThreshold = 50
 
+// prepareUploadProbeTimeout bounds the TCP connect preflight to the target before prepare-upload,
+// so an offline target fails fast instead of waiting for the HTTP client timeout.
+const prepareUploadProbeTimeout = 2 * time.Second
+
 var (
+	// UserUploadSessionTTL is the idle lifetime of sender-side sessions; refreshed on each upload.
 	UserUploadSessionTTL      = 60 * time.Minute
-	UserUploadSessions        = ttlworker.NewCache[string, types.UserUploadSession](UserUploadSessionTTL)
+	UserUploadSessions        = ttlworker.NewCacheOn(UserUploadSessionTTL, userUploadSessionHooks(userUploadSessionsMoved))
 	userUploadSessionContexts = ttlworker.NewCache[string, *types.UserUploadSessionContext](UserUploadSessionTTL)
 	userUploadSessionMu       sync.RWMutex
+	// userUploadSessionsMoved is set when SetUserSessionTTL moves the sessions out of UserUploadSessions
+	userUploadSessionsMoved = new(atomic.Bool)
 )
 
+// userUploadSessionHooks returns the UserUploadSessions hooks: a session that ends or expires removes its temp
+// ZIPs and transfer stats, unless moved is set because the session lives on in a new cache.
+func userUploadSessionHooks(moved *atomic.Bool) [4]func(string, types.UserUploadSession) {
+	return [4]func(string, types.UserUploadSession){nil, nil, func(sessionId string, session types.UserUploadSession) {
+		if moved.Load() {
+			return
+		}
+		transfer.EndTransferStats(sessionId)
+		for _, zipPath := range session.ZipPaths {
+			if err := os.Remove(zipPath); err != nil && !os.IsNotExist(err) {
+				tool.DefaultLogger.Warnf("Failed to remove temp zip %s: %v", zipPath, err)
+			}
+		}
+	}, nil}
+}
+
+// SetUserSessionTTL sets the idle lifetime of sender-side upload sessions.
+// Meant to be called at startup; live sessions are moved to the new caches and the old ones destroyed.
+func SetUserSessionTTL(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	userUploadSessionMu.Lock()
+	defer userUploadSessionMu.Unlock()
+	UserUploadSessionTTL = d
+	userUploadSessionsMoved.Store(true)
+	userUploadSessionsMoved = new(atomic.Bool)
+	UserUploadSessions = tool.ReplaceCache(UserUploadSessions, d, userUploadSessionHooks(userUploadSessionsMoved))
+	userUploadSessionContexts = tool.ReplaceCache(userUploadSessionContexts, d, [4]func(string, *types.UserUploadSessionContext){})
+}
+
+// touchUserUploadSession refreshes the TTL of a sender-side session during a long transfer.
+func touchUserUploadSession(sessionId string) {
+	tool.TouchActivity()
+	UserUploadSessions.Get(sessionId)
+	GetUserUploadSessionContext(sessionId)
+}
+
 // CreateUserUploadSessionContext creates a new context for the user upload session
 func CreateUserUploadSessionContext(sessionId string) context.Context {
 	userUploadSessionMu.Lock()
@@ -61,6 +108,31 @@ func GetUserUploadSessionContext(sessionId string) context.Context {
 	return sessCtx.Ctx
 }
 
+// sendStats returns the current speed and ETA of a sending session; zero values if it is not tracked.
+func sendStats(sessionId string) types.TransferStats {
+	stats, ok := transfer.GetTransferStats(sessionId)
+	if !ok {
+		return types.TransferStats{SessionId: sessionId, EtaSeconds: -1}
+	}
+	return stats
+}
+
+// UserSendProgress returns the bytes sent, current speed and estimated time remaining of a sending session.
+// GET /api/self/v1/send-progress?sessionId=xxx
+func UserSendProgress(c *gin.Context) {
+	sessionId := c.Query("sessionId")
+	if sessionId == "" {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing required parameter: sessionId"))
+		return
+	}
+	stats, ok := transfer.GetTransferStats(sessionId)
+	if !ok {
+		c.JSON(http.StatusNotFound, tool.FastReturnError("Session not found or expired"))
+		return
+	}
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(stats))
+}
+
 // CancelUserUploadSession cancels the user upload session and removes it
 func CancelUserUploadSession(sessionId string) {
 	userUploadSessionMu.Lock()
@@ -69,6 +141,7 @@ func CancelUserUploadSession(sessionId string) {
 		sessCtx.Cancel()
 		userUploadSessionContexts.Delete(sessionId)
 	}
+	// Deleting the session removes its transfer stats and temp archives of zipBeforeSend, see userUploadSessionHooks
 	UserUploadSessions.Delete(sessionId)
 }
 
@@ -99,9 +172,21 @@ func resolveFastSenderIP(fullIP, ipSuffix string) (string, error) {
 	return "", errors.New("either useFastSenderIp or useFastSenderIPSuffex must be provided when useFastSender is true")
 }
 
-// UserPrepareUpload handles prepare upload request
+// targetUDPAddr builds the address of a discovered device. Supports IPv4, IPv6 and hostnames.
+func targetUDPAddr(item types.UserScanCurrentItem) (*net.UDPAddr, error) {
+	ip, err := tool.ParseTargetIP(item.Ipaddress)
+	if err != nil {
+		return nil, err
+	}
+	return &net.UDPAddr{IP: ip, Port: item.Port}, nil
+}
+
+// UserPrepareUpload handles prepare upload request.
+// With skipSHA no SHA-256 is sent for any file, so the receiver skips integrity verification.
+// A target that does not accept TCP connections on its port is answered with 503 right away.
 // POST /api/self/v1/prepare-upload
 func UserPrepareUpload(c *gin.Context) {
+	tool.TouchActivity()
 	var request types.UserPrepareUploadRequest
 	pin := c.Query("pin")
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -125,20 +210,7 @@ func UserPrepareUpload(c *gin.Context) {
 			c.JSON(http.StatusNotFound, tool.FastReturnError("Failed to fetch device info: "+err.Error()))
 			return
 		}
-		targetItem = types.UserScanCurrentItem{
-			Ipaddress: targetIP,
-			VersionMessage: types.VersionMessage{
-				Alias:       deviceInfo.Alias,
-				Version:     deviceInfo.Version,
-				DeviceModel: deviceInfo.DeviceModel,
-				DeviceType:  deviceInfo.DeviceType,
-				Fingerprint: deviceInfo.Fingerprint,
-				Port:        defaultPort,
-				Protocol:    protocol,
-				Download:    deviceInfo.Download,
-				Announce:    true,
-			},
-		}
+		targetItem = deviceItemFromInfo(targetIP, defaultPort, protocol, deviceInfo)
 		tool.DefaultLogger.Infof("[FastSender] Successfully fetched device info: %s (fingerprint: %s) at %s",
 			deviceInfo.Alias, deviceInfo.Fingerprint, targetIP)
 		share.SetUserScanCurrent(deviceInfo.Fingerprint, targetItem)
@@ -154,6 +226,13 @@ func UserPrepareUpload(c *gin.Context) {
 		request.Files = make(map[string]types.FileInput)
 	}
 	additionalFiles := make(map[string]types.FileInput)
+	// zipPaths holds temp archives of zipBeforeSend; they are handed to the session or removed on failure
+	var zipPaths map[string]string
+	defer func() {
+		for _, zipPath := range zipPaths {
+			_ = os.Remove(zipPath)
+		}
+	}()
 	maps.Copy(additionalFiles, request.Files)
 
 	if request.UseFolderUpload {
@@ -167,9 +246,21 @@ func UserPrepareUpload(c *gin.Context) {
 			return
 		}
 		request.Files = make(map[string]types.FileInput, len(additionalFiles))
+		if request.ZipBeforeSend {
+			// Many small files: one archive avoids a request per file
+			zipInput, zipPath, err := tool.ZipFoldersForUpload(folderPaths, !request.SkipSHA, request.ZipLevel)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, tool.FastReturnError("Failed to zip folders: "+err.Error()))
+				return
+			}
+			request.Files[zipInput.ID] = *zipInput
+			zipPaths = map[string]string{zipInput.ID: zipPath}
+			tool.DefaultLogger.Infof("[PrepareUpload] Packed %d folder(s) into %s (%d bytes)", len(folderPaths), zipInput.FileName, zipInput.SizeValue())
+			folderPaths = nil
+		}
 		for _, folderPath := range folderPaths {
 			tool.DefaultLogger.Infof("[PrepareUpload] Processing folder upload: %s", folderPath)
-			fileInputMap, _, err := tool.ProcessFolderForUpload(folderPath, false)
+			fileInputMap, _, err := tool.ProcessFolderForUpload(folderPath, false, true)
 			if err != nil {
 				c.JSON(http.StatusBadRequest, tool.FastReturnError(fmt.Sprintf("Failed to process folder %s: %v", folderPath, err)))
 				return
@@ -190,7 +281,8 @@ func UserPrepareUpload(c *gin.Context) {
 	} else {
 		singleFileCount = len(request.Files)
 	}
-	skipSHAForSingleFiles := singleFileCount > prepareUploadSkipSHASingleFileThreshold
+	// skipSHA opts out of hashing entirely; the receiver then cannot verify file integrity
+	skipSHAForSingleFiles := request.SkipSHA || singleFileCount > prepareUploadSkipSHASingleFileThreshold
 
 	tool.DefaultLogger.Infof("Processing %d total files for prepare-upload", len(request.Files))
 	for fileID, fileInput := range request.Files {
@@ -217,7 +309,7 @@ func UserPrepareUpload(c *gin.Context) {
 		filesMap[fileID] = types.FileInfo{
 			ID:       fileInput.ID,
 			FileName: fileInput.FileName,
-			Size:     fileInput.Size,
+			Size:     fileInput.SizeValue(),
 			FileType: fileInput.FileType,
 			SHA256:   fileInput.SHA256,
 			Preview:  preview,
@@ -244,12 +336,25 @@ func UserPrepareUpload(c *gin.Context) {
 		Files: filesMap,
 	}
 
-	targetAddr := &net.UDPAddr{
-		IP:   net.ParseIP(targetItem.Ipaddress).To4(),
-		Port: targetItem.Port,
+	targetAddr, err := targetUDPAddr(targetItem)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid target address: "+err.Error()))
+		return
 	}
+	if !tool.QuickTCPProbe(targetAddr.IP.String(), targetAddr.Port, prepareUploadProbeTimeout) {
+		tool.DefaultLogger.Warnf("[PrepareUpload] Target %s (%s) not reachable on port %d", targetItem.Alias, targetAddr.IP, targetAddr.Port)
+		c.JSON(http.StatusServiceUnavailable, tool.FastReturnError("Target unreachable"))
+		return
+	}
+
+	warning := storageWarning(targetAddr, targetItem, filesMap)
 
+	cachedProtocol := targetItem.Protocol
 	prepareResponse, err := transfer.ReadyToUploadTo(targetAddr, &targetItem.VersionMessage, prepareRequest, pin)
+	if targetItem.Protocol != cachedProtocol {
+		tool.DefaultLogger.Infof("[PrepareUpload] %s switched protocol %s -> %s, updating cached device", targetItem.Alias, cachedProtocol, targetItem.Protocol)
+		share.SetUserScanCurrent(targetItem.Fingerprint, targetItem)
+	}
 	if err != nil {
 		errorMsg := err.Error()
 		if strings.Contains(errorMsg, "prepare-upload request rejected") {
@@ -277,22 +382,58 @@ func UserPrepareUpload(c *gin.Context) {
 		Target:    targetItem,
 		SessionId: prepareResponse.SessionId,
 		Tokens:    prepareResponse.Files,
+		ZipPaths:  zipPaths,
+		Files:     filesMap,
 	}
 	UserUploadSessions.Set(prepareResponse.SessionId, sessionInfo)
 	CreateUserUploadSessionContext(prepareResponse.SessionId)
+	// Only files the receiver accepted count towards the ETA
+	var totalBytes int64
+	for fileID := range prepareResponse.Files {
+		totalBytes += filesMap[fileID].Size
+	}
+	transfer.StartTransferStats(prepareResponse.SessionId, totalBytes)
+	zipPaths = nil
 
-	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(types.PrepareUploadResponse{
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(types.UserPrepareUploadResponse{
 		SessionId: prepareResponse.SessionId,
 		Files:     prepareResponse.Files,
+		Warning:   warning,
 	}))
 }
 
+// storageWarning returns a warning when the target advertises storageInfo and reports less free space
+// than the files need. The check is advisory: any failure to ask is only logged.
+func storageWarning(targetAddr *net.UDPAddr, targetItem types.UserScanCurrentItem, files map[string]types.FileInfo) string {
+	if !targetItem.Capabilities[types.CapabilityStorageInfo] {
+		return ""
+	}
+	info, err := transfer.FetchStorageInfo(targetAddr, &targetItem.VersionMessage)
+	if err != nil {
+		tool.DefaultLogger.Debugf("[PrepareUpload] Could not get storage info of %s: %v", targetItem.Alias, err)
+		return ""
+	}
+	var needed int64
+	for _, file := range files {
+		needed += file.Size
+	}
+	if needed <= 0 || uint64(needed) <= info.Available {
+		return ""
+	}
+	tool.DefaultLogger.Warnf("[PrepareUpload] %s has %d bytes free, session needs %d", targetItem.Alias, info.Available, needed)
+	return fmt.Sprintf("receiver has %d bytes free, the transfer needs %d", info.Available, needed)
+}
+
 // UserUpload handles actual file upload request
 // POST /api/self/v1/upload
 func UserUpload(c *gin.Context) {
-	var sessionId, fileId, token string
+	tool.TouchActivity()
+	var sessionId, fileId, token, fileName string
 	var fileReader io.Reader
 	var fileData []byte
+	// chunkPath/chunkSize are set when a file:// source should be sent in chunks instead of read into memory
+	var chunkPath string
+	var chunkSize int64
 	contentType := c.GetHeader("Content-Type")
 
 	if strings.Contains(contentType, "application/json") {
@@ -317,12 +458,17 @@ func UserUpload(c *gin.Context) {
 			}
 			if parsedUrl.Scheme == "file" {
 				filePath := parsedUrl.Path
-				data, err := os.ReadFile(filePath)
-				if err != nil {
-					c.JSON(http.StatusBadRequest, tool.FastReturnErrorWithData(fmt.Sprintf("Failed to read file from %s: %v", filePath, err), map[string]any{"filePath": filePath}))
-					return
+				fileName = filepath.Base(filePath)
+				if request.ChunkSize > 0 {
+					chunkPath, chunkSize = filePath, request.ChunkSize
+				} else {
+					data, err := os.ReadFile(filePath)
+					if err != nil {
+						c.JSON(http.StatusBadRequest, tool.FastReturnErrorWithData(fmt.Sprintf("Failed to read file from %s: %v", filePath, err), map[string]any{"filePath": filePath}))
+						return
+					}
+					fileData = data
 				}
-				fileData = data
 			} else {
 				c.JSON(http.StatusBadRequest, tool.FastReturnError("Only file:// protocol is supported for fileUrl"))
 				return
@@ -349,13 +495,14 @@ func UserUpload(c *gin.Context) {
 				tool.DefaultLogger.Errorf("Failed to close request body: %v", err)
 			}
 		}()
+		// An empty body is most likely a client mistake; empty files are sent via file:// instead
+		if len(data) == 0 {
+			c.JSON(http.StatusBadRequest, tool.FastReturnError("File data is empty"))
+			return
+		}
 		fileData = data
 	}
 
-	if len(fileData) == 0 {
-		c.JSON(http.StatusBadRequest, tool.FastReturnError("File data is empty"))
-		return
-	}
 	if IsUserUploadSessionCancelled(sessionId) {
 		c.JSON(http.StatusConflict, tool.FastReturnError("Upload session cancelled"))
 		return
@@ -374,12 +521,26 @@ func UserUpload(c *gin.Context) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	fileReader = bytes.NewReader(fileData)
-	targetAddr := &net.UDPAddr{
-		IP:   net.ParseIP(sessionInfo.Target.Ipaddress).To4(),
-		Port: sessionInfo.Target.Port,
+	targetAddr, err := targetUDPAddr(sessionInfo.Target)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid target address: "+err.Error()))
+		return
+	}
+	var size int64
+	if chunkPath != "" {
+		size, err = uploadFileInChunks(ctx, targetAddr, sessionInfo, fileId, token, chunkPath, chunkSize)
+	} else {
+		fileReader = bytes.NewReader(fileData)
+		size = int64(len(fileData))
+		err = transfer.UploadFileWithContext(ctx, targetAddr, &sessionInfo.Target.VersionMessage, sessionId, fileId, token, fileReader)
 	}
-	err := transfer.UploadFileWithContext(ctx, targetAddr, &sessionInfo.Target.VersionMessage, sessionId, fileId, token, fileReader)
+	// A raw body carries no file name, so the name (and a missing size) come from prepare-upload
+	if info, ok := sessionInfo.Files[fileId]; ok {
+		fileName = cmp.Or(fileName, info.FileName)
+		size = cmp.Or(size, info.Size)
+	}
+	auditSentFile(sessionInfo, fileId, fileName, size, err)
+	recordSentFileHistory(sessionInfo, fileName, size, err)
 	if err != nil {
 		if ctx.Err() != nil {
 			c.JSON(http.StatusConflict, tool.FastReturnError("Upload cancelled"))
@@ -391,9 +552,33 @@ func UserUpload(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "File uploaded successfully"})
 }
 
+// uploadFileInChunks sends the file at path in chunks of chunkSize bytes and returns its size.
+func uploadFileInChunks(ctx context.Context, targetAddr *net.UDPAddr, sessionInfo types.UserUploadSession, fileId, token, path string, chunkSize int64) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file %s: %v", path, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close file: %v", err)
+		}
+	}()
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat file %s: %v", path, err)
+	}
+	if stat.Size() == 0 {
+		// Nothing to chunk: send the empty file as a plain upload
+		return 0, transfer.UploadFileWithContext(ctx, targetAddr, &sessionInfo.Target.VersionMessage, sessionInfo.SessionId, fileId, token, http.NoBody)
+	}
+	err = transfer.UploadFileInChunks(ctx, targetAddr, &sessionInfo.Target.VersionMessage, sessionInfo.SessionId, fileId, token, file, stat.Size(), chunkSize)
+	return stat.Size(), err
+}
+
 // UserUploadBatch handles batch file upload request
 // POST /api/self/v1/upload-batch
 func UserUploadBatch(c *gin.Context) {
+	tool.TouchActivity()
 	var request types.UserUploadBatchRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid JSON request: "+err.Error()))
@@ -423,8 +608,22 @@ func UserUploadBatch(c *gin.Context) {
 		}
 		// use map to avoid duplicate fileIds
 		fileMap := make(map[string]types.UserUploadFileItem)
+		if request.ZipBeforeSend {
+			if len(sessionInfo.ZipPaths) == 0 {
+				c.JSON(http.StatusBadRequest, tool.FastReturnError("Session was not prepared with zipBeforeSend"))
+				return
+			}
+			for fileId, zipPath := range sessionInfo.ZipPaths {
+				fileMap[fileId] = types.UserUploadFileItem{
+					FileId:  fileId,
+					Token:   sessionInfo.Tokens[fileId],
+					FileUrl: "file://" + zipPath,
+				}
+			}
+			folderPaths = nil
+		}
 		for _, folderPath := range folderPaths {
-			_, fileIdToPathMap, err := tool.ProcessFolderForUpload(folderPath, false)
+			_, fileIdToPathMap, err := tool.ProcessFolderForUpload(folderPath, false, false)
 			if err != nil {
 				c.JSON(http.StatusBadRequest, tool.FastReturnError(fmt.Sprintf("Failed to process folder %s: %v", folderPath, err)))
 				return
@@ -468,127 +667,63 @@ func UserUploadBatch(c *gin.Context) {
 		ctx = context.Background()
 	}
 
-	result := types.UserUploadBatchResult{
-		Total:   len(request.Files),
-		Success: 0,
-		Failed:  0,
-		Results: make([]types.UserUploadItemResult, 0, len(request.Files)),
-	}
-	targetAddr := &net.UDPAddr{
-		IP:   net.ParseIP(sessionInfo.Target.Ipaddress).To4(),
-		Port: sessionInfo.Target.Port,
+	targetAddr, err := targetUDPAddr(sessionInfo.Target)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, tool.FastReturnError("Invalid target address: "+err.Error()))
+		return
 	}
 	reason := "completed"
+	send := newBatchSend(sessionInfo, len(request.Files))
 
 	for _, fileItem := range request.Files {
-		fileName := ""
+		touchUserUploadSession(request.SessionId)
 		select {
 		case <-ctx.Done():
 			reason = "cancelled"
-			itemResult := types.UserUploadItemResult{FileId: fileItem.FileId, Success: false, Error: "Upload cancelled"}
-			result.Results = append(result.Results, itemResult)
-			result.Failed++
-			if err := notify.SendSendProgressNotification(request.SessionId, fileItem.FileId, false, itemResult.Error, result.Success+result.Failed, result.Total, fileName); err != nil {
-				tool.DefaultLogger.Warnf("[Notify] Failed to send send_progress: %v", err)
-			}
+			send.add(fileItem.FileId, "", 0, "Upload cancelled")
 			goto batchComplete
 		default:
 		}
-		itemResult := types.UserUploadItemResult{FileId: fileItem.FileId, Success: false}
 		if fileItem.FileId == "" || fileItem.Token == "" || fileItem.FileUrl == "" {
-			itemResult.Error = "Missing required parameters: fileId, token, or fileUrl"
-			result.Results = append(result.Results, itemResult)
-			result.Failed++
-			if err := notify.SendSendProgressNotification(request.SessionId, fileItem.FileId, false, itemResult.Error, result.Success+result.Failed, result.Total, fileName); err != nil {
-				tool.DefaultLogger.Warnf("[Notify] Failed to send send_progress: %v", err)
-			}
+			send.add(fileItem.FileId, "", 0, "Missing required parameters: fileId, token, or fileUrl")
 			continue
 		}
 		expectedToken, ok := sessionInfo.Tokens[fileItem.FileId]
 		if !ok || expectedToken != fileItem.Token {
-			itemResult.Error = "Invalid file ID or token"
-			result.Results = append(result.Results, itemResult)
-			result.Failed++
-			if err := notify.SendSendProgressNotification(request.SessionId, fileItem.FileId, false, itemResult.Error, result.Success+result.Failed, result.Total, fileName); err != nil {
-				tool.DefaultLogger.Warnf("[Notify] Failed to send send_progress: %v", err)
-			}
+			send.add(fileItem.FileId, "", 0, "Invalid file ID or token")
 			continue
 		}
 		parsedUrl, err := url.Parse(fileItem.FileUrl)
 		if err != nil {
-			itemResult.Error = fmt.Sprintf("Invalid fileUrl: %v", err)
-			result.Results = append(result.Results, itemResult)
-			result.Failed++
-			if err := notify.SendSendProgressNotification(request.SessionId, fileItem.FileId, false, itemResult.Error, result.Success+result.Failed, result.Total, fileName); err != nil {
-				tool.DefaultLogger.Warnf("[Notify] Failed to send send_progress: %v", err)
-			}
+			send.add(fileItem.FileId, "", 0, fmt.Sprintf("Invalid fileUrl: %v", err))
 			continue
 		}
 		if parsedUrl.Scheme != "file" {
-			itemResult.Error = "Only file:// protocol is supported"
-			result.Results = append(result.Results, itemResult)
-			result.Failed++
-			if err := notify.SendSendProgressNotification(request.SessionId, fileItem.FileId, false, itemResult.Error, result.Success+result.Failed, result.Total, fileName); err != nil {
-				tool.DefaultLogger.Warnf("[Notify] Failed to send send_progress: %v", err)
-			}
+			send.add(fileItem.FileId, "", 0, "Only file:// protocol is supported")
 			continue
 		}
 		filePath := parsedUrl.Path
-		fileName = filepath.Base(filePath)
+		fileName := filepath.Base(filePath)
 		fileData, err := os.ReadFile(filePath)
 		if err != nil {
-			itemResult.Error = fmt.Sprintf("Failed to read file: %v", err)
-			result.Results = append(result.Results, itemResult)
-			result.Failed++
-			if err := notify.SendSendProgressNotification(request.SessionId, fileItem.FileId, false, itemResult.Error, result.Success+result.Failed, result.Total, fileName); err != nil {
-				tool.DefaultLogger.Warnf("[Notify] Failed to send send_progress: %v", err)
-			}
-			continue
-		}
-		if len(fileData) == 0 {
-			itemResult.Error = "File data is empty"
-			result.Results = append(result.Results, itemResult)
-			result.Failed++
-			if err := notify.SendSendProgressNotification(request.SessionId, fileItem.FileId, false, itemResult.Error, result.Success+result.Failed, result.Total, fileName); err != nil {
-				tool.DefaultLogger.Warnf("[Notify] Failed to send send_progress: %v", err)
-			}
+			send.add(fileItem.FileId, fileName, 0, fmt.Sprintf("Failed to read file: %v", err))
 			continue
 		}
+		size := int64(len(fileData))
 		err = transfer.UploadFileWithContext(ctx, targetAddr, &sessionInfo.Target.VersionMessage, request.SessionId, fileItem.FileId, fileItem.Token, bytes.NewReader(fileData))
-		if err != nil {
-			if ctx.Err() != nil {
-				reason = "cancelled"
-				itemResult.Error = "Upload cancelled"
-				result.Results = append(result.Results, itemResult)
-				result.Failed++
-				if err := notify.SendSendProgressNotification(request.SessionId, fileItem.FileId, false, itemResult.Error, result.Success+result.Failed, result.Total, fileName); err != nil {
-					tool.DefaultLogger.Warnf("[Notify] Failed to send send_progress: %v", err)
-				}
-				goto batchComplete
-			}
-			if strings.Contains(err.Error(), "blocked by another session") {
-				reason = "rejected"
-				itemResult.Error = err.Error()
-				result.Results = append(result.Results, itemResult)
-				result.Failed++
-				if err := notify.SendSendProgressNotification(request.SessionId, fileItem.FileId, false, itemResult.Error, result.Success+result.Failed, result.Total, fileName); err != nil {
-					tool.DefaultLogger.Warnf("[Notify] Failed to send send_progress: %v", err)
-				}
-				goto batchComplete
-			}
-			itemResult.Error = fmt.Sprintf("Upload failed: %v", err)
-			result.Results = append(result.Results, itemResult)
-			result.Failed++
-			if err := notify.SendSendProgressNotification(request.SessionId, fileItem.FileId, false, itemResult.Error, result.Success+result.Failed, result.Total, fileName); err != nil {
-				tool.DefaultLogger.Warnf("[Notify] Failed to send send_progress: %v", err)
-			}
-		} else {
-			itemResult.Success = true
-			result.Results = append(result.Results, itemResult)
-			result.Success++
-			if err := notify.SendSendProgressNotification(request.SessionId, fileItem.FileId, true, "", result.Success+result.Failed, result.Total, fileName); err != nil {
-				tool.DefaultLogger.Warnf("[Notify] Failed to send send_progress: %v", err)
-			}
+		switch {
+		case err == nil:
+			send.add(fileItem.FileId, fileName, size, "")
+		case ctx.Err() != nil:
+			reason = "cancelled"
+			send.add(fileItem.FileId, fileName, size, "Upload cancelled")
+			goto batchComplete
+		case strings.Contains(err.Error(), "blocked by another session"):
+			reason = "rejected"
+			send.add(fileItem.FileId, fileName, size, err.Error())
+			goto batchComplete
+		default:
+			send.add(fileItem.FileId, fileName, size, fmt.Sprintf("Upload failed: %v", err))
 		}
 	}
 batchComplete:
@@ -598,13 +733,15 @@ batchComplete:
 	// If the session was already cleaned up externally (e.g., by UserCancelUpload),
 	// UserUploadSessions.Get will return empty and we skip the redundant cancel.
 	if reason == "cancelled" || reason == "rejected" {
+		cancelReason := types.CancelReasonUserCancelled
+		if reason == "rejected" {
+			cancelReason = types.CancelReasonError
+		}
 		batchSessionInfo := UserUploadSessions.Get(request.SessionId)
 		if batchSessionInfo.SessionId != "" {
-			cancelAddr := &net.UDPAddr{
-				IP:   net.ParseIP(batchSessionInfo.Target.Ipaddress).To4(),
-				Port: batchSessionInfo.Target.Port,
-			}
-			if err := transfer.CancelSession(cancelAddr, &batchSessionInfo.Target.VersionMessage, request.SessionId); err != nil {
+			if cancelAddr, err := targetUDPAddr(batchSessionInfo.Target); err != nil {
+				tool.DefaultLogger.Warnf("[UserUploadBatch] Failed to cancel receiver session: %v", err)
+			} else if _, err := transfer.CancelSession(cancelAddr, &batchSessionInfo.Target.VersionMessage, request.SessionId, cancelReason); err != nil {
 				tool.DefaultLogger.Warnf("[UserUploadBatch] Failed to cancel receiver session: %v", err)
 			}
 		}
@@ -613,25 +750,14 @@ batchComplete:
 	// Always clean up the session after batch completes (idempotent if already cancelled externally)
 	CancelUserUploadSession(request.SessionId)
 
-	failedFileIds := make([]string, 0, result.Failed)
-	for _, r := range result.Results {
-		if !r.Success && r.FileId != "" {
-			failedFileIds = append(failedFileIds, r.FileId)
-		}
-	}
-	if err := notify.SendSendFinishedNotification(request.SessionId, reason, result.Success, result.Failed, failedFileIds); err != nil {
-		tool.DefaultLogger.Warnf("[Notify] Failed to send send_finished: %v", err)
-	}
-	if result.Failed == result.Total {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "All files failed to upload", "result": result})
-	} else if result.Failed > 0 {
-		c.JSON(http.StatusMultiStatus, gin.H{"message": "Batch upload completed with some failures", "result": result})
-	} else {
-		c.JSON(http.StatusOK, gin.H{"message": "All files uploaded successfully", "result": result})
-	}
+	send.finish(reason)
+	send.respond(c, "Batch upload completed with some failures", send.result)
 }
 
 // UserCancelUpload handles cancel upload request (sender side)
+// For a sending session the response tells whether the receiver acknowledged the cancel
+// or only the local side was cancelled. The optional reason query (default user-cancelled)
+// is passed on to the other side.
 // POST /api/self/v1/cancel
 func UserCancelUpload(c *gin.Context) {
 	sessionId := strings.TrimSpace(c.Query("sessionId"))
@@ -639,6 +765,10 @@ func UserCancelUpload(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, tool.FastReturnError("Missing required parameter: sessionId"))
 		return
 	}
+	reason := strings.TrimSpace(c.Query("reason"))
+	if reason == "" {
+		reason = types.CancelReasonUserCancelled
+	}
 
 	// Try to find UserUploadSession first (push mode - our device sending to others).
 	// Sender-side sessions are stored in UserUploadSessions (not tool.SessionCache,
@@ -653,16 +783,21 @@ func UserCancelUpload(c *gin.Context) {
 		boardcast.ResumeScan()
 
 		// Send cancel request to the receiver so it cleans up its side
-		targetAddr := &net.UDPAddr{
-			IP:   net.ParseIP(sessionInfo.Target.Ipaddress).To4(),
-			Port: sessionInfo.Target.Port,
+		response := types.UserCancelUploadResponse{LocalCancelled: true}
+		targetAddr, err := targetUDPAddr(sessionInfo.Target)
+		if err == nil {
+			var result types.CancelResult
+			result, err = transfer.CancelSession(targetAddr, &sessionInfo.Target.VersionMessage, sessionId, reason)
+			response.RemoteNotified = result.Acknowledged
+			response.Attempts = result.Attempts
 		}
-		if err := transfer.CancelSession(targetAddr, &sessionInfo.Target.VersionMessage, sessionId); err != nil {
+		if err != nil {
+			response.RemoteError = err.Error()
 			tool.DefaultLogger.Warnf("[CancelUpload] Failed to send cancel request to target: %v", err)
 		}
 
-		tool.DefaultLogger.Infof("[CancelUpload] Successfully cancelled upload session: %s", sessionId)
-		c.JSON(http.StatusOK, tool.FastReturnSuccess())
+		tool.DefaultLogger.Infof("[CancelUpload] Cancelled upload session: %s (receiver notified: %v)", sessionId, response.RemoteNotified)
+		c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(response))
 		return
 	}
 
@@ -684,11 +819,12 @@ func UserCancelUpload(c *gin.Context) {
 			return
 		}
 		models.RemoveUploadSession(sessionId)
+		models.SetCancelReason(sessionId, reason)
 		if _, ok := models.GetShareSession(sessionId); ok {
 			models.RemoveShareSession(sessionId)
 			tool.DefaultLogger.Infof("[CancelUpload] Also removed share session: %s", sessionId)
 		}
-		if err := notify.SendUploadCancelledNotification(sessionId); err != nil {
+		if err := notify.SendUploadCancelledNotification(sessionId, models.GetCancelReason(sessionId)); err != nil {
 			tool.DefaultLogger.Warnf("[CancelUpload] Failed to send upload_cancelled notification: %v", err)
 		}
 		boardcast.ResumeScan()
@@ -699,3 +835,168 @@ func UserCancelUpload(c *gin.Context) {
 	// Session not found in either mode
 	c.JSON(http.StatusNotFound, tool.FastReturnError("Session not found or expired"))
 }
+
+// UserCancelAllUploads cancels every active sender-side upload session and tells each receiver.
+// POST /api/self/v1/cancel-all-uploads
+func UserCancelAllUploads(c *gin.Context) {
+	var sessions []types.UserUploadSession
+	_ = UserUploadSessions.Range(func(_ string, sessionInfo types.UserUploadSession) error {
+		if sessionInfo.SessionId != "" {
+			sessions = append(sessions, sessionInfo)
+		}
+		return nil
+	})
+
+	// Interrupt all local uploads first, then notify receivers in parallel so one slow peer does not hold up the rest
+	for _, sessionInfo := range sessions {
+		CancelUserUploadSession(sessionInfo.SessionId)
+		boardcast.ResumeScan()
+	}
+	var wg sync.WaitGroup
+	var receiverFailed atomic.Int32
+	for _, sessionInfo := range sessions {
+		wg.Add(1)
+		go func(sessionInfo types.UserUploadSession) {
+			defer wg.Done()
+			targetAddr, err := targetUDPAddr(sessionInfo.Target)
+			if err == nil {
+				_, err = transfer.CancelSession(targetAddr, &sessionInfo.Target.VersionMessage, sessionInfo.SessionId, types.CancelReasonUserCancelled)
+			}
+			if err != nil {
+				receiverFailed.Add(1)
+				tool.DefaultLogger.Warnf("[CancelAllUploads] Failed to send cancel request for session %s: %v", sessionInfo.SessionId, err)
+			}
+		}(sessionInfo)
+	}
+	wg.Wait()
+
+	tool.DefaultLogger.Infof("[CancelAllUploads] Cancelled %d upload session(s), %d receiver(s) not reached", len(sessions), receiverFailed.Load())
+	c.JSON(http.StatusOK, tool.FastReturnSuccessWithData(gin.H{
+		"cancelled":         len(sessions),
+		"receiversFailed":   receiverFailed.Load(),
+		"receiversNotified": int32(len(sessions)) - receiverFailed.Load(),
+	}))
+}
+
+// recordSentFileHistory adds a single file sent through /upload to the transfer history of the target.
+func recordSentFileHistory(sessionInfo types.UserUploadSession, fileName string, size int64, sendErr error) {
+	entry := types.TransferHistoryEntry{
+		SessionId: sessionInfo.SessionId,
+		Direction: types.AuditDirectionSend,
+		PeerAlias: sessionInfo.Target.Alias,
+		Files:     1,
+		FileNames: []string{fileName},
+		Bytes:     size,
+		Result:    types.HistoryResultSuccess,
+	}
+	if sendErr != nil {
+		entry.Files, entry.FailedFiles, entry.FileNames, entry.Bytes = 0, 1, nil, 0
+		entry.Result = types.HistoryResultFailed
+	}
+	tool.RecordTransferHistory(sessionInfo.Target.Fingerprint, entry)
+}
+
+// batchSend collects the per-file outcome of a sending session (upload-batch, send-manifest)
+// and reports it: send_progress per file, then audit records, transfer history and send_finished.
+type batchSend struct {
+	sessionInfo types.UserUploadSession
+	result      types.UserUploadBatchResult
+	fileNames   []string // parallel to result.Results
+	fileSizes   []int64
+}
+
+func newBatchSend(sessionInfo types.UserUploadSession, total int) *batchSend {
+	return &batchSend{
+		sessionInfo: sessionInfo,
+		result: types.UserUploadBatchResult{
+			Total:   total,
+			Results: make([]types.UserUploadItemResult, 0, total),
+		},
+		fileNames: make([]string, 0, total),
+		fileSizes: make([]int64, 0, total),
+	}
+}
+
+// add records one file and sends its send_progress notification. errMsg is empty when the file was sent.
+func (b *batchSend) add(fileId, fileName string, size int64, errMsg string) {
+	itemResult := types.UserUploadItemResult{FileId: fileId, Success: errMsg == "", Error: errMsg}
+	if itemResult.Success {
+		b.result.Success++
+	} else {
+		b.result.Failed++
+	}
+	b.result.Results = append(b.result.Results, itemResult)
+	b.fileNames = append(b.fileNames, fileName)
+	b.fileSizes = append(b.fileSizes, size)
+	sessionId := b.sessionInfo.SessionId
+	if err := notify.SendSendProgressNotification(sessionId, fileId, itemResult.Success, errMsg, b.result.Success+b.result.Failed, b.result.Total, fileName, sendStats(sessionId)); err != nil {
+		tool.DefaultLogger.Warnf("[Notify] Failed to send send_progress: %v", err)
+	}
+}
+
+// finish writes the audit records and transfer history of the session and sends send_finished with reason.
+func (b *batchSend) finish(reason string) {
+	history := types.TransferHistoryEntry{
+		SessionId:   b.sessionInfo.SessionId,
+		Direction:   types.AuditDirectionSend,
+		PeerAlias:   b.sessionInfo.Target.Alias,
+		Files:       b.result.Success,
+		FailedFiles: b.result.Failed,
+		Result:      historyResult(b.result.Success, b.result.Failed),
+	}
+	if reason == "cancelled" || reason == "rejected" {
+		history.Result = types.HistoryResultCancelled
+	}
+	failedFileIds := make([]string, 0, b.result.Failed)
+	for i, r := range b.result.Results {
+		var itemErr error
+		if !r.Success {
+			itemErr = errors.New(r.Error)
+			if r.FileId != "" {
+				failedFileIds = append(failedFileIds, r.FileId)
+			}
+		} else {
+			history.Bytes += b.fileSizes[i]
+			history.FileNames = append(history.FileNames, b.fileNames[i])
+		}
+		auditSentFile(b.sessionInfo, r.FileId, b.fileNames[i], b.fileSizes[i], itemErr)
+	}
+	tool.RecordTransferHistory(b.sessionInfo.Target.Fingerprint, history)
+	if err := notify.SendSendFinishedNotification(b.sessionInfo.SessionId, reason, b.result.Success, b.result.Failed, failedFileIds); err != nil {
+		tool.DefaultLogger.Warnf("[Notify] Failed to send send_finished: %v", err)
+	}
+}
+
+// respond answers 500 when every file failed, 207 with partialMessage when some did and 200 otherwise,
+// with body as "result".
+func (b *batchSend) respond(c *gin.Context, partialMessage string, body any) {
+	switch {
+	case b.result.Total > 0 && b.result.Failed == b.result.Total:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "All files failed to upload", "result": body})
+	case b.result.Failed > 0:
+		c.JSON(http.StatusMultiStatus, gin.H{"message": partialMessage, "result": body})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "All files uploaded successfully", "result": body})
+	}
+}
+
+// auditSentFile appends a send-side audit record for a single file and counts it in tool.Stats when sent.
+func auditSentFile(sessionInfo types.UserUploadSession, fileId, fileName string, size int64, sendErr error) {
+	event := types.AuditEvent{
+		SessionId:       sessionInfo.SessionId,
+		Direction:       types.AuditDirectionSend,
+		PeerAlias:       sessionInfo.Target.Alias,
+		PeerFingerprint: sessionInfo.Target.Fingerprint,
+		FileId:          fileId,
+		FileName:        fileName,
+		Size:            size,
+		Result:          types.AuditResultSuccess,
+	}
+	if sendErr != nil {
+		event.Result = types.AuditResultFailed
+		event.Error = sendErr.Error()
+	} else {
+		tool.CountFileSent(size)
+	}
+	tool.WriteAuditEvent(event)
+}
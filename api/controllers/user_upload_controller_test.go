@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/transfer"
+	"github.com/moyoez/localsend-go/types"
+)
+
+func TestUserUploadRawBodyAuditRecord(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(receiver.Close)
+	host, port, err := net.SplitHostPort(receiver.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNumber, _ := strconv.Atoi(port)
+
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	tool.SetAuditLog(auditPath)
+	t.Cleanup(func() { tool.SetAuditLog("") })
+
+	const sessionId, fileId, token = "raw-audit-session", "raw-file", "raw-token"
+	UserUploadSessions.Set(sessionId, types.UserUploadSession{
+		Target: types.UserScanCurrentItem{
+			Ipaddress:      host,
+			VersionMessage: types.VersionMessage{Alias: "Raw Receiver", Fingerprint: "raw-receiver", Port: portNumber, Protocol: "http"},
+		},
+		SessionId: sessionId,
+		Tokens:    map[string]string{fileId: token},
+		Files:     map[string]types.FileInfo{fileId: {ID: fileId, FileName: "report.pdf", Size: 11, FileType: "application/pdf"}},
+	})
+	CreateUserUploadSessionContext(sessionId)
+	t.Cleanup(func() { CancelUserUploadSession(sessionId) })
+
+	engine := gin.New()
+	engine.POST("/api/self/v1/upload", UserUpload)
+	query := url.Values{"sessionId": {sessionId}, "fileId": {fileId}, "token": {token}}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/self/v1/upload?"+query.Encode(), bytes.NewReader([]byte("raw content"))))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("upload: status %d, body %s", recorder.Code, recorder.Body)
+	}
+
+	line, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	var event types.AuditEvent
+	if err := sonic.Unmarshal(bytes.TrimSpace(line), &event); err != nil {
+		t.Fatalf("decode audit record %q: %v", line, err)
+	}
+	if event.FileName != "report.pdf" || event.Size != 11 || event.FileId != fileId {
+		t.Errorf("audit record %+v, want file report.pdf of 11 bytes", event)
+	}
+}
+
+func TestUserUploadSessionCleanup(t *testing.T) {
+	previousTTL := UserUploadSessionTTL
+	t.Cleanup(func() { SetUserSessionTTL(previousTTL) })
+	const ttl = 50 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		end      func(sessionId string)
+		wantKept bool
+	}{
+		{name: "cancelled", end: CancelUserUploadSession},
+		{name: "expired", end: func(sessionId string) {
+			SetUserSessionTTL(ttl)
+			time.Sleep(2 * ttl)
+			UserUploadSessions.Get(sessionId)
+		}},
+		{name: "moved by a TTL change", end: func(string) { SetUserSessionTTL(time.Hour) }, wantKept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zipPath := filepath.Join(t.TempDir(), "folder.zip")
+			if err := os.WriteFile(zipPath, []byte("zip"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			sessionId := "temp-zip-" + tt.name
+			UserUploadSessions.Set(sessionId, types.UserUploadSession{SessionId: sessionId, ZipPaths: map[string]string{"zip": zipPath}})
+			transfer.StartTransferStats(sessionId, 3)
+			t.Cleanup(func() { CancelUserUploadSession(sessionId) })
+
+			tt.end(sessionId)
+			_, err := os.Stat(zipPath)
+			if kept := err == nil; kept != tt.wantKept {
+				t.Errorf("temp zip kept: %v, want %v", kept, tt.wantKept)
+			}
+			if _, kept := transfer.GetTransferStats(sessionId); kept != tt.wantKept {
+				t.Errorf("transfer stats kept: %v, want %v", kept, tt.wantKept)
+			}
+			if tt.wantKept && UserUploadSessions.Get(sessionId).SessionId != sessionId {
+				t.Error("session was not moved to the new cache")
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"context"
+	"os"
+
+	"github.com/moyoez/localsend-go/api/models"
+	"github.com/moyoez/localsend-go/tool"
+	"golang.org/x/net/webdav"
+)
+
+// WebDAVPrefix is where the WebDAV view of the upload folder is mounted.
+const WebDAVPrefix = "/webdav"
+
+// WebDAVMethods are the HTTP methods a WebDAV handler must be routed for.
+var WebDAVMethods = []string{
+	"OPTIONS", "GET", "HEAD", "POST", "DELETE", "PUT",
+	"MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK", "PROPFIND", "PROPPATCH",
+}
+
+// uploadFolderFS is a webdav.FileSystem rooted at models.DefaultUploadFolder at the time of each call,
+// so a hot-reloaded upload folder is picked up without remounting.
+type uploadFolderFS struct{}
+
+func (uploadFolderFS) dir() webdav.Dir {
+	return webdav.Dir(models.DefaultUploadFolder())
+}
+
+func (fs uploadFolderFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return fs.dir().Mkdir(ctx, name, models.ReceivedDirMode())
+}
+
+// OpenFile opens name in the upload folder. Files stored encrypted (see models.SetEncryptionKey) are
+// decrypted when opened read-only with the key set.
+func (fs uploadFolderFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	f, err := fs.dir().OpenFile(ctx, name, flag, models.ReceivedFileMode())
+	if err != nil || flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		return f, err
+	}
+	return decryptWebDAVFile(f)
+}
+
+func (fs uploadFolderFS) RemoveAll(ctx context.Context, name string) error {
+	return fs.dir().RemoveAll(ctx, name)
+}
+
+func (fs uploadFolderFS) Rename(ctx context.Context, oldName, newName string) error {
+	return fs.dir().Rename(ctx, oldName, newName)
+}
+
+func (fs uploadFolderFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	fi, err := fs.dir().Stat(ctx, name)
+	if err != nil || !fi.Mode().IsRegular() || models.EncryptionKey() == nil {
+		return fi, err
+	}
+	f, err := fs.OpenFile(ctx, name, os.O_RDONLY, 0)
+	if err != nil {
+		return fi, nil
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// decryptedFile serves the plaintext of an encrypted received file over WebDAV.
+type decryptedFile struct {
+	webdav.File
+	reader *tool.DecryptReader
+}
+
+// decryptedFileInfo reports the plaintext size of an encrypted file.
+type decryptedFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (fi decryptedFileInfo) Size() int64 {
+	return fi.size
+}
+
+// decryptWebDAVFile wraps f in a decryptedFile if it is an encrypted regular file and the key is set.
+func decryptWebDAVFile(f webdav.File) (webdav.File, error) {
+	key := models.EncryptionKey()
+	osFile, ok := f.(*os.File)
+	if key == nil || !ok {
+		return f, nil
+	}
+	fi, err := osFile.Stat()
+	if err != nil || !fi.Mode().IsRegular() || !tool.IsEncryptedFile(osFile) {
+		return f, nil
+	}
+	reader, err := tool.NewDecryptReader(osFile, fi.Size(), key)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &decryptedFile{File: f, reader: reader}, nil
+}
+
+func (f *decryptedFile) Read(p []byte) (int, error) {
+	return f.reader.Read(p)
+}
+
+func (f *decryptedFile) Seek(offset int64, whence int) (int64, error) {
+	return f.reader.Seek(offset, whence)
+}
+
+func (f *decryptedFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *decryptedFile) Stat() (os.FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return decryptedFileInfo{FileInfo: fi, size: f.reader.Size()}, nil
+}
+
+// NewWebDAVHandler returns a WebDAV server for the upload folder, mounted at WebDAVPrefix.
+func NewWebDAVHandler() *webdav.Handler {
+	return &webdav.Handler{
+		Prefix:     WebDAVPrefix,
+		FileSystem: uploadFolderFS{},
+		LockSystem: webdav.NewMemLS(),
+	}
+}
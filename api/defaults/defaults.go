@@ -4,11 +4,13 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/moyoez/localsend-go/api/models"
@@ -17,18 +19,42 @@ import (
 	"github.com/moyoez/localsend-go/types"
 )
 
+// Errors returned by DefaultOnPrepareUpload when the receiver is protected by a PIN.
+var (
+	ErrPinRequired = errors.New("pin required")
+	ErrInvalidPin  = errors.New("invalid PIN")
+)
+
+// ErrTooManyFiles is returned by DefaultOnPrepareUpload when a request offers more files than
+// tool.MaxFilesPerSession allows.
+var ErrTooManyFiles = errors.New("too many files")
+
 // DefaultOnRegister is the default callback for device register.
 func DefaultOnRegister(remote *types.VersionMessage) error {
 	tool.DefaultLogger.Infof("Received device register request: %s (fingerprint: %s, port: %d)",
 		remote.Alias, remote.Fingerprint, remote.Port)
+	if tool.IsBlocked(remote.Fingerprint) {
+		return fmt.Errorf("device blocked")
+	}
 	return nil
 }
 
 // DefaultOnPrepareUpload is the default callback for prepare-upload.
-func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string) (*types.PrepareUploadResponse, error) {
+// remoteAddr is the sender's client IP, used for subnet-based auto-accept.
+func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string, remoteAddr string) (*types.PrepareUploadResponse, error) {
 	tool.DefaultLogger.Infof("Received file transfer prepare request: from %s, file count: %d, PIN: %s",
 		request.Info.Alias, len(request.Files), pin)
 
+	if limit := tool.MaxFilesPerSession(); limit > 0 && len(request.Files) > limit {
+		return nil, fmt.Errorf("%w: %d (max %d)", ErrTooManyFiles, len(request.Files), limit)
+	}
+
+	tool.RecordDeviceAddress(remoteAddr, request.Info.Fingerprint)
+	if tool.IsBlocked(request.Info.Fingerprint) {
+		tool.DefaultLogger.Infof("Rejecting prepare request from blocked device: %s (fingerprint: %s)", request.Info.Alias, request.Info.Fingerprint)
+		return nil, fmt.Errorf("device blocked")
+	}
+
 	askSession := tool.GenerateRandomUUID()
 	response := &types.PrepareUploadResponse{
 		SessionId: askSession,
@@ -36,6 +62,20 @@ func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string) (*t
 	}
 
 	pinSetted := tool.GetProgramConfigStatus().Pin
+	switch tool.ActiveQuietHoursAction(time.Now()) {
+	case tool.QuietHoursReject:
+		tool.DefaultLogger.Infof("Rejecting prepare request from %s during quiet hours", request.Info.Alias)
+		return nil, fmt.Errorf("rejected during quiet hours")
+	case tool.QuietHoursRequirePin:
+		// Checked before autoSave/favorites/subnets, so the PIN is required even when they would accept
+		if quietPin := tool.GetQuietHoursPin(); quietPin != "" {
+			pinSetted = quietPin
+		}
+		if pinSetted == "" {
+			tool.DefaultLogger.Warnf("Quiet hours require a PIN but none is set, rejecting transfer from %s", request.Info.Alias)
+			return nil, fmt.Errorf("rejected during quiet hours")
+		}
+	}
 	switch {
 	case pinSetted != "" && pin == "":
 		notification := &types.Notification{
@@ -51,9 +91,9 @@ func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string) (*t
 		if err := notify.SendNotification(notification, ""); err != nil {
 			tool.DefaultLogger.Errorf("[Notify] Failed to send pin_required notification: %v", err)
 		}
-		return nil, fmt.Errorf("pin required")
+		return nil, ErrPinRequired
 	case pinSetted != "" && pin != pinSetted:
-		return nil, fmt.Errorf("invalid PIN")
+		return nil, ErrInvalidPin
 	}
 
 	// Text-only message: single file, text/plain, with preview — show dialog, wait for user dismiss, then return 204 (no upload)
@@ -93,6 +133,36 @@ func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string) (*t
 			needConfirmation = false
 		}
 	}
+	if needConfirmation && tool.IsAutoAcceptAddr(remoteAddr) {
+		tool.DefaultLogger.Infof("Auto-accepting from trusted subnet: %s (IP: %s)", request.Info.Alias, remoteAddr)
+		needConfirmation = false
+	}
+	if maxSize := tool.GetAutoAcceptMaxSize(); !needConfirmation && maxSize > 0 {
+		var totalSize int64
+		for _, info := range request.Files {
+			totalSize += info.Size
+		}
+		if totalSize > maxSize {
+			tool.DefaultLogger.Infof("Transfer from %s is %d bytes, above auto-accept limit %d, asking user", request.Info.Alias, totalSize, maxSize)
+			needConfirmation = true
+		}
+	}
+
+	if needConfirmation {
+		if handler := models.GetConfirmRecvHandler(); handler != nil {
+			accepted, err := handler(request, remoteAddr)
+			switch {
+			case err != nil:
+				tool.DefaultLogger.Infof("Confirm handler made no decision for %s, asking user: %v", request.Info.Alias, err)
+			case !accepted:
+				tool.DefaultLogger.Infof("Confirm handler rejected transfer from %s (IP: %s)", request.Info.Alias, remoteAddr)
+				return nil, fmt.Errorf("rejected")
+			default:
+				tool.DefaultLogger.Infof("Confirm handler accepted transfer from %s (IP: %s)", request.Info.Alias, remoteAddr)
+				needConfirmation = false
+			}
+		}
+	}
 
 	if needConfirmation {
 		confirmCh := make(chan types.ConfirmResult, 1)
@@ -151,6 +221,7 @@ func DefaultOnPrepareUpload(request *types.PrepareUploadRequest, pin string) (*t
 	}
 
 	models.CacheUploadSession(askSession, request.Files)
+	models.SetUploadSessionSender(askSession, request.Info)
 
 	return response, nil
 }
@@ -170,19 +241,285 @@ func DefaultOnUpload(sessionId, fileId, token string, data io.Reader, remoteAddr
 	if !ok {
 		return fmt.Errorf("file metadata not found")
 	}
+	models.TouchUploadSession(sessionId)
+
+	targetPath, err := resolveUploadTarget(sessionId, fileId, info)
+	if err != nil {
+		return err
+	}
+
+	file, err := openReceivedFile(targetPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("create file failed: %w", err)
+	}
+	defer func() {
+		// Failed uploads close the file early to remove it
+		if err := file.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			tool.DefaultLogger.Warnf("Failed to close file: %v", err)
+		}
+	}()
+
+	// With an encryption key the file is stored encrypted; the hash is still taken over the plaintext
+	var dst io.Writer = file
+	var encrypter io.WriteCloser
+	if key := models.EncryptionKey(); key != nil {
+		if encrypter, err = tool.NewEncryptWriter(file, key); err != nil {
+			return fmt.Errorf("encrypt file failed: %w", err)
+		}
+		dst = encrypter
+	}
+
+	hasher := sha256.New()
+	// Refresh the session TTL while data keeps flowing, so slow large files don't outlive it
+	writer := io.MultiWriter(dst, hasher, &sessionTouchWriter{sessionId: sessionId})
+
+	// The declared size bounds the body, so a client sending more than it announced cannot grow the file.
+	// 0 is a declared size too: an empty file must not take an unlimited body
+	src, stall := watchStall(sessionId, data)
+	src = io.LimitReader(src, info.Size)
+	// rejectFile removes a file that failed the size or hash check, so no corrupt file is left behind
+	rejectFile := func(err error) error {
+		_ = file.Close()
+		_ = os.Remove(targetPath)
+		return err
+	}
+
+	var written int64
+	// When data is io.Closer (e.g. http.Request.Body), close it on context cancel so that
+	// blocking Read() unblocks and in-flight upload can be interrupted immediately.
+	if closer, ok := data.(io.Closer); ok {
+		type copyResult struct {
+			n   int64
+			err error
+		}
+		ch := make(chan copyResult, 1)
+		go func() {
+			n, e := tool.CopyWithContext(ctx, writer, src)
+			ch <- copyResult{n, e}
+		}()
+		select {
+		case res := <-ch:
+			written, err = res.n, res.err
+		case <-ctx.Done():
+			_ = closer.Close()
+			res := <-ch
+			written, err = res.n, ctx.Err()
+		}
+	} else {
+		written, err = tool.CopyWithContext(ctx, writer, src)
+	}
+	if stallErr := stall.stop(); stallErr != nil {
+		_ = file.Close()
+		discardPartialFile(targetPath, encrypter != nil)
+		return stallErr
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			_ = file.Close()
+			discardPartialFile(targetPath, encrypter != nil)
+			return fmt.Errorf("upload cancelled")
+		}
+		// The client closed the connection before sending everything it declared
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			_ = file.Close()
+			discardPartialFile(targetPath, encrypter != nil)
+			return types.NewSizeMismatchError(info.Size, written)
+		}
+		return fmt.Errorf("write file failed: %w", err)
+	}
+
+	if ctx.Err() != nil {
+		_ = file.Close()
+		discardPartialFile(targetPath, encrypter != nil)
+		return fmt.Errorf("upload cancelled")
+	}
+	// Anything left after the declared size means the client lied about it
+	if written == info.Size {
+		if n, _ := io.ReadFull(data, make([]byte, 1)); n > 0 {
+			return rejectFile(types.NewSizeMismatchError(info.Size, written+int64(n)))
+		}
+	}
+	if encrypter != nil {
+		if err := encrypter.Close(); err != nil {
+			return fmt.Errorf("write file failed: %w", err)
+		}
+	}
+
+	if written != info.Size {
+		return rejectFile(types.NewSizeMismatchError(info.Size, written))
+	}
+
+	if info.SHA256 != "" {
+		actual := hex.EncodeToString(hasher.Sum(nil))
+		if !strings.EqualFold(actual, info.SHA256) {
+			return rejectFile(types.NewHashMismatchError(info.SHA256, actual))
+		}
+	}
+
+	models.SetFileSavePath(sessionId, fileId, targetPath)
+	models.SetReceivedFileSHA256(sessionId, fileId, info.SHA256)
+	tool.DefaultLogger.Infof("Upload saved: sessionId=%s, fileId=%s, path=%s", sessionId, fileId, targetPath)
+	return nil
+}
+
+// DefaultOnUploadChunk is the default callback for a single chunk of a chunked upload.
+// Chunks are written at index*chunkSize into a ".part" file next to the final path, so they may arrive
+// in any order and be resent after an interruption; DefaultOnUploadComplete assembles the result.
+func DefaultOnUploadChunk(sessionId, fileId, token string, index, total int, chunkSize int64, data io.Reader, remoteAddr string) error {
+	if models.IsSessionCancelled(sessionId) {
+		return fmt.Errorf("session cancelled")
+	}
+	if total <= 0 || index < 0 || index >= total || chunkSize <= 0 {
+		return fmt.Errorf("invalid chunk parameters")
+	}
+
+	ctx := models.GetSessionContext(sessionId)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	info, ok := models.LookupFileInfo(sessionId, fileId)
+	if !ok {
+		return fmt.Errorf("file metadata not found")
+	}
+	if info.Size > 0 && (int64(total-1)*chunkSize >= info.Size || int64(total)*chunkSize < info.Size) {
+		return fmt.Errorf("chunk layout does not match file size")
+	}
+	models.TouchUploadSession(sessionId)
 
-	uploadDir := models.DefaultUploadFolder
-	if !models.DoNotMakeSessionFolder {
-		uploadDir = filepath.Join(models.DefaultUploadFolder, sessionId)
+	progress, ok := models.LookupUploadChunkProgress(sessionId, fileId)
+	if !ok || progress.Total != total || progress.ChunkSize != chunkSize {
+		targetPath, err := resolveUploadTarget(sessionId, fileId, info)
+		if err != nil {
+			return err
+		}
+		progress = *models.StartUploadChunkProgress(sessionId, fileId, &types.UploadChunkProgress{
+			Total:      total,
+			ChunkSize:  chunkSize,
+			PartPath:   targetPath + models.PartialSuffix,
+			TargetPath: targetPath,
+		})
 	}
-	if err := os.MkdirAll(uploadDir, 0o755); err != nil {
-		return fmt.Errorf("create upload dir failed: %w", err)
+
+	file, err := openReceivedFile(progress.PartPath, os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		return fmt.Errorf("open part file failed: %w", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			tool.DefaultLogger.Warnf("Failed to close part file: %v", err)
+		}
+	}()
+
+	// Bound each chunk to its slot so a misbehaving sender cannot overwrite the next one
+	offset := int64(index) * chunkSize
+	writer := io.MultiWriter(io.NewOffsetWriter(file, offset), &sessionTouchWriter{sessionId: sessionId})
+	src, stall := watchStall(sessionId, data)
+	written, err := tool.CopyWithContext(ctx, writer, io.LimitReader(src, chunkSize+1))
+	if stallErr := stall.stop(); stallErr != nil {
+		return stallErr
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("upload cancelled")
+		}
+		return fmt.Errorf("write chunk failed: %w", err)
+	}
+	if written > chunkSize || (index < total-1 && written != chunkSize) {
+		return fmt.Errorf("chunk size mismatch")
+	}
+
+	models.MarkUploadChunkReceived(sessionId, fileId, index)
+	tool.DefaultLogger.Debugf("Upload chunk saved: sessionId=%s, fileId=%s, chunk=%d/%d", sessionId, fileId, index+1, total)
+	return nil
+}
+
+// DefaultOnUploadComplete is the default callback that finishes a chunked upload.
+// It checks that every chunk arrived, verifies size and SHA-256 of the assembled file against the
+// prepare-upload metadata (or expectedSHA256 when the sender did not announce one) and moves it into place.
+func DefaultOnUploadComplete(sessionId, fileId, token, expectedSHA256 string, remoteAddr string) error {
+	if models.IsSessionCancelled(sessionId) {
+		return fmt.Errorf("session cancelled")
+	}
+
+	info, ok := models.LookupFileInfo(sessionId, fileId)
+	if !ok {
+		return fmt.Errorf("file metadata not found")
+	}
+	progress, ok := models.LookupUploadChunkProgress(sessionId, fileId)
+	if !ok {
+		return fmt.Errorf("no chunks received")
+	}
+	if len(progress.Received) != progress.Total {
+		return fmt.Errorf("missing chunks: received %d of %d", len(progress.Received), progress.Total)
+	}
+	models.TouchUploadSession(sessionId)
+
+	file, err := os.Open(progress.PartPath)
+	if err != nil {
+		return fmt.Errorf("open part file failed: %w", err)
+	}
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	_ = file.Close()
+	if err != nil {
+		return fmt.Errorf("read part file failed: %w", err)
+	}
+
+	if info.Size > 0 && size != info.Size {
+		return types.NewSizeMismatchError(info.Size, size)
+	}
+	wantSHA256 := info.SHA256
+	if wantSHA256 == "" {
+		wantSHA256 = expectedSHA256
+	}
+	if wantSHA256 != "" {
+		actual := hex.EncodeToString(hasher.Sum(nil))
+		if !strings.EqualFold(actual, wantSHA256) {
+			_ = os.Remove(progress.PartPath)
+			models.RemoveUploadChunkProgress(sessionId, fileId)
+			return types.NewHashMismatchError(wantSHA256, actual)
+		}
+	}
+
+	if key := models.EncryptionKey(); key != nil {
+		// chunks are written at their offsets, so the part file is only encrypted once complete and verified
+		if err := encryptPartFile(progress.PartPath, progress.TargetPath, key); err != nil {
+			return fmt.Errorf("encrypt part file failed: %w", err)
+		}
+	} else if err := os.Rename(progress.PartPath, progress.TargetPath); err != nil {
+		return fmt.Errorf("rename part file failed: %w", err)
+	}
+	models.RemoveUploadChunkProgress(sessionId, fileId)
+	models.SetFileSavePath(sessionId, fileId, progress.TargetPath)
+	models.SetReceivedFileSHA256(sessionId, fileId, wantSHA256)
+	tool.DefaultLogger.Infof("Upload saved: sessionId=%s, fileId=%s, path=%s (%d chunks)", sessionId, fileId, progress.TargetPath, progress.Total)
+	return nil
+}
+
+// resolveUploadTarget returns the path a received file is saved to, creating its parent directories.
+func resolveUploadTarget(sessionId, fileId string, info types.FileInfo) (string, error) {
+	uploadDir := models.DefaultUploadFolder()
+	useTemplate := models.HasSavePathTemplate()
+	switch {
+	case useTemplate:
+		uploadDir = models.RenderSaveDir(sessionId, info, time.Now())
+	case !models.DoNotMakeSessionFolder:
+		uploadDir = filepath.Join(uploadDir, sessionId)
+	}
+	if err := mkdirReceived(uploadDir); err != nil {
+		return "", fmt.Errorf("create upload dir failed: %w", err)
 	}
 
 	fileName := strings.TrimSpace(info.FileName)
 	if fileName == "" {
 		fileName = fileId
 	}
+	if err := tool.ValidateRelativePath(fileName); err != nil {
+		return "", fmt.Errorf("invalid file path: %w", err)
+	}
+	// Strip control chars, reserved names and overlong segments from the peer-supplied name
+	fileName = tool.SanitizeRelativePath(fileName)
 	// Preserve relative path (e.g. "foldername/subdir/file.txt") for folder uploads
 	relativePath := filepath.Clean(filepath.FromSlash(fileName))
 	sep := string(filepath.Separator)
@@ -209,91 +546,108 @@ func DefaultOnUpload(sessionId, fileId, token string, data io.Reader, remoteAddr
 	// Prevent path traversal: ensure result stays under uploadDir
 	uploadDirAbs, err := filepath.Abs(uploadDir)
 	if err != nil {
-		return fmt.Errorf("upload dir abs: %w", err)
+		return "", fmt.Errorf("upload dir abs: %w", err)
 	}
 	targetPathAbs, err := filepath.Abs(targetPath)
 	if err != nil {
-		return fmt.Errorf("target path abs: %w", err)
+		return "", fmt.Errorf("target path abs: %w", err)
 	}
 	rel, err := filepath.Rel(uploadDirAbs, targetPathAbs)
 	if err != nil || strings.HasPrefix(rel, "..") || rel == ".." {
-		return fmt.Errorf("invalid file path: path traversal not allowed")
+		return "", fmt.Errorf("invalid file path: path traversal not allowed")
 	}
 	// Create parent directories for folder structure
-	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
-		return fmt.Errorf("create parent dir failed: %w", err)
+	if err := mkdirReceived(filepath.Dir(targetPath)); err != nil {
+		return "", fmt.Errorf("create parent dir failed: %w", err)
 	}
-	// For single-file (non-folder) with DoNotMakeSessionFolder, use NextAvailablePath for file name collision.
+	// For single-file (non-folder) with DoNotMakeSessionFolder or a save path template (shared folders),
+	// use NextAvailablePath for file name collision.
 	// For folder uploads we already resolved the folder name; do not rename files inside.
-	if models.DoNotMakeSessionFolder && !isFolderUpload {
+	if (models.DoNotMakeSessionFolder || useTemplate) && !isFolderUpload {
 		targetPath = tool.NextAvailablePath(filepath.Dir(targetPath), filepath.Base(targetPath))
 	}
+	return targetPath, nil
+}
 
-	file, err := os.Create(targetPath)
+// openReceivedFile opens a received file and sets it to models.ReceivedFileMode regardless of the umask.
+func openReceivedFile(path string, flag int) (*os.File, error) {
+	mode := models.ReceivedFileMode()
+	file, err := os.OpenFile(path, flag, mode)
 	if err != nil {
-		return fmt.Errorf("create file failed: %w", err)
+		return nil, err
 	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			tool.DefaultLogger.Warnf("Failed to close file: %v", err)
-		}
-	}()
-
-	hasher := sha256.New()
-	writer := io.MultiWriter(file, hasher)
+	if err := file.Chmod(mode); err != nil {
+		tool.DefaultLogger.Warnf("Failed to set mode %o on %s: %v", mode, path, err)
+	}
+	return file, nil
+}
 
-	var written int64
-	// When data is io.Closer (e.g. http.Request.Body), close it on context cancel so that
-	// blocking Read() unblocks and in-flight upload can be interrupted immediately.
-	if closer, ok := data.(io.Closer); ok {
-		type copyResult struct {
-			n   int64
-			err error
+// discardPartialFile drops the file of an interrupted upload. With models.KeepPartialOnCancel it is
+// renamed to "<name>.part" for a later resumed transfer instead, unless it was stored encrypted.
+func discardPartialFile(targetPath string, encrypted bool) {
+	if models.KeepPartialOnCancel() && !encrypted {
+		partPath := targetPath + models.PartialSuffix
+		err := os.Rename(targetPath, partPath)
+		if err == nil {
+			models.RecordKeptPartial(partPath)
+			tool.DefaultLogger.Infof("[Partial] Kept partial file %s", partPath)
+			return
 		}
-		ch := make(chan copyResult, 1)
-		go func() {
-			n, e := tool.CopyWithContext(ctx, writer, data)
-			ch <- copyResult{n, e}
-		}()
-		select {
-		case res := <-ch:
-			written, err = res.n, res.err
-		case <-ctx.Done():
-			_ = closer.Close()
-			res := <-ch
-			written, err = res.n, ctx.Err()
-		}
-	} else {
-		written, err = tool.CopyWithContext(ctx, writer, data)
+		tool.DefaultLogger.Warnf("[Partial] Failed to keep partial file %s: %v", targetPath, err)
 	}
+	_ = os.Remove(targetPath)
+}
+
+// encryptPartFile writes the encrypted content of partPath to targetPath and removes partPath.
+func encryptPartFile(partPath, targetPath string, key []byte) error {
+	part, err := os.Open(partPath)
 	if err != nil {
-		if ctx.Err() != nil {
-			_ = file.Close()
-			_ = os.Remove(targetPath)
-			return fmt.Errorf("upload cancelled")
+		return err
+	}
+	defer part.Close()
+	target, err := openReceivedFile(targetPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	encrypter, err := tool.NewEncryptWriter(target, key)
+	if err == nil {
+		if _, err = io.Copy(encrypter, part); err == nil {
+			err = encrypter.Close()
 		}
-		return fmt.Errorf("write file failed: %w", err)
 	}
-
-	if ctx.Err() != nil {
-		_ = file.Close()
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
 		_ = os.Remove(targetPath)
-		return fmt.Errorf("upload cancelled")
+		return err
 	}
+	_ = part.Close()
+	return os.Remove(partPath)
+}
 
-	if info.Size > 0 && written != info.Size {
-		return fmt.Errorf("size mismatch")
+// mkdirReceived creates dir and any missing parents with models.ReceivedDirMode.
+// Only directories created here are chmodded; existing ones keep their permissions.
+func mkdirReceived(dir string) error {
+	mode := models.ReceivedDirMode()
+	var created []string
+	for p := filepath.Clean(dir); ; p = filepath.Dir(p) {
+		if _, err := os.Stat(p); err == nil {
+			break
+		}
+		created = append(created, p)
+		if parent := filepath.Dir(p); parent == p {
+			break
+		}
 	}
-
-	if info.SHA256 != "" {
-		actual := hex.EncodeToString(hasher.Sum(nil))
-		if !strings.EqualFold(actual, info.SHA256) {
-			return fmt.Errorf("hash mismatch")
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+	for _, p := range created {
+		if err := os.Chmod(p, mode); err != nil {
+			tool.DefaultLogger.Warnf("Failed to set mode %o on %s: %v", mode, p, err)
 		}
 	}
-
-	models.SetFileSavePath(sessionId, fileId, targetPath)
-	tool.DefaultLogger.Infof("Upload saved: sessionId=%s, fileId=%s, path=%s", sessionId, fileId, targetPath)
 	return nil
 }
 
@@ -308,3 +662,70 @@ func DefaultOnCancel(sessionId string) error {
 	tool.DefaultLogger.Infof("Session %s canceled and all ongoing uploads interrupted", sessionId)
 	return nil
 }
+
+// sessionTouchInterval is how often an in-progress upload refreshes its session TTL, unless a quarter of the TTL is shorter.
+const sessionTouchInterval = 30 * time.Second
+
+// sessionTouchWriter refreshes the upload session TTL at most once per sessionTouchInterval as chunks are written.
+type sessionTouchWriter struct {
+	sessionId string
+	lastTouch time.Time
+}
+
+func (w *sessionTouchWriter) Write(p []byte) (int, error) {
+	if now := time.Now(); now.Sub(w.lastTouch) >= min(sessionTouchInterval, models.SessionTTL()/4) {
+		w.lastTouch = now
+		models.TouchUploadSession(w.sessionId)
+	}
+	return len(p), nil
+}
+
+// stallReader cancels the upload session when no data arrives for timeout, so a dead connection
+// cannot keep an upload (and its open file) alive indefinitely. Every successful read restarts the timer.
+type stallReader struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+// watchStall wraps data in a stallReader when a stall timeout is set (see models.SetUploadStallTimeout).
+// Otherwise it returns data unchanged and a nil stallReader, whose stop is a no-op.
+func watchStall(sessionId string, data io.Reader) (io.Reader, *stallReader) {
+	timeout := models.UploadStallTimeout()
+	if timeout <= 0 {
+		return data, nil
+	}
+	s := &stallReader{r: data, timeout: timeout}
+	s.timer = time.AfterFunc(timeout, func() {
+		s.stalled.Store(true)
+		tool.DefaultLogger.Warnf("Upload stalled: no data for %s, cancelling session %s", timeout, sessionId)
+		models.SetCancelReason(sessionId, types.CancelReasonTimeout)
+		models.CancelSessionContext(sessionId)
+		// unblock a Read waiting on the dead connection
+		if closer, ok := data.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	})
+	return s, s
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		s.timer.Reset(s.timeout)
+	}
+	return n, err
+}
+
+// stop ends stall detection and returns an error if the upload was aborted for stalling.
+func (s *stallReader) stop() error {
+	if s == nil {
+		return nil
+	}
+	s.timer.Stop()
+	if s.stalled.Load() {
+		return fmt.Errorf("upload stalled: no data received for %s", s.timeout)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package defaults
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moyoez/localsend-go/api/models"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// slowReader returns data one byte at a time, waiting delay before each read.
+type slowReader struct {
+	data  []byte
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	n := copy(p[:1], r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestSlowUploadKeepsSessionAlive(t *testing.T) {
+	const ttl = 200 * time.Millisecond
+	models.SetSessionTTL(ttl)
+	t.Cleanup(func() { models.SetSessionTTL(tool.DefaultTTL) })
+
+	folder := t.TempDir()
+	previousFolder := models.DefaultUploadFolder()
+	models.SetDefaultUploadFolder(folder)
+	t.Cleanup(func() { models.SetDefaultUploadFolder(previousFolder) })
+	models.SetConfirmRecvHandler(func(*types.PrepareUploadRequest, string) (bool, error) {
+		return true, nil
+	})
+	t.Cleanup(func() { models.SetConfirmRecvHandler(nil) })
+
+	data := []byte("slow transfer")
+	request := &types.PrepareUploadRequest{
+		Info: types.DeviceInfo{Alias: "Slow Sender", Version: "2.0", Fingerprint: "slow-sender", Port: 53317, Protocol: "http"},
+		Files: map[string]types.FileInfo{
+			"slow":  {ID: "slow", FileName: "slow.txt", Size: int64(len(data)), FileType: "text/plain"},
+			"other": {ID: "other", FileName: "other.txt", Size: 1, FileType: "text/plain"},
+		},
+	}
+	response, err := DefaultOnPrepareUpload(request, "", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("prepare-upload: %v", err)
+	}
+	sessionId := response.SessionId
+
+	// The body takes several TTLs to arrive; the session must survive it
+	reader := &slowReader{data: append([]byte(nil), data...), delay: ttl / 5}
+	if err := DefaultOnUpload(sessionId, "slow", "accepted", reader, "127.0.0.1"); err != nil {
+		t.Fatalf("slow upload failed: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(folder, sessionId, "slow.txt"))
+	if err != nil {
+		t.Fatalf("read received file: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("received %q, want %q", got, data)
+	}
+	if _, ok := models.LookupFileInfo(sessionId, "other"); !ok {
+		t.Fatal("session expired during the slow upload")
+	}
+	if !tool.QuerySessionIsValid(sessionId) {
+		t.Fatal("receive session slot expired during the slow upload")
+	}
+
+	// Without activity the session does expire, so the upload above really outlived the TTL
+	time.Sleep(3 * ttl)
+	if _, ok := models.LookupFileInfo(sessionId, "other"); ok {
+		t.Fatal("idle session did not expire")
+	}
+}
+
+func TestResolveUploadTargetMaliciousNames(t *testing.T) {
+	folder := t.TempDir()
+	previousFolder := models.DefaultUploadFolder()
+	models.SetDefaultUploadFolder(folder)
+	t.Cleanup(func() { models.SetDefaultUploadFolder(previousFolder) })
+
+	const sessionId = "malicious-names"
+	sessionDir := filepath.Join(folder, sessionId)
+	tests := []struct {
+		name     string
+		fileName string
+		want     string // path below the session folder; empty means the name is rejected
+	}{
+		{name: "traversal", fileName: "../../escape.txt"},
+		{name: "traversal inside a folder", fileName: "album/../../escape.txt"},
+		{name: "backslash traversal", fileName: `..\..\escape.txt`},
+		{name: "drive letter", fileName: `C:\Windows\evil.dll`},
+		{name: "drive relative", fileName: "C:evil.txt"},
+		{name: "UNC path", fileName: `\\server\share\evil.txt`},
+		{name: "absolute path", fileName: "/etc/passwd"},
+		{name: "NUL bytes", fileName: "evil\x00.txt\x00", want: "evil.txt"},
+		{name: "reserved name", fileName: "CON.txt", want: "_CON.txt"},
+		{name: "reserved folder name", fileName: "nul/photo.jpg", want: filepath.Join("_nul", "photo.jpg")},
+		{name: "folder upload", fileName: "album/photo.jpg", want: filepath.Join("album", "photo.jpg")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveUploadTarget(sessionId, "file", types.FileInfo{FileName: tt.fileName})
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("resolveUploadTarget(%q) = %q, want an error", tt.fileName, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveUploadTarget(%q): %v", tt.fileName, err)
+			}
+			if want := filepath.Join(sessionDir, tt.want); got != want {
+				t.Fatalf("resolveUploadTarget(%q) = %q, want %q", tt.fileName, got, want)
+			}
+			if !strings.HasPrefix(got, sessionDir+string(filepath.Separator)) {
+				t.Fatalf("resolveUploadTarget(%q) = %q escapes %q", tt.fileName, got, sessionDir)
+			}
+		})
+	}
+}
+
+func TestStalledUploadRecordsTimeoutReason(t *testing.T) {
+	models.SetUploadStallTimeout(50 * time.Millisecond)
+	t.Cleanup(func() { models.SetUploadStallTimeout(models.DefaultUploadStallTimeout) })
+
+	folder := t.TempDir()
+	previousFolder := models.DefaultUploadFolder()
+	models.SetDefaultUploadFolder(folder)
+	t.Cleanup(func() { models.SetDefaultUploadFolder(previousFolder) })
+	models.SetConfirmRecvHandler(func(*types.PrepareUploadRequest, string) (bool, error) {
+		return true, nil
+	})
+	t.Cleanup(func() { models.SetConfirmRecvHandler(nil) })
+
+	request := &types.PrepareUploadRequest{
+		Info: types.DeviceInfo{Alias: "Stalled Sender", Version: "2.0", Fingerprint: "stalled-sender", Port: 53317, Protocol: "http"},
+		Files: map[string]types.FileInfo{
+			"stalled": {ID: "stalled", FileName: "stalled.txt", Size: 10, FileType: "text/plain"},
+		},
+	}
+	response, err := DefaultOnPrepareUpload(request, "", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("prepare-upload: %v", err)
+	}
+
+	// The pipe is never written to; the stall watchdog closes it to unblock the read
+	body, writer := io.Pipe()
+	t.Cleanup(func() { writer.Close() })
+	if err := DefaultOnUpload(response.SessionId, "stalled", "accepted", body, "127.0.0.1"); err == nil {
+		t.Fatal("stalled upload succeeded")
+	}
+	if got := models.GetCancelReason(response.SessionId); got != types.CancelReasonTimeout {
+		t.Errorf("cancel reason %q, want %q", got, types.CancelReasonTimeout)
+	}
+}
+
+func TestUploadMustMatchDeclaredFile(t *testing.T) {
+	folder := t.TempDir()
+	previousFolder := models.DefaultUploadFolder()
+	models.SetDefaultUploadFolder(folder)
+	t.Cleanup(func() { models.SetDefaultUploadFolder(previousFolder) })
+	models.SetConfirmRecvHandler(func(*types.PrepareUploadRequest, string) (bool, error) {
+		return true, nil
+	})
+	t.Cleanup(func() { models.SetConfirmRecvHandler(nil) })
+
+	sum := sha256.Sum256([]byte("abc"))
+	tests := []struct {
+		name     string
+		size     int64
+		sha256   string
+		body     string
+		wantKind string // "" means the upload is accepted
+	}{
+		{name: "empty file", size: 0, body: ""},
+		{name: "empty file with a body", size: 0, body: "unexpected", wantKind: types.IntegrityKindSize},
+		{name: "longer body", size: 3, body: "abcd", wantKind: types.IntegrityKindSize},
+		{name: "shorter body", size: 3, body: "ab", wantKind: types.IntegrityKindSize},
+		{name: "hash mismatch", size: 3, sha256: hex.EncodeToString(sum[:]), body: "abd", wantKind: types.IntegrityKindHash},
+		{name: "hash match", size: 3, sha256: hex.EncodeToString(sum[:]), body: "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &types.PrepareUploadRequest{
+				Info: types.DeviceInfo{Alias: "Sender", Version: "2.0", Fingerprint: "declared-sender", Port: 53317, Protocol: "http"},
+				Files: map[string]types.FileInfo{
+					"file": {ID: "file", FileName: "file.txt", Size: tt.size, SHA256: tt.sha256, FileType: "text/plain"},
+				},
+			}
+			response, err := DefaultOnPrepareUpload(request, "", "127.0.0.1")
+			if err != nil {
+				t.Fatalf("prepare-upload: %v", err)
+			}
+			t.Cleanup(func() { models.RemoveUploadSession(response.SessionId) })
+
+			err = DefaultOnUpload(response.SessionId, "file", "accepted", strings.NewReader(tt.body), "127.0.0.1")
+			_, statErr := os.Stat(filepath.Join(folder, response.SessionId, "file.txt"))
+			if tt.wantKind == "" {
+				if err != nil {
+					t.Fatalf("upload: %v", err)
+				}
+				if statErr != nil {
+					t.Fatalf("accepted file is missing: %v", statErr)
+				}
+				return
+			}
+			var integrityErr *types.IntegrityError
+			if !errors.As(err, &integrityErr) || integrityErr.Kind != tt.wantKind {
+				t.Fatalf("upload error %v, want a %s mismatch", err, tt.wantKind)
+			}
+			if !os.IsNotExist(statErr) {
+				t.Errorf("rejected file was left behind (stat error %v)", statErr)
+			}
+		})
+	}
+}
+
+func TestPrepareUploadRejectsTooManyFiles(t *testing.T) {
+	previous := tool.MaxFilesPerSession()
+	tool.SetMaxFilesPerSession(2)
+	t.Cleanup(func() { tool.SetMaxFilesPerSession(previous) })
+
+	files := make(map[string]types.FileInfo)
+	for _, id := range []string{"a", "b", "c"} {
+		files[id] = types.FileInfo{ID: id, FileName: id + ".txt", Size: 1, FileType: "text/plain"}
+	}
+	request := &types.PrepareUploadRequest{
+		Info:  types.DeviceInfo{Alias: "Bulk Sender", Version: "2.0", Fingerprint: "bulk-sender", Port: 53317, Protocol: "http"},
+		Files: files,
+	}
+	response, err := DefaultOnPrepareUpload(request, "", "127.0.0.1")
+	if !errors.Is(err, ErrTooManyFiles) {
+		t.Fatalf("prepare-upload error = %v, want ErrTooManyFiles", err)
+	}
+	if response != nil {
+		t.Fatalf("prepare-upload returned a session for a rejected request: %+v", response)
+	}
+}
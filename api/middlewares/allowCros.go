@@ -14,9 +14,10 @@ func AllowAllCORS() gin.HandlerFunc {
 		c.Header("Access-Control-Allow-Origin", origin)
 		c.Header("Access-Control-Allow-Headers", "Content-Type,AccessToken,X-CSRF-Token, Authorization, Token,X-Token,X-User-Id")
 		c.Header("Access-Control-Allow-Methods", "HEAD, POST, GET, OPTIONS,DELETE,PUT")
-		c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Content-Type, New-Token, New-Expires-At")
+		c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Content-Type, New-Token, New-Expires-At, X-Request-Id")
 		c.Header("Access-Control-Allow-Credentials", "true")
-		if method == "OPTIONS" {
+		// Only answer CORS preflights here; other OPTIONS requests (e.g. WebDAV discovery) reach their route
+		if method == "OPTIONS" && c.Request.Header.Get("Access-Control-Request-Method") != "" {
 			c.AbortWithStatus(http.StatusOK)
 		}
 		c.Next()
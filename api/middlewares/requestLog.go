@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/moyoez/localsend-go/tool"
+)
+
+// RequestIdHeader carries the request ID; an incoming value is reused so callers can correlate their own logs.
+const RequestIdHeader = "X-Request-Id"
+
+// slowRequestThreshold is the handler duration above which a request is logged as a warning; 0 disables the warning.
+var slowRequestThreshold = 10 * time.Second
+
+// SetSlowRequestThreshold sets the duration above which requests are logged as slow (0 disables).
+func SetSlowRequestThreshold(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	slowRequestThreshold = d
+}
+
+// RequestLog assigns a request ID, stores a request-scoped logger in the request context
+// (see tool.LoggerFromContext) and logs method/path/status/duration once the handler returns.
+func RequestLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(RequestIdHeader)
+		if requestId == "" || len(requestId) > 64 {
+			requestId = tool.GenerateShortSessionID()
+		}
+		c.Header(RequestIdHeader, requestId)
+		c.Set("requestId", requestId)
+		c.Request = c.Request.WithContext(tool.WithRequestLogger(c.Request.Context(), requestId))
+
+		start := time.Now()
+		c.Next()
+		duration := time.Since(start)
+
+		logger := tool.LoggerFromContext(c.Request.Context())
+		if slowRequestThreshold > 0 && duration > slowRequestThreshold {
+			logger.Warnf("[Request] Slow request: %s %s -> %d in %s", c.Request.Method, c.Request.URL.Path, c.Writer.Status(), duration)
+			return
+		}
+		logger.Debugf("[Request] %s %s -> %d in %s", c.Request.Method, c.Request.URL.Path, c.Writer.Status(), duration)
+	}
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sharedSecret is the secret RequireSharedSecret checks; empty means no secret is configured.
+var sharedSecret string
+
+// SetSharedSecret sets the secret required by RequireSharedSecret.
+func SetSharedSecret(secret string) {
+	sharedSecret = secret
+}
+
+// HasSharedSecret reports whether a shared secret is configured.
+func HasSharedSecret() bool {
+	return sharedSecret != ""
+}
+
+// RequireSharedSecret lets a request through when it carries the shared secret, as the password of
+// HTTP Basic auth (any user name, which is what file managers prompt for), a Bearer token or an
+// X-Shared-Secret header. Without a configured secret only local requests are allowed.
+func RequireSharedSecret(c *gin.Context) {
+	if sharedSecret == "" {
+		OnlyAllowLocal(c)
+		return
+	}
+	if secretMatches(requestSecret(c.Request)) {
+		c.Next()
+		return
+	}
+	c.Header("WWW-Authenticate", `Basic realm="localsend"`)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	c.Abort()
+}
+
+// requestSecret extracts the secret a request was sent with, empty if none.
+func requestSecret(r *http.Request) string {
+	if secret := r.Header.Get("X-Shared-Secret"); secret != "" {
+		return secret
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+func secretMatches(secret string) bool {
+	return secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(sharedSecret)) == 1
+}
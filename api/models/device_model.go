@@ -1,6 +1,7 @@
 package models
 
 import (
+	"crypto/tls"
 	"sync"
 
 	"github.com/moyoez/localsend-go/types"
@@ -9,8 +10,25 @@ import (
 var (
 	selfDeviceMu sync.RWMutex
 	selfDevice   *types.VersionMessage
+
+	serverCertMu sync.RWMutex
+	serverCert   *tls.Certificate
 )
 
+// SetServerCertificate sets the TLS certificate served by the HTTPS server.
+func SetServerCertificate(cert *tls.Certificate) {
+	serverCertMu.Lock()
+	defer serverCertMu.Unlock()
+	serverCert = cert
+}
+
+// GetServerCertificate returns the TLS certificate served by the HTTPS server.
+func GetServerCertificate() *tls.Certificate {
+	serverCertMu.RLock()
+	defer serverCertMu.RUnlock()
+	return serverCert
+}
+
 // SetSelfDevice sets the local device info used for user-side scanning.
 func SetSelfDevice(device *types.VersionMessage) {
 	selfDeviceMu.Lock()
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	downloadSlotsMu        sync.Mutex
+	maxConcurrentDownloads int // 0 means no limit
+	activeDownloads        int
+	// downloadRateLimit caps each share download in bytes per second; 0 means no limit
+	downloadRateLimit atomic.Int64
+)
+
+// SetMaxConcurrentDownloads sets how many share downloads may be served at once; further downloads get 503.
+// 0 or less means no limit.
+func SetMaxConcurrentDownloads(n int) {
+	downloadSlotsMu.Lock()
+	defer downloadSlotsMu.Unlock()
+	maxConcurrentDownloads = max(n, 0)
+}
+
+// AcquireDownloadSlot takes one of the SetMaxConcurrentDownloads slots, returning false when all are in use.
+// Each successful call must be paired with ReleaseDownloadSlot.
+func AcquireDownloadSlot() bool {
+	downloadSlotsMu.Lock()
+	defer downloadSlotsMu.Unlock()
+	if maxConcurrentDownloads > 0 && activeDownloads >= maxConcurrentDownloads {
+		return false
+	}
+	activeDownloads++
+	return true
+}
+
+// ReleaseDownloadSlot gives back a slot taken by AcquireDownloadSlot.
+func ReleaseDownloadSlot() {
+	downloadSlotsMu.Lock()
+	defer downloadSlotsMu.Unlock()
+	if activeDownloads > 0 {
+		activeDownloads--
+	}
+}
+
+// ActiveDownloads returns the number of share downloads being served.
+func ActiveDownloads() int {
+	downloadSlotsMu.Lock()
+	defer downloadSlotsMu.Unlock()
+	return activeDownloads
+}
+
+// SetDownloadRateLimit caps each share download at bytesPerSecond (0 or less means no limit).
+func SetDownloadRateLimit(bytesPerSecond int64) {
+	downloadRateLimit.Store(max(bytesPerSecond, 0))
+}
+
+// DownloadRateLimit returns the per-download limit in bytes per second, 0 when unlimited.
+func DownloadRateLimit() int64 {
+	return downloadRateLimit.Load()
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/moyoez/localsend-go/tool"
+)
+
+var (
+	encryptionMu sync.RWMutex
+	// encryptionKey encrypts received files at rest (AES-GCM, see tool.NewEncryptWriter); nil disables it
+	encryptionKey []byte
+)
+
+// SetEncryptionKey enables encryption at rest of received files with an AES key of 16, 24 or 32 bytes.
+// A nil key disables it; files saved while it was set stay encrypted.
+func SetEncryptionKey(key []byte) error {
+	if key != nil {
+		if _, err := tool.NewEncryptionAEAD(key); err != nil {
+			return err
+		}
+	}
+	encryptionMu.Lock()
+	defer encryptionMu.Unlock()
+	encryptionKey = bytes.Clone(key)
+	return nil
+}
+
+// EncryptionKey returns the key received files are encrypted with, or nil when encryption is off.
+func EncryptionKey() []byte {
+	encryptionMu.RLock()
+	defer encryptionMu.RUnlock()
+	return encryptionKey
+}
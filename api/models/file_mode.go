@@ -0,0 +1,42 @@
+package models
+
+import (
+	"os"
+	"sync"
+)
+
+var (
+	fileModeMu sync.RWMutex
+	// receivedFileMode is the permission of saved files, applied with chmod so the umask cannot widen or narrow it
+	receivedFileMode os.FileMode = 0o644
+	// receivedDirMode is the permission of directories created for received files
+	receivedDirMode os.FileMode = 0o755
+)
+
+// SetReceivedFileMode sets the permission bits of received files (default 0644).
+func SetReceivedFileMode(mode os.FileMode) {
+	fileModeMu.Lock()
+	defer fileModeMu.Unlock()
+	receivedFileMode = mode.Perm()
+}
+
+// ReceivedFileMode returns the permission bits of received files.
+func ReceivedFileMode() os.FileMode {
+	fileModeMu.RLock()
+	defer fileModeMu.RUnlock()
+	return receivedFileMode
+}
+
+// SetReceivedDirMode sets the permission bits of directories created for received files (default 0755).
+func SetReceivedDirMode(mode os.FileMode) {
+	fileModeMu.Lock()
+	defer fileModeMu.Unlock()
+	receivedDirMode = mode.Perm()
+}
+
+// ReceivedDirMode returns the permission bits of directories created for received files.
+func ReceivedDirMode() os.FileMode {
+	fileModeMu.RLock()
+	defer fileModeMu.RUnlock()
+	return receivedDirMode
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/moyoez/localsend-go/tool"
+)
+
+// PartialSuffix marks incomplete received files: chunked uploads in progress and, with
+// SetKeepPartialOnCancel, plain uploads that were cancelled midway.
+const PartialSuffix = ".part"
+
+var (
+	keepPartialOnCancel atomic.Bool
+	// partialMaxAge holds the age in nanoseconds after which kept partial files are deleted; 0 keeps them
+	partialMaxAge atomic.Int64
+
+	keptPartialsMu sync.Mutex
+	// keptPartials holds the partial files kept by cancelled uploads of this process, the only ones cleanup deletes
+	keptPartials = make(map[string]struct{})
+)
+
+// SetKeepPartialOnCancel sets whether a cancelled upload keeps what it received as "<name>.part"
+// for a later resumed transfer instead of deleting it. Encrypted uploads are always deleted,
+// since an unfinished encrypted stream cannot be continued.
+func SetKeepPartialOnCancel(keep bool) {
+	keepPartialOnCancel.Store(keep)
+}
+
+// KeepPartialOnCancel reports whether cancelled uploads keep their partial file.
+func KeepPartialOnCancel() bool {
+	return keepPartialOnCancel.Load()
+}
+
+// SetPartialMaxAge sets how old a kept partial file may get before RunPartialCleanup deletes it (0 disables cleanup).
+func SetPartialMaxAge(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	partialMaxAge.Store(int64(d))
+}
+
+// RecordKeptPartial remembers a partial file kept by a cancelled upload, so RunPartialCleanup may delete it later.
+func RecordKeptPartial(path string) {
+	keptPartialsMu.Lock()
+	defer keptPartialsMu.Unlock()
+	keptPartials[path] = struct{}{}
+}
+
+// RunPartialCleanup periodically deletes the partial files kept by this process (see RecordKeptPartial)
+// once they were not modified for the partial max age. Returns immediately unless partials are kept
+// on cancel and a max age is set.
+func RunPartialCleanup() {
+	maxAge := time.Duration(partialMaxAge.Load())
+	if maxAge <= 0 || !KeepPartialOnCancel() {
+		return
+	}
+	interval := min(max(maxAge/4, time.Minute), time.Hour)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if removed := CleanupPartialFiles(maxAge); removed > 0 {
+			tool.DefaultLogger.Infof("[Partial] Removed %d partial file(s) older than %s", removed, maxAge)
+		}
+	}
+}
+
+// CleanupPartialFiles deletes the recorded partial files not modified for maxAge and returns how many.
+// Files that are gone (resumed, moved or deleted by the user) are forgotten.
+func CleanupPartialFiles(maxAge time.Duration) int {
+	cutoff := time.Now().Add(-maxAge)
+	keptPartialsMu.Lock()
+	defer keptPartialsMu.Unlock()
+	removed := 0
+	for path := range keptPartials {
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				delete(keptPartials, path)
+			}
+			continue
+		}
+		if !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			tool.DefaultLogger.Warnf("[Partial] Failed to delete %s: %v", path, err)
+			continue
+		}
+		delete(keptPartials, path)
+		removed++
+	}
+	return removed
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+var (
+	savePathTemplateMu sync.RWMutex
+	// savePathDirSegments is the directory part of the save path template, split on "/"; nil means no template
+	savePathDirSegments []string
+)
+
+// savePathPlaceholders lists the placeholders a save path template may use.
+var savePathPlaceholders = []string{"{root}", "{date}", "{sender}", "{sessionId}", "{type}", "{filename}"}
+
+// SetSavePathTemplate sets where received files are saved, e.g. "{root}/{date}/{sender}/{filename}".
+// Placeholders: {root} upload folder, {date} YYYY-MM-DD, {sender} sender alias, {sessionId},
+// {type} MIME top-level type (e.g. "image") and {filename} the original name, which must be the last segment.
+// Paths are always under the upload folder; a leading {root} is optional. Empty clears the template.
+// When set, the template replaces the per-session folder layout.
+func SetSavePathTemplate(template string) error {
+	template = strings.TrimSpace(template)
+	if template == "" {
+		savePathTemplateMu.Lock()
+		savePathDirSegments = nil
+		savePathTemplateMu.Unlock()
+		return nil
+	}
+	segments := strings.Split(filepath.ToSlash(template), "/")
+	if segments[len(segments)-1] != "{filename}" {
+		return fmt.Errorf("save path template must end with {filename}")
+	}
+	segments = segments[:len(segments)-1]
+	if len(segments) > 0 && segments[0] == "{root}" {
+		segments = segments[1:]
+	}
+	for _, seg := range segments {
+		if seg == "." || seg == ".." {
+			return fmt.Errorf("save path template must not contain %q", seg)
+		}
+		rest := seg
+		for _, placeholder := range savePathPlaceholders {
+			rest = strings.ReplaceAll(rest, placeholder, "")
+		}
+		if strings.Contains(seg, "{root}") || strings.Contains(seg, "{filename}") {
+			return fmt.Errorf("{root} may only start and {filename} only end the save path template")
+		}
+		if strings.ContainsAny(rest, "{}") {
+			return fmt.Errorf("unknown placeholder in save path template segment %q", seg)
+		}
+	}
+	savePathTemplateMu.Lock()
+	savePathDirSegments = segments
+	savePathTemplateMu.Unlock()
+	return nil
+}
+
+// HasSavePathTemplate reports whether a save path template is set.
+func HasSavePathTemplate() bool {
+	savePathTemplateMu.RLock()
+	defer savePathTemplateMu.RUnlock()
+	return savePathDirSegments != nil
+}
+
+// RenderSaveDir returns the directory a file of the session is saved to according to the template.
+// Every placeholder value is sanitized to a single path segment, so the result stays under DefaultUploadFolder.
+func RenderSaveDir(sessionId string, info types.FileInfo, now time.Time) string {
+	savePathTemplateMu.RLock()
+	segments := savePathDirSegments
+	savePathTemplateMu.RUnlock()
+
+	sender := "unknown"
+	if device, ok := GetUploadSessionSender(sessionId); ok && device.Alias != "" {
+		sender = device.Alias
+	}
+	fileType := "other"
+	if major, _, _ := strings.Cut(info.FileType, "/"); strings.TrimSpace(major) != "" {
+		fileType = strings.ToLower(strings.TrimSpace(major))
+	}
+	replacer := strings.NewReplacer(
+		"{date}", savePathComponent(now.Format("2006-01-02")),
+		"{sender}", savePathComponent(sender),
+		"{sessionId}", savePathComponent(sessionId),
+		"{type}", savePathComponent(fileType),
+	)
+
+	dir := DefaultUploadFolder()
+	for _, seg := range segments {
+		if strings.TrimSpace(seg) == "" {
+			continue
+		}
+		dir = filepath.Join(dir, savePathComponent(replacer.Replace(seg)))
+	}
+	return dir
+}
+
+// IdentityUploadFolder returns root namespaced by the self device, e.g. "uploads/Living Room TV",
+// so several identities sharing one upload root keep their files apart. The alias is used as one
+// sanitized segment, falling back to the fingerprint when it is empty.
+func IdentityUploadFolder(root string, self *types.VersionMessage) string {
+	if self == nil {
+		return root
+	}
+	name := strings.TrimSpace(self.Alias)
+	if name == "" {
+		name = self.Fingerprint
+	}
+	if name == "" {
+		return root
+	}
+	return filepath.Join(root, savePathComponent(name))
+}
+
+// savePathComponent turns a value into one safe path segment.
+func savePathComponent(v string) string {
+	v = strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(v)
+	v = tool.SanitizeFileName(v)
+	if v == "." || v == ".." {
+		return "_"
+	}
+	return v
+}
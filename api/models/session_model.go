@@ -3,7 +3,11 @@ package models
 import (
 	"context"
 	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	ttlworker "github.com/FloatTech/ttl"
 	"github.com/moyoez/localsend-go/tool"
@@ -12,7 +16,6 @@ import (
 
 var (
 	uploadSessionMu        sync.RWMutex
-	DefaultUploadFolder    = "uploads"
 	DoNotMakeSessionFolder bool // if true, save under upload folder only; same filename -> name-2.ext, name-3.ext, ...
 	uploadSessions         = ttlworker.NewCache[string, map[string]types.FileInfo](tool.DefaultTTL)
 	uploadValidated        = ttlworker.NewCache[string, bool](tool.DefaultTTL)
@@ -25,10 +28,123 @@ var (
 	uploadStats = ttlworker.NewCache[string, *types.SessionUploadStats](tool.DefaultTTL)
 	// fileSavePaths stores actual save path per (sessionId, fileId) for notifications
 	fileSavePaths = ttlworker.NewCache[string, map[string]string](tool.DefaultTTL)
+	// uploadSenders stores the sender device info per session (for audit records and notifications)
+	uploadSenders = ttlworker.NewCache[string, types.DeviceInfo](tool.DefaultTTL)
+	// uploadReceipts stores per-file completion receipts per session; kept after the session ends so senders can poll
+	uploadReceipts = ttlworker.NewCache[string, map[string]types.UploadReceipt](tool.DefaultTTL)
 	// resolvedReceiveFolders stores resolved top-level folder name per (sessionId, firstSegment) when folder name collides
 	resolvedReceiveFolders = ttlworker.NewCache[string, map[string]string](tool.DefaultTTL)
+	// uploadChunks tracks received chunk indexes per (sessionId, fileId) for chunked uploads
+	uploadChunks = ttlworker.NewCache[string, map[string]*types.UploadChunkProgress](tool.DefaultTTL)
+	// cancelReasons stores why a session was cancelled; kept after the session ends
+	cancelReasons = ttlworker.NewCache[string, string](tool.DefaultTTL)
+	// receivedSavePaths keeps every saved file path per session after the session ends, for DELETE /received
+	receivedSavePaths = ttlworker.NewCache[string, []string](ReceivedSavePathsTTL)
+	// receivedFiles keeps fileId -> saved path and recorded hash per session after the session ends, for verify-received
+	receivedFiles = ttlworker.NewCache[string, map[string]types.ReceivedFileRecord](ReceivedSavePathsTTL)
 )
 
+// defaultUploadFolder holds the folder received files are saved under. It is swapped when the
+// config is reloaded while uploads read it, so it is only accessed through DefaultUploadFolder.
+var defaultUploadFolder atomic.Value
+
+// DefaultUploadFolder returns the folder received files are saved under.
+func DefaultUploadFolder() string {
+	return defaultUploadFolder.Load().(string)
+}
+
+// SetDefaultUploadFolder sets the folder received files are saved under; an empty folder is ignored.
+func SetDefaultUploadFolder(folder string) {
+	if folder != "" {
+		defaultUploadFolder.Store(folder)
+	}
+}
+
+// DefaultUploadStallTimeout is how long an upload may go without receiving a byte before it is aborted.
+const DefaultUploadStallTimeout = 60 * time.Second
+
+// uploadStallTimeout holds the stall timeout in nanoseconds; 0 disables stall detection.
+var uploadStallTimeout atomic.Int64
+
+func init() {
+	defaultUploadFolder.Store("uploads")
+	uploadStallTimeout.Store(int64(DefaultUploadStallTimeout))
+	sessionTTL.Store(int64(tool.DefaultTTL))
+}
+
+// SetUploadStallTimeout sets how long an upload may receive no data before its session is cancelled
+// and the upload fails. 0 disables stall detection.
+func SetUploadStallTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	uploadStallTimeout.Store(int64(d))
+}
+
+// UploadStallTimeout returns the upload stall timeout, 0 when stall detection is disabled.
+func UploadStallTimeout() time.Duration {
+	return time.Duration(uploadStallTimeout.Load())
+}
+
+// sessionTTL holds the idle lifetime of receive-side session state in nanoseconds, see SetSessionTTL.
+var sessionTTL atomic.Int64
+
+// SessionTTL returns the idle lifetime of receive-side session state.
+func SessionTTL() time.Duration {
+	return time.Duration(sessionTTL.Load())
+}
+
+// ReceivedSavePathsTTL is how long saved paths of a finished session are remembered for cleanup.
+const ReceivedSavePathsTTL = 30 * 24 * time.Hour
+
+// SetSessionTTL sets the idle lifetime of receive-side session state. Entries are refreshed on activity
+// (see TouchUploadSession), so this bounds idle time, not total transfer time.
+// Meant to be called at startup; live entries are moved to the new caches and the old ones destroyed.
+func SetSessionTTL(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	uploadSessionMu.Lock()
+	defer uploadSessionMu.Unlock()
+	sessionTTL.Store(int64(d))
+	uploadSessions = tool.ReplaceCache(uploadSessions, d, [4]func(string, map[string]types.FileInfo){})
+	uploadValidated = tool.ReplaceCache(uploadValidated, d, [4]func(string, bool){})
+	confirmRecvChans = tool.ReplaceCache(confirmRecvChans, d, [4]func(string, chan types.ConfirmResult){})
+	textReceivedDismissChans = tool.ReplaceCache(textReceivedDismissChans, d, [4]func(string, chan struct{}){})
+	v1Sessions = tool.ReplaceCache(v1Sessions, d, [4]func(string, string){})
+	sessionContexts = tool.ReplaceCache(sessionContexts, d, [4]func(string, *types.SessionContext){})
+	uploadStats = tool.ReplaceCache(uploadStats, d, [4]func(string, *types.SessionUploadStats){})
+	fileSavePaths = tool.ReplaceCache(fileSavePaths, d, [4]func(string, map[string]string){})
+	uploadSenders = tool.ReplaceCache(uploadSenders, d, [4]func(string, types.DeviceInfo){})
+	uploadReceipts = tool.ReplaceCache(uploadReceipts, d, [4]func(string, map[string]types.UploadReceipt){})
+	resolvedReceiveFolders = tool.ReplaceCache(resolvedReceiveFolders, d, [4]func(string, map[string]string){})
+	uploadChunks = tool.ReplaceCache(uploadChunks, d, [4]func(string, map[string]*types.UploadChunkProgress){})
+	cancelReasons = tool.ReplaceCache(cancelReasons, d, [4]func(string, string){})
+	tool.SetReceiveSessionTTL(d)
+}
+
+// TouchUploadSession refreshes the TTL of all state belonging to an active session,
+// so long transfers are not evicted mid-way. Cache Get resets the expiry.
+func TouchUploadSession(sessionId string) {
+	tool.TouchActivity()
+	uploadSessionMu.RLock()
+	defer uploadSessionMu.RUnlock()
+	touchUploadSessionLocked(sessionId)
+}
+
+// touchUploadSessionLocked refreshes session TTLs; caller must hold uploadSessionMu.
+func touchUploadSessionLocked(sessionId string) {
+	tool.SessionCache.Get(sessionId)
+	uploadSessions.Get(sessionId)
+	uploadValidated.Get(sessionId)
+	sessionContexts.Get(sessionId)
+	uploadStats.Get(sessionId)
+	fileSavePaths.Get(sessionId)
+	uploadSenders.Get(sessionId)
+	resolvedReceiveFolders.Get(sessionId)
+	uploadChunks.Get(sessionId)
+}
+
 func CacheUploadSession(sessionId string, files map[string]types.FileInfo) {
 	uploadSessionMu.Lock()
 	defer uploadSessionMu.Unlock()
@@ -37,6 +153,21 @@ func CacheUploadSession(sessionId string, files map[string]types.FileInfo) {
 	uploadSessions.Set(sessionId, copied)
 }
 
+// SetUploadSessionSender stores the sender device info for a receive session.
+func SetUploadSessionSender(sessionId string, sender types.DeviceInfo) {
+	uploadSessionMu.Lock()
+	defer uploadSessionMu.Unlock()
+	uploadSenders.Set(sessionId, sender)
+}
+
+// GetUploadSessionSender returns the sender device info for a receive session, if known.
+func GetUploadSessionSender(sessionId string) (types.DeviceInfo, bool) {
+	uploadSessionMu.RLock()
+	defer uploadSessionMu.RUnlock()
+	sender := uploadSenders.Get(sessionId)
+	return sender, sender.Fingerprint != "" || sender.Alias != ""
+}
+
 func LookupFileInfo(sessionId, fileId string) (types.FileInfo, bool) {
 	uploadSessionMu.RLock()
 	defer uploadSessionMu.RUnlock()
@@ -85,6 +216,12 @@ func MarkFileUploadedAndCheckComplete(sessionId, fileId string, success bool) (r
 	if files == nil {
 		return 0, true, nil
 	}
+	// A fileId that is no longer pending was already counted; counting it again would skew the stats
+	if _, pending := files[fileId]; !pending {
+		return len(files), false, nil
+	}
+	// A file finished: keep the rest of the session alive for the remaining files
+	touchUploadSessionLocked(sessionId)
 
 	// Update stats
 	sessionStats := uploadStats.Get(sessionId)
@@ -97,12 +234,28 @@ func MarkFileUploadedAndCheckComplete(sessionId, fileId string, success bool) (r
 
 	if success {
 		sessionStats.SuccessFiles++
+		sessionStats.SuccessBytes += files[fileId].Size
+		if len(sessionStats.SuccessNames) < types.HistoryMaxFileNames {
+			sessionStats.SuccessNames = append(sessionStats.SuccessNames, files[fileId].FileName)
+		}
 	} else {
 		sessionStats.FailedFiles++
 		sessionStats.FailedFileIds = append(sessionStats.FailedFileIds, fileId)
 	}
 	uploadStats.Set(sessionId, sessionStats)
 
+	receipts := uploadReceipts.Get(sessionId)
+	if receipts == nil {
+		receipts = make(map[string]types.UploadReceipt)
+	}
+	receipts[fileId] = types.UploadReceipt{
+		SessionId:   sessionId,
+		FileId:      fileId,
+		Success:     success,
+		CompletedAt: time.Now().Format(time.RFC3339),
+	}
+	uploadReceipts.Set(sessionId, receipts)
+
 	// Remove from pending files
 	delete(files, fileId)
 	remaining = len(files)
@@ -118,6 +271,18 @@ func MarkFileUploadedAndCheckComplete(sessionId, fileId string, success bool) (r
 	return remaining, isLast, sessionStats
 }
 
+// GetUploadReceipt returns the completion receipt for a file, if it has finished (success or failure).
+func GetUploadReceipt(sessionId, fileId string) (types.UploadReceipt, bool) {
+	uploadSessionMu.RLock()
+	defer uploadSessionMu.RUnlock()
+	receipts := uploadReceipts.Get(sessionId)
+	if receipts == nil {
+		return types.UploadReceipt{}, false
+	}
+	receipt, ok := receipts[fileId]
+	return receipt, ok
+}
+
 // GetSessionStats returns the upload statistics for a session
 func GetSessionStats(sessionId string) *types.SessionUploadStats {
 	uploadSessionMu.RLock()
@@ -135,6 +300,64 @@ func SetFileSavePath(sessionId, fileId, savePath string) {
 		fileSavePaths.Set(sessionId, m)
 	}
 	m[fileId] = savePath
+	receivedSavePaths.Set(sessionId, append(receivedSavePaths.Get(sessionId), savePath))
+	records := receivedFiles.Get(sessionId)
+	if records == nil {
+		records = make(map[string]types.ReceivedFileRecord)
+	}
+	record := records[fileId]
+	record.SavePath = savePath
+	records[fileId] = record
+	receivedFiles.Set(sessionId, records)
+}
+
+// SetReceivedFileSHA256 records the hash a saved file was verified against, for later verify-received checks.
+func SetReceivedFileSHA256(sessionId, fileId, sha256 string) {
+	uploadSessionMu.Lock()
+	defer uploadSessionMu.Unlock()
+	records := receivedFiles.Get(sessionId)
+	if records == nil {
+		records = make(map[string]types.ReceivedFileRecord)
+	}
+	record := records[fileId]
+	record.SHA256 = sha256
+	records[fileId] = record
+	receivedFiles.Set(sessionId, records)
+}
+
+// GetReceivedFiles returns a copy of fileId -> saved file record for the session, including after it has ended.
+func GetReceivedFiles(sessionId string) map[string]types.ReceivedFileRecord {
+	uploadSessionMu.RLock()
+	defer uploadSessionMu.RUnlock()
+	m := receivedFiles.Get(sessionId)
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[string]types.ReceivedFileRecord, len(m))
+	maps.Copy(out, m)
+	return out
+}
+
+// GetReceivedSavePaths returns every path saved for the session, including after it has ended.
+func GetReceivedSavePaths(sessionId string) []string {
+	uploadSessionMu.RLock()
+	defer uploadSessionMu.RUnlock()
+	return slices.Clone(receivedSavePaths.Get(sessionId))
+}
+
+// ForgetReceivedSavePaths drops the remembered save paths of a session once they have been deleted.
+func ForgetReceivedSavePaths(sessionId string) {
+	uploadSessionMu.Lock()
+	defer uploadSessionMu.Unlock()
+	receivedSavePaths.Delete(sessionId)
+	receivedFiles.Delete(sessionId)
+}
+
+// IsUploadSessionActive reports whether the session is still receiving files.
+func IsUploadSessionActive(sessionId string) bool {
+	uploadSessionMu.RLock()
+	defer uploadSessionMu.RUnlock()
+	return uploadSessions.Get(sessionId) != nil
 }
 
 // GetFileSavePath returns the stored save path for a file, if any.
@@ -185,6 +408,58 @@ func SetResolvedReceiveFolder(sessionId, firstSegment, resolved string) {
 	m[firstSegment] = resolved
 }
 
+// StartUploadChunkProgress registers the chunk progress of a file and returns the one in effect.
+// An existing progress with the same chunk layout wins, so concurrent first chunks share one part file;
+// one with a different layout is replaced, since its chunks cannot be reused.
+func StartUploadChunkProgress(sessionId, fileId string, progress *types.UploadChunkProgress) *types.UploadChunkProgress {
+	uploadSessionMu.Lock()
+	defer uploadSessionMu.Unlock()
+	m := uploadChunks.Get(sessionId)
+	if m == nil {
+		m = make(map[string]*types.UploadChunkProgress)
+		uploadChunks.Set(sessionId, m)
+	}
+	if p, ok := m[fileId]; ok && p.Total == progress.Total && p.ChunkSize == progress.ChunkSize {
+		return p
+	}
+	if progress.Received == nil {
+		progress.Received = make(map[int]bool, progress.Total)
+	}
+	m[fileId] = progress
+	return progress
+}
+
+// LookupUploadChunkProgress returns the chunk status of a file without creating it.
+func LookupUploadChunkProgress(sessionId, fileId string) (types.UploadChunkProgress, bool) {
+	uploadSessionMu.RLock()
+	defer uploadSessionMu.RUnlock()
+	m := uploadChunks.Get(sessionId)
+	if m == nil || m[fileId] == nil {
+		return types.UploadChunkProgress{}, false
+	}
+	p := *m[fileId]
+	p.Received = maps.Clone(p.Received)
+	return p, true
+}
+
+// MarkUploadChunkReceived records that chunk index of a file has been written.
+func MarkUploadChunkReceived(sessionId, fileId string, index int) {
+	uploadSessionMu.Lock()
+	defer uploadSessionMu.Unlock()
+	if m := uploadChunks.Get(sessionId); m != nil && m[fileId] != nil {
+		m[fileId].Received[index] = true
+	}
+}
+
+// RemoveUploadChunkProgress drops the chunk progress of a file once it has been assembled or abandoned.
+func RemoveUploadChunkProgress(sessionId, fileId string) {
+	uploadSessionMu.Lock()
+	defer uploadSessionMu.Unlock()
+	if m := uploadChunks.Get(sessionId); m != nil {
+		delete(m, fileId)
+	}
+}
+
 // CleanupSessionStats removes the upload statistics for a session
 func CleanupSessionStats(sessionId string) {
 	uploadSessionMu.Lock()
@@ -200,6 +475,10 @@ func RemoveUploadSession(sessionId string) {
 	confirmRecvChans.Delete(sessionId)
 	fileSavePaths.Delete(sessionId)
 	resolvedReceiveFolders.Delete(sessionId)
+	uploadSenders.Delete(sessionId)
+	uploadChunks.Delete(sessionId)
+	// Free the receive slot taken by JoinSession
+	tool.DestorySession(sessionId)
 	// Cancel the session context to interrupt ongoing uploads
 	if sessCtx := sessionContexts.Get(sessionId); sessCtx != nil {
 		sessCtx.Cancel()
@@ -207,6 +486,28 @@ func RemoveUploadSession(sessionId string) {
 	}
 }
 
+// SetCancelReason records why the session was cancelled. Reasons longer than
+// types.MaxCancelReasonLength are truncated; an empty reason is not stored.
+func SetCancelReason(sessionId, reason string) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return
+	}
+	if len(reason) > types.MaxCancelReasonLength {
+		reason = strings.ToValidUTF8(reason[:types.MaxCancelReasonLength], "")
+	}
+	uploadSessionMu.Lock()
+	defer uploadSessionMu.Unlock()
+	cancelReasons.Set(sessionId, reason)
+}
+
+// GetCancelReason returns the reason the session was cancelled with, or "" if none was given.
+func GetCancelReason(sessionId string) string {
+	uploadSessionMu.RLock()
+	defer uploadSessionMu.RUnlock()
+	return cancelReasons.Get(sessionId)
+}
+
 func IsSessionValidated(sessionId string) bool {
 	uploadSessionMu.RLock()
 	defer uploadSessionMu.RUnlock()
@@ -219,6 +520,29 @@ func MarkSessionValidated(sessionId string) {
 	uploadValidated.Set(sessionId, true)
 }
 
+// ConfirmRecvHandler decides programmatically whether an incoming transfer is accepted.
+// Returning an error means "no decision": the regular notify-and-wait confirmation is used instead.
+type ConfirmRecvHandler func(req *types.PrepareUploadRequest, remoteAddr string) (bool, error)
+
+var (
+	confirmRecvHandlerMu sync.RWMutex
+	confirmRecvHandler   ConfirmRecvHandler
+)
+
+// SetConfirmRecvHandler installs a handler consulted before the confirm_recv notification; nil removes it.
+func SetConfirmRecvHandler(handler ConfirmRecvHandler) {
+	confirmRecvHandlerMu.Lock()
+	defer confirmRecvHandlerMu.Unlock()
+	confirmRecvHandler = handler
+}
+
+// GetConfirmRecvHandler returns the installed confirm handler, or nil.
+func GetConfirmRecvHandler() ConfirmRecvHandler {
+	confirmRecvHandlerMu.RLock()
+	defer confirmRecvHandlerMu.RUnlock()
+	return confirmRecvHandler
+}
+
 func SetConfirmRecvChannel(sessionId string, ch chan types.ConfirmResult) {
 	uploadSessionMu.Lock()
 	defer uploadSessionMu.Unlock()
@@ -319,6 +643,16 @@ func GetSessionContext(sessionId string) context.Context {
 	return sessCtx.Ctx
 }
 
+// CancelSessionContext interrupts every ongoing upload of the session and makes further ones fail
+// as cancelled. Unlike RemoveUploadSession it keeps the session state until it expires.
+func CancelSessionContext(sessionId string) {
+	uploadSessionMu.RLock()
+	defer uploadSessionMu.RUnlock()
+	if sessCtx := sessionContexts.Get(sessionId); sessCtx != nil {
+		sessCtx.Cancel()
+	}
+}
+
 // IsSessionCancelled checks if the session has been cancelled
 func IsSessionCancelled(sessionId string) bool {
 	ctx := GetSessionContext(sessionId)
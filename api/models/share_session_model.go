@@ -1,6 +1,12 @@
 package models
 
 import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,27 +16,148 @@ import (
 )
 
 const (
-	ShareSessionTTL = 3600 * time.Second // 1 hour
+	ShareSessionTTL  = 3600 * time.Second // 1 hour
+	DownloadTokenTTL = 10 * time.Minute   // idle lifetime, refreshed on each use
 )
 
 var (
 	shareSessionMu        sync.RWMutex
-	shareSessions         = ttlworker.NewCache[string, *types.ShareSession](ShareSessionTTL)
+	shareSessions         = newShareSessionCache()
+	persistentShares      = make(map[string]*types.ShareSession) // persistent sessions, never expire
 	confirmDownloadChans  = ttlworker.NewCache[string, chan types.ConfirmResult](tool.DefaultTTL)
-	confirmedDownloadSess = ttlworker.NewCache[string, bool](ShareSessionTTL) // confirmed sessions.
+	confirmedDownloadSess = ttlworker.NewCache[string, bool](ShareSessionTTL)    // confirmed sessions.
+	downloadTokens        = ttlworker.NewCache[string, string](DownloadTokenTTL) // token -> sessionId
+	downloadTokenByClient = ttlworker.NewCache[string, string](DownloadTokenTTL) // confirmKey -> token
+	downloadTokenOwners   = ttlworker.NewCache[string, string](DownloadTokenTTL) // token -> clientKey it was issued to
+
+	// shareSessionIndex mirrors the expiring sessions for listing, since ranging over the cache would
+	// refresh their TTL. Entries are dropped by the cache's delete hook, so it has its own lock.
+	shareSessionIndexMu sync.Mutex
+	shareSessionIndex   = make(map[string]*types.ShareSession)
+
+	// shareUploadsDir holds the files uploaded with share sessions, one subfolder per session
+	shareUploadsDir = "share-uploads"
+	// shareUploadDirMu serializes picking and creating session subfolders, so two sessions never share one
+	shareUploadDirMu sync.Mutex
 )
 
-// CacheShareSession stores a share session
+// newShareSessionCache creates the expiring share session cache; deleted or expired sessions leave the listing index.
+func newShareSessionCache() *ttlworker.Cache[string, *types.ShareSession] {
+	return ttlworker.NewCacheOn(ShareSessionTTL, [4]func(string, *types.ShareSession){
+		nil, nil, func(sessionId string, session *types.ShareSession) {
+			removeShareUploadDir(session)
+			shareSessionIndexMu.Lock()
+			defer shareSessionIndexMu.Unlock()
+			delete(shareSessionIndex, sessionId)
+		}, nil,
+	})
+}
+
+// CreateShareUploadDir creates the folder for files uploaded with a share session under share-uploads/.
+// It is named after label, sanitized to a single folder name, or after the session id when label is empty;
+// a name already taken by another session gets a -2, -3, ... suffix so closing one never removes the other's files.
+func CreateShareUploadDir(label, sessionId string) (string, error) {
+	name := shareUploadLabel(label)
+	if name == "" {
+		name = NormalizeShareSessionId(sessionId)
+	}
+	shareUploadDirMu.Lock()
+	defer shareUploadDirMu.Unlock()
+	if err := os.MkdirAll(shareUploadsDir, 0o755); err != nil {
+		return "", fmt.Errorf("create share uploads folder: %w", err)
+	}
+	dir := filepath.Join(shareUploadsDir, tool.NextAvailableDir(shareUploadsDir, name))
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return "", fmt.Errorf("create share upload folder: %w", err)
+	}
+	return dir, nil
+}
+
+// shareUploadLabel turns a user-supplied label into a single folder name; separators cannot nest or escape
+// share-uploads/. Returns "" for a blank label.
+func shareUploadLabel(label string) string {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return ""
+	}
+	return tool.SanitizeFileName(strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(label))
+}
+
+// removeShareUploadDir deletes the uploaded files of a closed share session.
+func removeShareUploadDir(session *types.ShareSession) {
+	if session == nil || session.UploadDir == "" {
+		return
+	}
+	if err := os.RemoveAll(session.UploadDir); err != nil {
+		tool.DefaultLogger.Warnf("[ShareSession] Failed to remove upload folder %s: %v", session.UploadDir, err)
+	}
+}
+
+// NormalizeShareSessionId returns the form share session ids are stored and looked up in: trimmed of spaces
+// and trailing slashes (as left by copied URLs) and lowercased, so lookups do not depend on the caller's case.
+func NormalizeShareSessionId(sessionId string) string {
+	return strings.ToLower(strings.TrimRight(strings.TrimSpace(sessionId), "/"))
+}
+
+// CacheShareSession stores a share session; persistent sessions are kept until removed.
+// The session id is normalized with NormalizeShareSessionId.
 func CacheShareSession(session *types.ShareSession) {
+	session.SessionId = NormalizeShareSessionId(session.SessionId)
 	shareSessionMu.Lock()
 	defer shareSessionMu.Unlock()
+	if session.Persistent {
+		persistentShares[session.SessionId] = session
+		return
+	}
 	shareSessions.Set(session.SessionId, session)
+	shareSessionIndexMu.Lock()
+	shareSessionIndex[session.SessionId] = session
+	shareSessionIndexMu.Unlock()
+}
+
+// ListShareSessions returns a summary of every open share session, oldest first, without refreshing their TTL.
+func ListShareSessions() []types.ShareSessionSummary {
+	shareSessionMu.RLock()
+	defer shareSessionMu.RUnlock()
+	shareSessionIndexMu.Lock()
+	sessions := make([]*types.ShareSession, 0, len(shareSessionIndex)+len(persistentShares))
+	for _, session := range shareSessionIndex {
+		sessions = append(sessions, session)
+	}
+	shareSessionIndexMu.Unlock()
+	for _, session := range persistentShares {
+		sessions = append(sessions, session)
+	}
+
+	out := make([]types.ShareSessionSummary, 0, len(sessions))
+	for _, session := range sessions {
+		summary := types.ShareSessionSummary{
+			SessionId:    session.SessionId,
+			FileCount:    len(session.Files),
+			PinProtected: session.Pin != "",
+			AutoAccept:   session.AutoAccept,
+			Persistent:   session.Persistent,
+			CreatedAt:    session.CreatedAt,
+		}
+		for _, entry := range session.Files {
+			summary.TotalSize += entry.FileInfo.Size
+		}
+		out = append(out, summary)
+	}
+	slices.SortFunc(out, func(a, b types.ShareSessionSummary) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return out
 }
 
 // GetShareSession retrieves a share session by ID
 func GetShareSession(sessionId string) (*types.ShareSession, bool) {
+	sessionId = NormalizeShareSessionId(sessionId)
 	shareSessionMu.RLock()
 	defer shareSessionMu.RUnlock()
+	if sess, ok := persistentShares[sessionId]; ok {
+		return sess, true
+	}
 	sess := shareSessions.Get(sessionId)
 	if sess == nil {
 		return nil, false
@@ -41,16 +168,76 @@ func GetShareSession(sessionId string) (*types.ShareSession, bool) {
 // RemoveShareSession removes a share session
 // confirmKey returns cache key for session+client (per-device confirm).
 func confirmKey(sessionId, clientKey string) string {
-	return sessionId + "\n" + clientKey
+	return NormalizeShareSessionId(sessionId) + "\n" + clientKey
 }
 
-// RemoveShareSession removes a share session (confirm caches use per-client keys and will TTL out).
+// RemoveShareSession removes a share session (confirm caches use per-client keys and will TTL out)
+// along with its uploaded files; expiring sessions lose theirs in the cache's delete hook.
 func RemoveShareSession(sessionId string) {
+	sessionId = NormalizeShareSessionId(sessionId)
 	shareSessionMu.Lock()
 	defer shareSessionMu.Unlock()
+	if session, ok := persistentShares[sessionId]; ok {
+		removeShareUploadDir(session)
+		delete(persistentShares, sessionId)
+	}
 	shareSessions.Delete(sessionId)
 }
 
+// AddShareSessionFiles adds files to a share session, replacing entries with the same id.
+func AddShareSessionFiles(sessionId string, files map[string]types.ShareFileEntry) bool {
+	sessionId = NormalizeShareSessionId(sessionId)
+	shareSessionMu.Lock()
+	defer shareSessionMu.Unlock()
+	session, ok := persistentShares[sessionId]
+	if !ok {
+		if session = shareSessions.Get(sessionId); session == nil {
+			return false
+		}
+	}
+	for id, entry := range files {
+		session.Files[id] = entry
+	}
+	return true
+}
+
+// RemoveShareSessionFiles removes files from a share session and returns how many were removed.
+func RemoveShareSessionFiles(sessionId string, fileIds []string) (int, bool) {
+	sessionId = NormalizeShareSessionId(sessionId)
+	shareSessionMu.Lock()
+	defer shareSessionMu.Unlock()
+	session, ok := persistentShares[sessionId]
+	if !ok {
+		if session = shareSessions.Get(sessionId); session == nil {
+			return 0, false
+		}
+	}
+	removed := 0
+	for _, id := range fileIds {
+		if _, ok := session.Files[id]; ok {
+			delete(session.Files, id)
+			removed++
+		}
+	}
+	return removed, true
+}
+
+// RemoveExpiringShareSession removes a share session unless it is persistent.
+// Used when a remote cancel arrives, so peers cannot close a persistent share.
+func RemoveExpiringShareSession(sessionId string) bool {
+	sessionId = NormalizeShareSessionId(sessionId)
+	shareSessionMu.Lock()
+	defer shareSessionMu.Unlock()
+	if _, ok := persistentShares[sessionId]; ok {
+		return false
+	}
+	if shareSessions.Get(sessionId) == nil {
+		return false
+	}
+	shareSessions.Delete(sessionId)
+	return true
+}
+
 // IsDownloadConfirmed returns true if this client has been confirmed for this session (per-device).
 func IsDownloadConfirmed(sessionId, clientKey string) bool {
 	shareSessionMu.RLock()
@@ -65,6 +252,45 @@ func MarkDownloadConfirmed(sessionId, clientKey string) {
 	confirmedDownloadSess.Set(confirmKey(sessionId, clientKey), true)
 }
 
+// DownloadTokenFor returns the download token of a confirmed client, issuing one if needed.
+// The token stays tied to the session, so a client whose address changes can keep downloading.
+func DownloadTokenFor(sessionId, clientKey string) string {
+	sessionId = NormalizeShareSessionId(sessionId)
+	shareSessionMu.Lock()
+	defer shareSessionMu.Unlock()
+	key := confirmKey(sessionId, clientKey)
+	if token := downloadTokenByClient.Get(key); token != "" && downloadTokens.Get(token) == sessionId {
+		downloadTokenOwners.Get(token) // refresh the owner along with the token
+		return token
+	}
+	token := tool.GenerateRandomUUID()
+	downloadTokens.Set(token, sessionId)
+	downloadTokenByClient.Set(key, token)
+	downloadTokenOwners.Set(token, clientKey)
+	return token
+}
+
+// DownloadTokenOwner returns the client the download token was issued to, or "" for an unknown token.
+func DownloadTokenOwner(token string) string {
+	if token == "" {
+		return ""
+	}
+	shareSessionMu.RLock()
+	defer shareSessionMu.RUnlock()
+	return downloadTokenOwners.Get(token)
+}
+
+// IsValidDownloadToken reports whether token was issued for sessionId and has not expired.
+func IsValidDownloadToken(sessionId, token string) bool {
+	if token == "" {
+		return false
+	}
+	sessionId = NormalizeShareSessionId(sessionId)
+	shareSessionMu.RLock()
+	defer shareSessionMu.RUnlock()
+	return downloadTokens.Get(token) == sessionId
+}
+
 // SetConfirmDownloadChannel sets the channel for confirm-download callback (per clientKey).
 func SetConfirmDownloadChannel(sessionId, clientKey string, ch chan types.ConfirmResult) {
 	shareSessionMu.Lock()
@@ -92,6 +318,8 @@ func DeleteConfirmDownloadChannel(sessionId, clientKey string) {
 
 // GetShareSessionFiles returns the files map for prepare-download response
 func GetShareSessionFiles(session *types.ShareSession) map[string]types.FileInfo {
+	shareSessionMu.RLock()
+	defer shareSessionMu.RUnlock()
 	files := make(map[string]types.FileInfo, len(session.Files))
 	for id, entry := range session.Files {
 		files[id] = entry.FileInfo
@@ -99,8 +327,17 @@ func GetShareSessionFiles(session *types.ShareSession) map[string]types.FileInfo
 	return files
 }
 
+// GetShareSessionEntries returns a copy of the file entries of a share session, including their local paths.
+func GetShareSessionEntries(session *types.ShareSession) map[string]types.ShareFileEntry {
+	shareSessionMu.RLock()
+	defer shareSessionMu.RUnlock()
+	return maps.Clone(session.Files)
+}
+
 // LookupShareFile looks up a file in a share session
 func LookupShareFile(session *types.ShareSession, fileId string) (types.ShareFileEntry, bool) {
+	shareSessionMu.RLock()
+	defer shareSessionMu.RUnlock()
 	entry, ok := session.Files[fileId]
 	return entry, ok
 }
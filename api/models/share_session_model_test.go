@@ -0,0 +1,108 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+func TestShareSessionIdIsCaseInsensitive(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     string
+		lookups    []string
+		persistent bool
+	}{
+		{name: "mixed case id", stored: "AbCd-1234-EfGh", lookups: []string{"abcd-1234-efgh", "ABCD-1234-EFGH", "aBcD-1234-eFgH"}},
+		{name: "copied url id", stored: "MiXeD-Id", lookups: []string{" mixed-id/ ", "MIXED-ID//"}},
+		{name: "persistent share", stored: "Persistent-ID", lookups: []string{"persistent-id", "PERSISTENT-ID"}, persistent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			CacheShareSession(&types.ShareSession{
+				SessionId:  tt.stored,
+				Files:      map[string]types.ShareFileEntry{},
+				Persistent: tt.persistent,
+			})
+			t.Cleanup(func() { RemoveShareSession(tt.stored) })
+
+			for _, lookup := range tt.lookups {
+				session, ok := GetShareSession(lookup)
+				if !ok {
+					t.Fatalf("GetShareSession(%q) found nothing for a session stored as %q", lookup, tt.stored)
+				}
+				if session.SessionId != NormalizeShareSessionId(tt.stored) {
+					t.Errorf("GetShareSession(%q) returned session %q", lookup, session.SessionId)
+				}
+			}
+
+			token := DownloadTokenFor(tt.lookups[0], "client")
+			for _, lookup := range tt.lookups {
+				if !IsValidDownloadToken(lookup, token) {
+					t.Errorf("download token issued for %q is not valid for %q", tt.lookups[0], lookup)
+				}
+			}
+
+			RemoveShareSession(tt.lookups[len(tt.lookups)-1])
+			if _, ok := GetShareSession(tt.stored); ok {
+				t.Errorf("session %q is still present after removal by %q", tt.stored, tt.lookups[len(tt.lookups)-1])
+			}
+		})
+	}
+}
+
+func TestShareUploadDirRemovedOnClose(t *testing.T) {
+	previous := shareUploadsDir
+	shareUploadsDir = t.TempDir()
+	t.Cleanup(func() { shareUploadsDir = previous })
+
+	tests := []struct {
+		name       string
+		label      string
+		sessionId  string
+		wantDir    string
+		persistent bool
+	}{
+		{name: "label", label: "Holiday Photos", sessionId: "upload-a", wantDir: "Holiday Photos"},
+		{name: "duplicate label", label: "Holiday Photos", sessionId: "upload-b", wantDir: "Holiday Photos-2"},
+		{name: "traversal label", label: "../../etc", sessionId: "upload-c", wantDir: ".._.._etc"},
+		{name: "blank label", label: "  ", sessionId: "Upload-D", wantDir: "upload-d"},
+		{name: "persistent share", label: "team", sessionId: "upload-e", wantDir: "team", persistent: true},
+	}
+	dirs := make(map[string]string)
+	for _, tt := range tests {
+		dir, err := CreateShareUploadDir(tt.label, tt.sessionId)
+		if err != nil {
+			t.Fatalf("%s: CreateShareUploadDir: %v", tt.name, err)
+		}
+		if want := filepath.Join(shareUploadsDir, tt.wantDir); dir != want {
+			t.Fatalf("%s: upload folder %q, want %q", tt.name, dir, want)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte(tt.name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		CacheShareSession(&types.ShareSession{
+			SessionId:  tt.sessionId,
+			Files:      map[string]types.ShareFileEntry{},
+			Persistent: tt.persistent,
+			UploadDir:  dir,
+		})
+		t.Cleanup(func() { RemoveShareSession(tt.sessionId) })
+		dirs[tt.sessionId] = dir
+	}
+
+	// Closing a session removes only its own folder, even when another session has the same label
+	for i, tt := range tests {
+		RemoveShareSession(tt.sessionId)
+		if _, err := os.Stat(dirs[tt.sessionId]); !os.IsNotExist(err) {
+			t.Errorf("%s: upload folder %s still exists after close (err %v)", tt.name, dirs[tt.sessionId], err)
+		}
+		for _, open := range tests[i+1:] {
+			if _, err := os.Stat(filepath.Join(dirs[open.sessionId], "file.txt")); err != nil {
+				t.Errorf("%s: closing it removed the files of %s: %v", tt.name, open.name, err)
+			}
+		}
+	}
+}
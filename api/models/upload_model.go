@@ -1,10 +1,35 @@
 package models
 
 import (
+	"fmt"
+
 	"github.com/moyoez/localsend-go/boardcast"
+	"github.com/moyoez/localsend-go/tool"
 	"github.com/moyoez/localsend-go/types"
 )
 
 func ParsePrepareUploadRequest(body []byte) (*types.PrepareUploadRequest, error) {
 	return boardcast.ParsePrepareUploadRequestFromBody(body)
 }
+
+// ValidatePrepareUploadRequest rejects malformed prepare-upload requests before any session is created.
+func ValidatePrepareUploadRequest(request *types.PrepareUploadRequest) error {
+	if len(request.Files) == 0 {
+		return fmt.Errorf("files must not be empty")
+	}
+	if limit := tool.MaxFilesPerSession(); limit > 0 && len(request.Files) > limit {
+		return fmt.Errorf("too many files: %d (max %d)", len(request.Files), limit)
+	}
+	for fileId, info := range request.Files {
+		if fileId == "" {
+			return fmt.Errorf("file id must not be empty")
+		}
+		if info.FileName == "" {
+			return fmt.Errorf("file %s: fileName is required", fileId)
+		}
+		if info.Size < 0 {
+			return fmt.Errorf("file %s: size must not be negative", fileId)
+		}
+	}
+	return nil
+}
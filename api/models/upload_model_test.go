@@ -0,0 +1,61 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+func TestValidatePrepareUploadRequest(t *testing.T) {
+	previous := tool.MaxFilesPerSession()
+	tool.SetMaxFilesPerSession(2)
+	t.Cleanup(func() { tool.SetMaxFilesPerSession(previous) })
+
+	file := func(name string, size int64) types.FileInfo {
+		return types.FileInfo{FileName: name, Size: size, FileType: "text/plain"}
+	}
+	tests := []struct {
+		name    string
+		files   map[string]types.FileInfo
+		wantErr string
+	}{
+		{name: "nil files", files: nil, wantErr: "files must not be empty"},
+		{name: "empty files", files: map[string]types.FileInfo{}, wantErr: "files must not be empty"},
+		{name: "too many files", files: map[string]types.FileInfo{
+			"a": file("a.txt", 1), "b": file("b.txt", 1), "c": file("c.txt", 1),
+		}, wantErr: "too many files: 3 (max 2)"},
+		{name: "empty file id", files: map[string]types.FileInfo{"": file("a.txt", 1)}, wantErr: "file id must not be empty"},
+		{name: "missing file name", files: map[string]types.FileInfo{"a": file("", 1)}, wantErr: "file a: fileName is required"},
+		{name: "negative size", files: map[string]types.FileInfo{"a": file("a.txt", -1)}, wantErr: "file a: size must not be negative"},
+		{name: "valid", files: map[string]types.FileInfo{"a": file("a.txt", 0), "b": file("b.txt", 10)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePrepareUploadRequest(&types.PrepareUploadRequest{Files: tt.files})
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Fatalf("error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePrepareUploadRequestNoLimit(t *testing.T) {
+	previous := tool.MaxFilesPerSession()
+	tool.SetMaxFilesPerSession(0)
+	t.Cleanup(func() { tool.SetMaxFilesPerSession(previous) })
+
+	files := make(map[string]types.FileInfo, 100)
+	for i := range 100 {
+		id := strconv.Itoa(i)
+		files[id] = types.FileInfo{FileName: strings.Repeat("x", i+1), Size: int64(i)}
+	}
+	if err := ValidatePrepareUploadRequest(&types.PrepareUploadRequest{Files: files}); err != nil {
+		t.Fatalf("unexpected error with no file limit: %v", err)
+	}
+}
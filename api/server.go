@@ -1,13 +1,15 @@
 package api
 
 import (
+	"context"
 	"crypto/tls"
-	"encoding/pem"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/gin-gonic/gin"
@@ -26,12 +28,17 @@ type Server struct {
 	server     *http.Server
 	configPath string // path to config file for TLS cert storage
 	mu         sync.RWMutex
+	// routeHooks add routes of library consumers after the built-in ones, see RegisterRoutes
+	routeHooks []func(r *gin.Engine)
 }
 
 var (
-	DefaultConfigPath   = "config.yaml"
-	DefaultUploadFolder = "uploads"
-	WebOutPath          = "web/out"
+	DefaultConfigPath = "config.yaml"
+	WebOutPath        = "web/out"
+	webDAVEnabled     bool
+	logStreamEnabled  bool
+	// identityUploadFolder namespaces the upload folder by the self device alias
+	identityUploadFolder bool
 )
 
 // SetDoNotMakeSessionFolder sets whether to skip session subfolder and use numbered filenames when same name exists.
@@ -39,6 +46,42 @@ func SetDoNotMakeSessionFolder(v bool) {
 	models.DoNotMakeSessionFolder = v
 }
 
+// SetReceivedFileModes sets the permission bits of received files and of directories created for them.
+func SetReceivedFileModes(fileMode, dirMode os.FileMode) {
+	models.SetReceivedFileMode(fileMode)
+	models.SetReceivedDirMode(dirMode)
+}
+
+// SetWebDAVEnabled sets whether the upload folder is served over WebDAV at /webdav.
+// Access needs the shared secret (see SetSharedSecret), or comes from localhost only when none is set.
+func SetWebDAVEnabled(v bool) {
+	webDAVEnabled = v
+}
+
+// SetCommandSharesEnabled sets whether share sessions may stream the output of a command instead of a file.
+func SetCommandSharesEnabled(v bool) {
+	controllers.SetCommandSharesEnabled(v)
+}
+
+// SetLogStreamEnabled sets whether server logs are buffered and streamed over SSE at /api/self/v1/logs.
+// Access is protected like WebDAV (shared secret, or localhost only when none is set).
+func SetLogStreamEnabled(v bool) {
+	logStreamEnabled = v
+	if v {
+		tool.EnableLogStream()
+	}
+}
+
+// SetSharedSecret sets the secret that protects optional endpoints such as WebDAV.
+func SetSharedSecret(secret string) {
+	middlewares.SetSharedSecret(secret)
+}
+
+// SetSavePathTemplate sets the save path template for received files, see models.SetSavePathTemplate.
+func SetSavePathTemplate(template string) error {
+	return models.SetSavePathTemplate(template)
+}
+
 // SetDefaultWebOutPath sets the default web out path for both api and models packages
 func SetDefaultWebOutPath(path string) {
 	if path != "" {
@@ -51,11 +94,85 @@ func SetSelfDevice(device *types.VersionMessage) {
 	models.SetSelfDevice(device)
 }
 
-// SetDefaultUploadFolder sets the default upload folder for both api and models packages
+// SetMaxPrepareUploadFiles sets the max number of files accepted per prepare-upload request (0 = no limit).
+func SetMaxPrepareUploadFiles(n int) {
+	if n >= 0 {
+		tool.SetMaxFilesPerSession(n)
+	}
+}
+
+// SetSessionTTL sets the idle lifetime of receive-side and sender-side upload sessions.
+func SetSessionTTL(d time.Duration) {
+	models.SetSessionTTL(d)
+	controllers.SetUserSessionTTL(d)
+}
+
+// SetUploadStallTimeout sets how long an incoming upload may receive no data before it is aborted (0 disables).
+func SetUploadStallTimeout(d time.Duration) {
+	models.SetUploadStallTimeout(d)
+}
+
+// SetKeepPartialOnCancel sets whether cancelled uploads keep their partial file as "<name>.part".
+func SetKeepPartialOnCancel(keep bool) {
+	models.SetKeepPartialOnCancel(keep)
+}
+
+// SetPartialMaxAge sets the age after which partial files kept on cancel are deleted (0 keeps them).
+func SetPartialMaxAge(d time.Duration) {
+	models.SetPartialMaxAge(d)
+}
+
+// RunPartialCleanup deletes stale partial files kept on cancel until the process exits.
+func RunPartialCleanup() {
+	models.RunPartialCleanup()
+}
+
+// SetMaxConcurrentDownloads sets how many share downloads may be served at once (0 means no limit).
+func SetMaxConcurrentDownloads(n int) {
+	models.SetMaxConcurrentDownloads(n)
+}
+
+// SetDownloadRateLimit caps each share download at bytesPerSecond (0 means no limit).
+func SetDownloadRateLimit(bytesPerSecond int64) {
+	models.SetDownloadRateLimit(bytesPerSecond)
+}
+
+// SetImageServeRoots sets the base directories get-image is allowed to serve from.
+func SetImageServeRoots(roots []string) {
+	controllers.SetImageServeRoots(roots)
+}
+
+// SetSlowRequestThreshold sets the handler duration above which API requests are logged as slow (0 disables).
+func SetSlowRequestThreshold(d time.Duration) {
+	middlewares.SetSlowRequestThreshold(d)
+}
+
+// SetIdentityUploadFolder sets whether the upload folder gets a subfolder named after the self device
+// alias (see models.IdentityUploadFolder). Must be called after SetSelfDevice and before SetDefaultUploadFolder.
+func SetIdentityUploadFolder(v bool) {
+	identityUploadFolder = v
+}
+
+// SetEncryptionKey sets the AES key received files are encrypted with at rest, see models.SetEncryptionKey.
+func SetEncryptionKey(key []byte) error {
+	return models.SetEncryptionKey(key)
+}
+
+// DefaultUploadFolder returns the folder received files are saved under, see models.DefaultUploadFolder.
+func DefaultUploadFolder() string {
+	return models.DefaultUploadFolder()
+}
+
+// SetDefaultUploadFolder sets the default upload folder. Safe to call while uploads run, e.g. on config reload.
 func SetDefaultUploadFolder(folder string) {
 	if folder != "" {
-		DefaultUploadFolder = folder
-		models.DefaultUploadFolder = folder
+		if identityUploadFolder {
+			folder = models.IdentityUploadFolder(folder, models.GetSelfDevice())
+			if err := os.MkdirAll(folder, models.ReceivedDirMode()); err != nil {
+				tool.DefaultLogger.Warnf("Failed to create upload folder %s: %v", folder, err)
+			}
+		}
+		models.SetDefaultUploadFolder(folder)
 	}
 }
 
@@ -71,6 +188,15 @@ func NewServerWithConfig(port int, protocol string, configPath string) *Server {
 	}
 }
 
+// RegisterRoutes lets code embedding the server attach its own handlers (e.g. a settings page).
+// fn is called with the engine once the built-in routes are set up, so it must not register a path
+// the server already serves. Hooks must be registered before Start.
+func (s *Server) RegisterRoutes(fn func(r *gin.Engine)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routeHooks = append(s.routeHooks, fn)
+}
+
 func (s *Server) setupRoutes() *gin.Engine {
 	if tool.DefaultLogger.GetLevel() == log.DebugLevel {
 		gin.SetMode(gin.DebugMode)
@@ -87,17 +213,22 @@ func (s *Server) setupRoutes() *gin.Engine {
 	cancelCtrl := controllers.NewCancelController()
 
 	// Register API endpoints
-	v2 := engine.Group("/api/localsend/v2")
+	v2 := engine.Group("/api/localsend/v2", middlewares.RequestLog())
 	{
 		v2.GET("/info", controllers.HandleLocalsendV2InfoGet)
 		v2.POST("/register", registerCtrl.HandleRegister)
 		v2.POST("/prepare-upload", uploadCtrl.HandlePrepareUpload)
 		v2.POST("/upload", uploadCtrl.HandleUpload)
+		v2.GET("/upload-chunks", uploadCtrl.HandleUploadChunks)
+		v2.POST("/upload-complete", uploadCtrl.HandleUploadComplete)
 		v2.POST("/cancel", cancelCtrl.HandleCancel)
+		v2.GET("/storage-info", controllers.HandleLocalsendV2StorageInfo)
+		v2.POST("/echo", controllers.HandleLocalsendV2Echo)
 		// Download API (LocalSend protocol Section 5)
 		if selfDevice := models.GetSelfDevice(); selfDevice != nil && selfDevice.Download {
 			v2.GET("/prepare-download", controllers.HandlePrepareDownload)
 			v2.GET("/download", controllers.HandleDownload)
+			v2.GET("/manifest", controllers.HandleDownloadManifest)
 		}
 	}
 	// V1 Is Deprecated, but due to some reasons, I support to this ONLY ACCEPT REQUESTS.
@@ -110,27 +241,71 @@ func (s *Server) setupRoutes() *gin.Engine {
 		v1.POST("/send", uploadCtrl.HandleUploadV1Upload)
 		v1.POST("/cancel", cancelCtrl.HandleCancelV1Cancel)
 	}
-	self := engine.Group("/api/self/v1", middlewares.OnlyAllowLocal)
+	self := engine.Group("/api/self/v1", middlewares.RequestLog(), middlewares.OnlyAllowLocal)
 	{
 		self.GET("/get-network-info", controllers.UserGetNetworkInfo)           // Get local network info with IP and segment number
 		self.GET("/scan-current", controllers.UserScanCurrent)                  // Get current scanned devices
+		self.GET("/device", controllers.UserDevice)                             // Get one cached device by fingerprint
+		self.GET("/device-live", controllers.UserDeviceLive)                    // Probe a device by ip/port for fresh info
+		self.POST("/speed-test", controllers.UserSpeedTest)                     // Measure throughput to a device's /echo
 		self.GET("/scan-now", controllers.UserScanNow)                          // Trigger immediate scan based on current config
+		self.GET("/scan-status", controllers.UserScanStatus)                    // HTTP sweep progress (probed/responded/total)
+		self.GET("/scan-control", controllers.UserScanControlGet)               // Get scan pause state
+		self.POST("/scan-control", controllers.UserScanControl)                 // Pause/resume discovery
 		self.POST("/prepare-upload", controllers.UserPrepareUpload)             // Prepare upload endpoint
 		self.POST("/upload", controllers.UserUpload)                            // Actual upload endpoint
 		self.POST("/upload-batch", controllers.UserUploadBatch)                 // Batch upload endpoint (supports file:/// protocol)
+		self.POST("/send-manifest", controllers.UserSendManifest)               // Prepare and upload the files of a local manifest JSON in one call
 		self.GET("/confirm-recv", controllers.UserConfirmRecv)                  // Confirm recv endpoint
 		self.GET("/text-received-dismiss", controllers.UserTextReceivedDismiss) // Text received modal dismiss
 		self.GET("/confirm-download", controllers.UserConfirmDownload)          // Confirm download endpoint
 		self.POST("/cancel", controllers.UserCancelUpload)                      // Cancel upload endpoint (sender side)
+		self.POST("/cancel-all-uploads", controllers.UserCancelAllUploads)      // Cancel every active upload session (sender side)
+		self.GET("/send-progress", controllers.UserSendProgress)                // Bytes sent, speed and ETA (sender side)
+		self.GET("/upload-receipt", controllers.UserUploadReceipt)              // Per-file completion receipt (receiver side)
+		self.GET("/history", controllers.UserHistory)                           // Completed transfers with one device, newest first
+		self.GET("/stats", controllers.UserStats)                               // Runtime counters: transfers, bytes, sessions, discovery
+		self.DELETE("/received", controllers.UserDeleteReceived)                // Delete a received session's saved files
+		self.POST("/cleanup-received", controllers.UserCleanupReceived)         // Purge received entries older than olderThan
+		self.POST("/verify-received", controllers.UserVerifyReceived)           // Re-hash a received session's files against recorded hashes
 		self.GET("/get-image", controllers.UserGetImage)
-		self.GET("/favorites", controllers.UserFavoritesList)                     // List favorite devices
-		self.POST("/favorites", controllers.UserFavoritesAdd)                     // Add a favorite device
-		self.DELETE("/favorites/:fingerprint", controllers.UserFavoritesDelete)   // Remove a favorite device
-		self.GET("/get-network-interfaces", controllers.UserGetNetworkInterfaces) // Get network interfaces,used same as usergetNetwork Info
-		self.POST("/create-share-session", controllers.UserCreateShareSession)    // Create share session for download API
-		self.DELETE("/close-share-session", controllers.UserCloseShareSession)    // Close share session
-		self.GET("/create-qr-code", controllers.GenerateQRCode)                   // QR code PNG (same params as api.qrserver.com)
-		self.GET("/get-user-screenshot", controllers.GetUserScreenShot)           // made screenshot in frontend.
+		self.GET("/favorites", controllers.UserFavoritesList)                                 // List favorite devices
+		self.POST("/favorites", controllers.UserFavoritesAdd)                                 // Add a favorite device
+		self.DELETE("/favorites/:fingerprint", controllers.UserFavoritesDelete)               // Remove a favorite device
+		self.GET("/blocklist", controllers.UserBlocklistList)                                 // List blocked devices
+		self.POST("/blocklist", controllers.UserBlocklistAdd)                                 // Block a device
+		self.DELETE("/blocklist/:fingerprint", controllers.UserBlocklistDelete)               // Unblock a device
+		self.GET("/get-network-interfaces", controllers.UserGetNetworkInterfaces)             // Get network interfaces,used same as usergetNetwork Info
+		self.POST("/create-share-session", controllers.UserCreateShareSession)                // Create share session for download API
+		self.DELETE("/close-share-session", controllers.UserCloseShareSession)                // Close share session
+		self.GET("/share-sessions", controllers.UserListShareSessions)                        // List open share sessions
+		self.POST("/share-session/:id/add-files", controllers.UserShareSessionAddFiles)       // Add files to an open share session
+		self.POST("/share-session/:id/remove-files", controllers.UserShareSessionRemoveFiles) // Remove files from an open share session
+		self.GET("/create-qr-code", controllers.GenerateQRCode)                               // QR code PNG (same params as api.qrserver.com)
+		self.GET("/get-user-screenshot", controllers.GetUserScreenShot)                       // made screenshot in frontend.
+		self.POST("/rotate-cert", controllers.UserRotateCert)                                 // Regenerate TLS certificate and fingerprint
+		self.POST("/test-notify", controllers.UserTestNotify)                                 // Send a test notification through the Unix socket
+		self.GET("/notify-payload", controllers.UserNotifyPayload)                            // Full notification behind an oversized notification's payloadRef
+		self.GET("/export-config", controllers.UserExportConfig)                              // Export config, favorites and blocklist (includeKey=true adds the TLS key)
+		self.POST("/import-config", controllers.UserImportConfig)                             // Restore a config bundle from export-config
+	}
+
+	if logStreamEnabled {
+		// outside the self group: long-lived, so no request logging, and reachable remotely with the secret
+		engine.GET("/api/self/v1/logs", middlewares.RequireSharedSecret, controllers.UserStreamLogs)
+	}
+
+	if webDAVEnabled {
+		dav := gin.WrapH(controllers.NewWebDAVHandler())
+		for _, method := range controllers.WebDAVMethods {
+			engine.Handle(method, controllers.WebDAVPrefix, middlewares.RequireSharedSecret, dav)
+			engine.Handle(method, controllers.WebDAVPrefix+"/*path", middlewares.RequireSharedSecret, dav)
+		}
+		if middlewares.HasSharedSecret() {
+			tool.DefaultLogger.Infof("[Server] Serving upload folder over WebDAV at %s (shared secret required)", controllers.WebDAVPrefix)
+		} else {
+			tool.DefaultLogger.Warnf("[Server] Serving upload folder over WebDAV at %s for localhost only; set a shared secret for remote access", controllers.WebDAVPrefix)
+		}
 	}
 
 	// Serve Next.js static export for download page at root (when Download enabled and web/out exists)
@@ -148,6 +323,13 @@ func (s *Server) setupRoutes() *gin.Engine {
 		}
 	}
 
+	s.mu.RLock()
+	routeHooks := slices.Clone(s.routeHooks)
+	s.mu.RUnlock()
+	for _, fn := range routeHooks {
+		fn(engine)
+	}
+
 	return engine
 }
 
@@ -167,34 +349,40 @@ func (s *Server) Start() error {
 	tool.DefaultLogger.Infof("Starting API server on %s", address)
 
 	if s.protocol == "https" {
-		// Get or create TLS certificate from config
-		cfg := tool.GetCurrentConfig()
-		certBytes, keyBytes, err := tool.GetOrCreateTLSCertFromConfig(cfg)
-		if err != nil {
+		// Get or create TLS certificate from config; the advertised fingerprint must be the hash of the certificate we actually serve
+		var certBytes, keyBytes []byte
+		var certFingerprint string
+		if err := tool.UpdateCurrentConfig(func(cfg *types.AppConfig) error {
+			var err error
+			if certBytes, keyBytes, err = tool.GetOrCreateTLSCertFromConfig(cfg); err != nil {
+				return err
+			}
+			certFingerprint = tool.CertFingerprint(certBytes)
+			cfg.Fingerprint = certFingerprint
+			return nil
+		}); err != nil {
 			return fmt.Errorf("failed to get TLS certificate: %v", err)
 		}
 
-		// Convert DER format to PEM format
-		certPEM := pem.EncodeToMemory(&pem.Block{
-			Type:  "CERTIFICATE",
-			Bytes: certBytes,
-		})
-
-		keyPEM := pem.EncodeToMemory(&pem.Block{
-			Type:  "EC PRIVATE KEY",
-			Bytes: keyBytes,
-		})
+		if selfDevice := models.GetSelfDevice(); selfDevice != nil && selfDevice.Fingerprint != certFingerprint {
+			tool.DefaultLogger.Warnf("[Server] Advertised fingerprint %s does not match TLS certificate %s, using certificate fingerprint", selfDevice.Fingerprint, certFingerprint)
+			selfDevice.Fingerprint = certFingerprint
+			models.SetSelfDevice(selfDevice)
+		}
 
 		// Load certificate and key for TLS
-		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		cert, err := tool.TLSKeyPairFromDER(certBytes, keyBytes)
 		if err != nil {
 			return fmt.Errorf("failed to load TLS certificate: %v", err)
 		}
+		models.SetServerCertificate(&cert)
 
-		// Configure TLS
+		// Configure TLS; the certificate is looked up per handshake so rotate-cert applies without restart
 		s.mu.Lock()
 		s.server.TLSConfig = &tls.Config{
-			Certificates: []tls.Certificate{cert},
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return models.GetServerCertificate(), nil
+			},
 		}
 		s.mu.Unlock()
 
@@ -204,3 +392,15 @@ func (s *Server) Start() error {
 
 	return s.server.ListenAndServe()
 }
+
+// Shutdown gracefully stops the HTTP server, waiting for in-flight requests until ctx is done.
+// Start then returns http.ErrServerClosed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.RLock()
+	server := s.server
+	s.mu.RUnlock()
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
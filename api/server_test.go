@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moyoez/localsend-go/api/models"
+	"github.com/moyoez/localsend-go/transfer"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// TestLoopbackPrepareUploadAndUpload sends two files through the transfer client to the in-process router:
+// one as a plain upload, one in chunks finished by upload-complete.
+func TestLoopbackPrepareUploadAndUpload(t *testing.T) {
+	folder := t.TempDir()
+	previousFolder := DefaultUploadFolder()
+	SetDefaultUploadFolder(folder)
+	t.Cleanup(func() { SetDefaultUploadFolder(previousFolder) })
+
+	models.SetConfirmRecvHandler(func(*types.PrepareUploadRequest, string) (bool, error) {
+		return true, nil
+	})
+	t.Cleanup(func() { models.SetConfirmRecvHandler(nil) })
+
+	restore := transfer.UseLoopbackReceiver((&Server{}).setupRoutes())
+	t.Cleanup(restore)
+
+	plain := []byte("hello over the loopback receiver")
+	chunked := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+	sum := sha256.Sum256(chunked)
+	request := &types.PrepareUploadRequest{
+		Info: types.DeviceInfo{
+			Alias:       "Loopback Sender",
+			Version:     "2.0",
+			DeviceType:  "headless",
+			Fingerprint: "loopback-sender",
+			Port:        53317,
+			Protocol:    "http",
+		},
+		Files: map[string]types.FileInfo{
+			"plain": {
+				ID:       "plain",
+				FileName: "plain.txt",
+				Size:     int64(len(plain)),
+				FileType: "application/octet-stream",
+			},
+			"chunked": {
+				ID:       "chunked",
+				FileName: "chunked.bin",
+				Size:     int64(len(chunked)),
+				FileType: "application/octet-stream",
+				SHA256:   hex.EncodeToString(sum[:]),
+			},
+		},
+	}
+
+	target := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53317}
+	remote := &types.VersionMessage{Alias: "Loopback Receiver", Port: 53317, Protocol: "http"}
+	response, err := transfer.ReadyToUploadTo(target, remote, request, "")
+	if err != nil {
+		t.Fatalf("prepare-upload: %v", err)
+	}
+	if response == nil || response.SessionId == "" {
+		t.Fatalf("prepare-upload returned no session: %+v", response)
+	}
+	for fileId := range request.Files {
+		if response.Files[fileId] == "" {
+			t.Fatalf("prepare-upload returned no token for %s: %+v", fileId, response.Files)
+		}
+	}
+
+	if err := transfer.UploadFile(target, remote, response.SessionId, "plain", response.Files["plain"], bytes.NewReader(plain)); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if err := transfer.UploadFileInChunks(context.Background(), target, remote, response.SessionId, "chunked", response.Files["chunked"],
+		bytes.NewReader(chunked), int64(len(chunked)), 4096); err != nil {
+		t.Fatalf("chunked upload and upload-complete: %v", err)
+	}
+
+	// upload_end is sent and the session removed in the background; wait so it does not outlive the test
+	deadline := time.Now().Add(5 * time.Second)
+	for models.IsUploadSessionActive(response.SessionId) {
+		if time.Now().After(deadline) {
+			t.Fatal("upload session was not finished after the last file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for name, want := range map[string][]byte{"plain.txt": plain, "chunked.bin": chunked} {
+		path := findReceivedFile(t, folder, name)
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: received %d bytes that differ from the %d bytes sent", name, len(got), len(want))
+		}
+	}
+}
+
+// findReceivedFile returns the path of name below root, wherever the session folder layout put it.
+func findReceivedFile(t *testing.T, root, name string) string {
+	t.Helper()
+	var found string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == name {
+			found = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk %s: %v", root, err)
+	}
+	if found == "" {
+		t.Fatalf("%s was not received below %s", name, root)
+	}
+	return found
+}
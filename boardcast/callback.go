@@ -37,14 +37,16 @@ func CallbackMulticastMessageUsingTCP(targetAddr *net.UDPAddr, self *types.Callb
 		response := *self
 		//	https://github.com/localsend/protocol/blob/main/README.md#31-multicast-udp-default
 		if udpErr := CallbackMulticastMessageUsingUDP(&types.VersionMessage{
-			Alias:       response.Alias,
-			Version:     response.Version,
-			DeviceModel: response.DeviceModel,
-			DeviceType:  response.DeviceType,
-			Fingerprint: response.Fingerprint,
-			Port:        response.Port,
-			Protocol:    response.Protocol,
-			Announce:    false,
+			Alias:        response.Alias,
+			Version:      response.Version,
+			DeviceModel:  response.DeviceModel,
+			DeviceType:   response.DeviceType,
+			Fingerprint:  response.Fingerprint,
+			Port:         response.Port,
+			Protocol:     response.Protocol,
+			Announce:     false,
+			Capabilities: response.Capabilities,
+			Note:         response.Note,
 		}); udpErr != nil {
 			return fmt.Errorf("both HTTP and UDP multicast fallback failed: %v; original: %v", udpErr, sendErr)
 		}
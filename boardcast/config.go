@@ -2,7 +2,9 @@ package boardcast
 
 import (
 	"fmt"
+	"math"
 	"net"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,21 +18,35 @@ import (
 const (
 	defaultMultcastAddress = "224.0.0.167"
 	defaultMultcastPort    = 53317 // UDP & HTTP
-	// scanNowHTTPConcurrency is the concurrency cap for scan-now (no rate limit; high concurrency for speed)
-	scanNowHTTPConcurrency = 256
-	// autoScanConcurrencyLimit limits concurrent HTTP scan goroutines for periodic auto scan (16~32)
-	autoScanConcurrencyLimit = 24
+	// defaultScanNowHTTPConcurrency is the default concurrency cap for scan-now (high concurrency for speed)
+	defaultScanNowHTTPConcurrency = 256
+	// defaultAutoScanConcurrencyLimit limits concurrent HTTP scan goroutines for periodic auto scan (16~32)
+	defaultAutoScanConcurrencyLimit = 24
 	// autoScanICMPRatePPS is the ICMP probe rate limit (packets per second) for auto scan; /24 ~ 6~12s
 	autoScanICMPRatePPS = 30
 	// icmpProbeTimeout is the timeout for ICMP echo probe (host reachability before HTTP register)
 	icmpProbeTimeout = 200 * time.Millisecond
+	// tcpProbeTimeout bounds the TCP connect probe used instead of ICMP when skipICMPProbe is set
+	tcpProbeTimeout = 300 * time.Millisecond
+	// defaultMulticastTTL keeps announces on the local segment; the protocol does not ask for more,
+	// and 1 is what the official clients send with.
+	defaultMulticastTTL = 1
+	// networkWatchInterval is how often the network watcher polls interfaces for address changes
+	networkWatchInterval = 5 * time.Second
+	// udpRebindBackoff is how long the UDP listener waits before retrying when interfaces cannot be resolved
+	udpRebindBackoff = 5 * time.Second
+	// defaultScanInterval is how often the UDP announce and HTTP scan loops fire
+	defaultScanInterval = 30 * time.Second
+	// minScanInterval keeps a misconfigured interval from flooding the network
+	minScanInterval = 5 * time.Second
 )
 
 var (
 	multcastAddress       = defaultMultcastAddress
 	multcastPort          = defaultMultcastPort
-	referNetworkInterface string // the specified network interface name
-	listenAllInterfaces   = true // whether to listen on all network interfaces
+	referNetworkInterface string   // the specified network interface name
+	listenAllInterfaces   = true   // whether to listen on all network interfaces
+	interfaceAllowlist    []string // named interfaces to listen on when more than one is wanted
 
 	// networkIPsCache caches generated network IPs to avoid repeated generation
 	networkIPsCacheMu  sync.RWMutex
@@ -50,10 +66,40 @@ var (
 	// scanPauseCount is an atomic reference counter for pausing scans during file transfers.
 	// When > 0, scan loops skip their ticks without resetting timers.
 	scanPauseCount atomic.Int32
+	// udpListeners holds active multicast listeners; closed and rebound on network change
+	udpListenersMu        sync.Mutex
+	udpListeners          = make(map[*net.UDPConn]struct{})
+	udpListenersRestartCh = make(chan struct{}, 1)
+
+	// skipICMPProbe replaces the ICMP reachability probe with a TCP connect probe (for ICMP-filtered networks)
+	skipICMPProbe atomic.Bool
+
+	// scanUserPaused is the user-controlled pause flag (scan-control API), kept apart from scanPauseCount.
+	scanUserPaused atomic.Bool
+
+	// multicastTTL and skipMulticastLoopback are applied to every outgoing multicast socket
+	multicastTTL          atomic.Int32
+	skipMulticastLoopback atomic.Bool
+
+	// udpScanInterval and httpScanInterval are the tick periods of the auto scan loops
+	udpScanInterval  atomic.Int64
+	httpScanInterval atomic.Int64
+
+	// autoScanConcurrency and scanNowConcurrency cap concurrent HTTP scan workers, see SetScanConcurrency
+	autoScanConcurrency atomic.Int32
+	scanNowConcurrency  atomic.Int32
 )
 
+func init() {
+	multicastTTL.Store(defaultMulticastTTL)
+	autoScanConcurrency.Store(defaultAutoScanConcurrencyLimit)
+	scanNowConcurrency.Store(defaultScanNowHTTPConcurrency)
+	udpScanInterval.Store(int64(defaultScanInterval))
+	httpScanInterval.Store(int64(defaultScanInterval))
+}
+
 // restartAction is sent on autoScanRestartCh. When SkipHTTPImmediateScan is true (e.g. after scan-now),
-// HTTP loop only resets timeout and does not run scanOnce() immediately; next scan is in 30s.
+// HTTP loop only resets timeout and does not run scanOnce() immediately; next scan is one interval later.
 type restartAction struct {
 	SkipHTTPImmediateScan bool
 }
@@ -70,9 +116,85 @@ func ResumeScan() {
 	tool.DefaultLogger.Infof("Scan resumed (active transfers: %d)", n)
 }
 
-// IsScanPaused returns true if any file transfer is active and scanning should be skipped.
+// IsScanPaused returns true if any file transfer is active or the user paused scanning, and scanning should be skipped.
 func IsScanPaused() bool {
-	return scanPauseCount.Load() > 0
+	return scanPauseCount.Load() > 0 || scanUserPaused.Load()
+}
+
+// SetScanUserPaused sets the user-controlled pause flag. It does not touch the transfer reference counter.
+func SetScanUserPaused(paused bool) {
+	scanUserPaused.Store(paused)
+	tool.DefaultLogger.Infof("Scan user pause set to %v", paused)
+}
+
+// GetScanPauseState returns the user pause flag and the active transfer pause counter.
+func GetScanPauseState() (userPaused bool, transferCount int32) {
+	return scanUserPaused.Load(), scanPauseCount.Load()
+}
+
+// SetSkipICMPProbe sets whether HTTP scan skips the ICMP probe and uses a short TCP connect probe instead.
+func SetSkipICMPProbe(skip bool) {
+	skipICMPProbe.Store(skip)
+}
+
+// SetMulticastTTL sets the IP TTL of outgoing multicast announces. Raise it (e.g. 2-4) to reach
+// devices behind a router or managed switch that forwards multicast. Values outside 1-255 are ignored.
+func SetMulticastTTL(ttl int) {
+	if ttl >= 1 && ttl <= 255 {
+		multicastTTL.Store(int32(ttl))
+	}
+}
+
+// SetSkipMulticastLoopback sets whether outgoing multicast announces are kept from looping back to this host.
+func SetSkipMulticastLoopback(skip bool) {
+	skipMulticastLoopback.Store(skip)
+}
+
+// SetScanInterval sets the tick period of both the UDP announce and the HTTP scan loops.
+// Values below 5s are raised to 5s, 0 keeps the current intervals. Applies to loops started afterwards.
+func SetScanInterval(d time.Duration) {
+	SetUDPScanInterval(d)
+	SetHTTPScanInterval(d)
+}
+
+// SetUDPScanInterval sets the tick period of the UDP multicast announce loop, see SetScanInterval.
+func SetUDPScanInterval(d time.Duration) {
+	if d > 0 {
+		udpScanInterval.Store(int64(max(d, minScanInterval)))
+	}
+}
+
+// SetHTTPScanInterval sets the tick period of the HTTP scan loops, see SetScanInterval.
+func SetHTTPScanInterval(d time.Duration) {
+	if d > 0 {
+		httpScanInterval.Store(int64(max(d, minScanInterval)))
+	}
+}
+
+// GetUDPScanInterval returns the tick period of the UDP multicast announce loop.
+func GetUDPScanInterval() time.Duration {
+	return time.Duration(udpScanInterval.Load())
+}
+
+// GetHTTPScanInterval returns the tick period of the HTTP scan loops.
+func GetHTTPScanInterval() time.Duration {
+	return time.Duration(httpScanInterval.Load())
+}
+
+// SetScanConcurrency sets how many HTTP scan workers run at once for the periodic auto scan and for
+// scan-now. Lower values ease CPU and network load on weak hardware. Values below 1 keep the current setting.
+func SetScanConcurrency(auto, scanNow int) {
+	if auto >= 1 {
+		autoScanConcurrency.Store(int32(min(auto, math.MaxInt32)))
+	}
+	if scanNow >= 1 {
+		scanNowConcurrency.Store(int32(min(scanNow, math.MaxInt32)))
+	}
+}
+
+// GetScanConcurrency returns the worker caps of the auto scan and of scan-now.
+func GetScanConcurrency() (auto, scanNow int) {
+	return int(autoScanConcurrency.Load()), int(scanNowConcurrency.Load())
 }
 
 // SetMultcastAddress overrides the default multicast address
@@ -92,16 +214,42 @@ func SetMultcastPort(port int) {
 // SetReferNetworkInterface sets the network interface to use for multicast.
 // If interfaceName is empty, it will use the system default interface.
 // If interfaceName is "*", it will listen on all available interfaces.
+// A comma-separated list (e.g. "eth0,wlan0") is passed to SetInterfaceAllowlist.
 func SetReferNetworkInterface(interfaceName string) {
+	if strings.Contains(interfaceName, ",") {
+		SetInterfaceAllowlist(strings.Split(interfaceName, ","))
+		return
+	}
 	if interfaceName != "" && interfaceName != "*" {
 		listenAllInterfaces = false
 		referNetworkInterface = interfaceName
+		interfaceAllowlist = nil
 	}
 }
 
+// SetInterfaceAllowlist restricts discovery to the named interfaces.
+// Unknown or unsupported names are skipped with a warning when interfaces are resolved.
+// An empty list keeps the current setting.
+func SetInterfaceAllowlist(names []string) {
+	var allowlist []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name != "" && !slices.Contains(allowlist, name) {
+			allowlist = append(allowlist, name)
+		}
+	}
+	if len(allowlist) == 0 {
+		return
+	}
+	listenAllInterfaces = false
+	referNetworkInterface = ""
+	interfaceAllowlist = allowlist
+}
+
 // getNetworkInterfaces returns a list of network interfaces to listen on.
 // If listenAllInterfaces is true, returns all valid interfaces.
 // If referNetworkInterface is set, returns only that interface.
+// If interfaceAllowlist is set, returns the supported interfaces among the named ones.
 // Otherwise, returns nil (use system default).
 func getNetworkInterfaces() ([]*net.Interface, error) {
 	if listenAllInterfaces {
@@ -136,6 +284,24 @@ func getNetworkInterfaces() ([]*net.Interface, error) {
 			return nil, fmt.Errorf("network interface %s is not supported", referNetworkInterface)
 		}
 		return []*net.Interface{iface}, nil
+	} else if len(interfaceAllowlist) > 0 {
+		var validInterfaces []*net.Interface
+		for _, name := range interfaceAllowlist {
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				tool.DefaultLogger.Warnf("Skipping network interface %s: %v", name, err)
+				continue
+			}
+			if tool.RejectUnsupportNetworkInterface(iface) {
+				tool.DefaultLogger.Warnf("Skipping network interface %s: not supported", name)
+				continue
+			}
+			validInterfaces = append(validInterfaces, iface)
+		}
+		if len(validInterfaces) == 0 {
+			return nil, fmt.Errorf("none of the network interfaces %s are available", strings.Join(interfaceAllowlist, ","))
+		}
+		return validInterfaces, nil
 	}
 
 	// use the system default interface
@@ -174,6 +340,8 @@ func getCachedNetworkIPs() ([]string, error) {
 	fmt.Fprint(&keyBuilder, listenAllInterfaces)
 	keyBuilder.WriteString(";rif:")
 	keyBuilder.WriteString(referNetworkInterface)
+	keyBuilder.WriteString(";al:")
+	keyBuilder.WriteString(strings.Join(interfaceAllowlist, ","))
 	keyBuilder.WriteString(";")
 	for _, addr := range addrs {
 		ipnet, ok := addr.(*net.IPNet)
@@ -219,11 +387,22 @@ func getCachedNetworkIPs() ([]string, error) {
 	return result, nil
 }
 
+// invalidateNetworkIPsCache drops the cached scan targets so they are regenerated on next use.
+func invalidateNetworkIPsCache() {
+	networkIPsCacheMu.Lock()
+	defer networkIPsCacheMu.Unlock()
+	networkIPsCache = nil
+	networkIPsCacheKey = ""
+}
+
 // GetPreferredOutgoingBindAddr returns the local address to bind outgoing HTTP connections to.
 // When useReferNetworkInterface specifies a concrete interface (not "*"), returns the first
 // valid IPv4 address on that interface so HTTP requests use that interface.
-// Returns (nil, nil) when listenAllInterfaces is true or referNetworkInterface is empty.
-// Returns an error when the specified interface has no valid IPv4 address.
+// Returns (nil, nil) when listenAllInterfaces is true or referNetworkInterface is empty
+// (including an interface allowlist, where the OS picks the route per destination).
+// Returns an error when the specified interface is down or has no valid IPv4 address.
+// Unlike discovery, binding needs no multicast, so point-to-point interfaces such as WireGuard
+// or Tailscale tunnels are accepted.
 func GetPreferredOutgoingBindAddr() (*net.TCPAddr, error) {
 	if listenAllInterfaces || referNetworkInterface == "" {
 		return nil, nil
@@ -232,8 +411,8 @@ func GetPreferredOutgoingBindAddr() (*net.TCPAddr, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get network interface %s: %w", referNetworkInterface, err)
 	}
-	if tool.RejectUnsupportNetworkInterface(iface) {
-		return nil, fmt.Errorf("network interface %s is not supported", referNetworkInterface)
+	if iface.Flags&net.FlagUp == 0 {
+		return nil, fmt.Errorf("network interface %s is down", referNetworkInterface)
 	}
 	addrs, err := iface.Addrs()
 	if err != nil {
@@ -248,3 +427,39 @@ func GetPreferredOutgoingBindAddr() (*net.TCPAddr, error) {
 	}
 	return nil, fmt.Errorf("interface %s has no valid IPv4 address", referNetworkInterface)
 }
+
+// outgoingBindAddr is the address the HTTP clients were last bound to by RefreshOutgoingBindAddr, nil when unbound
+var (
+	outgoingBindMu      sync.Mutex
+	outgoingBindAddr    *net.TCPAddr
+	outgoingBindApplied bool
+)
+
+// RefreshOutgoingBindAddr rebinds the HTTP clients, uploads included, to GetPreferredOutgoingBindAddr.
+// Called at startup and on network changes, so a VPN interface that comes up or changes address later is used.
+// When the address cannot be resolved the clients use the default route. The clients are only rebuilt when
+// the address changed.
+func RefreshOutgoingBindAddr() {
+	bindAddr, err := GetPreferredOutgoingBindAddr()
+	if err != nil {
+		tool.DefaultLogger.Warnf("GetPreferredOutgoingBindAddr: %v, HTTP clients will use default interface", err)
+		bindAddr = nil
+	}
+	outgoingBindMu.Lock()
+	defer outgoingBindMu.Unlock()
+	if outgoingBindApplied && sameBindAddr(outgoingBindAddr, bindAddr) {
+		return
+	}
+	if bindAddr != nil {
+		tool.DefaultLogger.Infof("Outgoing HTTP connections bound to %s (%s)", bindAddr.IP, referNetworkInterface)
+	}
+	outgoingBindAddr, outgoingBindApplied = bindAddr, true
+	tool.InitHTTPClients(bindAddr)
+}
+
+func sameBindAddr(a, b *net.TCPAddr) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.IP.Equal(b.IP)
+}
@@ -0,0 +1,77 @@
+package boardcast
+
+import (
+	"time"
+
+	"github.com/moyoez/localsend-go/share"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/transfer"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// defaultFavoritePort is used for favorites whose last port is unknown.
+const defaultFavoritePort = 53317
+
+// WatchFavorites periodically probes favorite devices that are not currently discovered at their
+// last known address and adds them back to the scan list, so favorites stay reachable when
+// multicast misses them. Returns immediately if probing is disabled; otherwise blocks forever.
+func WatchFavorites() {
+	interval := tool.GetFavoriteProbeInterval()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if IsScanPaused() {
+			continue
+		}
+		probeFavorites()
+	}
+}
+
+// probeFavorites runs one probe round over the undiscovered favorites.
+func probeFavorites() {
+	for _, fav := range tool.ListFavorites() {
+		if fav.LastIP == "" || tool.IsBlocked(fav.Fingerprint) {
+			continue
+		}
+		if _, ok := share.GetUserScanCurrent(fav.Fingerprint); ok {
+			continue
+		}
+		port := fav.LastPort
+		if port == 0 {
+			port = defaultFavoritePort
+		}
+
+		remote, protocol, err := transfer.FetchDeviceInfo(fav.LastIP, port)
+		if err != nil {
+			tool.DefaultLogger.Debugf("[Favorites] %s not reachable at %s:%d: %v", fav.Alias, fav.LastIP, port, err)
+			continue
+		}
+		// The address may have been handed to another device in the meantime
+		if remote.Fingerprint != fav.Fingerprint {
+			tool.DefaultLogger.Debugf("[Favorites] %s:%d now belongs to %s, skipping", fav.LastIP, port, remote.Fingerprint)
+			continue
+		}
+
+		tool.DefaultLogger.Infof("[Favorites] Reconnected favorite %s at %s:%d", remote.Alias, fav.LastIP, port)
+		share.SetUserScanCurrent(remote.Fingerprint, types.UserScanCurrentItem{
+			Ipaddress: fav.LastIP,
+			VersionMessage: types.VersionMessage{
+				Alias:        remote.Alias,
+				Version:      remote.Version,
+				DeviceModel:  remote.DeviceModel,
+				DeviceType:   remote.DeviceType,
+				Fingerprint:  remote.Fingerprint,
+				Port:         port,
+				Protocol:     protocol,
+				Download:     remote.Download,
+				Announce:     true,
+				Capabilities: remote.Capabilities,
+				Note:         remote.Note,
+			},
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package boardcast
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/share"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+const (
+	// maxGossipPeers caps the peer list sent in one register response, and how much of a received one is used
+	maxGossipPeers = 32
+	// maxGossipResponseSize caps how much of a gossiped peer's register response is read
+	maxGossipResponseSize = 64 << 10
+	// gossipRetryInterval keeps a gossiped address from being registered with more than once per interval
+	gossipRetryInterval = 5 * time.Minute
+	// gossipConcurrency bounds register requests to gossiped peers
+	gossipConcurrency = 4
+)
+
+var (
+	// gossipEnabled adds the known device list to register responses and registers with peers learned from them
+	gossipEnabled atomic.Bool
+
+	gossipTriedMu sync.Mutex
+	gossipTried   = make(map[string]time.Time) // "ip:port" -> last register attempt
+	gossipSem     = make(chan struct{}, gossipConcurrency)
+
+	// interfaceAddrs lists the local addresses whose subnets gossiped peers may be on; replaced in tests
+	interfaceAddrs = net.InterfaceAddrs
+)
+
+// SetGossip enables peer-list exchange on register: responses carry the devices this instance knows,
+// and devices learned from other responses are registered with directly. This lets devices find each
+// other when multicast is filtered and the HTTP sweep cannot reach them. Off by default.
+func SetGossip(enabled bool) {
+	gossipEnabled.Store(enabled)
+}
+
+// GossipPeers returns the known devices to send in a register response, excluding the requester.
+// It returns nil when gossip is disabled.
+func GossipPeers(excludeFingerprint string) []types.GossipPeer {
+	if !gossipEnabled.Load() {
+		return nil
+	}
+	var peers []types.GossipPeer
+	for _, fingerprint := range share.ListUserScanCurrent() {
+		if len(peers) >= maxGossipPeers {
+			break
+		}
+		if fingerprint == excludeFingerprint {
+			continue
+		}
+		item, ok := share.GetUserScanCurrent(fingerprint)
+		if !ok || item.Port == 0 {
+			continue
+		}
+		peers = append(peers, types.GossipPeer{
+			Ip:          item.Ipaddress,
+			Port:        item.Port,
+			Protocol:    item.Protocol,
+			Fingerprint: item.Fingerprint,
+		})
+	}
+	return peers
+}
+
+// learnGossipPeers registers with gossiped peers that are not in the scan list yet.
+// Only the first maxGossipPeers entries are used, and only peers on a private or local network are contacted.
+// At most gossipConcurrency registers run at a time; peers beyond that are dropped and picked up from a later list.
+func learnGossipPeers(peers []types.GossipPeer) {
+	if !gossipEnabled.Load() {
+		return
+	}
+	if len(peers) > maxGossipPeers {
+		peers = peers[:maxGossipPeers]
+	}
+	for _, peer := range peers {
+		if peer.Ip == "" || peer.Port <= 0 || peer.Port > 65535 || peer.Fingerprint == "" {
+			continue
+		}
+		if tool.CheckFingerPrintIsSame(peer.Fingerprint) || tool.IsBlocked(peer.Fingerprint) {
+			continue
+		}
+		if _, known := share.GetUserScanCurrent(peer.Fingerprint); known {
+			continue
+		}
+		if !isGossipPeerAddr(net.ParseIP(peer.Ip)) {
+			tool.DefaultLogger.Debugf("[Gossip] Ignoring peer %s outside the local networks", peer.Ip)
+			continue
+		}
+		// Not blocking here, since registerGossipPeer calls back into this while holding a slot
+		select {
+		case gossipSem <- struct{}{}:
+		default:
+			tool.DefaultLogger.Debugf("[Gossip] Too many registers in flight, dropping the remaining peers")
+			return
+		}
+		if !markGossipTried(peer) {
+			<-gossipSem
+			continue
+		}
+		go func(peer types.GossipPeer) {
+			defer func() { <-gossipSem }()
+			registerGossipPeer(peer)
+		}(peer)
+	}
+}
+
+// isGossipPeerAddr reports whether a gossiped peer at ip may be contacted: a private address,
+// or one on the subnet of a local interface. Loopback, unspecified and multicast addresses never are.
+func isGossipPeerAddr(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	if ip.IsPrivate() {
+		return true
+	}
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// markGossipTried reports whether peer may be contacted now and records the attempt.
+func markGossipTried(peer types.GossipPeer) bool {
+	key := net.JoinHostPort(peer.Ip, strconv.Itoa(peer.Port))
+	now := time.Now()
+	gossipTriedMu.Lock()
+	defer gossipTriedMu.Unlock()
+	if last, ok := gossipTried[key]; ok && now.Sub(last) < gossipRetryInterval {
+		return false
+	}
+	for k, last := range gossipTried {
+		if now.Sub(last) >= gossipRetryInterval {
+			delete(gossipTried, k)
+		}
+	}
+	gossipTried[key] = now
+	return true
+}
+
+// registerGossipPeer sends our register message to a gossiped peer and stores it on success.
+// The peer learns about us from the request, and its response may gossip further peers.
+func registerGossipPeer(peer types.GossipPeer) {
+	config := GetScanConfig()
+	if config == nil || config.SelfHTTP == nil {
+		return
+	}
+	payload, err := sonic.Marshal(config.SelfHTTP)
+	if err != nil {
+		return
+	}
+	protocol := peer.Protocol
+	if protocol != "http" {
+		protocol = "https"
+	}
+	url := tool.BuildScanOnceRegisterUrl(protocol, peer.Ip, peer.Port)
+	req, err := tool.NewHTTPReqWithApplication(http.NewRequest("POST", url, bytes.NewReader(payload)))
+	if err != nil {
+		return
+	}
+	resp, err := tool.GetScanHttpClient().Do(req)
+	if err != nil {
+		tool.DefaultLogger.Debugf("[Gossip] Failed to register with %s: %v", url, err)
+		return
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close response body: %v", err)
+		}
+	}()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		tool.DefaultLogger.Debugf("[Gossip] Register with %s failed: %s", url, resp.Status)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxGossipResponseSize))
+	if err != nil {
+		return
+	}
+	var remote types.CallbackLegacyVersionMessageHTTP
+	if err := sonic.Unmarshal(body, &remote); err != nil || remote.Fingerprint == "" {
+		return
+	}
+	tool.DefaultLogger.Infof("[Gossip] Discovered %s at %s via peer list", remote.Alias, url)
+	share.SetUserScanCurrent(remote.Fingerprint, types.UserScanCurrentItem{
+		Ipaddress: peer.Ip,
+		VersionMessage: types.VersionMessage{
+			Alias:        remote.Alias,
+			Version:      remote.Version,
+			DeviceModel:  remote.DeviceModel,
+			DeviceType:   remote.DeviceType,
+			Fingerprint:  remote.Fingerprint,
+			Port:         peer.Port,
+			Protocol:     protocol,
+			Download:     remote.Download,
+			Announce:     true,
+			Capabilities: remote.Capabilities,
+			Note:         remote.Note,
+		},
+	})
+	learnGossipPeers(remote.Peers)
+}
@@ -0,0 +1,39 @@
+package boardcast
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsGossipPeerAddr(t *testing.T) {
+	previous := interfaceAddrs
+	t.Cleanup(func() { interfaceAddrs = previous })
+	interfaceAddrs = func() ([]net.Addr, error) {
+		_, tailnet, _ := net.ParseCIDR("100.64.0.0/10")
+		_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+		return []net.Addr{tailnet, loopback}, nil
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "192.168.1.20", want: true},
+		{ip: "10.0.0.5", want: true},
+		{ip: "172.16.3.4", want: true},
+		{ip: "fd00::1", want: true},
+		{ip: "100.100.1.2", want: true}, // on the subnet of a local interface
+		{ip: "8.8.8.8"},
+		{ip: "2001:4860:4860::8888"},
+		{ip: "127.0.0.1"},
+		{ip: "::1"},
+		{ip: "0.0.0.0"},
+		{ip: "224.0.0.167"},
+		{ip: "not-an-ip"},
+	}
+	for _, tt := range tests {
+		if got := isGossipPeerAddr(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("isGossipPeerAddr(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -18,17 +18,97 @@ import (
 )
 
 // HTTPScanOptions configures concurrency and ICMP rate limit for HTTP scan.
-// Concurrency: max concurrent scan goroutines; 0 or large value = effectively unlimited (e.g. scan-now).
+// Concurrency: max concurrent scan goroutines; 0 uses the scan-now cap (see SetScanConcurrency).
 // RateLimitPPS: ICMP probe rate limit (packets per second); 0 = no limit.
 type HTTPScanOptions struct {
 	Concurrency  int // max concurrent workers
 	RateLimitPPS int // 0 = no rate limit
+	// OnProgress, if set, is called after each target is probed. May be called concurrently.
+	OnProgress func(probed, responded, total int)
+	// Timeout stops probing further targets once elapsed; 0 = probe every target.
+	Timeout time.Duration
 }
 
-// scanOneIPHTTP performs ICMP probe (host reachability), then POST register (https then http on EOF), parses response and stores device via share.SetUserScanCurrent.
+// HTTPScanStats summarizes one HTTP sweep.
+type HTTPScanStats struct {
+	Probed    int
+	Responded int
+	Duration  time.Duration
+	TimedOut  bool // Timeout elapsed before every target was probed
+}
+
+// httpSweepStatus tracks the progress of one HTTP sweep. Sweeps may overlap (the periodic scan and
+// a manual refresh), so each keeps its own counters.
+type httpSweepStatus struct {
+	mu     sync.Mutex
+	status types.ScanStatus
+}
+
+var (
+	latestHTTPSweepMu sync.RWMutex
+	// latestHTTPSweep is the most recently started sweep, reported by the scan-status API
+	latestHTTPSweep *httpSweepStatus
+)
+
+// GetHTTPScanStatus returns the progress of the current (or last) HTTP sweep.
+// While sweeps overlap it reports the one started last.
+func GetHTTPScanStatus() types.ScanStatus {
+	latestHTTPSweepMu.RLock()
+	sweep := latestHTTPSweep
+	latestHTTPSweepMu.RUnlock()
+	if sweep == nil {
+		return types.ScanStatus{}
+	}
+	sweep.mu.Lock()
+	defer sweep.mu.Unlock()
+	return sweep.status
+}
+
+// startHTTPScanStatus starts tracking a new sweep of total targets and makes it the one reported.
+func startHTTPScanStatus(total int) *httpSweepStatus {
+	sweep := &httpSweepStatus{status: types.ScanStatus{
+		Running:   true,
+		Total:     total,
+		StartedAt: time.Now().Format(time.RFC3339),
+	}}
+	latestHTTPSweepMu.Lock()
+	defer latestHTTPSweepMu.Unlock()
+	latestHTTPSweep = sweep
+	return sweep
+}
+
+// update records one probed target of the sweep and returns its new counters.
+func (s *httpSweepStatus) update(responded bool) (probedCount, respondedCount, total int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Probed++
+	if responded {
+		s.status.Responded++
+	}
+	return s.status.Probed, s.status.Responded, s.status.Total
+}
+
+// finish marks the sweep as done.
+func (s *httpSweepStatus) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = false
+	s.status.FinishedAt = time.Now().Format(time.RFC3339)
+}
+
+// probeHost checks host reachability before the register request: ICMP by default,
+// or a TCP connect to the LocalSend port when ICMP probing is disabled.
+func probeHost(targetIP string) bool {
+	if skipICMPProbe.Load() {
+		return tool.QuickTCPProbe(targetIP, multcastPort, tcpProbeTimeout)
+	}
+	return tool.QuickICMPProbe(targetIP, icmpProbeTimeout)
+}
+
+// scanOneIPHTTP performs a reachability probe (ICMP or TCP, see probeHost), then POST register (https then http on EOF), parses response and stores device via share.SetUserScanCurrent.
 // Used by ListenMulticastUsingHTTPWithTimeout and ScanOnceHTTP. Returns true if a device was discovered and stored.
 func scanOneIPHTTP(targetIP string, payloadBytes []byte, httpClient *http.Client) bool {
-	if !tool.QuickICMPProbe(targetIP, icmpProbeTimeout) {
+	if !probeHost(targetIP) {
 		return false
 	}
 	protocol := "https"
@@ -76,19 +156,22 @@ func scanOneIPHTTP(targetIP string, payloadBytes []byte, httpClient *http.Client
 		return false
 	}
 	tool.DefaultLogger.Infof("scanOneIPHTTP: discovered device at %s: %s (fingerprint: %s)", urlStr, remote.Alias, remote.Fingerprint)
+	learnGossipPeers(remote.Peers)
 	if remote.Fingerprint != "" {
 		share.SetUserScanCurrent(remote.Fingerprint, types.UserScanCurrentItem{
 			Ipaddress: targetIP,
 			VersionMessage: types.VersionMessage{
-				Alias:       remote.Alias,
-				Version:     remote.Version,
-				DeviceModel: remote.DeviceModel,
-				DeviceType:  remote.DeviceType,
-				Fingerprint: remote.Fingerprint,
-				Port:        multcastPort,
-				Protocol:    globalProtocol,
-				Download:    remote.Download,
-				Announce:    true,
+				Alias:        remote.Alias,
+				Version:      remote.Version,
+				DeviceModel:  remote.DeviceModel,
+				DeviceType:   remote.DeviceType,
+				Fingerprint:  remote.Fingerprint,
+				Port:         multcastPort,
+				Protocol:     globalProtocol,
+				Download:     remote.Download,
+				Announce:     true,
+				Capabilities: remote.Capabilities,
+				Note:         remote.Note,
 			},
 		})
 		return true
@@ -116,12 +199,18 @@ func sendRegisterRequest(url string, payload string) error {
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
 		return fmt.Errorf("register request failed: %s", resp.Status)
 	}
+	if gossipEnabled.Load() {
+		var remote types.CallbackLegacyVersionMessageHTTP
+		if body, err := io.ReadAll(resp.Body); err == nil && sonic.Unmarshal(body, &remote) == nil {
+			learnGossipPeers(remote.Peers)
+		}
+	}
 	return nil
 }
 
 // ListenMulticastUsingHTTPWithTimeout is the same as ListenMulticastUsingHTTP but with configurable timeout.
 // timeout: total duration in seconds after which scanning stops. 0 means no timeout.
-// skipInitialScan: if true (e.g. after scan-now), do not run initial scan; first scan in 30s.
+// skipInitialScan: if true (e.g. after scan-now), do not run initial scan; first scan after one interval.
 func ListenMulticastUsingHTTPWithTimeout(self *types.VersionMessageHTTP, timeout int, skipInitialScan bool) {
 	if self == nil {
 		tool.DefaultLogger.Warn("ListenMulticastUsingHTTP: self is nil")
@@ -142,13 +231,14 @@ func ListenMulticastUsingHTTPWithTimeout(self *types.VersionMessageHTTP, timeout
 		autoScanControlMu.Unlock()
 	}()
 
+	interval := GetHTTPScanInterval()
 	if timeout > 0 {
-		tool.DefaultLogger.Infof("Starting Legacy Mode HTTP scanning (scanning every 30 seconds, timeout: %d seconds)", timeout)
+		tool.DefaultLogger.Infof("Starting Legacy Mode HTTP scanning (scanning every %s, timeout: %d seconds)", interval, timeout)
 	} else {
-		tool.DefaultLogger.Info("Starting Legacy Mode HTTP scanning (scanning every 30 seconds, no timeout)")
+		tool.DefaultLogger.Infof("Starting Legacy Mode HTTP scanning (scanning every %s, no timeout)", interval)
 	}
 
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	var timeoutTimer *time.Timer
@@ -176,8 +266,8 @@ func ListenMulticastUsingHTTPWithTimeout(self *types.VersionMessageHTTP, timeout
 	startTime := time.Now()
 
 	scanOnce := func() {
-		opts := &HTTPScanOptions{Concurrency: autoScanConcurrencyLimit, RateLimitPPS: autoScanICMPRatePPS}
-		if err := ScanOnceHTTP(self, opts); err != nil {
+		opts := &HTTPScanOptions{Concurrency: int(autoScanConcurrency.Load()), RateLimitPPS: autoScanICMPRatePPS}
+		if _, err := ScanOnceHTTP(currentSelfHTTP(self), opts); err != nil {
 			tool.DefaultLogger.Warnf("ListenMulticastUsingHTTP: scan failed: %v", err)
 		}
 	}
@@ -185,7 +275,7 @@ func ListenMulticastUsingHTTPWithTimeout(self *types.VersionMessageHTTP, timeout
 	if !skipInitialScan {
 		scanOnce()
 	} else {
-		tool.DefaultLogger.Debug("HTTP scan: skipping initial scan, first scan in 30s")
+		tool.DefaultLogger.Debugf("HTTP scan: skipping initial scan, first scan in %s", interval)
 	}
 
 	for {
@@ -200,7 +290,7 @@ func ListenMulticastUsingHTTPWithTimeout(self *types.VersionMessageHTTP, timeout
 			if !action.SkipHTTPImmediateScan {
 				scanOnce()
 			} else {
-				tool.DefaultLogger.Debug("HTTP scan: restart without immediate scan, next scan in 30s")
+				tool.DefaultLogger.Debugf("HTTP scan: restart without immediate scan, next scan in %s", interval)
 			}
 		case <-ticker.C:
 			if IsScanPaused() {
@@ -214,27 +304,28 @@ func ListenMulticastUsingHTTPWithTimeout(self *types.VersionMessageHTTP, timeout
 
 // ScanOnceHTTP performs a single HTTP scan for devices.
 // opts: nil or RateLimitPPS=0 and Concurrency=0 means unlimited (scan-now style).
-func ScanOnceHTTP(self *types.VersionMessageHTTP, opts *HTTPScanOptions) error {
+func ScanOnceHTTP(self *types.VersionMessageHTTP, opts *HTTPScanOptions) (HTTPScanStats, error) {
+	var stats HTTPScanStats
 	if self == nil {
-		return fmt.Errorf("self message is nil")
+		return stats, fmt.Errorf("self message is nil")
 	}
 	if opts == nil {
-		opts = &HTTPScanOptions{Concurrency: scanNowHTTPConcurrency, RateLimitPPS: 0}
+		opts = &HTTPScanOptions{Concurrency: int(scanNowConcurrency.Load()), RateLimitPPS: 0}
 	}
 	concurrency := opts.Concurrency
 	if concurrency <= 0 {
-		concurrency = scanNowHTTPConcurrency
+		concurrency = int(scanNowConcurrency.Load())
 	}
 	payloadBytes, err := sonic.Marshal(self)
 	if err != nil {
-		return fmt.Errorf("failed to marshal self message: %v", err)
+		return stats, fmt.Errorf("failed to marshal self message: %v", err)
 	}
 	targets, err := getCachedNetworkIPs()
 	if err != nil {
-		return fmt.Errorf("failed to get network IPs: %v", err)
+		return stats, fmt.Errorf("failed to get network IPs: %v", err)
 	}
 	if len(targets) == 0 {
-		return fmt.Errorf("no usable local IPv4 addresses found")
+		return stats, fmt.Errorf("no usable local IPv4 addresses found")
 	}
 	tool.DefaultLogger.Debugf("ScanOnceHTTP: scanning %d IP addresses (concurrency=%d, ratePPS=%d)", len(targets), concurrency, opts.RateLimitPPS)
 
@@ -257,23 +348,56 @@ func ScanOnceHTTP(self *types.VersionMessageHTTP, opts *HTTPScanOptions) error {
 		limiter = rate.NewLimiter(rate.Limit(opts.RateLimitPPS), burst)
 	}
 
+	sweep := startHTTPScanStatus(len(targets))
+	defer sweep.finish()
+	defer tool.CountScanCycle()
+
+	start := time.Now()
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
 	sem := make(chan struct{}, concurrency)
 	var wg sync.WaitGroup
-	ctx := context.Background()
+	var statsMu sync.Mutex
 	for _, ip := range targets {
 		wg.Add(1)
 		go func(targetIP string) {
 			defer wg.Done()
-			sem <- struct{}{}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
 			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
 			if limiter != nil {
 				if err := limiter.Wait(ctx); err != nil {
 					return
 				}
 			}
-			scanOneIPHTTP(targetIP, payloadBytes, tool.GetScanHttpClient())
+			found := scanOneIPHTTP(targetIP, payloadBytes, tool.GetScanHttpClient())
+			probed, responded, total := sweep.update(found)
+			statsMu.Lock()
+			stats.Probed++
+			if found {
+				stats.Responded++
+			}
+			statsMu.Unlock()
+			if opts.OnProgress != nil {
+				opts.OnProgress(probed, responded, total)
+			}
 		}(ip)
 	}
 	wg.Wait()
-	return nil
+	stats.Duration = time.Since(start)
+	stats.TimedOut = stats.Probed < len(targets) && ctx.Err() != nil
+	if stats.TimedOut {
+		tool.DefaultLogger.Infof("ScanOnceHTTP: stopped after %s with %d/%d IPs probed", opts.Timeout, stats.Probed, len(targets))
+	}
+	return stats, nil
 }
@@ -0,0 +1,24 @@
+package boardcast
+
+import "testing"
+
+func TestOverlappingSweepsKeepTheirOwnStatus(t *testing.T) {
+	periodic := startHTTPScanStatus(10)
+	manual := startHTTPScanStatus(5)
+	for range 3 {
+		periodic.update(true)
+	}
+	if probed, responded, total := manual.update(false); probed != 1 || responded != 0 || total != 5 {
+		t.Errorf("manual sweep counters = %d/%d of %d, want 1/0 of 5", probed, responded, total)
+	}
+	periodic.finish()
+
+	status := GetHTTPScanStatus()
+	if !status.Running || status.Total != 5 || status.Probed != 1 || status.Responded != 0 {
+		t.Errorf("status %+v, want the running manual sweep with 1 of 5 probed", status)
+	}
+	manual.finish()
+	if status := GetHTTPScanStatus(); status.Running || status.FinishedAt == "" {
+		t.Errorf("status %+v after the manual sweep finished, want done", status)
+	}
+}
@@ -0,0 +1,63 @@
+package boardcast
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/moyoez/localsend-go/tool"
+)
+
+// WatchNetworkChanges polls the network interfaces and refreshes discovery when addresses change
+// (e.g. Wi-Fi reconnects with a new IP). On change it invalidates the scan target cache,
+// rebinds the multicast listeners and restarts the auto scan loops. Blocks forever.
+func WatchNetworkChanges() {
+	lastSnapshot := networkSnapshot()
+	ticker := time.NewTicker(networkWatchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		snapshot := networkSnapshot()
+		if snapshot == lastSnapshot {
+			continue
+		}
+		tool.DefaultLogger.Infof("[NetWatch] Network change detected, refreshing discovery")
+		tool.DefaultLogger.Debugf("[NetWatch] %s -> %s", lastSnapshot, snapshot)
+		lastSnapshot = snapshot
+
+		invalidateNetworkIPsCache()
+		RefreshOutgoingBindAddr()
+		restartUDPListeners()
+		RestartAutoScan(false)
+	}
+}
+
+// networkSnapshot returns a stable string describing the up interfaces and their IPv4 addresses.
+func networkSnapshot() string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		tool.DefaultLogger.Debugf("[NetWatch] Failed to list interfaces: %v", err)
+		return ""
+	}
+
+	var entries []string
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil {
+				continue
+			}
+			entries = append(entries, iface.Name+"="+ipnet.String())
+		}
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ";")
+}
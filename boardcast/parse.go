@@ -3,11 +3,62 @@ package boardcast
 import (
 	"fmt"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/tool"
 	"github.com/moyoez/localsend-go/types"
 )
 
+// udpParseErrorLogInterval is the minimum gap between logged UDP parse errors; the rest are counted.
+const udpParseErrorLogInterval = 10 * time.Second
+
+// udpParseErrors rate-limits logging of malformed multicast packets.
+var udpParseErrors parseErrorLimiter
+
+// parseErrorLimiter logs at most one parse error per udpParseErrorLogInterval and reports how many were suppressed.
+type parseErrorLimiter struct {
+	mu         sync.Mutex
+	lastLogged time.Time
+	suppressed int
+}
+
+// report logs the parse error of a packet from addr, or counts it if one was logged recently.
+func (l *parseErrorLimiter) report(addr net.Addr, reason string) {
+	l.mu.Lock()
+	now := time.Now()
+	if now.Sub(l.lastLogged) < udpParseErrorLogInterval {
+		l.suppressed++
+		l.mu.Unlock()
+		return
+	}
+	suppressed := l.suppressed
+	l.lastLogged, l.suppressed = now, 0
+	l.mu.Unlock()
+
+	if suppressed > 0 {
+		tool.DefaultLogger.Warnf("Failed to parse UDP message from %v: %s (%d similar suppressed)", addr, reason, suppressed)
+		return
+	}
+	tool.DefaultLogger.Warnf("Failed to parse UDP message from %v: %s", addr, reason)
+}
+
+// looksLikeJSONObject reports whether b starts with '{' after optional whitespace.
+func looksLikeJSONObject(b []byte) bool {
+	for _, ch := range b {
+		switch ch {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
 // CastToUDPAddr casts the address to a UDP address.
 // Made public for reuse in other packages.
 func CastToUDPAddr(addr net.Addr) (*net.UDPAddr, error) {
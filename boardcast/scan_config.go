@@ -30,13 +30,58 @@ func GetScanConfig() *types.ScanConfig {
 	return currentScanConfig
 }
 
+// currentSelfMessage returns the announce message of the scan config, or message when none is set.
+// Running loops call it before every send, since SetSelfFingerprint replaces the message instead of changing it.
+func currentSelfMessage(message *types.VersionMessage) *types.VersionMessage {
+	if config := GetScanConfig(); config != nil && config.SelfMessage != nil {
+		return config.SelfMessage
+	}
+	return message
+}
+
+// currentSelfHTTP is currentSelfMessage for the HTTP register message.
+func currentSelfHTTP(self *types.VersionMessageHTTP) *types.VersionMessageHTTP {
+	if config := GetScanConfig(); config != nil && config.SelfHTTP != nil {
+		return config.SelfHTTP
+	}
+	return self
+}
+
+// SetSelfFingerprint updates the fingerprint used in announce/register messages and announces it once.
+// The scan config and its messages are replaced by updated copies, so readers never see a partial change.
+func SetSelfFingerprint(fingerprint string) {
+	currentScanConfigMu.Lock()
+	if currentScanConfig != nil {
+		next := *currentScanConfig
+		if next.SelfMessage != nil {
+			message := *next.SelfMessage
+			message.Fingerprint = fingerprint
+			next.SelfMessage = &message
+		}
+		if next.SelfHTTP != nil {
+			self := *next.SelfHTTP
+			self.Fingerprint = fingerprint
+			next.SelfHTTP = &self
+		}
+		currentScanConfig = &next
+	}
+	config := currentScanConfig
+	currentScanConfigMu.Unlock()
+
+	if config != nil && config.SelfMessage != nil {
+		if err := ScanOnceUDP(config.SelfMessage); err != nil {
+			tool.DefaultLogger.Warnf("Failed to announce new fingerprint: %v", err)
+		}
+	}
+}
+
 // ScanOnceUDP sends a single UDP multicast message to trigger device discovery.
 func ScanOnceUDP(message *types.VersionMessage) error {
 	return SendMulticastOnce(message)
 }
 
 // RestartAutoScan sends a restart signal to all running auto scan loops.
-// skipHTTPImmediateScan: if true (e.g. after scan-now), HTTP loop only resets timeout; next scan in 30s.
+// skipHTTPImmediateScan: if true (e.g. after scan-now), HTTP loop only resets timeout; next scan after one interval.
 func RestartAutoScan(skipHTTPImmediateScan bool) {
 	autoScanControlMu.Lock()
 	defer autoScanControlMu.Unlock()
@@ -58,10 +103,10 @@ func RestartAutoScan(skipHTTPImmediateScan bool) {
 // scanNowRestartAutoScan restarts or resumes auto scan after scan-now completes.
 func scanNowRestartAutoScan(config *types.ScanConfig) {
 	if IsAutoScanRunning() {
-		tool.DefaultLogger.Debug("Auto scan is running, sending restart signal (HTTP next scan in 30s)")
+		tool.DefaultLogger.Debugf("Auto scan is running, sending restart signal (HTTP next scan in %s)", GetHTTPScanInterval())
 		RestartAutoScan(true)
 	} else {
-		tool.DefaultLogger.Info("Auto scan has stopped, restarting auto scan loops (HTTP first scan in 30s)")
+		tool.DefaultLogger.Infof("Auto scan has stopped, restarting auto scan loops (HTTP first scan in %s)", GetHTTPScanInterval())
 		restartAutoScanLoops(config, true)
 	}
 }
@@ -77,33 +122,49 @@ func IsAutoScanRunning() bool {
 // - scan-now: executes HTTP scan only; returns after HTTP scan completes so API can return device list.
 // - other/normal scan: unchanged (auto scan by Mode: UDP, HTTP, or Mixed runs in background).
 // When SelfHTTP is nil, falls back to legacy one-shot by Mode.
+// The HTTP sweep stops after HTTPTimeout (default 60s); the result reports what ran.
 // Returns error if scan config is not set or scan fails.
-func ScanNow() error {
+func ScanNow() (types.ScanNowResult, error) {
 	config := GetScanConfig()
 	if config == nil {
-		return fmt.Errorf("scan config not set")
+		return types.ScanNowResult{}, fmt.Errorf("scan config not set")
 	}
 
 	tool.DefaultLogger.Info("Performing manual scan (HTTP)...")
 
 	if config.SelfHTTP != nil {
-		tool.DefaultLogger.Debug("scan-now: executing HTTP scan with default background scan options...")
-		scanNowOpts := &HTTPScanOptions{Concurrency: autoScanConcurrencyLimit, RateLimitPPS: autoScanICMPRatePPS}
+		tool.DefaultLogger.Debug("scan-now: executing HTTP scan with the scan-now concurrency...")
+		sweepTimeout := config.HTTPTimeout
+		if sweepTimeout <= 0 {
+			sweepTimeout = 60
+		}
+		scanNowOpts := &HTTPScanOptions{
+			Concurrency:  int(scanNowConcurrency.Load()),
+			RateLimitPPS: autoScanICMPRatePPS,
+			Timeout:      time.Duration(sweepTimeout) * time.Second,
+		}
 
-		// 1. First scan (wait for full completion)
-		if err := ScanOnceHTTP(config.SelfHTTP, scanNowOpts); err != nil {
-			return err
+		// 1. First scan (wait for completion or timeout)
+		stats, err := ScanOnceHTTP(config.SelfHTTP, scanNowOpts)
+		if err != nil {
+			return types.ScanNowResult{}, err
+		}
+		result := types.ScanNowResult{
+			Mode:        types.ScanModeHTTP.String(),
+			ProbedCount: stats.Probed,
+			DurationMs:  stats.Duration.Milliseconds(),
+			TimedOut:    stats.TimedOut,
 		}
 
 		// 2. Check if devices found after first scan
 		if len(share.ListUserScanCurrent()) > 0 {
 			go scanNowRestartAutoScan(config)
-			return nil
+			return result, nil
 		}
 
 		// 3. No devices found: start background retry loop (non-blocking)
 		go scanNowBackgroundLoop(config, scanNowOpts)
-		return nil
+		return result, nil
 	}
 
 	go func() {
@@ -116,16 +177,23 @@ func ScanNow() error {
 		}
 	}()
 
+	start := time.Now()
+	result := func() types.ScanNowResult {
+		return types.ScanNowResult{Mode: config.Mode.String(), DurationMs: time.Since(start).Milliseconds()}
+	}
 	switch config.Mode {
 	case types.ScanModeUDP:
 		if config.SelfMessage == nil {
-			return fmt.Errorf("self message not configured for UDP scan")
+			return types.ScanNowResult{}, fmt.Errorf("self message not configured for UDP scan")
 		}
 		tool.DefaultLogger.Debug("Sending UDP multicast scan...")
-		return ScanOnceUDP(config.SelfMessage)
+		if err := ScanOnceUDP(config.SelfMessage); err != nil {
+			return types.ScanNowResult{}, err
+		}
+		return result(), nil
 
 	case types.ScanModeHTTP:
-		return fmt.Errorf("self HTTP message not configured for HTTP scan")
+		return types.ScanNowResult{}, fmt.Errorf("self HTTP message not configured for HTTP scan")
 
 	case types.ScanModeMixed:
 		var udpErr error
@@ -143,29 +211,30 @@ func ScanNow() error {
 		}
 		wg.Wait()
 		if udpErr != nil {
-			return udpErr
+			return types.ScanNowResult{}, udpErr
 		}
-		return nil
+		return result(), nil
 
 	default:
-		return fmt.Errorf("unknown scan mode: %d", config.Mode)
+		return types.ScanNowResult{}, fmt.Errorf("unknown scan mode: %d", config.Mode)
 	}
 }
 
 // scanNowBackgroundLoop runs in a background goroutine after scan-now returns empty.
-// It retries HTTP scanning every 30s, up to HTTPTimeout (default 60s).
+// It retries HTTP scanning every HTTP scan interval (default 30s), up to HTTPTimeout (default 60s).
 // Exits early if devices are found. On exit, restarts normal auto scan.
 func scanNowBackgroundLoop(config *types.ScanConfig, opts *HTTPScanOptions) {
 	httpTimeout := config.HTTPTimeout
 	if httpTimeout <= 0 {
 		httpTimeout = 60
 	}
-	tool.DefaultLogger.Infof("scan-now: no devices found, starting background retry loop (30s interval, %ds timeout)", httpTimeout)
+	interval := GetHTTPScanInterval()
+	tool.DefaultLogger.Infof("scan-now: no devices found, starting background retry loop (%s interval, %ds timeout)", interval, httpTimeout)
 
 	timeoutTimer := time.NewTimer(time.Duration(httpTimeout) * time.Second)
 	defer timeoutTimer.Stop()
 
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -179,7 +248,7 @@ func scanNowBackgroundLoop(config *types.ScanConfig, opts *HTTPScanOptions) {
 				tool.DefaultLogger.Debug("scan-now: background loop paused, skipping this tick")
 				continue
 			}
-			if err := ScanOnceHTTP(config.SelfHTTP, opts); err != nil {
+			if _, err := ScanOnceHTTP(config.SelfHTTP, opts); err != nil {
 				tool.DefaultLogger.Warnf("scan-now: background HTTP scan failed: %v", err)
 				continue
 			}
@@ -193,7 +262,7 @@ func scanNowBackgroundLoop(config *types.ScanConfig, opts *HTTPScanOptions) {
 }
 
 // restartAutoScanLoops restarts the auto scan goroutines based on configuration.
-// skipHTTPInitialScan: if true (e.g. after scan-now), HTTP loop does not run initial scan; first scan in 30s.
+// skipHTTPInitialScan: if true (e.g. after scan-now), HTTP loop does not run initial scan; first scan after one interval.
 func restartAutoScanLoops(config *types.ScanConfig, skipHTTPInitialScan bool) {
 	if config == nil {
 		return
@@ -1,27 +1,68 @@
 package boardcast
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/moyoez/localsend-go/share"
 	"github.com/moyoez/localsend-go/tool"
 	"github.com/moyoez/localsend-go/types"
+	"golang.org/x/net/ipv4"
 )
 
+// applyMulticastOptions sets the configured multicast TTL and loopback on an outgoing UDP socket.
+// Failures are logged only: the OS defaults still allow discovery on the local segment.
+func applyMulticastOptions(c *net.UDPConn) {
+	p := ipv4.NewPacketConn(c)
+	if err := p.SetMulticastTTL(int(multicastTTL.Load())); err != nil {
+		tool.DefaultLogger.Warnf("Failed to set multicast TTL: %v", err)
+	}
+	if err := p.SetMulticastLoopback(!skipMulticastLoopback.Load()); err != nil {
+		tool.DefaultLogger.Warnf("Failed to set multicast loopback: %v", err)
+	}
+}
+
+// listenUnicastOnInterface listens for directed UDP announces on the first IPv4 address of iface.
+func listenUnicastOnInterface(iface *net.Interface, port int) (*net.UDPConn, error) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return net.ListenUDP("udp4", &net.UDPAddr{IP: ipNet.IP.To4(), Port: port})
+		}
+	}
+	return nil, fmt.Errorf("no IPv4 address")
+}
+
 // listenOnInterface listens for multicast messages on a specific network interface. (UDP4)
+// When the interface cannot join the multicast group it falls back to unicast UDP on the same port,
+// so announces sent directly to this host are still received.
 func listenOnInterface(iface *net.Interface, addr *net.UDPAddr, self *types.VersionMessage) {
 	interfaceName := iface.Name
 
+	listening := "multicast UDP address " + addr.String()
 	c, err := net.ListenMulticastUDP("udp4", iface, addr)
 	if err != nil {
-		tool.DefaultLogger.Errorf("Failed to listen on multicast UDP address for interface %s: %v", interfaceName, err)
-		return
+		tool.DefaultLogger.Warnf("Failed to join multicast group on interface %s: %v, falling back to unicast UDP", interfaceName, err)
+		c, err = listenUnicastOnInterface(iface, addr.Port)
+		if err != nil {
+			// The port is usually taken by a multicast listener of another interface, which is bound to
+			// every address and so already receives directed announces
+			tool.DefaultLogger.Warnf("Failed to listen on unicast UDP for interface %s: %v", interfaceName, err)
+			return
+		}
+		listening = "unicast UDP address " + c.LocalAddr().String()
 	}
+	trackUDPListener(c)
 	defer func() {
-		if err := c.Close(); err != nil {
+		untrackUDPListener(c)
+		if err := c.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
 			tool.DefaultLogger.Errorf("Failed to close multicast UDP connection: %v", err)
 		}
 	}()
@@ -30,17 +71,23 @@ func listenOnInterface(iface *net.Interface, addr *net.UDPAddr, self *types.Vers
 		tool.DefaultLogger.Errorf("Failed to set read buffer: %v", err)
 	}
 	buf := make([]byte, 1024*8)
-	tool.DefaultLogger.Infof("Listening on multicast UDP address: %s (interface: %s)", addr.String(), interfaceName)
+	tool.DefaultLogger.Infof("Listening on %s (interface: %s)", listening, interfaceName)
 
 	for {
 		n, addr, err := c.ReadFrom(buf)
 		if err == nil {
+			// Other multicast users may share the group/port: drop non-JSON cheaply, log failures rate-limited
+			if !looksLikeJSONObject(buf[:n]) {
+				udpParseErrors.report(addr, "payload is not a JSON object")
+				continue
+			}
 			var incoming types.VersionMessage
 			parseErr := sonic.Unmarshal(buf[:n], &incoming)
 			if parseErr != nil {
-				tool.DefaultLogger.Errorf("Failed to parse UDP message: %v\n", parseErr)
+				udpParseErrors.report(addr, parseErr.Error())
 				continue
 			}
+			self := currentSelfMessage(self)
 			// Ignore non-announce or from self broadcasts.
 			if !tool.ShouldRespond(self, &incoming) {
 				continue
@@ -60,52 +107,117 @@ func listenOnInterface(iface *net.Interface, addr *net.UDPAddr, self *types.Vers
 				// Call the /register callback using HTTP/TCP to send the device information to the remote device.
 				// convert self to CallbackVersionMessageHTTP
 				selfHTTP := &types.CallbackVersionMessageHTTP{
-					Alias:       self.Alias,
-					Version:     self.Version,
-					DeviceModel: self.DeviceModel,
-					DeviceType:  self.DeviceType,
-					Fingerprint: self.Fingerprint,
-					Port:        self.Port,
-					Protocol:    self.Protocol,
-					Download:    self.Download,
+					Alias:        self.Alias,
+					Version:      self.Version,
+					DeviceModel:  self.DeviceModel,
+					DeviceType:   self.DeviceType,
+					Fingerprint:  self.Fingerprint,
+					Port:         self.Port,
+					Protocol:     self.Protocol,
+					Download:     self.Download,
+					Capabilities: self.Capabilities,
+					Note:         self.Note,
 				}
 				if callbackErr := CallbackMulticastMessageUsingTCP(remoteAddr, selfHTTP, &remote); callbackErr != nil {
 					tool.DefaultLogger.Errorf("Failed to callback TCP register: %v\n", callbackErr)
 				}
 			}(incoming, udpAddr)
 		} else {
+			if errors.Is(err, net.ErrClosed) {
+				// listener closed by restartUDPListeners
+				tool.DefaultLogger.Infof("Stopped listening on interface %s", interfaceName)
+				return
+			}
 			// error reading from udp, consider using http.
 			tool.DefaultLogger.Errorf("Error reading from UDP on interface %s: %v\n", interfaceName, err)
 		}
 	}
 }
 
+// trackUDPListener registers an active multicast listener so it can be closed on network change.
+func trackUDPListener(c *net.UDPConn) {
+	udpListenersMu.Lock()
+	defer udpListenersMu.Unlock()
+	udpListeners[c] = struct{}{}
+}
+
+// untrackUDPListener removes a multicast listener from the active set.
+func untrackUDPListener(c *net.UDPConn) {
+	udpListenersMu.Lock()
+	defer udpListenersMu.Unlock()
+	delete(udpListeners, c)
+}
+
+// restartUDPListeners closes all active multicast listeners and signals ListenMulticastUsingUDP to rebind them.
+func restartUDPListeners() {
+	select {
+	case udpListenersRestartCh <- struct{}{}:
+	default:
+		tool.DefaultLogger.Debug("UDP listener restart already pending")
+	}
+}
+
+// closeUDPListeners closes all active multicast listeners.
+func closeUDPListeners() {
+	udpListenersMu.Lock()
+	defer udpListenersMu.Unlock()
+	for c := range udpListeners {
+		if err := c.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close multicast UDP connection: %v", err)
+		}
+	}
+}
+
 // ListenMulticastUsingUDP listens for multicast UDP broadcasts to discover other devices.
 // Only respond to callbacks if the remote device announce=true and is not the same device.
+// Listeners are rebound when restartUDPListeners is called (e.g. on network change).
+// Resolution failures are retried after udpRebindBackoff; the current listeners keep running meanwhile.
 // * With Register Callback
 // * With Prepare-upload Callback
 func ListenMulticastUsingUDP(self *types.VersionMessage) {
-	addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", multcastAddress, multcastPort))
-	if err != nil {
-		tool.DefaultLogger.Fatalf("Failed to resolve UDP address: %v", err)
+	var addr *net.UDPAddr
+	for {
+		var err error
+		addr, err = net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d", multcastAddress, multcastPort))
+		if err == nil {
+			break
+		}
+		tool.DefaultLogger.Warnf("Failed to resolve UDP address: %v, retrying in %s", err, udpRebindBackoff)
+		time.Sleep(udpRebindBackoff)
 	}
 
-	interfaces, err := getNetworkInterfaces()
-	if err != nil {
-		tool.DefaultLogger.Fatalf("Failed to get network interfaces: %v", err)
-	}
+	var wg sync.WaitGroup
+	running := false
+	for {
+		interfaces, err := getNetworkInterfaces()
+		if err != nil {
+			tool.DefaultLogger.Warnf("Failed to get network interfaces: %v, retrying in %s", err, udpRebindBackoff)
+			// a further network change retries right away
+			select {
+			case <-udpListenersRestartCh:
+			case <-time.After(udpRebindBackoff):
+			}
+			continue
+		}
+		if running {
+			tool.DefaultLogger.Info("Restarting multicast UDP listeners")
+			closeUDPListeners()
+			wg.Wait()
+		}
 
-	if len(interfaces) == 1 {
-		// single interface, just run
-		listenOnInterface(interfaces[0], addr, self)
-	} else {
-		// multiple interfaces, start a goroutine for each interface
+		// start a goroutine for each interface
 		tool.DefaultLogger.Infof("Listening on %d network interfaces", len(interfaces))
 		for _, iface := range interfaces {
-			go listenOnInterface(iface, addr, self)
+			wg.Add(1)
+			go func(iface *net.Interface) {
+				defer wg.Done()
+				listenOnInterface(iface, addr, self)
+			}(iface)
 		}
-		// block the main goroutine
-		select {}
+		running = true
+
+		// block until a restart is requested, then resolve the interfaces again before rebinding
+		<-udpListenersRestartCh
 	}
 }
 
@@ -135,10 +247,11 @@ func SendMulticastUsingUDPWithTimeout(message *types.VersionMessage, timeout int
 		autoScanControlMu.Unlock()
 	}()
 
+	interval := GetUDPScanInterval()
 	if timeout > 0 {
-		tool.DefaultLogger.Infof("Starting UDP multicast sending (every 30 seconds, timeout: %d seconds)", timeout)
+		tool.DefaultLogger.Infof("Starting UDP multicast sending (every %s, timeout: %d seconds)", interval, timeout)
 	} else {
-		tool.DefaultLogger.Info("Starting UDP multicast sending (every 30 seconds, no timeout)")
+		tool.DefaultLogger.Infof("Starting UDP multicast sending (every %s, no timeout)", interval)
 	}
 
 	var c *net.UDPConn
@@ -147,6 +260,7 @@ func SendMulticastUsingUDPWithTimeout(message *types.VersionMessage, timeout int
 		if dialErr != nil {
 			return dialErr
 		}
+		applyMulticastOptions(conn)
 		if c != nil {
 			_ = c.Close()
 		}
@@ -189,7 +303,7 @@ func SendMulticastUsingUDPWithTimeout(message *types.VersionMessage, timeout int
 	}()
 
 	startTime := time.Now()
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	// Send immediately first
@@ -200,7 +314,7 @@ func SendMulticastUsingUDPWithTimeout(message *types.VersionMessage, timeout int
 				return
 			}
 		}
-		payload, err := sonic.Marshal(message)
+		payload, err := sonic.Marshal(currentSelfMessage(message))
 		if err != nil {
 			tool.DefaultLogger.Errorf("failed to marshal message: %v", err)
 			return
@@ -260,6 +374,7 @@ func SendMulticastOnce(message *types.VersionMessage) error {
 		}
 		return fmt.Errorf("failed to dial UDP address: %v", err)
 	}
+	applyMulticastOptions(c)
 	defer func() {
 		if err := c.Close(); err != nil {
 			tool.DefaultLogger.Errorf("Failed to close multicast UDP connection: %v", err)
@@ -294,6 +409,7 @@ func CallbackMulticastMessageUsingUDP(message *types.VersionMessage) error {
 	if err != nil {
 		return fmt.Errorf("failed to dial UDP address: %v", err)
 	}
+	applyMulticastOptions(c)
 	defer func() {
 		if err := c.Close(); err != nil {
 			tool.DefaultLogger.Errorf("Failed to close multicast UDP connection: %v", err)
@@ -1,24 +1,53 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/charmbracelet/log"
 	"github.com/moyoez/localsend-go/api"
 	"github.com/moyoez/localsend-go/boardcast"
 	"github.com/moyoez/localsend-go/notify"
 	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/transfer"
 	"github.com/moyoez/localsend-go/types"
 )
 
 func main() {
 	// method: always use config first, then flag overwrite config.
 	FlagConfig := tool.SetFlags() // get flags
+	// resolve default relative paths under the data dir, if any
+	if err := tool.SetDataRoot(FlagConfig.UseDataDir); err != nil {
+		tool.DefaultLogger.Fatalf("Failed to create data directory: %v", err)
+	}
+	FlagConfig.UseConfigPath = tool.ResolveDataPath(FlagConfig.UseConfigPath)
+	FlagConfig.UseDefaultUploadFolder = tool.ResolveDataPath(FlagConfig.UseDefaultUploadFolder)
+	FlagConfig.UseAuditLog = tool.ResolveDataPath(FlagConfig.UseAuditLog)
+	FlagConfig.UseHistoryFile = tool.ResolveDataPath(FlagConfig.UseHistoryFile)
 	appCfg, err := tool.LoadConfig(FlagConfig.UseConfigPath)
 	if err != nil {
 		tool.DefaultLogger.Fatalf("%v", err)
 	}
 	tool.InitLogger()
+	// config values for these apply only when the flag is not given
+	if appCfg.Pin != nil && !tool.IsFlagSet("usePin") {
+		FlagConfig.UsePin = *appCfg.Pin
+	}
+	if appCfg.AutoSave != nil && !tool.IsFlagSet("useAutoSave") {
+		FlagConfig.UseAutoSave = *appCfg.AutoSave
+	}
+	if appCfg.UploadFolder != "" && !tool.IsFlagSet("useDefaultUploadFolder") {
+		FlagConfig.UseDefaultUploadFolder = tool.ResolveDataPath(appCfg.UploadFolder)
+	}
 
 	// set user self action.
+	tool.SetUserAgent(FlagConfig.UseUserAgent)
 	message, httpMessage := tool.BuildVersionMessages(&appCfg, FlagConfig)
 	api.SetSelfDevice(message)
 
@@ -35,27 +64,111 @@ func main() {
 	default:
 		tool.DefaultLogger.SetLevel(log.InfoLevel)
 	}
+	api.SetLogStreamEnabled(FlagConfig.UseLogStream)
 
 	// sets here.
 	boardcast.SetMultcastAddress(FlagConfig.UseMultcastAddress)
 	boardcast.SetMultcastPort(FlagConfig.UseMultcastPort)
 	boardcast.SetReferNetworkInterface(FlagConfig.UseReferNetworkInterface)
-	if bindAddr, err := boardcast.GetPreferredOutgoingBindAddr(); err != nil {
-		tool.DefaultLogger.Warnf("GetPreferredOutgoingBindAddr: %v, HTTP clients will use default interface", err)
-		tool.InitHTTPClients(nil)
-	} else {
-		tool.InitHTTPClients(bindAddr)
-	}
+	boardcast.SetSkipICMPProbe(FlagConfig.SkipICMPProbe)
+	boardcast.SetGossip(FlagConfig.UseGossip)
+	boardcast.SetMulticastTTL(FlagConfig.UseMulticastTTL)
+	boardcast.SetSkipMulticastLoopback(FlagConfig.SkipMulticastLoopback)
+	transfer.SetParallelParts(FlagConfig.UseParallelParts)
+	boardcast.SetScanInterval(time.Duration(FlagConfig.UseScanInterval) * time.Second)
+	boardcast.SetUDPScanInterval(time.Duration(FlagConfig.UseUDPScanInterval) * time.Second)
+	boardcast.SetHTTPScanInterval(time.Duration(FlagConfig.UseHTTPScanInterval) * time.Second)
+	boardcast.SetScanConcurrency(FlagConfig.UseAutoScanConcurrency, FlagConfig.UseScanNowConcurrency)
+	boardcast.RefreshOutgoingBindAddr()
+	api.SetIdentityUploadFolder(FlagConfig.UseIdentityUploadFolder)
 	api.SetDefaultUploadFolder(FlagConfig.UseDefaultUploadFolder)
+	if err := os.MkdirAll(FlagConfig.UseDefaultUploadFolder, 0o755); err != nil {
+		tool.DefaultLogger.Warnf("Failed to create upload folder %s: %v", FlagConfig.UseDefaultUploadFolder, err)
+	}
 	api.SetDoNotMakeSessionFolder(FlagConfig.DoNotMakeSessionFolder)
+	if err := api.SetSavePathTemplate(FlagConfig.UseSavePathTemplate); err != nil {
+		tool.DefaultLogger.Fatalf("Invalid -useSavePathTemplate: %v", err)
+	}
+	fileMode, err := strconv.ParseUint(FlagConfig.UseReceivedFileMode, 8, 32)
+	if err != nil || fileMode > 0o777 {
+		tool.DefaultLogger.Fatalf("Invalid -useReceivedFileMode %q: must be octal, e.g. 0644", FlagConfig.UseReceivedFileMode)
+	}
+	dirMode, err := strconv.ParseUint(FlagConfig.UseReceivedDirMode, 8, 32)
+	if err != nil || dirMode > 0o777 {
+		tool.DefaultLogger.Fatalf("Invalid -useReceivedDirMode %q: must be octal, e.g. 0755", FlagConfig.UseReceivedDirMode)
+	}
+	api.SetReceivedFileModes(os.FileMode(fileMode), os.FileMode(dirMode))
+	if FlagConfig.UseEncryptionKeyFile != "" {
+		keyHex, err := os.ReadFile(FlagConfig.UseEncryptionKeyFile)
+		if err != nil {
+			tool.DefaultLogger.Fatalf("Failed to read -useEncryptionKeyFile: %v", err)
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(string(keyHex)))
+		if err != nil {
+			tool.DefaultLogger.Fatalf("Invalid -useEncryptionKeyFile: key must be hex: %v", err)
+		}
+		if err := api.SetEncryptionKey(key); err != nil {
+			tool.DefaultLogger.Fatalf("Invalid -useEncryptionKeyFile: %v", err)
+		}
+		tool.DefaultLogger.Infof("Received files are stored encrypted")
+	}
+	if FlagConfig.UseQuietHours != "" {
+		start, end, _ := strings.Cut(FlagConfig.UseQuietHours, "-")
+		if err := tool.SetQuietHours(strings.TrimSpace(start), strings.TrimSpace(end), FlagConfig.UseQuietHoursAction); err != nil {
+			tool.DefaultLogger.Fatalf("Invalid -useQuietHours: %v", err)
+		}
+		tool.SetQuietHoursPin(FlagConfig.UseQuietHoursPin)
+	}
+	tool.SetAutoAcceptMaxSize(FlagConfig.UseAutoAcceptMaxSize)
+	tool.SetMaxActiveReceiveSessions(FlagConfig.UseMaxReceiveSessions)
+	api.SetSharedSecret(FlagConfig.UseSharedSecret)
+	api.SetWebDAVEnabled(FlagConfig.UseWebDAV)
+	api.SetCommandSharesEnabled(FlagConfig.UseCommandShares)
+	api.SetMaxPrepareUploadFiles(FlagConfig.UseMaxUploadFiles)
+	api.SetSessionTTL(time.Duration(FlagConfig.UseSessionTTL) * time.Second)
+	api.SetUploadStallTimeout(time.Duration(FlagConfig.UseUploadStallTimeout) * time.Second)
+	api.SetKeepPartialOnCancel(FlagConfig.UseKeepPartialOnCancel)
+	api.SetPartialMaxAge(time.Duration(FlagConfig.UsePartialMaxAge) * time.Second)
+	api.SetMaxConcurrentDownloads(FlagConfig.UseMaxConcurrentDownloads)
+	api.SetDownloadRateLimit(FlagConfig.UseDownloadRateLimit)
+	api.SetSlowRequestThreshold(time.Duration(FlagConfig.UseSlowRequestThreshold) * time.Second)
+	tool.SetMaxFileNameBytes(FlagConfig.UseMaxFileNameLength)
+	tool.SetGeneratePreviews(FlagConfig.UseGeneratePreviews)
+	tool.SetPreviewMaxDimension(FlagConfig.UsePreviewMaxDimension)
+	tool.SetMaxPreviewBytes(FlagConfig.UseMaxPreviewBytes)
+	tool.SetFavoriteProbeInterval(time.Duration(FlagConfig.UseFavoriteProbeInterval) * time.Second)
 	tool.SetProgramConfigStatus(FlagConfig.UsePin, FlagConfig.UseAutoSave, FlagConfig.UseAutoSaveFromFavorites)
+	if FlagConfig.UseAutoAcceptSubnets != "" {
+		if err := tool.SetAutoAcceptSubnets(strings.Split(FlagConfig.UseAutoAcceptSubnets, ",")); err != nil {
+			tool.DefaultLogger.Fatalf("Invalid -useAutoAcceptSubnets: %v", err)
+		}
+	}
 	api.SetDefaultWebOutPath(FlagConfig.UseWebOutPath)
+	if FlagConfig.UseImageServeRoots != "" {
+		api.SetImageServeRoots(strings.Split(FlagConfig.UseImageServeRoots, ","))
+	}
 	notify.SetUseNotify(!FlagConfig.SkipNotify)
+	tool.SetAuditLog(FlagConfig.UseAuditLog)
+	if err := tool.SetHistoryFile(FlagConfig.UseHistoryFile); err != nil {
+		tool.DefaultLogger.Warnf("Transfer history not loaded, keeping it in memory only: %v", err)
+	}
+	if err := tool.SetHashCacheFile(FlagConfig.UseHashCacheFile); err != nil {
+		tool.DefaultLogger.Warnf("Hash cache not loaded, keeping it in memory only: %v", err)
+	}
+	tool.SetIdleShutdown(time.Duration(FlagConfig.UseIdleShutdown) * time.Second)
+	if !FlagConfig.SkipConfigWatch {
+		go tool.WatchConfig(func(folder string) {
+			if err := os.MkdirAll(folder, 0o755); err != nil {
+				tool.DefaultLogger.Warnf("Failed to create upload folder %s: %v", folder, err)
+			}
+			api.SetDefaultUploadFolder(folder)
+		})
+	}
 
 	// armed, clear this area. // port should focus on 53317
 	apiServer := api.NewServerWithConfig(53317, message.Protocol, FlagConfig.UseConfigPath)
 	go func() {
-		if err := apiServer.Start(); err != nil {
+		if err := apiServer.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			tool.DefaultLogger.Fatalf("API server startup failed: %v", err)
 			panic(err)
 		}
@@ -67,6 +180,27 @@ func main() {
 	go boardcast.ListenMulticastUsingUDP(message)
 	go boardcast.SendMulticastUsingUDPWithTimeout(message, FlagConfig.ScanTimeout)
 	go boardcast.ListenMulticastUsingHTTPWithTimeout(httpMessage, 60, false)
+	go boardcast.WatchNetworkChanges()
+	go boardcast.WatchFavorites()
+	if FlagConfig.UseKeepPartialOnCancel && FlagConfig.UsePartialMaxAge > 0 {
+		go api.RunPartialCleanup()
+	}
+	go tool.RunIdleWatchdog(func() {
+		if err := notify.SendNotification(&types.Notification{
+			Type:    types.NotifyTypeShutdown,
+			Title:   "LocalSend Stopped",
+			Message: "Stopped after a period without transfers",
+		}, ""); err != nil {
+			tool.DefaultLogger.Warnf("[Idle] Failed to send shutdown notification: %v", err)
+		}
+		// Give in-flight downloads a moment to finish
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := apiServer.Shutdown(ctx); err != nil {
+			tool.DefaultLogger.Warnf("[Idle] API server shutdown: %v", err)
+		}
+		cancel()
+		os.Exit(0)
+	})
 
 	select {}
 }
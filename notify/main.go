@@ -1,7 +1,9 @@
 package notify
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"maps"
@@ -9,6 +11,7 @@ import (
 	"os"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bytedance/sonic"
@@ -19,6 +22,9 @@ import (
 // NotifyWriteChunkSize is the chunk size when writing payload to Unix socket (avoid large single write).
 const NotifyWriteChunkSize = 32 * 1024 // 32KB
 
+// MaxNotifyResponseSize bounds the listener's response body.
+const MaxNotifyResponseSize = 1024 * 1024 // 1MB
+
 // MaxNotifyFiles is the maximum number of files to include in notify payload (truncate if exceeded)
 const MaxNotifyFiles = 20
 
@@ -46,11 +52,96 @@ var (
 	}
 )
 
+// Circuit breaker for when the notify listener is not running
+var (
+	breakerMu           sync.Mutex
+	breakerThreshold    = 3                // consecutive connection failures before backing off
+	breakerCooldown     = 60 * time.Second // how long to stop attempting after tripping
+	consecutiveFailures int
+	breakerOpenUntil    time.Time
+	// outageLogged is set once the unreachable listener was reported; only a successful send clears it,
+	// so the warning is not repeated after every cooldown
+	outageLogged bool
+)
+
 // SetUseNotify sets whether to use notify
 func SetUseNotify(use bool) {
 	UseNotify = use
 }
 
+// SetReconnectBackoff sets how many consecutive connection failures trip the breaker,
+// and how long notifications are skipped afterwards. Non-positive values are ignored.
+func SetReconnectBackoff(threshold int, cooldown time.Duration) {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	if threshold > 0 {
+		breakerThreshold = threshold
+	}
+	if cooldown > 0 {
+		breakerCooldown = cooldown
+	}
+}
+
+// breakerAllows reports whether a connection attempt should be made now.
+func breakerAllows() bool {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	return time.Now().After(breakerOpenUntil)
+}
+
+// recordConnectFailure counts a connection failure and trips the breaker at the threshold.
+// tripped is true if this failure tripped the breaker, logged if the outage was already reported.
+func recordConnectFailure() (tripped, logged bool) {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	logged = outageLogged
+	consecutiveFailures++
+	if consecutiveFailures < breakerThreshold {
+		return false, logged
+	}
+	consecutiveFailures = 0
+	breakerOpenUntil = time.Now().Add(breakerCooldown)
+	outageLogged = true
+	return true, logged
+}
+
+// recordConnectSuccess resets the failure counter.
+func recordConnectSuccess() {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	consecutiveFailures = 0
+	breakerOpenUntil = time.Time{}
+}
+
+// recordSendSuccess clears the outage report after a notification was delivered.
+func recordSendSuccess() {
+	breakerMu.Lock()
+	defer breakerMu.Unlock()
+	outageLogged = false
+}
+
+// connectFailure records a connection failure and returns err until the breaker trips, which logs a single
+// warning. Until a notification is delivered again, further failures return nil and are only debug-logged.
+func connectFailure(err error) error {
+	tripped, logged := recordConnectFailure()
+	switch {
+	case logged:
+		tool.DefaultLogger.Debugf("[Notify] %v", err)
+		return nil
+	case tripped:
+		tool.DefaultLogger.Warnf("[Notify] %v; pausing notifications for %s", err, breakerCooldown)
+		return nil
+	}
+	return err
+}
+
+// connectError marks a failure to reach the notify listener, as opposed to a failed exchange.
+type connectError struct{ err error }
+
+func (e *connectError) Error() string { return e.err.Error() }
+
+func (e *connectError) Unwrap() error { return e.err }
+
 // SendNotification sends notification via Unix Domain Socket
 func SendNotification(notification *types.Notification, socketPath string) error {
 	if !UseNotify {
@@ -67,11 +158,45 @@ func SendNotification(notification *types.Notification, socketPath string) error
 			notification.Data["files"] = files[:MaxNotifyFiles]
 			notification.Data["totalFiles"] = len(files)
 		}
+		if files, ok := notification.Data["files"].([]types.FileInfo); ok {
+			limited := make([]types.FileInfo, len(files))
+			for i, file := range files {
+				file.Preview = tool.LimitPreview(file.FileName, file.FileType, file.Preview)
+				limited[i] = file
+			}
+			notification.Data["files"] = limited
+		}
+	}
+
+	// Skip silently while backing off after repeated connection failures
+	if !breakerAllows() {
+		return nil
+	}
+
+	_, err := deliverNotification(notification, socketPath)
+	var connErr *connectError
+	if errors.As(err, &connErr) {
+		return connectFailure(connErr.err)
+	}
+	return err
+}
+
+// SendTestNotification sends a "Test"/"Hello" info notification regardless of the backoff state
+// and returns the listener's raw response, so integrators can check the socket end to end.
+func SendTestNotification() (string, error) {
+	notification := &types.Notification{
+		Type:    types.NotifyTypeInfo,
+		Title:   "Test",
+		Message: "Hello",
 	}
+	return deliverNotification(notification, DefaultUnixSocketPath)
+}
 
+// deliverNotification writes one length-prefixed notification to the Unix socket and returns the raw response.
+func deliverNotification(notification *types.Notification, socketPath string) (string, error) {
 	// Check if socket file exists
 	if _, err := os.Stat(socketPath); os.IsNotExist(err) {
-		return fmt.Errorf("unix socket not found: %s (is the Python server running?)", socketPath)
+		return "", &connectError{fmt.Errorf("unix socket not found: %s (is the Python server running?)", socketPath)}
 	}
 
 	// Serialize notification data to JSON
@@ -80,22 +205,33 @@ func SendNotification(notification *types.Notification, socketPath string) error
 	if notification != nil {
 		payload, err = sonic.Marshal(notification)
 		if err != nil {
-			return fmt.Errorf("failed to serialize notification data: %v", err)
+			return "", fmt.Errorf("failed to serialize notification data: %v", err)
 		}
 	} else {
 		payload = []byte("{}")
 	}
 
+	// Send a summary referencing the full payload instead of dropping it (see GetNotifyPayload)
+	if notification != nil && len(payload) > NotifyWriteChunkSize {
+		fullSize := len(payload)
+		payload, err = summarizeNotification(notification, payload)
+		if err != nil {
+			return "", fmt.Errorf("failed to serialize notification summary: %v", err)
+		}
+		tool.DefaultLogger.Infof("[Notify] %s payload of %d bytes sent as a summary", notification.Type, fullSize)
+	}
+
 	// Reject payload over 32KB
 	if len(payload) > NotifyWriteChunkSize {
-		return fmt.Errorf("notification payload too large: %d bytes (max %d)", len(payload), NotifyWriteChunkSize)
+		return "", fmt.Errorf("notification payload too large: %d bytes (max %d)", len(payload), NotifyWriteChunkSize)
 	}
 
 	// Connect to Unix socket
 	conn, err := net.DialTimeout("unix", socketPath, UnixSocketTimeout)
 	if err != nil {
-		return fmt.Errorf("failed to connect to Unix socket %s: %v", socketPath, err)
+		return "", &connectError{fmt.Errorf("failed to connect to Unix socket %s: %v", socketPath, err)}
 	}
+	recordConnectSuccess()
 	defer func() {
 		if err := conn.Close(); err != nil {
 			tool.DefaultLogger.Errorf("Failed to close Unix socket connection: %v", err)
@@ -113,7 +249,7 @@ func SendNotification(notification *types.Notification, socketPath string) error
 	binary.LittleEndian.PutUint32(lengthBuf, uint32(len(payload)))
 	_, err = conn.Write(lengthBuf)
 	if err != nil {
-		return fmt.Errorf("failed to write length to Unix socket: %v", err)
+		return "", fmt.Errorf("failed to write length to Unix socket: %v", err)
 	}
 	tool.DefaultLogger.Debugf("Sending notification to Unix socket (len=%d): %s", len(payload), tool.BytesToString(payload))
 	for off := 0; off < len(payload); {
@@ -123,7 +259,7 @@ func SendNotification(notification *types.Notification, socketPath string) error
 		}
 		nw, err := conn.Write(payload[off:chunkEnd])
 		if err != nil {
-			return fmt.Errorf("failed to write payload to Unix socket: %v", err)
+			return "", fmt.Errorf("failed to write payload to Unix socket: %v", err)
 		}
 		off += nw
 	}
@@ -135,34 +271,87 @@ func SendNotification(notification *types.Notification, socketPath string) error
 	}
 
 	// Read response
-	buf := make([]byte, 4096)
-	n, err := conn.Read(buf)
-	if err != nil && err != io.EOF {
-		return fmt.Errorf("failed to read response from Unix socket: %v", err)
+	body, err := readNotifyResponse(conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response from Unix socket: %v", err)
 	}
 
 	// Parse response
 	var response map[string]any
-	if n > 0 {
-		if err := sonic.Unmarshal(buf[:n], &response); err != nil {
-			tool.DefaultLogger.Debugf("Unix socket response (raw): %s", string(buf[:n]))
+	if len(body) > 0 {
+		if err := sonic.Unmarshal(body, &response); err != nil {
+			tool.DefaultLogger.Debugf("Unix socket response (raw): %s", string(body))
 		} else {
 			tool.DefaultLogger.Debugf("Unix socket response: %v", response)
 			// Check for error in response
 			if errMsg, ok := response["error"].(string); ok && errMsg != "" {
-				return fmt.Errorf("server returned error: %s", errMsg)
+				return string(body), fmt.Errorf("server returned error: %s", errMsg)
 			}
 		}
 	}
 
 	// Log success
+	recordSendSuccess()
 	if notification != nil {
 		tool.DefaultLogger.Infof("[UnixSocket] Notification sent: %s - %s", notification.Type, notification.Title)
 	} else {
 		tool.DefaultLogger.Infof("[UnixSocket] Notification sent")
 	}
 
-	return nil
+	return string(body), nil
+}
+
+// readNotifyResponse reads the listener's response until it is complete, the listener closes,
+// or the read deadline passes. Two forms are accepted:
+//   - framed: 4-byte little-endian length, then that many bytes (mirrors the request framing);
+//   - legacy: bare JSON, complete once it parses.
+//
+// They are told apart by the 4th byte: it is 0 in any length prefix below 16MB and never 0 in JSON text.
+// A first read that is neither is a plain-text reply of an older listener and is returned as is.
+func readNotifyResponse(conn net.Conn) ([]byte, error) {
+	var buf []byte
+	chunk := make([]byte, 4096)
+	for {
+		n, err := conn.Read(chunk)
+		first := len(buf) == 0 && n > 0
+		buf = append(buf, chunk[:n]...)
+		if first && !(len(buf) >= 4 && buf[3] == 0) && !isJSONPrefix(buf) {
+			return buf, nil
+		}
+		if len(buf) >= 4 && buf[3] == 0 {
+			size := int(binary.LittleEndian.Uint32(buf[:4]))
+			if size > MaxNotifyResponseSize {
+				return nil, fmt.Errorf("response too large: %d bytes (max %d)", size, MaxNotifyResponseSize)
+			}
+			if len(buf)-4 >= size {
+				return buf[4 : 4+size], nil
+			}
+		} else if len(buf) > 0 && sonic.Valid(buf) {
+			return buf, nil
+		}
+		if len(buf) > MaxNotifyResponseSize+4 {
+			return nil, fmt.Errorf("response too large: over %d bytes", MaxNotifyResponseSize)
+		}
+		if err != nil {
+			if err == io.EOF {
+				// listener closed: return what was sent, complete or not
+				if len(buf) >= 4 && buf[3] == 0 {
+					return buf[4:], nil
+				}
+				return buf, nil
+			}
+			if len(buf) > 0 {
+				return nil, fmt.Errorf("incomplete response (%d bytes): %v", len(buf), err)
+			}
+			return nil, err
+		}
+	}
+}
+
+// isJSONPrefix reports whether data may be the start of a JSON object, array or string.
+func isJSONPrefix(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) == 0 || data[0] == '{' || data[0] == '[' || data[0] == '"'
 }
 
 // SendUploadNotification sends upload-related notifications using Unix Domain Socket.
@@ -332,15 +521,22 @@ func SendTextReceivedNotification(from, title, content, fileName, sessionId stri
 	return SendNotification(notification, DefaultUnixSocketPath)
 }
 
-// SendUploadCancelledNotification notifies Decky that the sender cancelled the upload (receiver side).
-func SendUploadCancelledNotification(sessionId string) error {
+// SendUploadCancelledNotification notifies Decky that the upload was cancelled (receiver side).
+// reason (e.g. types.CancelReasonUserCancelled) is forwarded as data.reason when not empty.
+func SendUploadCancelledNotification(sessionId, reason string) error {
+	message := "Transfer was cancelled by the sender"
+	data := map[string]any{
+		"sessionId": sessionId,
+	}
+	if reason != "" {
+		message += " (" + reason + ")"
+		data["reason"] = reason
+	}
 	notification := &types.Notification{
 		Type:    types.NotifyTypeUploadCancelled,
 		Title:   "Upload Cancelled",
-		Message: "Transfer was cancelled by the sender",
-		Data: map[string]any{
-			"sessionId": sessionId,
-		},
+		Message: message,
+		Data:    data,
 	}
 	return SendNotification(notification, DefaultUnixSocketPath)
 }
@@ -364,7 +560,8 @@ func SendUploadProgressNotification(sessionId string, totalFiles, successFiles,
 
 // SendSendProgressNotification notifies Decky of send progress (sender side, during upload-batch).
 // Called after each file completes so the sender UI can show incremental progress (e.g. 1/10, 2/10).
-func SendSendProgressNotification(sessionId, fileId string, success bool, errMsg string, completedCount, totalFiles int, fileName string) error {
+// stats adds bytes sent, current speed and ETA (etaSeconds is -1 when unknown).
+func SendSendProgressNotification(sessionId, fileId string, success bool, errMsg string, completedCount, totalFiles int, fileName string, stats types.TransferStats) error {
 	data := map[string]any{
 		"sessionId":      sessionId,
 		"fileId":         fileId,
@@ -373,6 +570,10 @@ func SendSendProgressNotification(sessionId, fileId string, success bool, errMsg
 		"completedCount": completedCount,
 		"totalFiles":     totalFiles,
 		"fileName":       fileName,
+		"bytesSent":      stats.BytesSent,
+		"totalBytes":     stats.TotalBytes,
+		"bytesPerSecond": stats.BytesPerSecond,
+		"etaSeconds":     stats.EtaSeconds,
 	}
 	notification := &types.Notification{
 		Type:  types.NotifyTypeSendProgress,
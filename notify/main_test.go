@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+// serveNotifications answers every framed notification on socketPath with an empty JSON object.
+func serveNotifications(t *testing.T, socketPath string) {
+	t.Helper()
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("unix sockets are not available: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			lengthBuf := make([]byte, 4)
+			if _, err := io.ReadFull(conn, lengthBuf); err == nil {
+				_, _ = io.CopyN(io.Discard, conn, int64(binary.LittleEndian.Uint32(lengthBuf)))
+				_, _ = conn.Write([]byte("{}"))
+			}
+			conn.Close()
+		}
+	}()
+}
+
+func TestConnectFailureReportedOncePerOutage(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	previousThreshold, previousCooldown := breakerThreshold, breakerCooldown
+	SetReconnectBackoff(2, cooldown)
+	t.Cleanup(func() {
+		SetReconnectBackoff(previousThreshold, previousCooldown)
+		recordConnectSuccess()
+		recordSendSuccess()
+	})
+	recordConnectSuccess()
+	recordSendSuccess()
+
+	socketPath := filepath.Join(t.TempDir(), "notify.sock")
+	notification := &types.Notification{Type: types.NotifyTypeInfo, Title: "Test"}
+	send := func() error { return SendNotification(notification, socketPath) }
+
+	if err := send(); err == nil {
+		t.Fatal("first failure was not reported")
+	}
+	if err := send(); err != nil {
+		t.Fatalf("failure that trips the breaker returned %v, want nil with a warning", err)
+	}
+	for cycle := range 2 {
+		time.Sleep(2 * cooldown)
+		for attempt := range 2 {
+			if err := send(); err != nil {
+				t.Fatalf("cooldown %d, attempt %d: outage reported again: %v", cycle, attempt, err)
+			}
+		}
+	}
+
+	// A delivered notification ends the outage, so the next one is reported again
+	time.Sleep(2 * cooldown)
+	serveNotifications(t, socketPath)
+	if err := send(); err != nil {
+		t.Fatalf("send to a running listener: %v", err)
+	}
+	if err := SendNotification(notification, filepath.Join(t.TempDir(), "missing.sock")); err == nil {
+		t.Fatal("failure after a successful send was not reported")
+	}
+}
+
+func TestReadNotifyResponse(t *testing.T) {
+	framed := func(payload string) []string {
+		prefix := binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))
+		return []string{string(prefix) + payload}
+	}
+	tests := []struct {
+		name    string
+		writes  []string // written one after another; the listener keeps the connection open afterwards
+		want    string
+		wantErr bool
+	}{
+		{name: "framed", writes: framed(`{"ok":true}`), want: `{"ok":true}`},
+		{name: "legacy JSON in pieces", writes: []string{`{"ok":`, `true}`}, want: `{"ok":true}`},
+		{name: "plain-text reply", writes: []string{"OK\n"}, want: "OK\n"},
+		{name: "short plain-text reply", writes: []string{"ok"}, want: "ok"},
+		{name: "incomplete JSON", writes: []string{`{"ok":`}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, listener := net.Pipe()
+			t.Cleanup(func() {
+				client.Close()
+				listener.Close()
+			})
+			go func() {
+				for _, data := range tt.writes {
+					if _, err := listener.Write([]byte(data)); err != nil {
+						return
+					}
+				}
+			}()
+			if err := client.SetReadDeadline(time.Now().Add(200 * time.Millisecond)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readNotifyResponse(client)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readNotifyResponse() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readNotifyResponse(): %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("readNotifyResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"time"
+
+	ttlworker "github.com/FloatTech/ttl"
+	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// Notifications whose JSON exceeds NotifyWriteChunkSize are not sent whole: the full payload is kept for
+// notifyPayloadTTL and the listener gets a summary carrying data.payloadRef and data.payloadSize instead.
+// The full notification is fetched with GET /api/self/v1/notify-payload?id=<payloadRef>.
+const (
+	notifyPayloadTTL = 5 * time.Minute
+	// maxSummaryStringLen bounds the message and the string data fields copied into a summary
+	maxSummaryStringLen = 256
+)
+
+var notifyPayloads = ttlworker.NewCache[string, []byte](notifyPayloadTTL)
+
+// GetNotifyPayload returns the full JSON of an oversized notification by the payloadRef of its summary.
+func GetNotifyPayload(ref string) ([]byte, bool) {
+	payload := notifyPayloads.Get(ref)
+	return payload, payload != nil
+}
+
+// summarizeNotification keeps payload for GetNotifyPayload and returns the JSON of the summary sent in its place:
+// type, title and a shortened message, plus the scalar data fields (e.g. sessionId) and the payload reference.
+func summarizeNotification(notification *types.Notification, payload []byte) ([]byte, error) {
+	ref := tool.GenerateRandomUUID()
+	notifyPayloads.Set(ref, payload)
+
+	data := make(map[string]any, len(notification.Data)+2)
+	for k, v := range notification.Data {
+		switch v := v.(type) {
+		case string:
+			if len(v) <= maxSummaryStringLen {
+				data[k] = v
+			}
+		case bool, int, int64, float64:
+			data[k] = v
+		}
+	}
+	data["payloadRef"] = ref
+	data["payloadSize"] = len(payload)
+
+	message := notification.Message
+	if len(message) > maxSummaryStringLen {
+		message = tool.TruncateUTF8(message, maxSummaryStringLen) + "..."
+	}
+	return sonic.Marshal(&types.Notification{
+		Type:       notification.Type,
+		Title:      notification.Title,
+		Message:    message,
+		Data:       data,
+		IsTextOnly: notification.IsTextOnly,
+	})
+}
@@ -0,0 +1,33 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/types"
+)
+
+func TestSummarizeNotificationKeepsMessageValidUTF8(t *testing.T) {
+	// The leading byte puts the maxSummaryStringLen cut in the middle of a two-byte rune
+	message := "a" + strings.Repeat("é", maxSummaryStringLen)
+	summary, err := summarizeNotification(&types.Notification{Type: types.NotifyTypeUploadEnd, Message: message}, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Decode the raw bytes: a JSON decoder would hide invalid UTF-8 behind replacement characters
+	if !utf8.Valid(summary) {
+		t.Fatalf("summary is not valid UTF-8: %q", summary)
+	}
+	var decoded types.Notification
+	if err := sonic.Unmarshal(summary, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(decoded.Message, "...") || len(decoded.Message) > maxSummaryStringLen+len("...") {
+		t.Errorf("message of %d bytes was shortened to %q", len(message), decoded.Message)
+	}
+	if !strings.HasPrefix(message, strings.TrimSuffix(decoded.Message, "...")) {
+		t.Errorf("shortened message %q is not a prefix of the original", decoded.Message)
+	}
+}
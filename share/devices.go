@@ -3,6 +3,7 @@ package share
 import (
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	ttlworker "github.com/FloatTech/ttl"
@@ -18,9 +19,28 @@ const (
 
 var (
 	UserScanCurrent = ttlworker.NewCache[string, types.UserScanCurrentItem](DefaultTTL)
+
+	onDeviceDiscoveredMu sync.RWMutex
+	onDeviceDiscovered   func(item types.UserScanCurrentItem, isNew bool)
 )
 
+// SetOnDeviceDiscovered sets a callback run when SetUserScanCurrent adds a device (isNew) or its info changes.
+// It is called from the discovery paths (multicast, HTTP scan, gossip, register, favorite probes), each time in its own
+// goroutine, so it may block but must be safe for concurrent use. A nil callback removes it.
+func SetOnDeviceDiscovered(fn func(item types.UserScanCurrentItem, isNew bool)) {
+	onDeviceDiscoveredMu.Lock()
+	defer onDeviceDiscoveredMu.Unlock()
+	onDeviceDiscovered = fn
+}
+
 func SetUserScanCurrent(sessionId string, data types.UserScanCurrentItem) {
+	tool.RecordDeviceAddress(data.Ipaddress, data.Fingerprint)
+	// Blocked devices never show up in the scan list
+	if tool.IsBlocked(data.Fingerprint) {
+		tool.DefaultLogger.Debugf("Ignoring blocked device: %s", data.Fingerprint)
+		return
+	}
+
 	// Check if device exists and if info has changed
 	existing, exists := GetUserScanCurrent(sessionId)
 
@@ -33,8 +53,13 @@ func SetUserScanCurrent(sessionId string, data types.UserScanCurrentItem) {
 
 	// Send notification if new device or info changed
 	if isNew || isChanged {
+		// Remember where favorites were last seen so they can be probed when multicast misses them
+		if err := tool.UpdateFavoriteAddress(data.Fingerprint, data.Ipaddress, data.Port); err != nil {
+			tool.DefaultLogger.Warnf("Failed to update favorite address for %s: %v", data.Fingerprint, err)
+		}
 		var eventType string
 		if isNew {
+			tool.CountDeviceDiscovered()
 			eventType = types.NotifyTypeDeviceDiscovered
 			tool.DefaultLogger.Infof("New device discovered: %s (%s) at %s", data.Alias, data.Fingerprint, data.Ipaddress)
 		} else {
@@ -62,6 +87,13 @@ func SetUserScanCurrent(sessionId string, data types.UserScanCurrentItem) {
 		if err := notify.SendNotification(notification, ""); err != nil {
 			tool.DefaultLogger.Debugf("Failed to send device notification: %v", err)
 		}
+
+		onDeviceDiscoveredMu.RLock()
+		fn := onDeviceDiscovered
+		onDeviceDiscoveredMu.RUnlock()
+		if fn != nil {
+			go fn(data, isNew)
+		}
 	}
 }
 
@@ -94,6 +126,23 @@ func ListUserScanCurrent() []string {
 	return keys
 }
 
+// FindUserScanCurrentByIP returns the discovered device at ip, if any.
+func FindUserScanCurrentByIP(ip string) (types.UserScanCurrentItem, bool) {
+	var found types.UserScanCurrentItem
+	_ = UserScanCurrent.Range(func(_ string, v types.UserScanCurrentItem) error {
+		if v.Ipaddress == ip {
+			found = v
+		}
+		return nil
+	})
+	return found, found.Ipaddress != ""
+}
+
+// RemoveUserScanCurrent removes a single device from the scan result cache.
+func RemoveUserScanCurrent(fingerprint string) {
+	UserScanCurrent.Delete(fingerprint)
+}
+
 // ClearUserScanCurrent removes all entries from the scan result cache.
 // Used by scan-now to clear the list before performing a fresh scan.
 func ClearUserScanCurrent() {
@@ -108,6 +157,16 @@ func ClearUserScanCurrent() {
 // The number is derived from the last octet of the IP address.
 // For example: 192.168.3.12 -> #12
 func GetSelfNetworkInfos() []types.SelfNetworkInfo {
+	return collectNetworkInfos(true)
+}
+
+// GetAllNetworkInfos is like GetSelfNetworkInfos but keeps interfaces that are down, loopback
+// or not multicast capable, so callers can show them together with their flags.
+func GetAllNetworkInfos() []types.SelfNetworkInfo {
+	return collectNetworkInfos(false)
+}
+
+func collectNetworkInfos(onlySupported bool) []types.SelfNetworkInfo {
 	var result []types.SelfNetworkInfo
 
 	interfaces, err := net.Interfaces()
@@ -118,7 +177,7 @@ func GetSelfNetworkInfos() []types.SelfNetworkInfo {
 
 	for _, iface := range interfaces {
 		// use tool package function to filter unsupported interfaces (including tun)
-		if tool.RejectUnsupportNetworkInterface(&iface) {
+		if onlySupported && tool.RejectUnsupportNetworkInterface(&iface) {
 			continue
 		}
 
@@ -134,7 +193,7 @@ func GetSelfNetworkInfos() []types.SelfNetworkInfo {
 			}
 
 			ip := ipnet.IP.To4()
-			if ip == nil || ip.IsLoopback() {
+			if ip == nil || (onlySupported && ip.IsLoopback()) {
 				continue
 			}
 
@@ -147,6 +206,12 @@ func GetSelfNetworkInfos() []types.SelfNetworkInfo {
 				IPAddress:     ip.String(),
 				Number:        number,
 				NumberInt:     lastOctet,
+
+				Index:             iface.Index,
+				MTU:               iface.MTU,
+				IsUp:              iface.Flags&net.FlagUp != 0,
+				IsLoopback:        iface.Flags&net.FlagLoopback != 0,
+				SupportsMulticast: iface.Flags&net.FlagMulticast != 0,
 			})
 		}
 	}
@@ -0,0 +1,59 @@
+package tool
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/types"
+)
+
+var (
+	auditLogMu   sync.Mutex
+	auditLogPath string // empty disables the audit log
+)
+
+// SetAuditLog sets the file that transfer events are appended to (one JSON object per line).
+// An empty path disables the audit log.
+func SetAuditLog(path string) {
+	auditLogMu.Lock()
+	defer auditLogMu.Unlock()
+	auditLogPath = path
+}
+
+// WriteAuditEvent appends a transfer event to the audit log, if enabled.
+// It is independent of DefaultLogger so records survive log-level changes.
+func WriteAuditEvent(event types.AuditEvent) {
+	auditLogMu.Lock()
+	defer auditLogMu.Unlock()
+	if auditLogPath == "" {
+		return
+	}
+	if event.Timestamp == "" {
+		event.Timestamp = time.Now().Format(time.RFC3339)
+	}
+	if err := appendAuditLine(auditLogPath, event); err != nil {
+		DefaultLogger.Warnf("[Audit] Failed to write audit event: %v", err)
+	}
+}
+
+// appendAuditLine writes one JSON line; the caller holds auditLogMu so concurrent writers never interleave.
+func appendAuditLine(path string, event types.AuditEvent) error {
+	line, err := sonic.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit event: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			DefaultLogger.Errorf("Failed to close audit log: %v", err)
+		}
+	}()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,74 @@
+package tool
+
+import (
+	"slices"
+	"time"
+
+	ttlworker "github.com/FloatTech/ttl"
+)
+
+// DeviceAddressTTL is how long the fingerprint last seen at an address is remembered, see RecordDeviceAddress.
+const DeviceAddressTTL = 24 * time.Hour
+
+// deviceAddresses maps a client IP to the fingerprint last seen from it
+var deviceAddresses = ttlworker.NewCache[string, string](DeviceAddressTTL)
+
+// AddBlockedFingerprint adds a device fingerprint to the deny-list and persists it to the config file.
+func AddBlockedFingerprint(fingerprint string) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	if !slices.Contains(CurrentConfig.BlockedFingerprints, fingerprint) {
+		CurrentConfig.BlockedFingerprints = append(slices.Clone(CurrentConfig.BlockedFingerprints), fingerprint)
+	}
+
+	// Write back to config file
+	return writeDefaultConfig(ConfigPath, CurrentConfig)
+}
+
+// RemoveBlockedFingerprint removes a device fingerprint from the deny-list and persists the change.
+func RemoveBlockedFingerprint(fingerprint string) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	CurrentConfig.BlockedFingerprints = slices.DeleteFunc(slices.Clone(CurrentConfig.BlockedFingerprints), func(fp string) bool {
+		return fp == fingerprint
+	})
+
+	// Write back to config file
+	return writeDefaultConfig(ConfigPath, CurrentConfig)
+}
+
+// ListBlockedFingerprints returns a copy of the deny-list.
+func ListBlockedFingerprints() []string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return slices.Clone(CurrentConfig.BlockedFingerprints)
+}
+
+// IsBlocked checks if a device fingerprint is on the deny-list.
+func IsBlocked(fingerprint string) bool {
+	if fingerprint == "" {
+		return false
+	}
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return slices.Contains(CurrentConfig.BlockedFingerprints, fingerprint)
+}
+
+// RecordDeviceAddress remembers that the device with fingerprint was seen at ip, so requests from ip that
+// carry no fingerprint, such as share downloads, can still be matched against the deny-list.
+func RecordDeviceAddress(ip, fingerprint string) {
+	if ip == "" || fingerprint == "" {
+		return
+	}
+	deviceAddresses.Set(ip, fingerprint)
+}
+
+// IsBlockedAddress checks if the device last seen at ip (see RecordDeviceAddress) is on the deny-list.
+func IsBlockedAddress(ip string) bool {
+	if ip == "" {
+		return false
+	}
+	return IsBlocked(deviceAddresses.Get(ip))
+}
@@ -2,8 +2,10 @@ package tool
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 
@@ -14,6 +16,13 @@ var (
 	ConfigPath           = "config.yaml" // be aware that it can be changed, default to ./config.yaml
 	CurrentConfig        types.AppConfig
 	ProgramCurrentConfig types.ProgramConfig
+	// configMu guards CurrentConfig and ProgramCurrentConfig: read them with GetCurrentConfig and
+	// GetProgramConfigStatus, change them with UpdateCurrentConfig and SetProgramConfigStatus
+	configMu sync.RWMutex
+	// autoAcceptSubnets are trusted networks whose transfers are accepted without confirmation
+	autoAcceptSubnets []*net.IPNet
+	// autoAcceptMaxSize is the largest total size accepted without confirmation; 0 means no limit
+	autoAcceptMaxSize int64
 )
 
 func init() {
@@ -21,15 +30,71 @@ func init() {
 }
 
 func SetProgramConfigStatus(pin string, autoSave bool, autoSaveFromFavorites bool) {
+	configMu.Lock()
+	defer configMu.Unlock()
 	ProgramCurrentConfig.Pin = pin
 	ProgramCurrentConfig.AutoSave = autoSave
 	ProgramCurrentConfig.AutoSaveFromFavorites = autoSaveFromFavorites
 }
 
 func GetProgramConfigStatus() types.ProgramConfig {
+	configMu.RLock()
+	defer configMu.RUnlock()
 	return ProgramCurrentConfig
 }
 
+// SetAutoAcceptSubnets sets the CIDRs (e.g. "192.168.1.0/24") whose senders are auto-accepted.
+// Empty entries are ignored; an invalid CIDR is an error and leaves the current list unchanged.
+func SetAutoAcceptSubnets(cidrs []string) error {
+	subnets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid auto-accept subnet %q: %w", cidr, err)
+		}
+		subnets = append(subnets, subnet)
+	}
+	autoAcceptSubnets = subnets
+	return nil
+}
+
+// IsAutoAcceptAddr reports whether remoteAddr (an IP, optionally with port) lies in an auto-accept subnet.
+func IsAutoAcceptAddr(remoteAddr string) bool {
+	if len(autoAcceptSubnets) == 0 {
+		return false
+	}
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, subnet := range autoAcceptSubnets {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAutoAcceptMaxSize sets the largest total declared size (bytes) of a transfer that autoSave,
+// favorites or trusted subnets may accept without confirmation; larger ones go through confirm-recv.
+// 0 or less means no limit.
+func SetAutoAcceptMaxSize(bytes int64) {
+	autoAcceptMaxSize = max(bytes, 0)
+}
+
+// GetAutoAcceptMaxSize returns the auto-accept size limit, 0 if there is none.
+func GetAutoAcceptMaxSize() int64 {
+	return autoAcceptMaxSize
+}
+
 // this save to memory , no file provided.
 func DefaultProgramConfig() types.ProgramConfig {
 	return types.ProgramConfig{
@@ -80,8 +145,7 @@ func LoadConfig(path string) (types.AppConfig, error) {
 				return cfg, fmt.Errorf("config file not found, and failed to generate default config: %v", writeErr)
 			}
 			DefaultLogger.Infof("Created new config file with fingerprint and certificate")
-			CurrentConfig = cfg
-			ProgramCurrentConfig.AutoSaveFromFavorites = cfg.AutoSaveFromFavorites
+			setLoadedConfig(cfg)
 			return cfg, nil
 		}
 		return cfg, fmt.Errorf("failed to read config file: %v", err)
@@ -97,6 +161,18 @@ func LoadConfig(path string) (types.AppConfig, error) {
 		return cfg, fmt.Errorf("failed to parse config file: %v", err)
 	}
 
+	// Import an existing cert+key pair so the derived fingerprint is preserved across installs
+	if cfg.CertPath != "" && cfg.KeyPath != "" {
+		changed, err := importTLSCertFromPaths(&cfg)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to import TLS certificate: %v", err)
+		}
+		if changed {
+			DefaultLogger.Infof("Imported TLS certificate from %s", cfg.CertPath)
+			configChanged = true
+		}
+	}
+
 	// Handle fingerprint based on protocol
 	if cfg.Protocol == "https" {
 		// HTTPS mode: fingerprint should match TLS certificate
@@ -124,9 +200,16 @@ func LoadConfig(path string) (types.AppConfig, error) {
 		}
 	}
 
+	setLoadedConfig(cfg)
+	return cfg, nil
+}
+
+// setLoadedConfig publishes a config read by LoadConfig.
+func setLoadedConfig(cfg types.AppConfig) {
+	configMu.Lock()
+	defer configMu.Unlock()
 	CurrentConfig = cfg
 	ProgramCurrentConfig.AutoSaveFromFavorites = cfg.AutoSaveFromFavorites
-	return cfg, nil
 }
 
 func writeDefaultConfig(path string, cfg types.AppConfig) error {
@@ -137,6 +220,24 @@ func writeDefaultConfig(path string, cfg types.AppConfig) error {
 	return os.WriteFile(path, data, 0o644)
 }
 
+// GetCurrentConfig returns a copy of the current config; changing it does not affect CurrentConfig.
 func GetCurrentConfig() *types.AppConfig {
-	return &CurrentConfig
+	configMu.RLock()
+	defer configMu.RUnlock()
+	cfg := CurrentConfig
+	return &cfg
+}
+
+// UpdateCurrentConfig calls fn with a copy of the current config and publishes the copy if fn succeeds.
+// fn runs with the config lock held, so it must not call the other config accessors. Slices of the
+// copy are shared with earlier snapshots: replace them instead of changing their elements.
+func UpdateCurrentConfig(fn func(cfg *types.AppConfig) error) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	next := CurrentConfig
+	if err := fn(&next); err != nil {
+		return err
+	}
+	CurrentConfig = next
+	return nil
 }
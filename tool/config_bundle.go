@@ -0,0 +1,131 @@
+package tool
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+// ExportConfigBundle returns the current configuration as a portable bundle.
+// The TLS certificate and private key are only included when includeKey is true.
+func ExportConfigBundle(includeKey bool) types.ConfigBundle {
+	cfg := GetCurrentConfig()
+	bundle := types.ConfigBundle{
+		Version:               types.ConfigBundleVersion,
+		ExportedAt:            time.Now(),
+		Alias:                 cfg.Alias,
+		DeviceModel:           cfg.DeviceModel,
+		DeviceType:            cfg.DeviceType,
+		Fingerprint:           cfg.Fingerprint,
+		Port:                  cfg.Port,
+		Protocol:              cfg.Protocol,
+		Download:              cfg.Download,
+		Announce:              cfg.Announce,
+		Note:                  cfg.Note,
+		Capabilities:          cfg.Capabilities,
+		Pin:                   cfg.Pin,
+		AutoSave:              cfg.AutoSave,
+		AutoSaveFromFavorites: cfg.AutoSaveFromFavorites,
+		UploadFolder:          cfg.UploadFolder,
+		FavoriteDevices:       ListFavorites(),
+		BlockedFingerprints:   ListBlockedFingerprints(),
+	}
+	if includeKey {
+		bundle.CertPEM = cfg.CertPEM
+		bundle.KeyPEM = cfg.KeyPEM
+	}
+	return bundle
+}
+
+// ImportConfigBundle replaces the configuration with bundle and saves the config file.
+// With a certificate and key in the bundle the fingerprint identity is carried over; without them
+// (or for a bundle exported without includeKey) this device keeps its own certificate and, in https
+// mode, its own fingerprint. Favorites, blocklist, pin and autoSave apply immediately (pin/autoSave
+// only when not fixed by a flag); the returned response reports whether other fields need a restart.
+func ImportConfigBundle(bundle types.ConfigBundle) (types.ImportConfigResponse, error) {
+	if bundle.Version != types.ConfigBundleVersion {
+		return types.ImportConfigResponse{}, fmt.Errorf("unsupported bundle version %d", bundle.Version)
+	}
+	if bundle.Protocol != "" && bundle.Protocol != "http" && bundle.Protocol != "https" {
+		return types.ImportConfigResponse{}, fmt.Errorf("invalid protocol %q", bundle.Protocol)
+	}
+	if (bundle.CertPEM == "") != (bundle.KeyPEM == "") {
+		return types.ImportConfigResponse{}, fmt.Errorf("certPEM and keyPEM must be given together")
+	}
+
+	var next types.AppConfig
+	keyImported := false
+	restartRequired := false
+	err := UpdateCurrentConfig(func(cfg *types.AppConfig) error {
+		prev := *cfg
+		next = prev
+		next.Alias = bundle.Alias
+		next.DeviceModel = bundle.DeviceModel
+		next.DeviceType = bundle.DeviceType
+		next.Port = bundle.Port
+		next.Protocol = bundle.Protocol
+		next.Download = bundle.Download
+		next.Announce = bundle.Announce
+		next.Note = bundle.Note
+		next.Capabilities = bundle.Capabilities
+		next.Pin = bundle.Pin
+		next.AutoSave = bundle.AutoSave
+		next.AutoSaveFromFavorites = bundle.AutoSaveFromFavorites
+		next.UploadFolder = bundle.UploadFolder
+		next.FavoriteDevices = slices.Clone(bundle.FavoriteDevices)
+		next.BlockedFingerprints = slices.Clone(bundle.BlockedFingerprints)
+
+		if bundle.CertPEM != "" {
+			certDER, _, err := loadTLSCertFromPEM(bundle.CertPEM, bundle.KeyPEM)
+			if err != nil {
+				return fmt.Errorf("invalid certificate in bundle: %v", err)
+			}
+			next.CertPEM = bundle.CertPEM
+			next.KeyPEM = bundle.KeyPEM
+			// an imported pair would be re-imported on next start, replacing the bundle's certificate
+			next.CertPath = ""
+			next.KeyPath = ""
+			keyImported = true
+			if next.Protocol == "https" {
+				next.Fingerprint = CertFingerprint(certDER)
+			}
+		}
+		if next.Protocol != "https" && bundle.Fingerprint != "" {
+			// http fingerprints are random, so the bundle's one can be kept as is
+			next.Fingerprint = bundle.Fingerprint
+		}
+
+		if err := writeDefaultConfig(ConfigPath, next); err != nil {
+			return fmt.Errorf("failed to save config: %v", err)
+		}
+		restartRequired = next.Alias != prev.Alias || next.Port != prev.Port ||
+			next.Protocol != prev.Protocol || next.Download != prev.Download ||
+			next.DeviceModel != prev.DeviceModel || next.DeviceType != prev.DeviceType ||
+			next.Note != prev.Note || next.Fingerprint != prev.Fingerprint ||
+			next.CertPEM != prev.CertPEM || next.UploadFolder != prev.UploadFolder
+		*cfg = next
+		return nil
+	})
+	if err != nil {
+		return types.ImportConfigResponse{}, err
+	}
+
+	status := GetProgramConfigStatus()
+	if next.Pin != nil && !IsFlagSet("usePin") {
+		status.Pin = *next.Pin
+	}
+	if next.AutoSave != nil && !IsFlagSet("useAutoSave") {
+		status.AutoSave = *next.AutoSave
+	}
+	SetProgramConfigStatus(status.Pin, status.AutoSave, next.AutoSaveFromFavorites)
+
+	DefaultLogger.Infof("[Config] Imported config bundle (%d favorites, %d blocked, key imported: %v)",
+		len(next.FavoriteDevices), len(next.BlockedFingerprints), keyImported)
+	return types.ImportConfigResponse{
+		Fingerprint:     next.Fingerprint,
+		KeyImported:     keyImported,
+		RestartRequired: restartRequired,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package tool
+
+import (
+	"flag"
+	"os"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+// configWatchInterval is how often the config file is checked for changes.
+const configWatchInterval = 2 * time.Second
+
+// IsFlagSet reports whether the named flag was given on the command line or through its environment variable.
+func IsFlagSet(name string) bool {
+	set := envSetFlags[name]
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// WatchConfig polls the config file and applies fields that are safe to change at runtime:
+// pin, autoSave, autoSaveFromFavorites, favoriteDevices, blockedFingerprints and uploadFolder.
+// Pin, autoSave and uploadFolder are skipped when their command-line flag was given, so flags keep priority.
+// onUploadFolder is called with the new (data-root resolved) folder; listeners and identity fields are
+// never touched, changes to them are only logged as needing a restart. It blocks, run it in a goroutine.
+func WatchConfig(onUploadFolder func(folder string)) {
+	path := ConfigPath
+	lastMod, lastSize := configStamp(path)
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+	DefaultLogger.Infof("[Config] Watching %s for changes", path)
+
+	for range ticker.C {
+		mod, size := configStamp(path)
+		if mod.Equal(lastMod) && size == lastSize {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			DefaultLogger.Warnf("[Config] Failed to read %s: %v", path, err)
+			continue
+		}
+		var next types.AppConfig
+		if err := yaml.Unmarshal(data, &next); err != nil {
+			// may be a partial write; retry on the next tick
+			DefaultLogger.Warnf("[Config] Failed to parse %s, keeping current config: %v", path, err)
+			continue
+		}
+		lastMod, lastSize = mod, size
+		applyReloadedConfig(next, onUploadFolder)
+	}
+}
+
+// configStamp returns the modification time and size of the config file, zero values if missing.
+func configStamp(path string) (time.Time, int64) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
+
+// applyReloadedConfig applies the reloadable fields of next and logs what changed.
+// The updated config and program status are built from copies and published together under the config lock.
+// Files written by the app itself (favorites, blocklist) reload without any change.
+func applyReloadedConfig(next types.AppConfig, onUploadFolder func(folder string)) {
+	configMu.Lock()
+	prev, prevStatus := CurrentConfig, ProgramCurrentConfig
+	updated, status := prev, prevStatus
+	updated.FavoriteDevices = next.FavoriteDevices
+	updated.BlockedFingerprints = next.BlockedFingerprints
+	if prev.AutoSaveFromFavorites != next.AutoSaveFromFavorites {
+		updated.AutoSaveFromFavorites = next.AutoSaveFromFavorites
+		status.AutoSaveFromFavorites = next.AutoSaveFromFavorites
+	}
+	if next.Pin != nil && !IsFlagSet("usePin") {
+		status.Pin = *next.Pin
+	}
+	if next.AutoSave != nil && !IsFlagSet("useAutoSave") {
+		status.AutoSave = *next.AutoSave
+	}
+	updated.Pin, updated.AutoSave = next.Pin, next.AutoSave
+	updated.UploadFolder = next.UploadFolder
+	CurrentConfig, ProgramCurrentConfig = updated, status
+	configMu.Unlock()
+
+	if !slices.Equal(prev.FavoriteDevices, next.FavoriteDevices) {
+		DefaultLogger.Infof("[Config] favoriteDevices: %d -> %d entries", len(prev.FavoriteDevices), len(next.FavoriteDevices))
+	}
+	if !slices.Equal(prev.BlockedFingerprints, next.BlockedFingerprints) {
+		DefaultLogger.Infof("[Config] blockedFingerprints: %d -> %d entries", len(prev.BlockedFingerprints), len(next.BlockedFingerprints))
+	}
+	if prev.AutoSaveFromFavorites != next.AutoSaveFromFavorites {
+		DefaultLogger.Infof("[Config] autoSaveFromFavorites: %v -> %v", prev.AutoSaveFromFavorites, next.AutoSaveFromFavorites)
+	}
+	if status.Pin != prevStatus.Pin {
+		DefaultLogger.Infof("[Config] pin changed")
+	}
+	if status.AutoSave != prevStatus.AutoSave {
+		DefaultLogger.Infof("[Config] autoSave: %v -> %v", prevStatus.AutoSave, status.AutoSave)
+	}
+
+	if next.UploadFolder != prev.UploadFolder && next.UploadFolder != "" && !IsFlagSet("useDefaultUploadFolder") && onUploadFolder != nil {
+		DefaultLogger.Infof("[Config] uploadFolder: %q -> %q", prev.UploadFolder, next.UploadFolder)
+		onUploadFolder(ResolveDataPath(next.UploadFolder))
+	}
+
+	if next.Alias != prev.Alias || next.Port != prev.Port || next.Protocol != prev.Protocol ||
+		next.Download != prev.Download || next.DeviceModel != prev.DeviceModel || next.DeviceType != prev.DeviceType {
+		DefaultLogger.Warnf("[Config] Device identity/listener fields changed; restart to apply them")
+	}
+}
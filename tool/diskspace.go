@@ -0,0 +1,26 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DiskSpace returns the bytes available to this process and the total size of the filesystem holding path.
+// path may not exist yet (e.g. an upload folder created on first receive); its nearest existing parent is used.
+func DiskSpace(path string) (available, total uint64, err error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	for {
+		if _, statErr := os.Stat(dir); statErr == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return diskSpace(dir)
+}
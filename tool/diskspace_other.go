@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package tool
+
+import "errors"
+
+func diskSpace(string) (uint64, uint64, error) {
+	return 0, 0, errors.ErrUnsupported
+}
@@ -0,0 +1,13 @@
+//go:build linux || darwin || freebsd
+
+package tool
+
+import "golang.org/x/sys/unix"
+
+func diskSpace(dir string) (available, total uint64, err error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), uint64(st.Blocks) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package tool
+
+import "golang.org/x/sys/windows"
+
+func diskSpace(dir string) (available, total uint64, err error) {
+	dirPtr, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := windows.GetDiskFreeSpaceEx(dirPtr, &available, &total, nil); err != nil {
+		return 0, 0, err
+	}
+	return available, total, nil
+}
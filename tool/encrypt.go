@@ -0,0 +1,239 @@
+package tool
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Encrypted files use AES-GCM in the STREAM construction: the plaintext is cut into encryptChunkSize
+// chunks, each sealed on its own with a nonce built from a random per-file prefix, the chunk counter and
+// a last-chunk flag, so chunks cannot be reordered, dropped or truncated without failing to open.
+//
+// Layout: encryptMagic | nonce prefix (7 bytes) | sealed chunks (encryptChunkSize+16 bytes, the last one shorter)
+const (
+	encryptMagic      = "LSENC\x00\x01\x00"
+	encryptChunkSize  = 64 * 1024
+	encryptPrefixSize = 7
+	encryptHeaderSize = len(encryptMagic) + encryptPrefixSize
+)
+
+// ErrNotEncrypted is returned by NewDecryptReader for data that does not start with the encryption header.
+var ErrNotEncrypted = errors.New("not an encrypted file")
+
+// NewEncryptionAEAD returns the AES-GCM cipher for key, which must be 16, 24 or 32 bytes long.
+func NewEncryptionAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptNonce returns the nonce of chunk counter: prefix | big-endian counter | last flag.
+func encryptNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, encryptPrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// encryptWriter seals everything written to it in chunks; Close writes the last chunk.
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	sealed  []byte
+	closed  bool
+}
+
+// NewEncryptWriter writes the encryption header to w and returns a writer that encrypts into it.
+// Close must be called to write the final chunk; it does not close w.
+func NewEncryptWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	aead, err := NewEncryptionAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, encryptPrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	if _, err := io.WriteString(w, encryptMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		prefix: prefix,
+		buf:    make([]byte, 0, encryptChunkSize),
+		sealed: make([]byte, 0, encryptChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed encrypt writer")
+	}
+	n := 0
+	for len(p) > 0 {
+		// a full chunk is only sealed once more data arrives, so the last chunk is never empty unless the file is
+		if len(e.buf) == encryptChunkSize {
+			if err := e.seal(false); err != nil {
+				return n, err
+			}
+		}
+		m := min(encryptChunkSize-len(e.buf), len(p))
+		e.buf = append(e.buf, p[:m]...)
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	if e.counter == math.MaxUint32 {
+		return errors.New("file too large to encrypt")
+	}
+	e.sealed = e.aead.Seal(e.sealed[:0], encryptNonce(e.prefix, e.counter, last), e.buf, nil)
+	if _, err := e.w.Write(e.sealed); err != nil {
+		return err
+	}
+	e.buf = e.buf[:0]
+	e.counter++
+	return nil
+}
+
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+// IsEncryptedFile reports whether r starts with the encryption header.
+func IsEncryptedFile(r io.ReaderAt) bool {
+	magic := make([]byte, len(encryptMagic))
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		return false
+	}
+	return bytes.Equal(magic, []byte(encryptMagic))
+}
+
+// DecryptReader reads the plaintext of an encrypted file with random access, one chunk at a time.
+type DecryptReader struct {
+	r        io.ReaderAt
+	aead     cipher.AEAD
+	prefix   []byte
+	body     int64 // bytes after the header
+	chunks   int64
+	size     int64 // plaintext size
+	off      int64
+	chunk    []byte
+	chunkIdx int64
+}
+
+// NewDecryptReader opens encrypted data of cipherSize bytes read from r.
+// It returns ErrNotEncrypted when r does not start with the encryption header.
+func NewDecryptReader(r io.ReaderAt, cipherSize int64, key []byte) (*DecryptReader, error) {
+	aead, err := NewEncryptionAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, encryptHeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNotEncrypted
+		}
+		return nil, err
+	}
+	if !bytes.HasPrefix(header, []byte(encryptMagic)) {
+		return nil, ErrNotEncrypted
+	}
+	overhead := int64(aead.Overhead())
+	sealedChunk := encryptChunkSize + overhead
+	body := cipherSize - int64(encryptHeaderSize)
+	chunks := (body + sealedChunk - 1) / sealedChunk
+	if chunks == 0 || body-chunks*overhead < 0 {
+		return nil, errors.New("encrypted file is truncated")
+	}
+	return &DecryptReader{
+		r:        r,
+		aead:     aead,
+		prefix:   header[len(encryptMagic):],
+		body:     body,
+		chunks:   chunks,
+		size:     body - chunks*overhead,
+		chunkIdx: -1,
+	}, nil
+}
+
+// Size returns the plaintext size.
+func (d *DecryptReader) Size() int64 {
+	return d.size
+}
+
+// loadChunk decrypts chunk i into d.chunk.
+func (d *DecryptReader) loadChunk(i int64) error {
+	if d.chunkIdx == i {
+		return nil
+	}
+	sealedChunk := encryptChunkSize + int64(d.aead.Overhead())
+	start := i * sealedChunk
+	sealed := make([]byte, min(sealedChunk, d.body-start))
+	if _, err := d.r.ReadAt(sealed, int64(encryptHeaderSize)+start); err != nil {
+		return err
+	}
+	plain, err := d.aead.Open(d.chunk[:0], encryptNonce(d.prefix, uint32(i), i == d.chunks-1), sealed, nil)
+	if err != nil {
+		d.chunkIdx = -1
+		return fmt.Errorf("decrypt chunk %d: %w", i, err)
+	}
+	d.chunk = plain
+	d.chunkIdx = i
+	return nil
+}
+
+func (d *DecryptReader) Read(p []byte) (int, error) {
+	if d.off >= d.size {
+		return 0, io.EOF
+	}
+	i := d.off / encryptChunkSize
+	if err := d.loadChunk(i); err != nil {
+		return 0, err
+	}
+	n := copy(p, d.chunk[d.off-i*encryptChunkSize:])
+	d.off += int64(n)
+	return n, nil
+}
+
+func (d *DecryptReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += d.off
+	case io.SeekEnd:
+		offset += d.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	d.off = offset
+	return offset, nil
+}
@@ -2,26 +2,91 @@ package tool
 
 import (
 	"os"
-	"sync"
+	"slices"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
 	"github.com/moyoez/localsend-go/types"
 )
 
-var favoritesMu sync.RWMutex
+// favoriteAddressSaveDelay batches the last known addresses learned from announcements into one config write
+const favoriteAddressSaveDelay = 30 * time.Second
+
+var (
+	// favoriteProbeInterval is how often undiscovered favorites are probed at their last known address; 0 disables it
+	favoriteProbeInterval = 60 * time.Second
+	// favoriteAddressSaveDue is set while a save of changed favorite addresses is scheduled; guarded by configMu
+	favoriteAddressSaveDue bool
+)
+
+// SetFavoriteProbeInterval sets how often favorites are probed at their last known address (0 disables probing).
+// Must be called before the probe loop starts.
+func SetFavoriteProbeInterval(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	favoriteProbeInterval = d
+}
+
+// GetFavoriteProbeInterval returns the favorite probe interval.
+func GetFavoriteProbeInterval() time.Duration {
+	return favoriteProbeInterval
+}
+
+// UpdateFavoriteAddress records the last known address of a favorite device; non-favorites are ignored.
+// Announcements call this often, so a changed address is kept in memory and written to the config file
+// by saveFavoriteAddresses after favoriteAddressSaveDelay, together with any other changes made meanwhile.
+func UpdateFavoriteAddress(fingerprint, ip string, port int) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	for i, fav := range CurrentConfig.FavoriteDevices {
+		if fav.Fingerprint != fingerprint {
+			continue
+		}
+		if fav.LastIP == ip && fav.LastPort == port {
+			return nil
+		}
+		favorites := slices.Clone(CurrentConfig.FavoriteDevices)
+		favorites[i].LastIP = ip
+		favorites[i].LastPort = port
+		CurrentConfig.FavoriteDevices = favorites
+		if !favoriteAddressSaveDue {
+			favoriteAddressSaveDue = true
+			time.AfterFunc(favoriteAddressSaveDelay, saveFavoriteAddresses)
+		}
+		return nil
+	}
+	return nil
+}
+
+// saveFavoriteAddresses writes the config with the favorite addresses UpdateFavoriteAddress kept in memory.
+// Nothing is written if another config write has saved them since.
+func saveFavoriteAddresses() {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if !favoriteAddressSaveDue {
+		return
+	}
+	favoriteAddressSaveDue = false
+	if err := writeDefaultConfig(ConfigPath, CurrentConfig); err != nil {
+		DefaultLogger.Warnf("[Favorites] Failed to save favorite addresses: %v", err)
+	}
+}
 
 // AddFavorite adds a device to favorites by fingerprint and alias.
 // If the fingerprint already exists, the alias will be updated.
 func AddFavorite(fingerprint, alias string) error {
-	favoritesMu.Lock()
-	defer favoritesMu.Unlock()
+	configMu.Lock()
+	defer configMu.Unlock()
 
-	// Check if already exists, update alias if so
+	// Check if already exists, update alias if so; snapshots share the old slice, so work on a copy
+	favorites := slices.Clone(CurrentConfig.FavoriteDevices)
 	found := false
-	for i, fav := range CurrentConfig.FavoriteDevices {
+	for i, fav := range favorites {
 		if fav.Fingerprint == fingerprint {
-			CurrentConfig.FavoriteDevices[i].Alias = alias
+			favorites[i].Alias = alias
 			found = true
 			break
 		}
@@ -29,20 +94,22 @@ func AddFavorite(fingerprint, alias string) error {
 
 	// Add new entry if not found
 	if !found {
-		CurrentConfig.FavoriteDevices = append(CurrentConfig.FavoriteDevices, types.FavoriteDeviceEntry{
+		favorites = append(favorites, types.FavoriteDeviceEntry{
 			Fingerprint: fingerprint,
 			Alias:       alias,
 		})
 	}
+	CurrentConfig.FavoriteDevices = favorites
 
-	// Write back to config file
+	// Write back to config file, pending favorite addresses included
+	favoriteAddressSaveDue = false
 	return writeDefaultConfig(ConfigPath, CurrentConfig)
 }
 
 // ListFavorites returns a copy of the current favorite devices list.
 func ListFavorites() []types.FavoriteDeviceEntry {
-	favoritesMu.RLock()
-	defer favoritesMu.RUnlock()
+	configMu.RLock()
+	defer configMu.RUnlock()
 
 	// Return a copy to avoid race conditions
 	result := make([]types.FavoriteDeviceEntry, len(CurrentConfig.FavoriteDevices))
@@ -52,8 +119,8 @@ func ListFavorites() []types.FavoriteDeviceEntry {
 
 // RemoveFavorite removes a device from favorites by fingerprint.
 func RemoveFavorite(fingerprint string) error {
-	favoritesMu.Lock()
-	defer favoritesMu.Unlock()
+	configMu.Lock()
+	defer configMu.Unlock()
 
 	// Find and remove the entry
 	newList := make([]types.FavoriteDeviceEntry, 0, len(CurrentConfig.FavoriteDevices))
@@ -64,15 +131,16 @@ func RemoveFavorite(fingerprint string) error {
 	}
 	CurrentConfig.FavoriteDevices = newList
 
-	// Write back to config file
+	// Write back to config file, pending favorite addresses included
+	favoriteAddressSaveDue = false
 	return writeDefaultConfig(ConfigPath, CurrentConfig)
 }
 
 // IsFavorite checks if a device with the given fingerprint is in favorites.
 // This function reads the config file in real-time to ensure up-to-date state.
 func IsFavorite(fingerprint string) bool {
-	favoritesMu.RLock()
-	defer favoritesMu.RUnlock()
+	configMu.RLock()
+	defer configMu.RUnlock()
 
 	// Read config file in real-time
 	data, err := os.ReadFile(ConfigPath)
@@ -0,0 +1,80 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+// savedFavorites reads the favorites from the config file, nil when it was not written.
+func savedFavorites(t *testing.T, path string) []types.FavoriteDeviceEntry {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg types.FavoriteDevicesYamlFileConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg.FavoriteDevices
+}
+
+func TestUpdateFavoriteAddressDefersConfigWrite(t *testing.T) {
+	configMu.Lock()
+	previousPath, previousConfig := ConfigPath, CurrentConfig
+	ConfigPath = filepath.Join(t.TempDir(), "config.yaml")
+	CurrentConfig = types.AppConfig{FavoriteDevices: []types.FavoriteDeviceEntry{{Fingerprint: "fav", Alias: "Favorite"}}}
+	configMu.Unlock()
+	t.Cleanup(func() {
+		configMu.Lock()
+		defer configMu.Unlock()
+		ConfigPath, CurrentConfig = previousPath, previousConfig
+		favoriteAddressSaveDue = false
+	})
+
+	for port := 53317; port < 53320; port++ {
+		if err := UpdateFavoriteAddress("fav", "192.168.1.20", port); err != nil {
+			t.Fatalf("UpdateFavoriteAddress: %v", err)
+		}
+	}
+	if err := UpdateFavoriteAddress("stranger", "192.168.1.30", 53317); err != nil {
+		t.Fatalf("UpdateFavoriteAddress for a non-favorite: %v", err)
+	}
+	if favorites := ListFavorites(); favorites[0].LastIP != "192.168.1.20" || favorites[0].LastPort != 53319 {
+		t.Fatalf("favorite in memory %+v, want the last announced address", favorites[0])
+	}
+	if saved := savedFavorites(t, ConfigPath); saved != nil {
+		t.Fatalf("config written on announce: %+v", saved)
+	}
+
+	saveFavoriteAddresses()
+	saved := savedFavorites(t, ConfigPath)
+	if len(saved) != 1 || saved[0].LastIP != "192.168.1.20" || saved[0].LastPort != 53319 {
+		t.Errorf("saved favorites %+v, want the last announced address", saved)
+	}
+
+	// An explicit change saves pending addresses right away, so the scheduled save has nothing left to do
+	if err := UpdateFavoriteAddress("fav", "192.168.1.21", 53317); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddFavorite("other", "Other"); err != nil {
+		t.Fatalf("AddFavorite: %v", err)
+	}
+	if saved := savedFavorites(t, ConfigPath); len(saved) != 2 || saved[0].LastIP != "192.168.1.21" {
+		t.Errorf("saved favorites %+v after AddFavorite, want the pending address included", saved)
+	}
+	configMu.RLock()
+	due := favoriteAddressSaveDue
+	configMu.RUnlock()
+	if due {
+		t.Error("a save is still due after AddFavorite wrote the config")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"io/fs"
 	"mime"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -18,7 +19,16 @@ import (
 
 // ProcessFileInput processes a FileInput and fills missing information from fileUrl if provided.
 // When calculateSHA is false, SHA256 is never computed. When true, it is computed only if fileInput.SHA256 is empty.
+// A caller-provided fileType is validated and always wins over extension/content detection.
 func ProcessFileInput(fileInput *types.FileInput, calculateSHA bool) error {
+	if fileInput.FileType != "" {
+		fileType, err := NormalizeMIMEType(fileInput.FileType)
+		if err != nil {
+			return fmt.Errorf("invalid fileType: %v", err)
+		}
+		fileInput.FileType = fileType
+	}
+
 	// If fileUrl is provided, auto-fill missing information
 	if fileInput.FileUrl != "" {
 		parsedUrl, err := url.Parse(fileInput.FileUrl)
@@ -37,7 +47,8 @@ func ProcessFileInput(fileInput *types.FileInput, calculateSHA bool) error {
 		doCalculateSHA := calculateSHA && fileInput.SHA256 == ""
 
 		// Get file information
-		fileName, fileSize, fileType, sha256Hash, err := GetFileInfoFromPath(filePath, doCalculateSHA)
+		// Only sniff content when the caller did not provide a fileType
+		fileName, fileSize, fileType, sha256Hash, err := GetFileInfoFromPath(filePath, doCalculateSHA, fileInput.FileType == "")
 		if err != nil {
 			return err
 		}
@@ -47,8 +58,8 @@ func ProcessFileInput(fileInput *types.FileInput, calculateSHA bool) error {
 			fileInput.FileName = fileName
 			DefaultLogger.Debugf("Auto-detected fileName: %s", fileName)
 		}
-		if fileInput.Size == 0 {
-			fileInput.Size = fileSize
+		if fileInput.Size == nil {
+			fileInput.Size = &fileSize
 			DefaultLogger.Debugf("Auto-detected size: %d bytes", fileSize)
 		}
 		if fileInput.FileType == "" {
@@ -59,14 +70,24 @@ func ProcessFileInput(fileInput *types.FileInput, calculateSHA bool) error {
 			fileInput.SHA256 = sha256Hash
 			DefaultLogger.Debugf("Auto-calculated SHA256: %s", sha256Hash)
 		}
+		if fileInput.Preview == "" && shouldGeneratePreview(fileInput.FileType) {
+			// A missing preview is not an error: the file is still sent
+			if preview, err := GenerateImagePreview(filePath); err != nil {
+				DefaultLogger.Debugf("Skipping preview for %s: %v", filePath, err)
+			} else {
+				fileInput.Preview = preview
+			}
+		}
 	}
+	fileInput.Preview = LimitPreview(fileInput.FileName, fileInput.FileType, fileInput.Preview)
 
 	// Validate required fields
 	if fileInput.FileName == "" {
 		return fmt.Errorf("fileName is required")
 	}
-	if fileInput.Size == 0 {
-		return fmt.Errorf("size is required or must be > 0")
+	// A nil size means "not provided"; 0 is a legitimate empty file
+	if fileInput.Size == nil || *fileInput.Size < 0 {
+		return fmt.Errorf("size is required and must be >= 0")
 	}
 	if fileInput.FileType == "" {
 		return fmt.Errorf("fileType is required")
@@ -75,9 +96,24 @@ func ProcessFileInput(fileInput *types.FileInput, calculateSHA bool) error {
 	return nil
 }
 
-// getFileInfoFromPath reads file information from local filesystem
+// NormalizeMIMEType checks that s is a plausible "type/subtype" MIME type (parameters allowed)
+// and returns it trimmed and in canonical form, e.g. " Image/PNG " becomes "image/png".
+func NormalizeMIMEType(s string) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(s))
+	if err != nil {
+		return "", fmt.Errorf("%q is not a MIME type: %v", s, err)
+	}
+	major, minor, ok := strings.Cut(mediaType, "/")
+	if !ok || major == "" || minor == "" || major == "*" || minor == "*" {
+		return "", fmt.Errorf("%q is not a type/subtype MIME type", s)
+	}
+	return mime.FormatMediaType(mediaType, params), nil
+}
+
+// GetFileInfoFromPath reads file information from local filesystem
+// When sniffType is true and the extension is unknown, the first 512 bytes are used to detect the fileType.
 // Returns fileName, size, fileType, sha256, error
-func GetFileInfoFromPath(filePath string, calculateSHA bool) (string, int64, string, string, error) {
+func GetFileInfoFromPath(filePath string, calculateSHA bool, sniffType bool) (string, int64, string, string, error) {
 	// Get file info
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
@@ -97,16 +133,14 @@ func GetFileInfoFromPath(filePath string, calculateSHA bool) (string, int64, str
 
 	// Detect file type (MIME type) from extension
 	fileType := mime.TypeByExtension(filepath.Ext(filePath))
-	if fileType == "" {
-		fileType = "application/octet-stream" // Default MIME type
-	}
+	needSniff := sniffType && fileType == ""
 
-	// Calculate SHA256 if requested
+	// Calculate SHA256 and/or sniff content type, sharing a single open
 	var sha256Hash string
-	if calculateSHA {
+	if calculateSHA || needSniff {
 		file, err := os.Open(filePath)
 		if err != nil {
-			return fileName, fileSize, fileType, "", fmt.Errorf("failed to open file for hashing: %v", err)
+			return fileName, fileSize, defaultFileType(fileType), "", fmt.Errorf("failed to open file: %v", err)
 		}
 		defer func() {
 			if err := file.Close(); err != nil {
@@ -114,21 +148,27 @@ func GetFileInfoFromPath(filePath string, calculateSHA bool) (string, int64, str
 			}
 		}()
 
-		hasher := sha256.New()
-		if _, err := io.Copy(hasher, file); err != nil {
-			return fileName, fileSize, fileType, "", fmt.Errorf("failed to calculate SHA256: %v", err)
+		if needSniff {
+			fileType = sniffFileType(file)
+		}
+
+		if calculateSHA {
+			sha256Hash, err = hashFileCached(filePath, fileInfo, file)
+			if err != nil {
+				return fileName, fileSize, defaultFileType(fileType), "", fmt.Errorf("failed to calculate SHA256: %v", err)
+			}
 		}
-		sha256Hash = hex.EncodeToString(hasher.Sum(nil))
 	}
 
-	return fileName, fileSize, fileType, sha256Hash, nil
+	return fileName, fileSize, defaultFileType(fileType), sha256Hash, nil
 }
 
 // ProcessFolderForUpload recursively processes a folder and returns file information for upload.
 // Returns a map of fileId -> FileInput with filenames in "foldername/subfolder/file.txt" format.
 // folderPath: absolute path to the folder to process
+// sniffType: detect fileType from content when the extension is unknown
 // fileIdToPathMap: output map of fileId to actual file path on disk (for later reading)
-func ProcessFolderForUpload(folderPath string, calculateSHA bool) (map[string]*types.FileInput, map[string]string, error) {
+func ProcessFolderForUpload(folderPath string, calculateSHA bool, sniffType bool) (map[string]*types.FileInput, map[string]string, error) {
 	// Get folder info
 	info, err := os.Stat(folderPath)
 	if err != nil {
@@ -174,25 +214,24 @@ func ProcessFolderForUpload(folderPath string, calculateSHA bool) (map[string]*t
 
 		// Detect file type (MIME type) from extension
 		fileType := mime.TypeByExtension(filepath.Ext(path))
-		if fileType == "" {
-			fileType = "application/octet-stream"
-		}
+		needSniff := sniffType && fileType == ""
 
 		// Generate unique ID based on the full path
 		fileId := GenerateFileID(path)
 
+		fileSize := fileInfo.Size()
 		fileInput := &types.FileInput{
 			ID:       fileId,
 			FileName: fileName,
-			Size:     fileInfo.Size(),
-			FileType: fileType,
+			Size:     &fileSize,
+			FileType: defaultFileType(fileType),
 		}
 
-		// Calculate SHA256 if requested
-		if calculateSHA {
+		// Calculate SHA256 and/or sniff content type, sharing a single open
+		if calculateSHA || needSniff {
 			file, err := os.Open(path)
 			if err != nil {
-				DefaultLogger.Warnf("Skipping file %s: failed to open for hashing: %v", path, err)
+				DefaultLogger.Warnf("Skipping file %s: failed to open: %v", path, err)
 				return nil
 			}
 			defer func() {
@@ -201,18 +240,25 @@ func ProcessFolderForUpload(folderPath string, calculateSHA bool) (map[string]*t
 				}
 			}()
 
-			hasher := sha256.New()
-			if _, err := io.Copy(hasher, file); err != nil {
-				DefaultLogger.Warnf("Skipping file %s: failed to calculate SHA256: %v", path, err)
-				return nil
+			if needSniff {
+				fileType = defaultFileType(sniffFileType(file))
+				fileInput.FileType = fileType
+			}
+
+			if calculateSHA {
+				sum, err := hashFileCached(path, fileInfo, file)
+				if err != nil {
+					DefaultLogger.Warnf("Skipping file %s: failed to calculate SHA256: %v", path, err)
+					return nil
+				}
+				fileInput.SHA256 = sum
 			}
-			fileInput.SHA256 = hex.EncodeToString(hasher.Sum(nil))
 		}
 
 		fileInputMap[fileId] = fileInput
 		fileIdToPathMap[fileId] = path
 
-		DefaultLogger.Debugf("Processed file: %s -> %s (size: %d, type: %s)", path, fileName, fileInfo.Size(), fileType)
+		DefaultLogger.Debugf("Processed file: %s -> %s (size: %d, type: %s)", path, fileName, fileInfo.Size(), fileInput.FileType)
 		return nil
 	})
 
@@ -228,6 +274,31 @@ func ProcessFolderForUpload(folderPath string, calculateSHA bool) (map[string]*t
 	return fileInputMap, fileIdToPathMap, nil
 }
 
+// sniffFileType detects the MIME type from the first 512 bytes of file and rewinds it.
+// Returns "" when nothing could be read.
+func sniffFileType(file io.ReadSeeker) string {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		DefaultLogger.Debugf("Failed to read file header for type detection: %v", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		DefaultLogger.Errorf("Failed to rewind file after type detection: %v", err)
+	}
+	if n == 0 {
+		return ""
+	}
+	return http.DetectContentType(buf[:n])
+}
+
+// defaultFileType falls back to application/octet-stream for an unknown fileType.
+func defaultFileType(fileType string) string {
+	if fileType == "" {
+		return "application/octet-stream"
+	}
+	return fileType
+}
+
 // GenerateFileID generates a unique file ID based on file path
 func GenerateFileID(filePath string) string {
 	hasher := sha256.New()
@@ -238,7 +309,8 @@ func GenerateFileID(filePath string) string {
 // ProcessPathInput processes a path (file or folder) and returns file information.
 // If path is a file, returns a single-item map.
 // If path is a folder, returns all files in the folder with proper naming.
-func ProcessPathInput(path string, calculateSHA bool) (map[string]*types.FileInput, map[string]string, error) {
+// sniffType: detect fileType from content when the extension is unknown
+func ProcessPathInput(path string, calculateSHA bool, sniffType bool) (map[string]*types.FileInput, map[string]string, error) {
 	// Handle file:// URL
 	if strings.HasPrefix(path, "file://") {
 		parsedUrl, err := url.Parse(path)
@@ -258,22 +330,21 @@ func ProcessPathInput(path string, calculateSHA bool) (map[string]*types.FileInp
 	if !info.IsDir() {
 		fileName := filepath.Base(path)
 		fileType := mime.TypeByExtension(filepath.Ext(path))
-		if fileType == "" {
-			fileType = "application/octet-stream"
-		}
+		needSniff := sniffType && fileType == ""
 
 		fileId := GenerateFileID(path)
+		fileSize := info.Size()
 		fileInput := &types.FileInput{
 			ID:       fileId,
 			FileName: fileName,
-			Size:     info.Size(),
-			FileType: fileType,
+			Size:     &fileSize,
+			FileType: defaultFileType(fileType),
 		}
 
-		if calculateSHA {
+		if calculateSHA || needSniff {
 			file, err := os.Open(path)
 			if err != nil {
-				return nil, nil, fmt.Errorf("failed to open file for hashing: %v", err)
+				return nil, nil, fmt.Errorf("failed to open file: %v", err)
 			}
 			defer func() {
 				if err := file.Close(); err != nil {
@@ -281,11 +352,17 @@ func ProcessPathInput(path string, calculateSHA bool) (map[string]*types.FileInp
 				}
 			}()
 
-			hasher := sha256.New()
-			if _, err := io.Copy(hasher, file); err != nil {
-				return nil, nil, fmt.Errorf("failed to calculate SHA256: %v", err)
+			if needSniff {
+				fileInput.FileType = defaultFileType(sniffFileType(file))
+			}
+
+			if calculateSHA {
+				sum, err := hashFileCached(path, info, file)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to calculate SHA256: %v", err)
+				}
+				fileInput.SHA256 = sum
 			}
-			fileInput.SHA256 = hex.EncodeToString(hasher.Sum(nil))
 		}
 
 		fileInputMap := map[string]*types.FileInput{fileId: fileInput}
@@ -295,7 +372,7 @@ func ProcessPathInput(path string, calculateSHA bool) (map[string]*types.FileInp
 	}
 
 	// It's a directory, recursively collect all files
-	return ProcessFolderForUpload(path, calculateSHA)
+	return ProcessFolderForUpload(path, calculateSHA, sniffType)
 }
 
 // BuildSavedFileNames returns an ordered slice of basenames from savePaths (fileId -> full path).
@@ -1,5 +1,7 @@
 package tool
 
 func CheckFingerPrintIsSame(fromFingerprint string) bool {
+	configMu.RLock()
+	defer configMu.RUnlock()
 	return fromFingerprint != "" && fromFingerprint == CurrentConfig.Fingerprint
 }
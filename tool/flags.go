@@ -2,11 +2,23 @@ package tool
 
 import (
 	"flag"
+	"os"
+	"strings"
+	"unicode"
 
 	"github.com/moyoez/localsend-go/types"
 )
 
+// EnvPrefix is the prefix of environment variables that stand in for flags, see FlagEnvName.
+const EnvPrefix = "LOCALSEND_"
+
+// envSetFlags holds the flags given through their environment variable.
+var envSetFlags = make(map[string]bool)
+
 // SetFlags parses CLI flags and returns the override config.
+// Every flag can also be given as a LOCALSEND_* environment variable (see FlagEnvName).
+// Precedence is config file < env < flag: an env value counts as a given flag for IsFlagSet,
+// and a flag on the command line replaces it.
 func SetFlags() types.Config {
 	var cfg types.Config
 	flag.StringVar(&cfg.Log, "log", "prod", "log mode: dev|prod|none")
@@ -14,7 +26,7 @@ func SetFlags() types.Config {
 	flag.IntVar(&cfg.UseMultcastPort, "useMultcastPort", 0, "override multicast port")
 	flag.StringVar(&cfg.UseConfigPath, "useConfigPath", "config.yaml", "override config file path")
 	flag.StringVar(&cfg.UseDefaultUploadFolder, "useDefaultUploadFolder", "uploads", "override default upload folder")
-	flag.StringVar(&cfg.UseReferNetworkInterface, "useReferNetworkInterface", "*", "specify network interface (e.g., 'en0', 'eth0') or '*' for all interfaces")
+	flag.StringVar(&cfg.UseReferNetworkInterface, "useReferNetworkInterface", "*", "specify network interface (e.g., 'en0', 'eth0'), a comma-separated list (e.g., 'eth0,wlan0') or '*' for all interfaces")
 	flag.StringVar(&cfg.UsePin, "usePin", "", "specify pin for upload (only for FROM upload request)")
 	flag.BoolVar(&cfg.UseAutoSave, "useAutoSave", false, "if false, user require to confirm before recv (only for FROM upload request)")
 	flag.BoolVar(&cfg.UseAutoSaveFromFavorites, "useAutoSaveFromFavorites", false, "if true and useAutoSave is false, auto-accept from favorite devices only")
@@ -25,6 +37,94 @@ func SetFlags() types.Config {
 	flag.BoolVar(&cfg.UseDownload, "useDownload", false, "if true, enable download API (prepare-download, download, download page)")
 	flag.StringVar(&cfg.UseWebOutPath, "useWebOutPath", "", "path to Next.js static export output for download page, maybe you dont need to change.")
 	flag.BoolVar(&cfg.DoNotMakeSessionFolder, "doNotMakeSessionFolder", false, "if true, do not create session subfolder; when file name exists, save as name-2.ext, name-3.ext, ...")
+	flag.StringVar(&cfg.UseImageServeRoots, "useImageServeRoots", "", "comma-separated base directories get-image may serve images from; empty keeps the Steam userdata default")
+	flag.StringVar(&cfg.UseAuditLog, "useAuditLog", "", "append a JSON line per completed/failed transfer to this file; empty disables the audit log")
+	flag.IntVar(&cfg.UseMaxUploadFiles, "useMaxUploadFiles", 10000, "max number of files accepted per incoming prepare-upload request, 0 means no limit")
+	flag.IntVar(&cfg.UseMaxFileNameLength, "useMaxFileNameLength", 255, "max bytes per received file name (extension preserved when truncating)")
+	flag.BoolVar(&cfg.SkipICMPProbe, "skipICMPProbe", false, "skip ICMP probe in HTTP scan and use a short TCP connect probe instead")
+	flag.StringVar(&cfg.UseDataDir, "useDataDir", "", "root directory that relative config/upload/audit-log paths resolve against (default: current directory)")
+	flag.IntVar(&cfg.UseSessionTTL, "useSessionTTL", 3600, "idle lifetime of upload sessions in seconds (refreshed on upload activity)")
+	flag.StringVar(&cfg.UseAutoAcceptSubnets, "useAutoAcceptSubnets", "", "comma-separated CIDRs (e.g. 192.168.1.0/24) whose transfers are accepted without confirmation")
+	flag.IntVar(&cfg.UseFavoriteProbeInterval, "useFavoriteProbeInterval", 60, "seconds between probes of undiscovered favorites at their last known address, 0 disables")
+	flag.IntVar(&cfg.UseSlowRequestThreshold, "useSlowRequestThreshold", 10, "log API requests slower than this many seconds as warnings, 0 disables")
+	flag.BoolVar(&cfg.UseGeneratePreviews, "useGeneratePreviews", false, "if true, attach a small base64 JPEG thumbnail as preview for jpeg/png/gif files in prepare-upload")
+	flag.IntVar(&cfg.UsePreviewMaxDimension, "usePreviewMaxDimension", 40, "longest side in pixels of generated image previews")
+	flag.IntVar(&cfg.UseIdleShutdown, "useIdleShutdown", 0, "exit after this many seconds without upload/download/confirm activity, 0 disables")
+	flag.StringVar(&cfg.UseDeviceNote, "useDeviceNote", "", "free-text note advertised to peers next to the alias, e.g. \"Living Room TV\"")
+	flag.IntVar(&cfg.UseMulticastTTL, "useMulticastTTL", 1, "IP TTL of outgoing multicast announces; raise to reach devices across routers that forward multicast")
+	flag.BoolVar(&cfg.SkipMulticastLoopback, "skipMulticastLoopback", false, "if true, outgoing multicast announces do not loop back to this host")
+	flag.BoolVar(&cfg.SkipConfigWatch, "skipConfigWatch", false, "if true, do not reload pin/autoSave/favorites/blocklist/uploadFolder when the config file changes")
+	flag.StringVar(&cfg.UseSavePathTemplate, "useSavePathTemplate", "", "where received files are saved, e.g. {root}/{date}/{sender}/{filename}; placeholders: {root} {date} {sender} {sessionId} {type} {filename}")
+	flag.StringVar(&cfg.UseReceivedFileMode, "useReceivedFileMode", "0644", "octal permission applied to received files, e.g. 0600 on shared hosts")
+	flag.StringVar(&cfg.UseReceivedDirMode, "useReceivedDirMode", "0755", "octal permission applied to directories created for received files, e.g. 0700")
+	flag.IntVar(&cfg.UseScanInterval, "useScanInterval", 30, "seconds between UDP multicast announces and HTTP scans (minimum 5)")
+	flag.IntVar(&cfg.UseUDPScanInterval, "useUDPScanInterval", 0, "seconds between UDP multicast announces, 0 uses useScanInterval")
+	flag.IntVar(&cfg.UseHTTPScanInterval, "useHTTPScanInterval", 0, "seconds between HTTP scans, 0 uses useScanInterval")
+	flag.StringVar(&cfg.UseQuietHours, "useQuietHours", "", "local time window HH:MM-HH:MM (e.g. 22:00-07:00) during which transfers are rejected or need a PIN")
+	flag.StringVar(&cfg.UseQuietHoursAction, "useQuietHoursAction", "reject", "what quiet hours do: reject, or require-pin (even when auto-save would accept)")
+	flag.StringVar(&cfg.UseQuietHoursPin, "useQuietHoursPin", "", "PIN required during quiet hours with require-pin; empty uses the configured PIN")
+	flag.IntVar(&cfg.UseParallelParts, "useParallelParts", 1, "chunks of a chunked upload (chunkSize set) sent in parallel, each on its own connection; 1-16")
+	flag.BoolVar(&cfg.UseWebDAV, "useWebDAV", false, "if true, serve the upload folder over WebDAV at /webdav (needs useSharedSecret for non-local access)")
+	flag.StringVar(&cfg.UseSharedSecret, "useSharedSecret", "", "secret for protected endpoints such as WebDAV, sent as Basic auth password, Bearer token or X-Shared-Secret header")
+	flag.Int64Var(&cfg.UseAutoAcceptMaxSize, "useAutoAcceptMaxSize", 0, "transfers larger than this many bytes need confirmation even with auto-save, favorites or trusted subnets; 0 means no limit")
+	flag.BoolVar(&cfg.UseCommandShares, "useCommandShares", false, "allow share sessions to stream the output of a command (files[].command); commands run with this process's permissions")
+	flag.BoolVar(&cfg.UseLogStream, "useLogStream", false, "buffer recent logs and stream them over SSE at /api/self/v1/logs (shared secret required, localhost only without one)")
+	flag.IntVar(&cfg.UseMaxReceiveSessions, "useMaxReceiveSessions", 0, "how many senders may transfer at once; further prepare-upload requests get 409 (0 means no limit)")
+	flag.BoolVar(&cfg.UseGossip, "useGossip", false, "share known devices in register responses and register with devices learned from peers (for networks that filter multicast)")
+	flag.BoolVar(&cfg.UseIdentityUploadFolder, "useIdentityUploadFolder", false, "save received files under <upload folder>/<alias> so instances sharing an upload folder stay apart")
+	flag.StringVar(&cfg.UseEncryptionKeyFile, "useEncryptionKeyFile", "", "file holding a hex AES-128/192/256 key; received files are stored encrypted with AES-GCM and decrypted when served")
+	flag.IntVar(&cfg.UseAutoScanConcurrency, "useAutoScanConcurrency", 24, "concurrent HTTP scan workers of the periodic auto scan (minimum 1); lower it on weak CPUs")
+	flag.IntVar(&cfg.UseScanNowConcurrency, "useScanNowConcurrency", 256, "concurrent HTTP scan workers of scan-now (minimum 1)")
+	flag.StringVar(&cfg.UseHistoryFile, "useHistoryFile", "", "persist the per-device transfer history to this JSON file; empty keeps it in memory only")
+	flag.IntVar(&cfg.UseUploadStallTimeout, "useUploadStallTimeout", 60, "abort an incoming upload and cancel its session after this many seconds without data; 0 disables")
+	flag.StringVar(&cfg.UseProtocolVersion, "useProtocolVersion", "", "protocol version advertised to peers (e.g. 2.1), overrides the config version; for interop testing")
+	flag.StringVar(&cfg.UseUserAgent, "useUserAgent", "", "User-Agent header of outgoing requests; empty keeps Go's default")
+	flag.BoolVar(&cfg.UseKeepPartialOnCancel, "useKeepPartialOnCancel", false, "keep the received part of a cancelled upload as <name>.part for a later resumed transfer")
+	flag.IntVar(&cfg.UsePartialMaxAge, "usePartialMaxAge", 0, "with -useKeepPartialOnCancel, delete kept partial files not modified for this many seconds; 0 keeps them")
+	flag.IntVar(&cfg.UseMaxConcurrentDownloads, "useMaxConcurrentDownloads", 0, "how many share downloads may be served at once; further downloads get 503 (0 means no limit)")
+	flag.Int64Var(&cfg.UseDownloadRateLimit, "useDownloadRateLimit", 0, "cap each share download at this many bytes per second; 0 means no limit")
+	flag.StringVar(&cfg.UseHashCacheFile, "useHashCacheFile", "", "persist the SHA-256 of sent files (reused while size and mtime match) to this JSON file; empty keeps it in memory only")
+	flag.IntVar(&cfg.UseMaxPreviewBytes, "useMaxPreviewBytes", 0, "drop file previews larger than this many bytes from prepare-upload and confirm notifications (text/plain exempt); 0 means no limit")
+	applyFlagEnv()
 	flag.Parse()
 	return cfg
 }
+
+// applyFlagEnv sets every defined flag from its environment variable, if present; flag.Parse runs after
+// it, so command-line values still win.
+func applyFlagEnv() {
+	flag.VisitAll(func(f *flag.Flag) {
+		name := FlagEnvName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := f.Value.Set(value); err != nil {
+			DefaultLogger.Fatalf("Invalid %s=%q: %v", name, value, err)
+		}
+		envSetFlags[f.Name] = true
+	})
+}
+
+// FlagEnvName returns the environment variable for a flag: a leading "use" is dropped and the
+// camelCase rest becomes upper snake case, e.g. useAlias -> LOCALSEND_ALIAS,
+// skipICMPProbe -> LOCALSEND_SKIP_ICMP_PROBE.
+func FlagEnvName(flagName string) string {
+	if rest, ok := strings.CutPrefix(flagName, "use"); ok && rest != "" && unicode.IsUpper(rune(rest[0])) {
+		flagName = rest
+	}
+	runes := []rune(flagName)
+	var b strings.Builder
+	b.WriteString(EnvPrefix)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
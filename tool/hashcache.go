@@ -0,0 +1,123 @@
+package tool
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+const (
+	// hashCacheMaxEntries caps the cached hashes; an arbitrary entry is dropped to make room
+	hashCacheMaxEntries = 50000
+	// hashCacheSaveDelay batches the writes of a folder hashed file by file into one save
+	hashCacheSaveDelay = 2 * time.Second
+)
+
+// hashCacheEntry is the SHA-256 of a file as it was when hashed; it is reused while size and mtime match.
+type hashCacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"` // UnixNano
+	SHA256  string `json:"sha256"`
+}
+
+var (
+	hashCacheMu      sync.Mutex
+	hashCache        = make(map[string]hashCacheEntry) // absolute path -> entry
+	hashCachePath    string                            // empty keeps the cache in memory only
+	hashCacheSaveDue bool
+)
+
+// SetHashCacheFile loads cached file hashes from path and saves them there from now on.
+// A missing file starts an empty cache; an empty path keeps the cache in memory only.
+// On error the cache is kept in memory only, so an unreadable file is not overwritten.
+func SetHashCacheFile(path string) error {
+	hashCacheMu.Lock()
+	defer hashCacheMu.Unlock()
+	hashCachePath = ""
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		hashCachePath = path
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read hash cache file: %v", err)
+	}
+	loaded := make(map[string]hashCacheEntry)
+	if err := sonic.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("failed to parse hash cache file: %v", err)
+	}
+	hashCache = loaded
+	hashCachePath = path
+	return nil
+}
+
+// hashFileCached returns the SHA-256 of file, opened from path and described by info. A hash cached for the
+// same path, size and mtime is reused without reading the file; otherwise it is read from its current offset.
+func hashFileCached(path string, info os.FileInfo, file io.Reader) (string, error) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		key = path
+	}
+	size, modTime := info.Size(), info.ModTime().UnixNano()
+
+	hashCacheMu.Lock()
+	entry, ok := hashCache[key]
+	hashCacheMu.Unlock()
+	if ok && entry.Size == size && entry.ModTime == modTime {
+		return entry.SHA256, nil
+	}
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(hasher.Sum(nil))
+
+	hashCacheMu.Lock()
+	defer hashCacheMu.Unlock()
+	if _, exists := hashCache[key]; !exists && len(hashCache) >= hashCacheMaxEntries {
+		for k := range hashCache {
+			delete(hashCache, k)
+			break
+		}
+	}
+	hashCache[key] = hashCacheEntry{Size: size, ModTime: modTime, SHA256: sum}
+	if hashCachePath != "" && !hashCacheSaveDue {
+		hashCacheSaveDue = true
+		time.AfterFunc(hashCacheSaveDelay, saveHashCache)
+	}
+	return sum, nil
+}
+
+// saveHashCache writes the cache to the hash cache file.
+func saveHashCache() {
+	hashCacheMu.Lock()
+	defer hashCacheMu.Unlock()
+	hashCacheSaveDue = false
+	if hashCachePath == "" {
+		return
+	}
+	data, err := sonic.Marshal(hashCache)
+	if err != nil {
+		DefaultLogger.Warnf("[HashCache] Failed to marshal hash cache: %v", err)
+		return
+	}
+	tmp := hashCachePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		DefaultLogger.Warnf("[HashCache] Failed to save hash cache: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, hashCachePath); err != nil {
+		DefaultLogger.Warnf("[HashCache] Failed to save hash cache: %v", err)
+	}
+}
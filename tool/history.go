@@ -0,0 +1,103 @@
+package tool
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// historyMaxPerDevice caps the entries kept per peer; the oldest are dropped first.
+const historyMaxPerDevice = 100
+
+var (
+	historyMu sync.Mutex
+	// transferHistory holds completed transfers per peer fingerprint, oldest first
+	transferHistory = make(map[string][]types.TransferHistoryEntry)
+	historyPath     string // empty keeps the history in memory only
+)
+
+// SetHistoryFile loads the transfer history from path and persists it there from now on.
+// A missing file starts an empty history; an empty path keeps the history in memory only.
+// On error the history is kept in memory only, so an unreadable file is not overwritten.
+func SetHistoryFile(path string) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	historyPath = ""
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		historyPath = path
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read history file: %v", err)
+	}
+	loaded := make(map[string][]types.TransferHistoryEntry)
+	if err := sonic.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("failed to parse history file: %v", err)
+	}
+	for fingerprint, entries := range loaded {
+		if len(entries) > historyMaxPerDevice {
+			loaded[fingerprint] = entries[len(entries)-historyMaxPerDevice:]
+		}
+	}
+	transferHistory = loaded
+	historyPath = path
+	return nil
+}
+
+// RecordTransferHistory adds a completed transfer to the history of the peer with fingerprint.
+// Entries without a fingerprint are ignored, since they cannot be attributed to a device.
+func RecordTransferHistory(fingerprint string, entry types.TransferHistoryEntry) {
+	if fingerprint == "" {
+		return
+	}
+	if entry.Timestamp == "" {
+		entry.Timestamp = time.Now().Format(time.RFC3339)
+	}
+	if len(entry.FileNames) > types.HistoryMaxFileNames {
+		entry.FileNames = entry.FileNames[:types.HistoryMaxFileNames]
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	entries := append(transferHistory[fingerprint], entry)
+	if len(entries) > historyMaxPerDevice {
+		entries = slices.Clone(entries[len(entries)-historyMaxPerDevice:])
+	}
+	transferHistory[fingerprint] = entries
+	if historyPath == "" {
+		return
+	}
+	if err := writeHistoryFile(historyPath); err != nil {
+		DefaultLogger.Warnf("[History] Failed to save transfer history: %v", err)
+	}
+}
+
+// GetTransferHistory returns the transfers with the peer device, newest first.
+func GetTransferHistory(fingerprint string) []types.TransferHistoryEntry {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	entries := slices.Clone(transferHistory[fingerprint])
+	slices.Reverse(entries)
+	return entries
+}
+
+// writeHistoryFile saves the whole history; the caller holds historyMu.
+func writeHistoryFile(path string) error {
+	data, err := sonic.Marshal(transferHistory)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %v", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
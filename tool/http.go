@@ -5,6 +5,8 @@ import (
 	"crypto/tls"
 	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,15 +16,31 @@ var (
 	// so that non-responding IPs fail fast and scan-now returns in seconds instead of ~30s.
 	ScanTimeout       = 5 * time.Second
 	ScanDialTimeout   = 3 * time.Second // dial timeout for scan client
-	ConnectionHttpClient *http.Client
-	DetectHttpClient     *http.Client
-	ScanDetectHttpClient *http.Client
+
+	// The shared clients are replaced on network changes while requests use them, so they are
+	// published atomically and read through GetHttpClient, GetDetectHttpClient and friends.
+	connectionHttpClient atomic.Pointer[http.Client]
+	detectHttpClient     atomic.Pointer[http.Client]
+	scanDetectHttpClient atomic.Pointer[http.Client]
+	// transferHttpClient is used for file uploads; see SetTransferClientTimeouts.
+	transferHttpClient atomic.Pointer[http.Client]
+
+	// httpClientsMu serializes rebuilding the clients and guards the settings they are built from
+	httpClientsMu sync.Mutex
+	// Transfer client timeouts. 0 means no limit: a large upload over a slow link must not be cut off,
+	// so only the dial is bounded by default.
+	transferDialTimeout           = 10 * time.Second
+	transferResponseHeaderTimeout time.Duration
+	transferTimeout               time.Duration
+	// httpBindAddr is the local address passed to InitHTTPClients, reused when the transfer client is rebuilt.
+	httpBindAddr *net.TCPAddr
 )
 
 func init() {
-	ConnectionHttpClient = NewHTTPClient()
-	DetectHttpClient = NewHTTPClient()
-	ScanDetectHttpClient = newHTTPClientForScan(nil)
+	connectionHttpClient.Store(NewHTTPClient())
+	detectHttpClient.Store(NewHTTPClient())
+	scanDetectHttpClient.Store(newHTTPClientForScan(nil))
+	transferHttpClient.Store(newHTTPClientForTransfer(nil))
 }
 
 // NewHTTPClient creates an HTTP client, skipping self-signed certificate verification in HTTPS mode.
@@ -86,20 +104,78 @@ func newHTTPClientForScan(bindAddr *net.TCPAddr) *http.Client {
 	}
 }
 
+// newHTTPClientForTransfer creates an HTTP client for file uploads using the transfer* timeouts.
+// Callers other than init must hold httpClientsMu.
+func newHTTPClientForTransfer(bindAddr *net.TCPAddr) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   transferDialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	if bindAddr != nil {
+		dialer.LocalAddr = bindAddr
+	}
+	transport := &http.Transport{
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
+		MaxIdleConns:          50,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       300 * time.Millisecond,
+		DisableKeepAlives:     false,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   transferDialTimeout,
+		ResponseHeaderTimeout: transferResponseHeaderTimeout,
+	}
+	return &http.Client{
+		Timeout:   transferTimeout,
+		Transport: transport,
+	}
+}
+
+// SetTransferClientTimeouts sets the dial, response-header and total timeouts of the upload client
+// and rebuilds it. 0 disables the respective limit; a negative value keeps the current one.
+func SetTransferClientTimeouts(dial, responseHeader, total time.Duration) {
+	httpClientsMu.Lock()
+	defer httpClientsMu.Unlock()
+	if dial >= 0 {
+		transferDialTimeout = dial
+	}
+	if responseHeader >= 0 {
+		transferResponseHeaderTimeout = responseHeader
+	}
+	if total >= 0 {
+		transferTimeout = total
+	}
+	transferHttpClient.Store(newHTTPClientForTransfer(httpBindAddr))
+}
+
 // InitHTTPClients (re)initializes the HTTP clients with optional bind address.
 // Call this after boardcast.SetReferNetworkInterface. When bindAddr is nil (e.g. useReferNetworkInterface is "*"),
 // clients use the default transport without interface binding.
+// Safe to call while requests are in flight; they finish on the clients they started with.
 func InitHTTPClients(bindAddr *net.TCPAddr) {
-	ConnectionHttpClient = newHTTPClientWithBindAddr(bindAddr)
-	DetectHttpClient = newHTTPClientWithBindAddr(bindAddr)
-	ScanDetectHttpClient = newHTTPClientForScan(bindAddr)
+	httpClientsMu.Lock()
+	defer httpClientsMu.Unlock()
+	connectionHttpClient.Store(newHTTPClientWithBindAddr(bindAddr))
+	detectHttpClient.Store(newHTTPClientWithBindAddr(bindAddr))
+	scanDetectHttpClient.Store(newHTTPClientForScan(bindAddr))
+	transferHttpClient.Store(newHTTPClientForTransfer(bindAddr))
+	httpBindAddr = bindAddr
 }
 
 func GetHttpClient() *http.Client {
-	return ConnectionHttpClient
+	return connectionHttpClient.Load()
+}
+
+// GetDetectHttpClient returns the HTTP client used for device detection.
+func GetDetectHttpClient() *http.Client {
+	return detectHttpClient.Load()
 }
 
 // GetScanHttpClient returns the HTTP client used for device scanning (scan-now), with short timeouts.
 func GetScanHttpClient() *http.Client {
-	return ScanDetectHttpClient
+	return scanDetectHttpClient.Load()
+}
+
+// GetTransferHttpClient returns the HTTP client used for file uploads, which has no total timeout by default.
+func GetTransferHttpClient() *http.Client {
+	return transferHttpClient.Load()
 }
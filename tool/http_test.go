@@ -0,0 +1,97 @@
+package tool
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInitHTTPClientsBindsLocalAddress(t *testing.T) {
+	// 127.0.0.2 is a second loopback address, so the server can tell a bound client from the default route
+	probe, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("127.0.0.2 is not usable on this system: %v", err)
+	}
+	probe.Close()
+
+	remoteIPs := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		remoteIPs <- host
+	}))
+	t.Cleanup(server.Close)
+
+	httpClientsMu.Lock()
+	previous := httpBindAddr
+	httpClientsMu.Unlock()
+	t.Cleanup(func() { InitHTTPClients(previous) })
+
+	clients := []struct {
+		name   string
+		client func() *http.Client
+	}{
+		{name: "connection", client: GetHttpClient},
+		{name: "detect", client: GetDetectHttpClient},
+		{name: "scan", client: GetScanHttpClient},
+		{name: "transfer", client: GetTransferHttpClient},
+	}
+	tests := []struct {
+		name        string
+		bindAddr    *net.TCPAddr
+		rebuild     func()
+		wantLocalIP string
+	}{
+		{name: "bound", bindAddr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 2)}, wantLocalIP: "127.0.0.2"},
+		{
+			name:        "bound after transfer timeouts change",
+			bindAddr:    &net.TCPAddr{IP: net.IPv4(127, 0, 0, 2)},
+			rebuild:     func() { SetTransferClientTimeouts(-1, -1, -1) },
+			wantLocalIP: "127.0.0.2",
+		},
+		{name: "unbound", bindAddr: nil, wantLocalIP: "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			InitHTTPClients(tt.bindAddr)
+			if tt.rebuild != nil {
+				tt.rebuild()
+			}
+			for _, c := range clients {
+				resp, err := c.client().Get(server.URL)
+				if err != nil {
+					t.Fatalf("%s client: %v", c.name, err)
+				}
+				resp.Body.Close()
+				if got := <-remoteIPs; got != tt.wantLocalIP {
+					t.Errorf("%s client connected from %s, want %s", c.name, got, tt.wantLocalIP)
+				}
+			}
+		})
+	}
+}
+
+// TestHTTPClientsRebuiltWhileInUse is meant for -race: clients are swapped on network changes while transfers read them.
+func TestHTTPClientsRebuiltWhileInUse(t *testing.T) {
+	httpClientsMu.Lock()
+	previous := httpBindAddr
+	httpClientsMu.Unlock()
+	t.Cleanup(func() { InitHTTPClients(previous) })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			InitHTTPClients(nil)
+			SetTransferClientTimeouts(-1, -1, -1)
+		}
+	}()
+	for range 100 {
+		for _, client := range []*http.Client{GetHttpClient(), GetDetectHttpClient(), GetScanHttpClient(), GetTransferHttpClient()} {
+			if client == nil {
+				t.Fatal("got a nil client while the clients were rebuilt")
+			}
+		}
+	}
+	<-done
+}
@@ -0,0 +1,74 @@
+package tool
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// idleShutdown is the idle window after which RunIdleWatchdog fires; 0 disables it
+	idleShutdown atomic.Int64
+	// lastActivity is the UnixNano time of the last transfer/confirm activity
+	lastActivity atomic.Int64
+	// activeTransfers counts uploads and downloads in flight; the app is never idle while it is above 0
+	activeTransfers atomic.Int64
+)
+
+func init() {
+	lastActivity.Store(time.Now().UnixNano())
+}
+
+// SetIdleShutdown sets how long the app may stay without transfer activity before shutting down (0 disables).
+// Must be called before RunIdleWatchdog.
+func SetIdleShutdown(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	idleShutdown.Store(int64(d))
+}
+
+// TouchActivity resets the idle watchdog. Call it on upload, download and confirm activity.
+func TouchActivity() {
+	lastActivity.Store(time.Now().UnixNano())
+}
+
+// BeginTransfer marks a transfer as in flight, so RunIdleWatchdog does not shut down under it however long
+// it runs. The returned func ends it and resets the idle window; call it once when the transfer is done.
+func BeginTransfer() (end func()) {
+	TouchActivity()
+	activeTransfers.Add(1)
+	return func() {
+		activeTransfers.Add(-1)
+		TouchActivity()
+	}
+}
+
+// idleTime returns how long no activity has been seen, 0 while a transfer is in flight.
+func idleTime() time.Duration {
+	if activeTransfers.Load() > 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, lastActivity.Load()))
+}
+
+// RunIdleWatchdog calls onIdle once no activity has been seen for the idle window, then returns.
+// Returns immediately when idle shutdown is disabled.
+func RunIdleWatchdog(onIdle func()) {
+	window := time.Duration(idleShutdown.Load())
+	if window <= 0 {
+		return
+	}
+	// Check a few times per window so the actual shutdown is not much later than configured
+	interval := min(max(window/4, time.Second), time.Minute)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		idle := idleTime()
+		if idle >= window {
+			DefaultLogger.Infof("[Idle] No activity for %s, shutting down", idle.Round(time.Second))
+			onIdle()
+			return
+		}
+	}
+}
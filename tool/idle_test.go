@@ -0,0 +1,28 @@
+package tool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdleTimeDuringTransfer(t *testing.T) {
+	previous := lastActivity.Load()
+	t.Cleanup(func() { lastActivity.Store(previous) })
+	stale := func() { lastActivity.Store(time.Now().Add(-time.Hour).UnixNano()) }
+
+	stale()
+	if idle := idleTime(); idle < time.Hour {
+		t.Fatalf("idleTime() = %s without transfers, want at least 1h", idle)
+	}
+
+	end := BeginTransfer()
+	stale()
+	if idle := idleTime(); idle != 0 {
+		t.Errorf("idleTime() = %s while a transfer is in flight, want 0", idle)
+	}
+
+	end()
+	if idle := idleTime(); idle >= time.Minute {
+		t.Errorf("idleTime() = %s right after a transfer ended, want the window restarted", idle)
+	}
+}
@@ -0,0 +1,144 @@
+package tool
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/moyoez/localsend-go/types"
+	"github.com/muesli/termenv"
+)
+
+const (
+	// logStreamHistory is how many recent lines a new logs subscriber receives first
+	logStreamHistory = 500
+	// logStreamBuffer is the per-subscriber backlog; lines are dropped for a subscriber that falls behind
+	logStreamBuffer = 256
+)
+
+// ansiEscape matches the color sequences of the console formatter.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// logLevelTokens maps the level labels printed by the text formatter to levels.
+var logLevelTokens = map[string]log.Level{
+	"DEBU": log.DebugLevel,
+	"INFO": log.InfoLevel,
+	"WARN": log.WarnLevel,
+	"ERRO": log.ErrorLevel,
+	"FATA": log.FatalLevel,
+}
+
+type logSubscriber struct {
+	minLevel log.Level
+	ch       chan types.LogEntry
+}
+
+// logSink keeps the most recent log lines and fans new ones out to subscribers.
+type logSink struct {
+	mu          sync.Mutex
+	entries     []types.LogEntry
+	levels      []log.Level
+	next        int // ring position of the next entry once the buffer is full
+	subscribers map[*logSubscriber]struct{}
+}
+
+var (
+	logStreamOnce sync.Once
+	logStream     *logSink
+)
+
+// EnableLogStream tees DefaultLogger output into an in-memory ring buffer that SubscribeLogs reads.
+// Console output is unchanged, including colors. Calling it again has no effect.
+func EnableLogStream() {
+	logStreamOnce.Do(func() {
+		logStream = &logSink{subscribers: make(map[*logSubscriber]struct{})}
+		// the renderer is derived from the output writer, so keep stderr's color profile for the tee
+		profile := termenv.NewOutput(os.Stderr).EnvColorProfile()
+		DefaultLogger.SetOutput(io.MultiWriter(os.Stderr, logStream))
+		DefaultLogger.SetColorProfile(profile)
+	})
+}
+
+// LogStreamEnabled reports whether EnableLogStream was called.
+func LogStreamEnabled() bool {
+	return logStream != nil
+}
+
+// SubscribeLogs returns the buffered lines at or above minLevel and a channel of new ones.
+// cancel must be called to release the subscription; it closes the channel.
+func SubscribeLogs(minLevel log.Level) (history []types.LogEntry, lines <-chan types.LogEntry, cancel func()) {
+	sink := logStream
+	if sink == nil {
+		ch := make(chan types.LogEntry)
+		close(ch)
+		return nil, ch, func() {}
+	}
+	sub := &logSubscriber{minLevel: minLevel, ch: make(chan types.LogEntry, logStreamBuffer)}
+	sink.mu.Lock()
+	for i := range sink.entries {
+		idx := (sink.next + i) % len(sink.entries)
+		if sink.levels[idx] >= minLevel {
+			history = append(history, sink.entries[idx])
+		}
+	}
+	sink.subscribers[sub] = struct{}{}
+	sink.mu.Unlock()
+
+	var once sync.Once
+	return history, sub.ch, func() {
+		once.Do(func() {
+			sink.mu.Lock()
+			delete(sink.subscribers, sub)
+			sink.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+}
+
+// Write records one formatted log line. It never blocks on slow subscribers.
+func (s *logSink) Write(p []byte) (int, error) {
+	line := strings.TrimRight(ansiEscape.ReplaceAllString(string(p), ""), "\n")
+	level := parseLogLineLevel(line)
+	entry := types.LogEntry{
+		Time:  time.Now().Format(time.RFC3339),
+		Level: level.String(),
+		Line:  line,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) < logStreamHistory {
+		s.entries = append(s.entries, entry)
+		s.levels = append(s.levels, level)
+	} else {
+		s.entries[s.next] = entry
+		s.levels[s.next] = level
+		s.next = (s.next + 1) % logStreamHistory
+	}
+	for sub := range s.subscribers {
+		if level < sub.minLevel {
+			continue
+		}
+		select {
+		case sub.ch <- entry:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// parseLogLineLevel finds the level label in a text-formatted line ("<date> <time> INFO <caller> msg");
+// lines without one count as info.
+func parseLogLineLevel(line string) log.Level {
+	fields := strings.Fields(line)
+	for _, field := range fields[:min(len(fields), 3)] {
+		if level, ok := logLevelTokens[field]; ok {
+			return level
+		}
+	}
+	return log.InfoLevel
+}
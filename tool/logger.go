@@ -1,6 +1,8 @@
 package tool
 
 import (
+	"context"
+
 	"github.com/charmbracelet/log"
 )
 
@@ -10,3 +12,16 @@ func InitLogger() {
 	DefaultLogger.SetTimeFormat("2006-01-02 15:04:05")
 	DefaultLogger.SetReportCaller(true)
 }
+
+// WithRequestLogger returns a context carrying a child of DefaultLogger tagged with the request ID.
+func WithRequestLogger(ctx context.Context, requestId string) context.Context {
+	return log.WithContext(ctx, DefaultLogger.With("reqId", requestId))
+}
+
+// LoggerFromContext returns the request-scoped logger stored by WithRequestLogger, or DefaultLogger.
+func LoggerFromContext(ctx context.Context) *log.Logger {
+	if logger, ok := ctx.Value(log.ContextKey).(*log.Logger); ok && logger != nil {
+		return logger
+	}
+	return DefaultLogger
+}
@@ -2,6 +2,7 @@ package tool
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"strconv"
@@ -161,11 +162,48 @@ func QuickICMPProbe(ip string, timeout time.Duration) bool {
 	return ok
 }
 
+// ParseTargetIP parses an IPv4/IPv6 address, or resolves a hostname, for an outgoing target.
+func ParseTargetIP(addr string) (net.IP, error) {
+	addr = strings.Trim(strings.TrimSpace(addr), "[]")
+	if addr == "" {
+		return nil, fmt.Errorf("empty address")
+	}
+	if ip := net.ParseIP(addr); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4, nil
+		}
+		return ip, nil
+	}
+	ips, err := net.LookupIP(addr)
+	if err != nil || len(ips) == 0 {
+		return nil, fmt.Errorf("unparseable address %q", addr)
+	}
+	return ips[0], nil
+}
+
+// QuickTCPProbe checks if a host accepts TCP connections on port within timeout.
+// Used instead of QuickICMPProbe on networks that drop ICMP, and as a preflight before prepare-upload.
+func QuickTCPProbe(ip string, port int, timeout time.Duration) bool {
+	if net.ParseIP(ip) == nil {
+		return false
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(port)), timeout)
+	if err != nil {
+		return false
+	}
+	if err := conn.Close(); err != nil {
+		DefaultLogger.Debugf("QuickTCPProbe: close %s: %v", ip, err)
+	}
+	DefaultLogger.Debugf("QuickTCPProbe: %s:%d accepted", ip, port)
+	return true
+}
+
 func NewHTTPReqWithApplication(req *http.Request, err error) (*http.Request, error) {
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	ApplyUserAgent(req)
 	return req, nil
 }
 
@@ -0,0 +1,156 @@
+package tool
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register decoder
+	"image/jpeg"
+	_ "image/png" // register decoder
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// MaxPreviewBytes caps a generated preview (data URI length). The confirm_recv notification carries up to
+// 20 files and must stay under the 32KB notify write limit, so each preview gets well under 1/20 of it.
+const MaxPreviewBytes = 1200
+
+// maxPreviewSourcePixels guards against decoding huge images just to build a thumbnail.
+const maxPreviewSourcePixels = 50_000_000
+
+var (
+	generatePreviews    atomic.Bool
+	previewMaxDimension atomic.Int32
+	// maxPreviewBytes caps any preview sent or notified, see SetMaxPreviewBytes; 0 means no limit
+	maxPreviewBytes atomic.Int64
+	// previewImageTypes are the fileTypes a thumbnail is generated for
+	previewImageTypes = map[string]bool{
+		"image/jpeg": true,
+		"image/png":  true,
+		"image/gif":  true,
+	}
+	// previewQualities are tried in order until the encoded preview fits MaxPreviewBytes
+	previewQualities = []int{60, 40, 20}
+)
+
+func init() {
+	previewMaxDimension.Store(40)
+}
+
+// SetGeneratePreviews enables thumbnail previews for image files in ProcessFileInput.
+// Off by default, since decoding every image costs CPU on each prepare-upload.
+func SetGeneratePreviews(enabled bool) {
+	generatePreviews.Store(enabled)
+}
+
+// SetPreviewMaxDimension sets the longest side of generated thumbnails in pixels.
+func SetPreviewMaxDimension(px int) {
+	if px > 0 {
+		previewMaxDimension.Store(int32(px))
+	}
+}
+
+// SetMaxPreviewBytes sets the largest preview kept by ProcessFileInput and in confirm notifications;
+// larger ones are dropped. 0 or less means no limit. text/plain previews carry the message of a text
+// transfer rather than a thumbnail, so they are never dropped.
+func SetMaxPreviewBytes(n int) {
+	maxPreviewBytes.Store(int64(max(n, 0)))
+}
+
+// LimitPreview returns preview, or "" when it exceeds the SetMaxPreviewBytes limit.
+func LimitPreview(fileName, fileType, preview string) string {
+	limit := maxPreviewBytes.Load()
+	if limit <= 0 || int64(len(preview)) <= limit || strings.EqualFold(strings.TrimSpace(fileType), "text/plain") {
+		return preview
+	}
+	DefaultLogger.Infof("[Preview] Dropped %d byte preview of %s (limit %d)", len(preview), fileName, limit)
+	return ""
+}
+
+// shouldGeneratePreview reports whether a preview should be built for a file of this type.
+func shouldGeneratePreview(fileType string) bool {
+	return generatePreviews.Load() && previewImageTypes[fileType]
+}
+
+// GenerateImagePreview decodes the image at path and returns a small JPEG thumbnail as a
+// "data:image/jpeg;base64,..." URI no longer than MaxPreviewBytes.
+func GenerateImagePreview(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			DefaultLogger.Warnf("Failed to close file: %v", err)
+		}
+	}()
+
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return "", fmt.Errorf("decode image config: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxPreviewSourcePixels {
+		return "", fmt.Errorf("image too large for preview: %dx%d", cfg.Width, cfg.Height)
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return "", err
+	}
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return "", fmt.Errorf("decode image: %w", err)
+	}
+
+	// Lower the quality first, then halve the size, until the preview fits
+	maxDim := int(previewMaxDimension.Load())
+	for _, dim := range []int{maxDim, max(1, maxDim/2)} {
+		thumb := downscaleImage(src, dim)
+		for _, quality := range previewQualities {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: quality}); err != nil {
+				return "", fmt.Errorf("encode preview: %w", err)
+			}
+			preview := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+			if len(preview) <= MaxPreviewBytes {
+				return preview, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("preview exceeds %d bytes", MaxPreviewBytes)
+}
+
+// downscaleImage shrinks src so its longest side is at most maxDim, averaging each source box
+// into one destination pixel. Images already small enough are returned unchanged.
+func downscaleImage(src image.Image, maxDim int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxDim && h <= maxDim {
+		return src
+	}
+	dw, dh := maxDim, maxDim
+	if w >= h {
+		dh = max(1, h*maxDim/w)
+	} else {
+		dw = max(1, w*maxDim/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		y0, y1 := bounds.Min.Y+y*h/dh, bounds.Min.Y+(y+1)*h/dh
+		for x := range dw {
+			x0, x1 := bounds.Min.X+x*w/dw, bounds.Min.X+(x+1)*w/dw
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
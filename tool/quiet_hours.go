@@ -0,0 +1,95 @@
+package tool
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Quiet hours actions.
+const (
+	QuietHoursReject     = "reject"      // reject every incoming transfer
+	QuietHoursRequirePin = "require-pin" // require a PIN even when autoSave/favorites/subnets would accept
+)
+
+var (
+	quietHoursMu sync.RWMutex
+	// quietHoursStart and quietHoursEnd are minutes since local midnight; equal values mean disabled
+	quietHoursStart  int
+	quietHoursEnd    int
+	quietHoursAction string
+	// quietHoursPin is the PIN asked for by require-pin; empty falls back to the configured PIN
+	quietHoursPin string
+)
+
+// SetQuietHours sets a daily window (local time, "HH:MM") during which incoming transfers are
+// handled by action instead of the normal accept logic. The window may cross midnight, e.g. 22:00-07:00.
+// Empty start and end disable quiet hours.
+func SetQuietHours(start, end, action string) error {
+	if start == "" && end == "" {
+		quietHoursMu.Lock()
+		quietHoursStart, quietHoursEnd, quietHoursAction = 0, 0, ""
+		quietHoursMu.Unlock()
+		return nil
+	}
+	startMin, err := parseClock(start)
+	if err != nil {
+		return fmt.Errorf("invalid quiet hours start: %v", err)
+	}
+	endMin, err := parseClock(end)
+	if err != nil {
+		return fmt.Errorf("invalid quiet hours end: %v", err)
+	}
+	if startMin == endMin {
+		return fmt.Errorf("quiet hours start and end must differ")
+	}
+	if action != QuietHoursReject && action != QuietHoursRequirePin {
+		return fmt.Errorf("invalid quiet hours action %q (use %s or %s)", action, QuietHoursReject, QuietHoursRequirePin)
+	}
+	quietHoursMu.Lock()
+	quietHoursStart, quietHoursEnd, quietHoursAction = startMin, endMin, action
+	quietHoursMu.Unlock()
+	return nil
+}
+
+// SetQuietHoursPin sets the PIN required during quiet hours with the require-pin action.
+func SetQuietHoursPin(pin string) {
+	quietHoursMu.Lock()
+	defer quietHoursMu.Unlock()
+	quietHoursPin = pin
+}
+
+// GetQuietHoursPin returns the quiet hours PIN, empty if the configured PIN is used.
+func GetQuietHoursPin() string {
+	quietHoursMu.RLock()
+	defer quietHoursMu.RUnlock()
+	return quietHoursPin
+}
+
+// ActiveQuietHoursAction returns the quiet hours action if now falls inside the window, otherwise "".
+func ActiveQuietHoursAction(now time.Time) string {
+	quietHoursMu.RLock()
+	defer quietHoursMu.RUnlock()
+	if quietHoursStart == quietHoursEnd {
+		return ""
+	}
+	minute := now.Hour()*60 + now.Minute()
+	inside := minute >= quietHoursStart && minute < quietHoursEnd
+	if quietHoursStart > quietHoursEnd {
+		// window crosses midnight
+		inside = minute >= quietHoursStart || minute < quietHoursEnd
+	}
+	if !inside {
+		return ""
+	}
+	return quietHoursAction
+}
+
+// parseClock parses "HH:MM" into minutes since midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
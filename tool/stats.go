@@ -0,0 +1,63 @@
+package tool
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+// Runtime counters for Stats, incremented where transfers and discovery events complete
+var (
+	statsStartedAt        = time.Now()
+	statFilesReceived     atomic.Int64
+	statFilesSent         atomic.Int64
+	statDownloads         atomic.Int64
+	statBytesIn           atomic.Int64
+	statBytesOut          atomic.Int64
+	statDevicesDiscovered atomic.Int64
+	statScanCycles        atomic.Int64
+)
+
+// CountFileReceived records a file of size bytes received successfully.
+func CountFileReceived(size int64) {
+	statFilesReceived.Add(1)
+	statBytesIn.Add(max(size, 0))
+}
+
+// CountFileSent records a file of size bytes sent successfully.
+func CountFileSent(size int64) {
+	statFilesSent.Add(1)
+	statBytesOut.Add(max(size, 0))
+}
+
+// CountDownload records a share download that wrote n bytes to the client.
+func CountDownload(n int64) {
+	statDownloads.Add(1)
+	statBytesOut.Add(max(n, 0))
+}
+
+// CountDeviceDiscovered records a device newly added to the scan list.
+func CountDeviceDiscovered() {
+	statDevicesDiscovered.Add(1)
+}
+
+// CountScanCycle records a completed HTTP scan sweep.
+func CountScanCycle() {
+	statScanCycles.Add(1)
+}
+
+// Stats returns the runtime counters since startup, for embedders that want stats without an HTTP round trip.
+func Stats() types.RuntimeStats {
+	return types.RuntimeStats{
+		FilesReceived:         statFilesReceived.Load(),
+		FilesSent:             statFilesSent.Load(),
+		Downloads:             statDownloads.Load(),
+		BytesIn:               statBytesIn.Load(),
+		BytesOut:              statBytesOut.Load(),
+		ActiveReceiveSessions: ActiveReceiveSessions(),
+		DevicesDiscovered:     statDevicesDiscovered.Load(),
+		ScanCycles:            statScanCycles.Load(),
+		UptimeSeconds:         int64(time.Since(statsStartedAt).Seconds()),
+	}
+}
@@ -0,0 +1,47 @@
+package tool
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// throttledReadSeeker limits how fast data is read from an io.ReadSeeker.
+type throttledReadSeeker struct {
+	ctx     context.Context
+	rs      io.ReadSeeker
+	limiter *rate.Limiter
+}
+
+// NewThrottledReadSeeker returns rs read at no more than bytesPerSecond; reads fail once ctx is done.
+// A bytesPerSecond of 0 or less returns rs unchanged. Seeking is passed through, so it works with http.ServeContent.
+func NewThrottledReadSeeker(ctx context.Context, rs io.ReadSeeker, bytesPerSecond int64) io.ReadSeeker {
+	if bytesPerSecond <= 0 {
+		return rs
+	}
+	// One read may take at most a burst, which is also the largest read WaitN accepts
+	burst := int(min(max(bytesPerSecond, 32*1024), 1024*1024))
+	return &throttledReadSeeker{
+		ctx:     ctx,
+		rs:      rs,
+		limiter: rate.NewLimiter(rate.Limit(bytesPerSecond), burst),
+	}
+}
+
+func (t *throttledReadSeeker) Read(p []byte) (int, error) {
+	if len(p) > t.limiter.Burst() {
+		p = p[:t.limiter.Burst()]
+	}
+	n, err := t.rs.Read(p)
+	if n > 0 {
+		if waitErr := t.limiter.WaitN(t.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func (t *throttledReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return t.rs.Seek(offset, whence)
+}
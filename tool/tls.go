@@ -5,6 +5,7 @@ import (
 	"crypto/elliptic"
 	cryptorand "crypto/rand"
 	"crypto/sha256"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/hex"
@@ -12,6 +13,7 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
+	"os"
 	"time"
 
 	"github.com/moyoez/localsend-go/types"
@@ -31,8 +33,7 @@ func GetOrCreateFingerprintFromConfig(cfg *types.AppConfig) string {
 		certDER, _, err := loadTLSCertFromPEM(cfg.CertPEM, cfg.KeyPEM)
 		if err == nil {
 			// Certificate exists and valid, calculate fingerprint
-			hash := sha256.Sum256(certDER)
-			fingerprint := hex.EncodeToString(hash[:16])
+			fingerprint := CertFingerprint(certDER)
 			GenerateTlsSha256Fingerprint = fingerprint
 			DefaultLogger.Debugf("Fingerprint from existing certificate in config: %s", fingerprint)
 			return fingerprint
@@ -73,8 +74,7 @@ func GetOrCreateTLSCertFromConfig(cfg *types.AppConfig) (certDER []byte, keyDER
 		certDER, keyDER, err = loadTLSCertFromPEM(cfg.CertPEM, cfg.KeyPEM)
 		if err == nil {
 			// Calculate fingerprint from loaded cert
-			hash := sha256.Sum256(certDER)
-			GenerateTlsSha256Fingerprint = hex.EncodeToString(hash[:16])
+			GenerateTlsSha256Fingerprint = CertFingerprint(certDER)
 			DefaultLogger.Infof("Loaded existing TLS certificate from config")
 			return certDER, keyDER, nil
 		}
@@ -102,6 +102,123 @@ func GetOrCreateTLSCertFromConfig(cfg *types.AppConfig) (certDER []byte, keyDER
 	return certDER, keyDER, nil
 }
 
+// RotateTLSCertInConfig generates a fresh certificate/key, stores it in cfg (replacing any imported
+// certPath/keyPath), updates the fingerprint and saves the config file.
+func RotateTLSCertInConfig(cfg *types.AppConfig) (certDER []byte, keyDER []byte, err error) {
+	certDER, keyDER, err = generateTLSCert()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg.CertPEM = string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: certDER,
+	}))
+	cfg.KeyPEM = string(pem.EncodeToMemory(&pem.Block{
+		Type:  "EC PRIVATE KEY",
+		Bytes: keyDER,
+	}))
+	// An imported pair would be re-imported on next start, undoing the rotation
+	cfg.CertPath = ""
+	cfg.KeyPath = ""
+	cfg.Fingerprint = CertFingerprint(certDER)
+
+	if err := writeDefaultConfig(ConfigPath, *cfg); err != nil {
+		return nil, nil, fmt.Errorf("failed to save rotated certificate: %v", err)
+	}
+	DefaultLogger.Infof("TLS certificate rotated, new fingerprint: %s", cfg.Fingerprint)
+	return certDER, keyDER, nil
+}
+
+// RotateCurrentTLSCert rotates the certificate of the current config (see RotateTLSCertInConfig)
+// and publishes the result, returning the new certificate, key and fingerprint.
+func RotateCurrentTLSCert() (certDER []byte, keyDER []byte, fingerprint string, err error) {
+	err = UpdateCurrentConfig(func(cfg *types.AppConfig) error {
+		certDER, keyDER, err = RotateTLSCertInConfig(cfg)
+		fingerprint = cfg.Fingerprint
+		return err
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return certDER, keyDER, fingerprint, nil
+}
+
+// TLSKeyPairFromDER builds a tls.Certificate from DER certificate and EC private key bytes.
+func TLSKeyPairFromDER(certDER []byte, keyDER []byte) (tls.Certificate, error) {
+	certPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: certDER,
+	})
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "EC PRIVATE KEY",
+		Bytes: keyDER,
+	})
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// CertFingerprint returns the device fingerprint derived from a DER certificate (SHA-256, first 16 bytes in hex).
+func CertFingerprint(certDER []byte) string {
+	hash := sha256.Sum256(certDER)
+	return hex.EncodeToString(hash[:16])
+}
+
+// importTLSCertFromPaths reads the cert+key pair referenced by cfg.CertPath and cfg.KeyPath
+// and stores it in CertPEM/KeyPEM, so a fingerprint can be carried over from another install.
+// Returns true when the stored PEM changed.
+func importTLSCertFromPaths(cfg *types.AppConfig) (bool, error) {
+	certPEM, err := os.ReadFile(cfg.CertPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read cert file: %v", err)
+	}
+	keyPEM, err := os.ReadFile(cfg.KeyPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read key file: %v", err)
+	}
+
+	// Validate certificate (parse + expiry)
+	if _, _, err := loadTLSCertFromPEM(string(certPEM), string(keyPEM)); err != nil {
+		return false, err
+	}
+
+	// Normalize key to SEC1 "EC PRIVATE KEY", which is what the server loads
+	keyBlock, _ := pem.Decode(keyPEM)
+	keyDER, err := normalizeECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return false, err
+	}
+	normalizedKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "EC PRIVATE KEY",
+		Bytes: keyDER,
+	})
+
+	// Make sure the key actually belongs to the certificate
+	if _, err := tls.X509KeyPair(certPEM, normalizedKeyPEM); err != nil {
+		return false, fmt.Errorf("cert and key do not match: %v", err)
+	}
+
+	changed := cfg.CertPEM != string(certPEM) || cfg.KeyPEM != string(normalizedKeyPEM)
+	cfg.CertPEM = string(certPEM)
+	cfg.KeyPEM = string(normalizedKeyPEM)
+	return changed, nil
+}
+
+// normalizeECPrivateKey accepts a SEC1 or PKCS#8 ECDSA key and returns it in SEC1 DER form.
+func normalizeECPrivateKey(der []byte) ([]byte, error) {
+	if _, err := x509.ParseECPrivateKey(der); err == nil {
+		return der, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("only ECDSA private keys are supported")
+	}
+	return x509.MarshalECPrivateKey(ecKey)
+}
+
 // generateRandomFingerprint generates a random 32-character fingerprint (fallback), for http method.
 func generateRandomFingerprint() string {
 	b := make([]byte, 16)
@@ -169,8 +286,7 @@ func generateTLSCert() (certDER []byte, keyDER []byte, err error) {
 		return nil, nil, fmt.Errorf("failed to marshal ECDSA private key: %v", err)
 	}
 
-	hash := sha256.Sum256(certBytes)
-	GenerateTlsSha256Fingerprint = hex.EncodeToString(hash[:16])
+	GenerateTlsSha256Fingerprint = CertFingerprint(certBytes)
 
 	return certBytes, privateKeyBytes, nil
 }
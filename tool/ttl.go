@@ -2,6 +2,8 @@ package tool
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	ttlworker "github.com/FloatTech/ttl"
@@ -9,16 +11,116 @@ import (
 
 const (
 	DefaultTTL = 3600 * time.Second
+	// DefaultMaxFilesPerSession is the default cap on files offered in one prepare-upload request.
+	DefaultMaxFilesPerSession = 10000
 )
 
 var (
-	SessionCache = ttlworker.NewCache[string, bool](DefaultTTL)
+	SessionCache = newSessionCache(DefaultTTL)
+
+	// sessionSlotsMu guards activeReceiveSessions and maxActiveReceiveSessions
+	sessionSlotsMu sync.Mutex
+	// activeReceiveSessions counts joined sessions still in SessionCache
+	activeReceiveSessions int
+	// maxActiveReceiveSessions caps concurrent receive sessions; 0 means no limit
+	maxActiveReceiveSessions int
+	// maxFilesPerSession caps the files offered in one prepare-upload; 0 means no limit
+	maxFilesPerSession atomic.Int64
 )
 
+func init() {
+	maxFilesPerSession.Store(DefaultMaxFilesPerSession)
+}
+
+// newSessionCache returns a session cache that frees a receive slot whenever an entry is deleted or expires.
+func newSessionCache(ttl time.Duration) *ttlworker.Cache[string, bool] {
+	return ttlworker.NewCacheOn(ttl, [4]func(string, bool){nil, nil, func(string, bool) {
+		sessionSlotsMu.Lock()
+		defer sessionSlotsMu.Unlock()
+		if activeReceiveSessions > 0 {
+			activeReceiveSessions--
+		}
+	}, nil})
+}
+
+// ReplaceCache returns a cache with the given ttl and hooks holding the entries of old, then destroys old
+// so its gc goroutine stops. Entries are copied with a fresh ttl; the delete hook of old runs for each of them.
+// Meant for setup, while nothing else writes to old.
+func ReplaceCache[K comparable, V any](old *ttlworker.Cache[K, V], ttl time.Duration, on [4]func(K, V)) *ttlworker.Cache[K, V] {
+	cache := ttlworker.NewCacheOn(ttl, on)
+	_ = old.Range(func(key K, value V) error {
+		cache.Set(key, value)
+		return nil
+	})
+	old.Destroy()
+	return cache
+}
+
+// SetMaxActiveReceiveSessions sets how many receive sessions may run at once; further senders get
+// "blocked by another session" (409). 0 or less means no limit.
+func SetMaxActiveReceiveSessions(n int) {
+	sessionSlotsMu.Lock()
+	defer sessionSlotsMu.Unlock()
+	maxActiveReceiveSessions = max(n, 0)
+}
+
+// SetMaxFilesPerSession sets how many files one prepare-upload request may offer; larger requests are
+// rejected with 400 before a session is created. 0 or less means no limit.
+func SetMaxFilesPerSession(n int) {
+	maxFilesPerSession.Store(int64(max(n, 0)))
+}
+
+// MaxFilesPerSession returns the cap set by SetMaxFilesPerSession.
+func MaxFilesPerSession() int {
+	return int(maxFilesPerSession.Load())
+}
+
+// ActiveReceiveSessions returns the number of receive sessions holding a slot.
+func ActiveReceiveSessions() int {
+	sessionSlotsMu.Lock()
+	defer sessionSlotsMu.Unlock()
+	return activeReceiveSessions
+}
+
+// SetReceiveSessionTTL sets the idle lifetime of joined sessions, so abandoned ones give their slot back.
+// Meant to be called at startup; joined sessions are moved to the new cache and keep their slots.
+func SetReceiveSessionTTL(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	sessionCache := newSessionCache(d)
+	joined := 0
+	_ = SessionCache.Range(func(sessionId string, valid bool) error {
+		if valid {
+			sessionCache.Set(sessionId, true)
+			joined++
+		}
+		return nil
+	})
+	// Destroy frees a slot for every old entry, so the slots of the moved sessions are taken again
+	SessionCache.Destroy()
+	SessionCache = sessionCache
+	sessionSlotsMu.Lock()
+	activeReceiveSessions += joined
+	sessionSlotsMu.Unlock()
+}
+
+// JoinSession registers a receive session, taking one of the SetMaxActiveReceiveSessions slots.
+// The slot is freed by DestorySession or when the session idles out.
 func JoinSession(sessionId string) error {
+	// SessionCache may free a slot from its delete hook, so it is not called with sessionSlotsMu held
 	if SessionCache.Get(sessionId) {
 		return fmt.Errorf("session %s already joined", sessionId)
 	}
+	sessionSlotsMu.Lock()
+	if maxActiveReceiveSessions > 0 && activeReceiveSessions >= maxActiveReceiveSessions {
+		active, limit := activeReceiveSessions, maxActiveReceiveSessions
+		sessionSlotsMu.Unlock()
+		DefaultLogger.Infof("Session %s refused: %d/%d receive sessions active", sessionId, active, limit)
+		return fmt.Errorf("blocked by another session")
+	}
+	activeReceiveSessions++
+	sessionSlotsMu.Unlock()
 	SessionCache.Set(sessionId, true)
 	DefaultLogger.Debugf("Session %s joined", sessionId)
 	return nil
@@ -0,0 +1,56 @@
+package tool
+
+import (
+	"testing"
+	"time"
+
+	ttlworker "github.com/FloatTech/ttl"
+)
+
+func TestReplaceCache(t *testing.T) {
+	var deleted []string
+	old := ttlworker.NewCacheOn(time.Hour, [4]func(string, int){nil, nil, func(key string, _ int) {
+		deleted = append(deleted, key)
+	}, nil})
+	old.Set("a", 1)
+	old.Set("b", 2)
+
+	replaced := ReplaceCache(old, time.Minute, [4]func(string, int){})
+	t.Cleanup(replaced.Destroy)
+
+	for key, want := range map[string]int{"a": 1, "b": 2} {
+		if got := replaced.Get(key); got != want {
+			t.Errorf("replaced.Get(%q) = %d, want %d", key, got, want)
+		}
+	}
+	if len(deleted) != 2 {
+		t.Errorf("delete hook of the old cache ran for %v, want both entries", deleted)
+	}
+	if got := old.Get("a"); got != 0 {
+		t.Errorf("old cache still returns %d after being replaced", got)
+	}
+}
+
+func TestSetReceiveSessionTTLKeepsJoinedSessions(t *testing.T) {
+	t.Cleanup(func() { SetReceiveSessionTTL(DefaultTTL) })
+	before := ActiveReceiveSessions()
+	for _, sessionId := range []string{"ttl-first", "ttl-second"} {
+		if err := JoinSession(sessionId); err != nil {
+			t.Fatalf("JoinSession(%q): %v", sessionId, err)
+		}
+		t.Cleanup(func() { DestorySession(sessionId) })
+	}
+
+	SetReceiveSessionTTL(time.Minute)
+	if !QuerySessionIsValid("ttl-first") || !QuerySessionIsValid("ttl-second") {
+		t.Fatal("joined sessions were dropped by SetReceiveSessionTTL")
+	}
+	if got := ActiveReceiveSessions(); got != before+2 {
+		t.Fatalf("ActiveReceiveSessions() = %d after the TTL change, want %d", got, before+2)
+	}
+
+	DestorySession("ttl-first")
+	if got := ActiveReceiveSessions(); got != before+1 {
+		t.Errorf("ActiveReceiveSessions() = %d after ending a moved session, want %d", got, before+1)
+	}
+}
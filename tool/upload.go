@@ -6,9 +6,113 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// MaxFileNameBytes is the max length in bytes of each received file/folder name segment.
+var MaxFileNameBytes = 255
+
+// windowsReservedNames are device names that cannot be used as file names on Windows (with any extension).
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SetMaxFileNameBytes sets the max received file name length in bytes. Non-positive values are ignored.
+func SetMaxFileNameBytes(n int) {
+	if n > 0 {
+		MaxFileNameBytes = n
+	}
+}
+
+// ValidateRelativePath rejects peer-supplied names that could escape the upload folder on any OS:
+// absolute paths, Windows drive letters (e.g. "C:\\evil" or "C:evil"), backslash separators and ".." segments.
+// Senders always use "/" between folder segments, so a backslash is never legitimate.
+func ValidateRelativePath(p string) error {
+	switch {
+	case slices.Contains(strings.Split(p, "/"), ".."):
+		return fmt.Errorf("path traversal not allowed")
+	case strings.Contains(p, "\\"):
+		return fmt.Errorf("backslash not allowed in file name")
+	case strings.HasPrefix(p, "/"):
+		return fmt.Errorf("absolute path not allowed")
+	case len(p) >= 2 && p[1] == ':' && ('a' <= p[0]|0x20 && p[0]|0x20 <= 'z'):
+		return fmt.Errorf("drive letter not allowed")
+	case filepath.IsAbs(p) || filepath.VolumeName(p) != "":
+		return fmt.Errorf("absolute path not allowed")
+	}
+	return nil
+}
+
+// SanitizeRelativePath sanitizes every segment of a slash-separated relative path from a peer
+// (e.g. "foldername/subdir/file.txt"). "." and ".." are kept so the caller's traversal guard still applies.
+func SanitizeRelativePath(p string) string {
+	segments := strings.Split(filepath.ToSlash(p), "/")
+	out := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if seg == "" {
+			continue
+		}
+		if seg == "." || seg == ".." {
+			out = append(out, seg)
+			continue
+		}
+		out = append(out, SanitizeFileName(seg))
+	}
+	return strings.Join(out, "/")
+}
+
+// SanitizeFileName strips control characters and trailing dots/spaces (which Windows drops), prefixes
+// reserved Windows device names with "_" and truncates to MaxFileNameBytes while preserving the extension.
+// A name is reserved when the part before its first dot is a device name, e.g. "CON.tar.gz" or "nul.txt".
+func SanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
+	if name == "" {
+		return "_"
+	}
+
+	stem, _, _ := strings.Cut(name, ".")
+	if windowsReservedNames[strings.ToUpper(strings.TrimRight(stem, " "))] {
+		name = "_" + name
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	if len(base)+len(ext) > MaxFileNameBytes {
+		if len(ext) >= MaxFileNameBytes {
+			// extension alone is too long, drop it
+			base, ext = base+ext, ""
+		}
+		base = TruncateUTF8(base, MaxFileNameBytes-len(ext))
+	}
+	if name = strings.TrimRight(base+ext, ". "); name == "" {
+		return "_"
+	}
+	return name
+}
+
+// TruncateUTF8 truncates s to at most n bytes without splitting a multi-byte rune.
+func TruncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
 // NextAvailablePath returns the first path under dir that does not exist, using fileName
 // and if it exists, trying base-2.ext, base-3.ext, ... (e.g. txt.txt -> txt-2.txt, txt-3.txt).
 func NextAvailablePath(dir, fileName string) string {
@@ -0,0 +1,107 @@
+package tool
+
+import "testing"
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain name", in: "photo.jpg", want: "photo.jpg"},
+		{name: "reserved name", in: "CON", want: "_CON"},
+		{name: "reserved name with extension", in: "nul.txt", want: "_nul.txt"},
+		{name: "reserved name with several extensions", in: "CON.tar.gz", want: "_CON.tar.gz"},
+		{name: "reserved name with backup extension", in: "nul.txt.bak", want: "_nul.txt.bak"},
+		{name: "reserved name with trailing dot", in: "CON.", want: "_CON"},
+		{name: "reserved name with trailing space", in: "aux ", want: "_aux"},
+		{name: "reserved name with space before extension", in: "com1 .log", want: "_com1 .log"},
+		{name: "reserved name as prefix only", in: "CONSOLE.txt", want: "CONSOLE.txt"},
+		{name: "trailing dots and spaces", in: "report.pdf. . ", want: "report.pdf"},
+		{name: "only dots", in: "...", want: "_"},
+		{name: "control characters", in: "a\x00b\x1fc.txt", want: "abc.txt"},
+		{name: "empty", in: "", want: "_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFileName(tt.in); got != tt.want {
+				t.Errorf("SanitizeFileName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFileNameTruncation(t *testing.T) {
+	previous := MaxFileNameBytes
+	MaxFileNameBytes = 10
+	t.Cleanup(func() { MaxFileNameBytes = previous })
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "keeps extension", in: "abcdefghijkl.txt", want: "abcdef.txt"},
+		{name: "trims dots and spaces left by truncation", in: "abcdefgh  xyz", want: "abcdefgh"},
+		{name: "does not split runes", in: "ééééé.txt", want: "ééé.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFileName(tt.in); got != tt.want {
+				t.Errorf("SanitizeFileName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRelativePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{name: "file", in: "photo.jpg"},
+		{name: "folder upload", in: "album/2024/photo.jpg"},
+		{name: "traversal", in: "../photo.jpg", wantErr: true},
+		{name: "traversal inside a folder", in: "album/../../photo.jpg", wantErr: true},
+		{name: "dots in a name", in: "album/..photo..jpg"},
+		{name: "backslash traversal", in: `..\..\evil.exe`, wantErr: true},
+		{name: "backslash separator", in: `album\photo.jpg`, wantErr: true},
+		{name: "drive letter with backslash", in: `C:\Windows\evil.dll`, wantErr: true},
+		{name: "drive letter with slash", in: "c:/Windows/evil.dll", wantErr: true},
+		{name: "drive relative", in: "C:evil.txt", wantErr: true},
+		{name: "UNC path", in: `\\server\share\evil.txt`, wantErr: true},
+		{name: "UNC path with slashes", in: "//server/share/evil.txt", wantErr: true},
+		{name: "absolute path", in: "/etc/passwd", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRelativePath(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRelativePath(%q) = %v, want error: %v", tt.in, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSanitizeRelativePath(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "folder upload", in: "album/photo.jpg", want: "album/photo.jpg"},
+		{name: "reserved segments", in: "CON/aux.txt/nul", want: "_CON/_aux.txt/_nul"},
+		{name: "NUL bytes", in: "alb\x00um/evil\x00.txt", want: "album/evil.txt"},
+		{name: "empty segments", in: "album//photo.jpg", want: "album/photo.jpg"},
+		{name: "dot segments are kept for the traversal guard", in: "../album/./photo.jpg", want: "../album/./photo.jpg"},
+		{name: "trailing dots on a folder", in: "album. /photo.jpg", want: "album/photo.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeRelativePath(tt.in); got != tt.want {
+				t.Errorf("SanitizeRelativePath(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,23 +4,24 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"strconv"
 
 	"github.com/moyoez/localsend-go/types"
 )
 
 // BuildRegisterURL builds the /register callback URL
 func BuildRegisterURL(targetAddr *net.UDPAddr, remote *types.VersionMessage) (string, error) {
-	return fmt.Sprintf("%s://%s:%d/api/localsend/v2/register", remote.Protocol, targetAddr.IP.String(), remote.Port), nil
+	return fmt.Sprintf("%s://%s/api/localsend/v2/register", remote.Protocol, hostPort(targetAddr.IP.String(), remote.Port)), nil
 }
 
 func BuildScanOnceRegisterUrl(protocol string, targetIp string, port int) string {
-	return fmt.Sprintf("%s://%s:%d/api/localsend/v2/register", protocol, targetIp, port)
+	return fmt.Sprintf("%s://%s/api/localsend/v2/register", protocol, hostPort(targetIp, port))
 }
 
 // BuildPrepareUploadURL builds the /prepare-upload URL.
 // If pin is not empty, add query parameter ?pin=xxx.
 func BuildPrepareUploadURL(targetAddr *net.UDPAddr, remote *types.VersionMessage, pin string) (string, error) {
-	url := fmt.Sprintf("%s://%s:%d/api/localsend/v2/prepare-upload", remote.Protocol, targetAddr.IP.String(), remote.Port)
+	url := fmt.Sprintf("%s://%s/api/localsend/v2/prepare-upload", remote.Protocol, hostPort(targetAddr.IP.String(), remote.Port))
 	if pin != "" {
 		url += fmt.Sprintf("?pin=%s", pin)
 	}
@@ -29,7 +30,7 @@ func BuildPrepareUploadURL(targetAddr *net.UDPAddr, remote *types.VersionMessage
 
 // BuildUploadURL builds the /upload URL with sessionId, fileId, and token query parameters.
 func BuildUploadURL(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId, fileId, token string) (string, error) {
-	baseURL := fmt.Sprintf("%s://%s:%d/api/localsend/v2/upload", remote.Protocol, targetAddr.IP.String(), remote.Port)
+	baseURL := fmt.Sprintf("%s://%s/api/localsend/v2/upload", remote.Protocol, hostPort(targetAddr.IP.String(), remote.Port))
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse base URL: %v", err)
@@ -39,19 +40,68 @@ func BuildUploadURL(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessi
 	return u.String(), nil
 }
 
-// BuildCancelURL builds the /cancel URL with sessionId query parameter.
-func BuildCancelURL(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId string) (string, error) {
-	baseURL := fmt.Sprintf("%s://%s:%d/api/localsend/v2/cancel", remote.Protocol, targetAddr.IP.String(), remote.Port)
+// BuildUploadChunkURL builds the /upload URL for one chunk of a chunked upload.
+func BuildUploadChunkURL(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId, fileId, token string, index, total int, chunkSize int64) (string, error) {
+	u, err := BuildUploadURL(targetAddr, remote, sessionId, fileId, token)
+	if err != nil {
+		return "", err
+	}
+	return u + fmt.Sprintf("&chunkIndex=%d&chunkTotal=%d&chunkSize=%d", index, total, chunkSize), nil
+}
+
+// BuildStorageInfoURL builds the /storage-info URL.
+func BuildStorageInfoURL(targetAddr *net.UDPAddr, remote *types.VersionMessage) string {
+	return fmt.Sprintf("%s://%s/api/localsend/v2/storage-info", remote.Protocol, hostPort(targetAddr.IP.String(), remote.Port))
+}
+
+// BuildEchoURL builds the /echo URL used for speed tests.
+func BuildEchoURL(targetAddr *net.UDPAddr, remote *types.VersionMessage) string {
+	return fmt.Sprintf("%s://%s/api/localsend/v2/echo", remote.Protocol, hostPort(targetAddr.IP.String(), remote.Port))
+}
+
+// BuildUploadChunksURL builds the /upload-chunks URL used to query already received chunks.
+func BuildUploadChunksURL(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId, fileId, token string) (string, error) {
+	baseURL := fmt.Sprintf("%s://%s/api/localsend/v2/upload-chunks", remote.Protocol, hostPort(targetAddr.IP.String(), remote.Port))
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse base URL: %v", err)
+	}
+	u.RawQuery = fmt.Sprintf("sessionId=%s&fileId=%s&token=%s", sessionId, fileId, token)
+	return u.String(), nil
+}
+
+// BuildUploadCompleteURL builds the /upload-complete URL with the SHA-256 of the whole file.
+func BuildUploadCompleteURL(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId, fileId, token, sha256 string) (string, error) {
+	baseURL := fmt.Sprintf("%s://%s/api/localsend/v2/upload-complete", remote.Protocol, hostPort(targetAddr.IP.String(), remote.Port))
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse base URL: %v", err)
+	}
+	u.RawQuery = fmt.Sprintf("sessionId=%s&fileId=%s&token=%s&sha256=%s", sessionId, fileId, token, sha256)
+	return u.String(), nil
+}
+
+// BuildCancelURL builds the /cancel URL with sessionId and, when not empty, reason query parameters.
+func BuildCancelURL(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId, reason string) (string, error) {
+	baseURL := fmt.Sprintf("%s://%s/api/localsend/v2/cancel", remote.Protocol, hostPort(targetAddr.IP.String(), remote.Port))
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse base URL: %v", err)
 	}
 	// add query parameters
 	u.RawQuery = fmt.Sprintf("sessionId=%s", sessionId)
+	if reason != "" {
+		u.RawQuery += "&reason=" + url.QueryEscape(reason)
+	}
 	return u.String(), nil
 }
 
 // BuildInfoURL builds the /info URL to get device information.
 func BuildInfoURL(protocol string, ip string, port int) string {
-	return fmt.Sprintf("%s://%s:%d/api/localsend/v2/info", protocol, ip, port)
+	return fmt.Sprintf("%s://%s/api/localsend/v2/info", protocol, hostPort(ip, port))
+}
+
+// hostPort joins host and port, bracketing IPv6 literals.
+func hostPort(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
 }
@@ -1,9 +1,68 @@
 package tool
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/moyoez/localsend-go/types"
 )
 
+// MaxDeviceNoteLength caps the advertised note so it fits comfortably in a multicast datagram.
+const MaxDeviceNoteLength = 64
+
+var deviceNote string
+
+// SetDeviceNote sets the free-text note advertised next to the alias. Longer notes are truncated.
+// Takes effect for version messages built afterwards.
+func SetDeviceNote(note string) {
+	note = strings.TrimSpace(note)
+	if runes := []rune(note); len(runes) > MaxDeviceNoteLength {
+		note = string(runes[:MaxDeviceNoteLength])
+	}
+	deviceNote = note
+}
+
+// GetDeviceNote returns the note set by SetDeviceNote.
+func GetDeviceNote() string {
+	return deviceNote
+}
+
+var (
+	// protocolVersion overrides the config version in version messages when not empty
+	protocolVersion string
+	// userAgent is sent with outgoing requests when not empty; otherwise Go's default is used
+	userAgent string
+)
+
+// SetProtocolVersion overrides the protocol version advertised in announces, /info and /register
+// (e.g. "2.1"), for interop testing or for peers that reject versions they do not know.
+// An empty version advertises the one from the config. Takes effect for version messages built afterwards.
+func SetProtocolVersion(version string) {
+	protocolVersion = strings.TrimSpace(version)
+}
+
+// GetProtocolVersion returns the version set by SetProtocolVersion, empty when the config version is used.
+func GetProtocolVersion() string {
+	return protocolVersion
+}
+
+// SetUserAgent sets the User-Agent header of outgoing requests. Empty keeps Go's default.
+func SetUserAgent(ua string) {
+	userAgent = strings.TrimSpace(ua)
+}
+
+// GetUserAgent returns the User-Agent set by SetUserAgent.
+func GetUserAgent() string {
+	return userAgent
+}
+
+// ApplyUserAgent sets the configured User-Agent on req, if any.
+func ApplyUserAgent(req *http.Request) {
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+}
+
 func BuildVersionMessages(appCfg *types.AppConfig, Flags types.Config) (*types.VersionMessage, *types.VersionMessageHTTP) {
 	if Flags.UseAlias != "" {
 		appCfg.Alias = Flags.UseAlias
@@ -16,27 +75,42 @@ func BuildVersionMessages(appCfg *types.AppConfig, Flags types.Config) (*types.V
 	if Flags.UseDownload {
 		appCfg.Download = true
 	}
+	if Flags.UseDeviceNote != "" {
+		appCfg.Note = Flags.UseDeviceNote
+	}
+	SetDeviceNote(appCfg.Note)
+	if Flags.UseProtocolVersion != "" {
+		SetProtocolVersion(Flags.UseProtocolVersion)
+	}
+	version := appCfg.Version
+	if v := GetProtocolVersion(); v != "" {
+		version = v
+	}
 
 	msg := &types.VersionMessage{
-		Alias:       appCfg.Alias,
-		Version:     appCfg.Version,
-		DeviceModel: appCfg.DeviceModel,
-		DeviceType:  appCfg.DeviceType,
-		Fingerprint: appCfg.Fingerprint,
-		Port:        appCfg.Port,
-		Protocol:    appCfg.Protocol,
-		Download:    appCfg.Download,
-		Announce:    true,
+		Alias:        appCfg.Alias,
+		Version:      version,
+		DeviceModel:  appCfg.DeviceModel,
+		DeviceType:   appCfg.DeviceType,
+		Fingerprint:  appCfg.Fingerprint,
+		Port:         appCfg.Port,
+		Protocol:     appCfg.Protocol,
+		Download:     appCfg.Download,
+		Announce:     true,
+		Capabilities: appCfg.Capabilities,
+		Note:         GetDeviceNote(),
 	}
 	httpMsg := &types.VersionMessageHTTP{
-		Alias:       appCfg.Alias,
-		Version:     appCfg.Version,
-		DeviceModel: appCfg.DeviceModel,
-		DeviceType:  appCfg.DeviceType,
-		Fingerprint: appCfg.Fingerprint,
-		Port:        appCfg.Port,
-		Protocol:    appCfg.Protocol,
-		Download:    appCfg.Download,
+		Alias:        appCfg.Alias,
+		Version:      version,
+		DeviceModel:  appCfg.DeviceModel,
+		DeviceType:   appCfg.DeviceType,
+		Fingerprint:  appCfg.Fingerprint,
+		Port:         appCfg.Port,
+		Protocol:     appCfg.Protocol,
+		Download:     appCfg.Download,
+		Capabilities: appCfg.Capabilities,
+		Note:         GetDeviceNote(),
 	}
 	return msg, httpMsg
 }
@@ -16,3 +16,31 @@ func GetRunPositionDir() string {
 	}
 	return filepath.Dir(exePath)
 }
+
+// dataRoot is the directory relative default paths (config, uploads, audit log) resolve against. Empty means CWD.
+var dataRoot string
+
+// SetDataRoot sets the data root directory and creates it (0700, it holds the TLS key in config) if missing.
+func SetDataRoot(path string) error {
+	if path == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(abs, 0o700); err != nil {
+		return err
+	}
+	dataRoot = abs
+	DefaultLogger.Infof("Using data directory: %s", abs)
+	return nil
+}
+
+// ResolveDataPath resolves a relative path against the data root. Absolute or empty paths, or no data root, are returned as is.
+func ResolveDataPath(path string) string {
+	if dataRoot == "" || path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dataRoot, path)
+}
@@ -0,0 +1,170 @@
+package tool
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+// ZIP compression levels accepted by ZipFoldersForUpload.
+const (
+	ZipLevelStore = "store" // no compression, the default: fastest, and media is already compressed
+	ZipLevelFast  = "fast"  // deflate with flate.BestSpeed
+	ZipLevelBest  = "best"  // deflate with flate.BestCompression, for text-heavy folders
+)
+
+// zipFlateLevel maps a ZipLevelXxx name to a flate level; store (or "") returns flate.NoCompression.
+func zipFlateLevel(level string) (int, error) {
+	switch level {
+	case "", ZipLevelStore:
+		return flate.NoCompression, nil
+	case ZipLevelFast:
+		return flate.BestSpeed, nil
+	case ZipLevelBest:
+		return flate.BestCompression, nil
+	}
+	return 0, fmt.Errorf("invalid zip level %q (use %s, %s or %s)", level, ZipLevelStore, ZipLevelFast, ZipLevelBest)
+}
+
+// compressedMIMEPrefixes are file types that are already compressed; deflating them again only costs time.
+var compressedMIMEPrefixes = []string{
+	"image/jpeg", "image/png", "image/gif", "image/webp", "image/heic", "image/heif", "image/avif",
+	"video/", "audio/mpeg", "audio/mp4", "audio/aac", "audio/ogg", "audio/opus", "audio/flac", "audio/webm",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-7z-compressed",
+	"application/x-rar-compressed", "application/vnd.rar", "application/x-bzip2", "application/x-xz",
+	"application/zstd", "application/java-archive", "application/vnd.android.package-archive",
+}
+
+// isCompressedMIME reports whether a file of fileType gains nothing from compression.
+func isCompressedMIME(fileType string) bool {
+	fileType = strings.ToLower(fileType)
+	for _, prefix := range compressedMIMEPrefixes {
+		if strings.HasPrefix(fileType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ZipFoldersForUpload packages the files ProcessFolderForUpload finds in folderPaths into a single ZIP
+// in the temp directory, keeping their "foldername/subfolder/file.txt" names. It returns the archive as
+// one FileInput (with SHA-256 when calculateSHA is true) and its path on disk; the caller removes the file
+// once it has been sent. level is one of ZipLevelStore (or ""), ZipLevelFast and ZipLevelBest; files
+// whose type is already compressed are always stored.
+func ZipFoldersForUpload(folderPaths []string, calculateSHA bool, level string) (*types.FileInput, string, error) {
+	if len(folderPaths) == 0 {
+		return nil, "", fmt.Errorf("no folders to zip")
+	}
+	flateLevel, err := zipFlateLevel(level)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tmp, err := os.CreateTemp("", "localsend-*.zip")
+	if err != nil {
+		return nil, "", fmt.Errorf("create temp zip: %w", err)
+	}
+	zipPath := tmp.Name()
+	if err := writeFoldersZip(tmp, folderPaths, flateLevel); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(zipPath)
+		return nil, "", err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(zipPath)
+		return nil, "", fmt.Errorf("close temp zip: %w", err)
+	}
+
+	_, size, _, sha256Hash, err := GetFileInfoFromPath(zipPath, calculateSHA, false)
+	if err != nil {
+		_ = os.Remove(zipPath)
+		return nil, "", err
+	}
+	zipName := "folders.zip"
+	if len(folderPaths) == 1 {
+		zipName = filepath.Base(folderPaths[0]) + ".zip"
+	}
+	return &types.FileInput{
+		ID:       GenerateFileID(zipPath),
+		FileName: zipName,
+		Size:     &size,
+		FileType: "application/zip",
+		SHA256:   sha256Hash,
+	}, zipPath, nil
+}
+
+// writeFoldersZip writes every file of folderPaths into w as a ZIP archive, deflating with flateLevel
+// (flate.NoCompression stores every file).
+func writeFoldersZip(w io.Writer, folderPaths []string, flateLevel int) error {
+	zw := zip.NewWriter(w)
+	zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, flateLevel)
+	})
+	for _, folderPath := range folderPaths {
+		fileInputMap, fileIdToPathMap, err := ProcessFolderForUpload(folderPath, false, false)
+		if err != nil {
+			return fmt.Errorf("failed to process folder %s: %v", folderPath, err)
+		}
+		// Stable entry order makes identical folders produce identical archives
+		fileIds := make([]string, 0, len(fileInputMap))
+		for fileId := range fileInputMap {
+			fileIds = append(fileIds, fileId)
+		}
+		sort.Slice(fileIds, func(i, j int) bool {
+			return fileInputMap[fileIds[i]].FileName < fileInputMap[fileIds[j]].FileName
+		})
+		for _, fileId := range fileIds {
+			fileInput := fileInputMap[fileId]
+			method := zip.Deflate
+			if flateLevel == flate.NoCompression || isCompressedMIME(fileInput.FileType) {
+				method = zip.Store
+			}
+			if err := addFileToZip(zw, fileInput.FileName, fileIdToPathMap[fileId], method); err != nil {
+				return err
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("finish zip: %w", err)
+	}
+	return nil
+}
+
+// addFileToZip copies the file at path into zw under name, compressed with method.
+func addFileToZip(zw *zip.Writer, name, path string, method uint16) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			DefaultLogger.Errorf("Failed to close file: %v", err)
+		}
+	}()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("zip header for %s: %w", path, err)
+	}
+	header.Name = name
+	header.Method = method
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("zip entry for %s: %w", path, err)
+	}
+	if _, err := io.Copy(entry, file); err != nil {
+		return fmt.Errorf("zip %s: %w", path, err)
+	}
+	return nil
+}
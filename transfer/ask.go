@@ -2,10 +2,13 @@ package transfer
 
 import (
 	"bytes"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"strings"
 
 	"github.com/bytedance/sonic"
 	"github.com/moyoez/localsend-go/tool"
@@ -25,29 +28,33 @@ const (
 // ReadyToUploadTo sends metadata to the receiver to prepare for upload.
 // The receiver will decide whether to accept, partially accept, or reject the request.
 // If a PIN is required, it should be provided in the pin parameter.
+// If the request fails in a way that suggests a stale protocol, it is retried with the other protocol
+// and remote.Protocol is updated on success so callers can refresh their cached device.
 func ReadyToUploadTo(targetAddr *net.UDPAddr, remote *types.VersionMessage, request *types.PrepareUploadRequest, pin string) (*types.PrepareUploadResponse, error) {
 	if targetAddr == nil || remote == nil || request == nil {
 		return nil, fmt.Errorf("invalid parameters: targetAddr, remote, and request must not be nil")
 	}
 
-	url, err := tool.BuildPrepareUploadURL(targetAddr, remote, pin)
-	if err != nil {
-		return nil, fmt.Errorf("failed to build prepare-upload URL: %v", err)
-	}
-
 	payload, err := sonic.Marshal(request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal prepare-upload request: %v", err)
 	}
 
-	req, err := tool.NewHTTPReqWithApplication(http.NewRequest("POST", url, bytes.NewReader(payload)))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create prepare-upload request: %v", err)
+	url, resp, err := sendPrepareUpload(targetAddr, remote, pin, payload)
+	if err != nil && isProtocolMismatch(err) {
+		// The cached protocol may be stale (device switched http<->https): retry with the other one
+		alt := *remote
+		alt.Protocol = otherProtocol(remote.Protocol)
+		tool.DefaultLogger.Warnf("Prepare-upload via %s failed (%v), retrying with %s", remote.Protocol, err, alt.Protocol)
+		firstErr := err
+		if url, resp, err = sendPrepareUpload(targetAddr, &alt, pin, payload); err == nil {
+			remote.Protocol = alt.Protocol
+		} else {
+			err = fmt.Errorf("via %s: %w; retry via %s: %w", remote.Protocol, firstErr, alt.Protocol, err)
+		}
 	}
-	client := tool.GetHttpClient()
-	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send prepare-upload request: %v", err)
+		return nil, err
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -137,7 +144,7 @@ func FetchDeviceInfo(ip string, port int) (*types.CallbackLegacyVersionMessageHT
 			continue
 		}
 
-		client := tool.GetHttpClient()
+		client := controlClient()
 		resp, err := client.Do(req)
 		if err != nil {
 			lastErr = fmt.Errorf("failed to send info request to %s: %v", url, err)
@@ -172,3 +179,38 @@ func FetchDeviceInfo(ip string, port int) (*types.CallbackLegacyVersionMessageHT
 
 	return nil, "", fmt.Errorf("failed to fetch device info from %s:%d: %v", ip, port, lastErr)
 }
+
+// sendPrepareUpload POSTs the prepare-upload payload using remote.Protocol and returns the URL used.
+func sendPrepareUpload(targetAddr *net.UDPAddr, remote *types.VersionMessage, pin string, payload []byte) (string, *http.Response, error) {
+	url, err := tool.BuildPrepareUploadURL(targetAddr, remote, pin)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to build prepare-upload URL: %v", err)
+	}
+	req, err := tool.NewHTTPReqWithApplication(http.NewRequest("POST", url, bytes.NewReader(payload)))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create prepare-upload request: %v", err)
+	}
+	resp, err := controlClient().Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to send prepare-upload request: %w", err)
+	}
+	return url, resp, nil
+}
+
+// isProtocolMismatch reports whether err comes from talking https to an http server or vice versa:
+// a TLS handshake that got no TLS record, or an HTTP response line that is not HTTP (e.g. a TLS alert).
+// Other failures such as a bare EOF or a refused connection are not retried with the other protocol.
+func isProtocolMismatch(err error) bool {
+	var recordErr tls.RecordHeaderError
+	return errors.As(err, &recordErr) ||
+		errors.Is(err, http.ErrSchemeMismatch) ||
+		strings.Contains(err.Error(), "malformed HTTP response")
+}
+
+// otherProtocol returns "http" for "https" and vice versa.
+func otherProtocol(protocol string) string {
+	if protocol == "http" {
+		return "https"
+	}
+	return "http"
+}
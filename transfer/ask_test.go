@@ -0,0 +1,136 @@
+package transfer
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+func TestIsProtocolMismatch(t *testing.T) {
+	wrap := func(err error) error {
+		return fmt.Errorf("failed to send prepare-upload request: %w", &url.Error{Op: "Post", URL: "https://127.0.0.1:53317", Err: err})
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "https to http server", err: wrap(http.ErrSchemeMismatch), want: true},
+		{name: "no TLS record", err: wrap(tls.RecordHeaderError{Msg: "tls: first record does not look like a TLS handshake"}), want: true},
+		{name: "http to https server", err: wrap(errors.New(`malformed HTTP response "\x15\x03\x01\x00\x02\x02"`)), want: true},
+		{name: "bare EOF", err: wrap(io.EOF)},
+		{name: "unexpected EOF", err: wrap(io.ErrUnexpectedEOF)},
+		{name: "connection refused", err: wrap(syscall.ECONNREFUSED)},
+		{name: "certificate error mentioning tls", err: wrap(errors.New("tls: failed to verify certificate"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isProtocolMismatch(tt.err); got != tt.want {
+				t.Errorf("isProtocolMismatch(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func targetOf(t *testing.T, address string) (*net.UDPAddr, int) {
+	t.Helper()
+	tcpAddr, err := net.ResolveTCPAddr("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &net.UDPAddr{IP: tcpAddr.IP, Port: tcpAddr.Port}, tcpAddr.Port
+}
+
+func TestReadyToUploadToProtocolFallback(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"sessionId":"fallback","files":{"a":"token"}}`))
+	}))
+	t.Cleanup(receiver.Close)
+	target, port := targetOf(t, receiver.Listener.Addr().String())
+
+	remote := &types.VersionMessage{Alias: "Stale Receiver", Port: port, Protocol: "https"}
+	request := &types.PrepareUploadRequest{Files: map[string]types.FileInfo{"a": {ID: "a", FileName: "a.txt", Size: 1}}}
+	response, err := ReadyToUploadTo(target, remote, request, "")
+	if err != nil {
+		t.Fatalf("prepare-upload with a stale protocol: %v", err)
+	}
+	if response.SessionId != "fallback" || remote.Protocol != "http" {
+		t.Errorf("session %q via %s, want session fallback via http", response.SessionId, remote.Protocol)
+	}
+}
+
+func TestReadyToUploadToFallbackFailureKeepsBothErrors(t *testing.T) {
+	// Answers every request with a TLS alert, which neither protocol can talk to
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go answerWithTLSAlert(conn)
+		}
+	}()
+	target, port := targetOf(t, listener.Addr().String())
+
+	remote := &types.VersionMessage{Alias: "Broken Receiver", Port: port, Protocol: "http"}
+	request := &types.PrepareUploadRequest{Files: map[string]types.FileInfo{"a": {ID: "a", FileName: "a.txt", Size: 1}}}
+	_, err = ReadyToUploadTo(target, remote, request, "")
+	if err == nil {
+		t.Fatal("prepare-upload to a broken receiver succeeded")
+	}
+	if !strings.Contains(err.Error(), "malformed HTTP response") || !strings.Contains(err.Error(), "retry via https") {
+		t.Errorf("error %q does not report both attempts", err)
+	}
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Errorf("error %q does not wrap the request errors", err)
+	}
+	if remote.Protocol != "http" {
+		t.Errorf("protocol changed to %s after a failed fallback", remote.Protocol)
+	}
+}
+
+// answerWithTLSAlert reads the whole first message of conn, an HTTP request or a TLS ClientHello,
+// before writing a TLS alert, so the client always gets the answer to a request it finished sending.
+func answerWithTLSAlert(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	if err != nil {
+		return
+	}
+	if first[0] == 0x16 {
+		// TLS handshake record: 5-byte header whose last two bytes are the payload length
+		header := make([]byte, 5)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return
+		}
+		if _, err := io.CopyN(io.Discard, reader, int64(binary.BigEndian.Uint16(header[3:]))); err != nil {
+			return
+		}
+	} else {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+	}
+	_, _ = conn.Write([]byte("\x15\x03\x01\x00\x02\x02\x0a"))
+}
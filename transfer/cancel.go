@@ -4,35 +4,68 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/moyoez/localsend-go/tool"
 	"github.com/moyoez/localsend-go/types"
 )
 
+const (
+	// cancelAttempts is how many times CancelSession tries to reach the remote
+	cancelAttempts = 3
+	// cancelBackoff is the wait before the second attempt, doubled for each further one
+	cancelBackoff = 200 * time.Millisecond
+)
+
 // CancelSession cancels a transfer session.
-// Uses sessionId from /send-request or /prepare-upload response.
-func CancelSession(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId string) error {
+// Uses sessionId from /send-request or /prepare-upload response. reason (e.g. types.CancelReasonUserCancelled)
+// is passed on to the receiver; it may be empty.
+// Network errors and 5xx responses are retried with a short backoff; a 4xx answer is final.
+// The result reports whether the remote acknowledged the cancel; err holds the last failure.
+func CancelSession(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId, reason string) (types.CancelResult, error) {
+	var result types.CancelResult
 	if targetAddr == nil || remote == nil {
-		return fmt.Errorf("invalid parameters: targetAddr and remote must not be nil")
+		return result, fmt.Errorf("invalid parameters: targetAddr and remote must not be nil")
 	}
 	if sessionId == "" {
-		return fmt.Errorf("invalid parameters: sessionId must not be empty")
+		return result, fmt.Errorf("invalid parameters: sessionId must not be empty")
 	}
 
-	url, err := tool.BuildCancelURL(targetAddr, remote, sessionId)
+	url, err := tool.BuildCancelURL(targetAddr, remote, sessionId, reason)
 	if err != nil {
-		return fmt.Errorf("failed to build cancel URL: %v", err)
+		return result, fmt.Errorf("failed to build cancel URL: %v", err)
+	}
+
+	backoff := cancelBackoff
+	for {
+		result.Attempts++
+		retry, err := sendCancel(url)
+		if err == nil {
+			result.Acknowledged = true
+			tool.DestorySession(sessionId)
+			tool.DefaultLogger.Infof("Cancel request sent successfully to %s", url)
+			return result, nil
+		}
+		if !retry || result.Attempts >= cancelAttempts {
+			return result, err
+		}
+		tool.DefaultLogger.Debugf("Cancel request to %s failed (attempt %d/%d): %v, retrying in %s", url, result.Attempts, cancelAttempts, err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
 	}
+}
 
+// sendCancel posts one cancel request. retry reports whether the failure may be transient.
+func sendCancel(url string) (retry bool, err error) {
 	req, err := tool.NewHTTPReqWithApplication(http.NewRequest("POST", url, nil))
 	if err != nil {
-		return fmt.Errorf("failed to create cancel request: %v", err)
+		return false, fmt.Errorf("failed to create cancel request: %v", err)
 	}
 
-	client := tool.GetHttpClient()
+	client := controlClient()
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send cancel request: %v", err)
+		return true, fmt.Errorf("failed to send cancel request: %v", err)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -42,13 +75,10 @@ func CancelSession(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessio
 
 	// check status code
 	if resp.StatusCode == http.StatusBadRequest {
-		return fmt.Errorf("missing parameters")
+		return false, fmt.Errorf("missing parameters")
 	}
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return fmt.Errorf("cancel request failed: %s", resp.Status)
+		return resp.StatusCode >= http.StatusInternalServerError, fmt.Errorf("cancel request failed: %s", resp.Status)
 	}
-	tool.DestorySession(sessionId)
-
-	tool.DefaultLogger.Infof("Cancel request sent successfully to %s", url)
-	return nil
+	return false, nil
 }
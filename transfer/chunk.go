@@ -0,0 +1,201 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// maxParallelParts caps SetParallelParts.
+const maxParallelParts = 16
+
+// parallelParts is how many chunks UploadFileInChunks keeps in flight; 1 sends them one by one.
+var parallelParts atomic.Int32
+
+func init() {
+	parallelParts.Store(1)
+}
+
+// SetParallelParts sets how many chunks of a chunked upload are sent at once, each on its own request
+// (and connection). More parts help fill high-latency links; values are clamped to 1-16.
+func SetParallelParts(n int) {
+	parallelParts.Store(int32(min(max(n, 1), maxParallelParts)))
+}
+
+// ParallelParts returns how many chunks of a chunked upload are sent at once.
+func ParallelParts() int {
+	return int(parallelParts.Load())
+}
+
+// UploadFileInChunks sends a file as chunks of chunkSize bytes and asks the receiver to assemble them.
+// Up to ParallelParts chunks are in flight at a time, so memory use is bounded by the transport, not by the file size;
+// the receiver writes each chunk at its offset, so they may arrive in any order.
+// Chunks the receiver already reports as received (from an earlier interrupted attempt) are skipped.
+func UploadFileInChunks(ctx context.Context, targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId, fileId, token string, file io.ReaderAt, size, chunkSize int64) error {
+	if targetAddr == nil || remote == nil {
+		return fmt.Errorf("invalid parameters: targetAddr and remote must not be nil")
+	}
+	if sessionId == "" || fileId == "" || token == "" {
+		return fmt.Errorf("invalid parameters: sessionId, fileId, and token must not be empty")
+	}
+	if chunkSize <= 0 || size <= 0 {
+		return fmt.Errorf("invalid parameters: size and chunkSize must be positive")
+	}
+	total := int((size + chunkSize - 1) / chunkSize)
+
+	received := make(map[int]bool)
+	if status, err := FetchUploadChunks(ctx, targetAddr, remote, sessionId, fileId, token); err != nil {
+		tool.DefaultLogger.Debugf("[Chunk] No resume info for fileId=%s, sending all chunks: %v", fileId, err)
+	} else if status.Total == total {
+		for _, index := range status.Received {
+			received[index] = true
+		}
+	}
+
+	sendChunk := func(ctx context.Context, index int, body io.Reader) error {
+		offset := int64(index) * chunkSize
+		url, err := tool.BuildUploadChunkURL(targetAddr, remote, sessionId, fileId, token, index, total, chunkSize)
+		if err != nil {
+			return fmt.Errorf("failed to build upload URL: %v", err)
+		}
+		header := http.Header{}
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+min(chunkSize, size-offset)-1, size))
+		if err := postUpload(ctx, sessionId, url, body, header); err != nil {
+			return fmt.Errorf("chunk %d/%d: %w", index+1, total, err)
+		}
+		return nil
+	}
+
+	hasher := sha256.New()
+	if parts := ParallelParts(); parts > 1 && total-len(received) > 1 {
+		var pending []int
+		for index := range total {
+			if !received[index] {
+				pending = append(pending, index)
+			}
+		}
+		if err := sendChunksParallel(ctx, pending, parts, func(ctx context.Context, index int) error {
+			offset := int64(index) * chunkSize
+			return sendChunk(ctx, index, io.NewSectionReader(file, offset, min(chunkSize, size-offset)))
+		}); err != nil {
+			return err
+		}
+		// Chunks went out of order, so hash the file in a separate pass
+		if _, err := io.Copy(hasher, io.NewSectionReader(file, 0, size)); err != nil {
+			return fmt.Errorf("failed to hash file: %v", err)
+		}
+	} else {
+		for index := range total {
+			offset := int64(index) * chunkSize
+			section := io.NewSectionReader(file, offset, min(chunkSize, size-offset))
+			if received[index] {
+				// Still hash skipped chunks: the completion check covers the whole file
+				if _, err := io.Copy(hasher, section); err != nil {
+					return fmt.Errorf("failed to read chunk %d: %v", index, err)
+				}
+				continue
+			}
+			if err := sendChunk(ctx, index, io.TeeReader(section, hasher)); err != nil {
+				return err
+			}
+		}
+	}
+	if len(received) > 0 {
+		tool.DefaultLogger.Infof("[Chunk] Resumed fileId=%s, skipped %d of %d chunks", fileId, len(received), total)
+	}
+
+	url, err := tool.BuildUploadCompleteURL(targetAddr, remote, sessionId, fileId, token, hex.EncodeToString(hasher.Sum(nil)))
+	if err != nil {
+		return fmt.Errorf("failed to build upload-complete URL: %v", err)
+	}
+	return postUpload(ctx, sessionId, url, http.NoBody, nil)
+}
+
+// sendChunksParallel runs send for every index with at most parts calls at once.
+// The first error cancels the remaining chunks and is returned.
+func sendChunksParallel(ctx context.Context, indexes []int, parts int, send func(ctx context.Context, index int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	next := make(chan int)
+	for range min(parts, len(indexes)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range next {
+				if err := send(ctx, index); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for _, index := range indexes {
+		select {
+		case next <- index:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	if firstErr == nil && ctx.Err() != nil {
+		// the caller's context was cancelled
+		return fmt.Errorf("upload cancelled: %w", ctx.Err())
+	}
+	return firstErr
+}
+
+// FetchUploadChunks asks the receiver which chunks of a file it already has.
+func FetchUploadChunks(ctx context.Context, targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId, fileId, token string) (*types.UploadChunkStatus, error) {
+	url, err := tool.BuildUploadChunksURL(targetAddr, remote, sessionId, fileId, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build upload-chunks URL: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload-chunks request: %v", err)
+	}
+	tool.ApplyUserAgent(req)
+
+	resp, err := controlClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send upload-chunks request: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close response body: %v", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upload-chunks request failed: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload-chunks response: %v", err)
+	}
+	var status types.UploadChunkStatus
+	if err := sonic.Unmarshal(body, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse upload-chunks response: %v", err)
+	}
+	return &status, nil
+}
@@ -0,0 +1,43 @@
+package transfer
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/moyoez/localsend-go/tool"
+)
+
+var (
+	httpClientOverrideMu sync.RWMutex
+	httpClientOverride   *http.Client
+)
+
+// SetHTTPClient replaces the HTTP client used for every request made by this package.
+// Pass nil to go back to the shared clients from tool.
+func SetHTTPClient(client *http.Client) {
+	httpClientOverrideMu.Lock()
+	defer httpClientOverrideMu.Unlock()
+	httpClientOverride = client
+}
+
+func getOverrideClient() *http.Client {
+	httpClientOverrideMu.RLock()
+	defer httpClientOverrideMu.RUnlock()
+	return httpClientOverride
+}
+
+// controlClient returns the client for short control requests (prepare-upload, cancel, info).
+func controlClient() *http.Client {
+	if client := getOverrideClient(); client != nil {
+		return client
+	}
+	return tool.GetHttpClient()
+}
+
+// uploadClient returns the client for file data uploads.
+func uploadClient() *http.Client {
+	if client := getOverrideClient(); client != nil {
+		return client
+	}
+	return tool.GetTransferHttpClient()
+}
@@ -0,0 +1,65 @@
+package transfer
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// MeasureThroughput streams sizeBytes of random data to the receiver's /echo and reports the rate.
+// Only receivers advertising the echo capability serve it; others answer 404.
+func MeasureThroughput(targetAddr *net.UDPAddr, remote *types.VersionMessage, sizeBytes int64) (*types.ThroughputResult, error) {
+	if targetAddr == nil || remote == nil {
+		return nil, fmt.Errorf("invalid parameters: targetAddr and remote must not be nil")
+	}
+	if sizeBytes <= 0 || sizeBytes > types.EchoMaxBytes {
+		return nil, fmt.Errorf("invalid parameters: size must be between 1 and %d bytes", int64(types.EchoMaxBytes))
+	}
+	req, err := http.NewRequest("POST", tool.BuildEchoURL(targetAddr, remote), io.LimitReader(rand.Reader, sizeBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create echo request: %v", err)
+	}
+	req.ContentLength = sizeBytes
+	req.Header.Set("Content-Type", "application/octet-stream")
+	tool.ApplyUserAgent(req)
+
+	start := time.Now()
+	resp, err := uploadClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send echo request: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close response body: %v", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("echo request failed: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read echo response: %v", err)
+	}
+	duration := time.Since(start)
+
+	var echo types.EchoResult
+	if err := sonic.Unmarshal(body, &echo); err != nil {
+		return nil, fmt.Errorf("failed to parse echo response: %v", err)
+	}
+	if echo.BytesReceived != sizeBytes {
+		return nil, fmt.Errorf("receiver got %d of %d bytes", echo.BytesReceived, sizeBytes)
+	}
+	return &types.ThroughputResult{
+		BytesSent:         sizeBytes,
+		DurationMs:        duration.Milliseconds(),
+		MBps:              float64(sizeBytes) / 1e6 / duration.Seconds(),
+		ReceiverElapsedMs: echo.ElapsedMs,
+	}, nil
+}
@@ -0,0 +1,95 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// pipeListener hands out the server ends of in-memory net.Pipe connections.
+type pipeListener struct {
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{
+		conns:  make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+func (l *pipeListener) dial(ctx context.Context, _, _ string) (net.Conn, error) {
+	clientConn, serverConn := net.Pipe()
+	select {
+	case l.conns <- serverConn:
+		return clientConn, nil
+	case <-l.closed:
+		clientConn.Close()
+		serverConn.Close()
+		return nil, errors.New("loopback receiver closed")
+	case <-ctx.Done():
+		clientConn.Close()
+		serverConn.Close()
+		return nil, ctx.Err()
+	}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "loopback" }
+
+// NewLoopbackClient serves handler over in-memory connections and returns a client wired to it.
+// Every request the client makes reaches handler regardless of host, so no real socket is opened.
+// Only plain http works; the returned func stops the receiver.
+func NewLoopbackClient(handler http.Handler) (*http.Client, func()) {
+	listener := newPipeListener()
+	server := &http.Server{Handler: handler}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext:       listener.dial,
+			DisableKeepAlives: true,
+		},
+	}
+	return client, func() {
+		_ = server.Close()
+	}
+}
+
+// UseLoopbackReceiver points this package at handler through NewLoopbackClient, so end-to-end
+// prepare-upload and upload flows can run against an in-process receiver (e.g. the api router).
+// Call the returned func to restore the default clients.
+func UseLoopbackReceiver(handler http.Handler) func() {
+	client, stop := NewLoopbackClient(handler)
+	SetHTTPClient(client)
+	return func() {
+		SetHTTPClient(nil)
+		stop()
+	}
+}
@@ -0,0 +1,121 @@
+package transfer
+
+import (
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/moyoez/localsend-go/types"
+)
+
+const (
+	// speedWindow is how far back samples are kept when computing the current speed.
+	speedWindow = 5 * time.Second
+	// sampleInterval limits how often a sample is recorded while bytes flow.
+	sampleInterval = 100 * time.Millisecond
+)
+
+var (
+	transferMetersMu sync.Mutex
+	transferMeters   = make(map[string]*transferMeter)
+)
+
+type speedSample struct {
+	at    time.Time
+	bytes int64
+}
+
+// transferMeter counts the bytes sent for one session and keeps recent samples for speed.
+type transferMeter struct {
+	mu      sync.Mutex
+	total   int64
+	sent    int64
+	samples []speedSample
+}
+
+func (m *transferMeter) add(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent += n
+	now := time.Now()
+	if len(m.samples) > 0 && now.Sub(m.samples[len(m.samples)-1].at) < sampleInterval {
+		m.samples[len(m.samples)-1].bytes = m.sent
+		return
+	}
+	m.samples = append(m.samples, speedSample{at: now, bytes: m.sent})
+	// keep one sample older than the window as the baseline
+	drop := 0
+	for drop < len(m.samples)-1 && now.Sub(m.samples[drop+1].at) >= speedWindow {
+		drop++
+	}
+	m.samples = m.samples[drop:]
+}
+
+func (m *transferMeter) snapshot(sessionId string) types.TransferStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := types.TransferStats{
+		SessionId:  sessionId,
+		BytesSent:  m.sent,
+		TotalBytes: m.total,
+		EtaSeconds: -1,
+	}
+	if len(m.samples) > 0 {
+		oldest := m.samples[0]
+		if elapsed := time.Since(oldest.at).Seconds(); elapsed > 0 && m.sent > oldest.bytes {
+			stats.BytesPerSecond = float64(m.sent-oldest.bytes) / elapsed
+		}
+	}
+	switch {
+	case m.total > 0 && m.sent >= m.total:
+		stats.EtaSeconds = 0
+	case m.total > 0 && stats.BytesPerSecond > 0:
+		stats.EtaSeconds = int64(math.Ceil(float64(m.total-m.sent) / stats.BytesPerSecond))
+	}
+	return stats
+}
+
+// countingBody reports every byte read by the HTTP transport to a meter.
+type countingBody struct {
+	io.ReadCloser
+	meter *transferMeter
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.meter.add(int64(n))
+	}
+	return n, err
+}
+
+// StartTransferStats begins tracking throughput for a sending session.
+// totalBytes is the expected payload size, used for the ETA; pass 0 if unknown.
+func StartTransferStats(sessionId string, totalBytes int64) {
+	transferMetersMu.Lock()
+	defer transferMetersMu.Unlock()
+	transferMeters[sessionId] = &transferMeter{total: totalBytes}
+}
+
+// EndTransferStats stops tracking a session.
+func EndTransferStats(sessionId string) {
+	transferMetersMu.Lock()
+	defer transferMetersMu.Unlock()
+	delete(transferMeters, sessionId)
+}
+
+// GetTransferStats returns the current speed and ETA of a session started with StartTransferStats.
+func GetTransferStats(sessionId string) (types.TransferStats, bool) {
+	meter := lookupTransferMeter(sessionId)
+	if meter == nil {
+		return types.TransferStats{}, false
+	}
+	return meter.snapshot(sessionId), true
+}
+
+func lookupTransferMeter(sessionId string) *transferMeter {
+	transferMetersMu.Lock()
+	defer transferMetersMu.Unlock()
+	return transferMeters[sessionId]
+}
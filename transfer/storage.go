@@ -0,0 +1,46 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/bytedance/sonic"
+	"github.com/moyoez/localsend-go/tool"
+	"github.com/moyoez/localsend-go/types"
+)
+
+// FetchStorageInfo asks the receiver how much space is left in its upload folder.
+// Only receivers advertising the storageInfo capability serve it; others answer 404.
+func FetchStorageInfo(targetAddr *net.UDPAddr, remote *types.VersionMessage) (*types.StorageInfo, error) {
+	if targetAddr == nil || remote == nil {
+		return nil, fmt.Errorf("invalid parameters: targetAddr and remote must not be nil")
+	}
+	req, err := tool.NewHTTPReqWithApplication(http.NewRequest("GET", tool.BuildStorageInfoURL(targetAddr, remote), nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage-info request: %v", err)
+	}
+	resp, err := controlClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send storage-info request: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			tool.DefaultLogger.Errorf("Failed to close response body: %v", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("storage-info request failed: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage-info response: %v", err)
+	}
+	var info types.StorageInfo
+	if err := sonic.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse storage-info response: %v", err)
+	}
+	return &info, nil
+}
@@ -6,11 +6,20 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"time"
 
+	"github.com/bytedance/sonic"
 	"github.com/moyoez/localsend-go/tool"
 	"github.com/moyoez/localsend-go/types"
 )
 
+// SetClientTimeouts tunes the HTTP client used for uploads, independently of the scan client.
+// dial bounds connection setup, responseHeader the wait for the receiver's reply once the body is sent,
+// and total the whole request; 0 means no limit. By default only the dial is bounded.
+func SetClientTimeouts(dial, responseHeader, total time.Duration) {
+	tool.SetTransferClientTimeouts(dial, responseHeader, total)
+}
+
 // UploadFile sends file data to the receiver.
 // Uses sessionId, fileId, and token from /prepare-upload response.
 func UploadFile(targetAddr *net.UDPAddr, remote *types.VersionMessage, sessionId, fileId, token string, data io.Reader) error {
@@ -41,15 +50,29 @@ func UploadFileWithContext(ctx context.Context, targetAddr *net.UDPAddr, remote
 	if err != nil {
 		return fmt.Errorf("failed to build upload URL: %v", err)
 	}
+	return postUpload(ctx, sessionId, url, data, nil)
+}
 
+// postUpload POSTs data to an /upload style URL and maps receiver status codes to errors.
+// Bytes sent are counted towards the session's transfer stats, if tracked. header may add request headers.
+func postUpload(ctx context.Context, sessionId, url string, data io.Reader, header http.Header) error {
 	// Create request with context for cancellation support
 	req, err := http.NewRequestWithContext(ctx, "POST", url, data)
 	if err != nil {
 		return fmt.Errorf("failed to create upload request: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/octet-stream")
+	tool.ApplyUserAgent(req)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if meter := lookupTransferMeter(sessionId); meter != nil && req.Body != nil && req.Body != http.NoBody {
+		req.Body = &countingBody{ReadCloser: req.Body, meter: meter}
+	}
 
-	client := tool.GetHttpClient()
+	// Keep the idle watchdog from shutting down while the body is still being sent
+	defer tool.BeginTransfer()()
+	client := uploadClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		// Check if it was cancelled
@@ -72,6 +95,13 @@ func UploadFileWithContext(ctx context.Context, targetAddr *net.UDPAddr, remote
 		return fmt.Errorf("invalid token or IP address")
 	case http.StatusConflict:
 		return fmt.Errorf("blocked by another session")
+	case http.StatusUnprocessableEntity:
+		// size/hash mismatch; the body carries the expected and actual values
+		var integrityErr types.IntegrityError
+		if body, err := io.ReadAll(resp.Body); err == nil && sonic.Unmarshal(body, &integrityErr) == nil && integrityErr.Kind != "" {
+			return fmt.Errorf("receiver rejected file: %w", &integrityErr)
+		}
+		return fmt.Errorf("receiver rejected file: %s", resp.Status)
 	case http.StatusInternalServerError:
 		return fmt.Errorf("unknown receiver error")
 	default:
@@ -0,0 +1,23 @@
+package types
+
+// Audit event directions and results.
+const (
+	AuditDirectionReceive = "receive"
+	AuditDirectionSend    = "send"
+	AuditResultSuccess    = "success"
+	AuditResultFailed     = "failed"
+)
+
+// AuditEvent is a single transfer record appended as one JSON line to the audit log.
+type AuditEvent struct {
+	Timestamp       string `json:"timestamp"`
+	SessionId       string `json:"sessionId"`
+	Direction       string `json:"direction"` // AuditDirectionReceive | AuditDirectionSend
+	PeerAlias       string `json:"peerAlias,omitempty"`
+	PeerFingerprint string `json:"peerFingerprint,omitempty"`
+	FileId          string `json:"fileId,omitempty"`
+	FileName        string `json:"fileName,omitempty"`
+	Size            int64  `json:"size"`
+	Result          string `json:"result"` // AuditResultSuccess | AuditResultFailed
+	Error           string `json:"error,omitempty"`
+}
@@ -13,8 +13,16 @@ type AppConfig struct {
 	Announce              bool                  `yaml:"announce"`
 	CertPEM               string                `yaml:"certPEM,omitempty"`
 	KeyPEM                string                `yaml:"keyPEM,omitempty"`
+	CertPath              string                `yaml:"certPath,omitempty"` // import an existing cert (PEM file) to keep its fingerprint
+	KeyPath               string                `yaml:"keyPath,omitempty"`  // private key (PEM file) paired with certPath
 	AutoSaveFromFavorites bool                  `yaml:"autoSaveFromFavorites,omitempty"`
 	FavoriteDevices       []FavoriteDeviceEntry `yaml:"favoriteDevices,omitempty"`
+	BlockedFingerprints   []string              `yaml:"blockedFingerprints,omitempty"` // devices whose requests are rejected
+	Capabilities          map[string]bool       `yaml:"capabilities,omitempty"` // optional feature flags advertised to peers
+	Note                  string                `yaml:"note,omitempty"`         // optional free-text label advertised to peers
+	Pin                   *string               `yaml:"pin,omitempty"`          // receive PIN, used when -usePin is not given; hot-reloaded
+	AutoSave              *bool                 `yaml:"autoSave,omitempty"`     // used when -useAutoSave is not given; hot-reloaded
+	UploadFolder          string                `yaml:"uploadFolder,omitempty"` // used when -useDefaultUploadFolder is not given; hot-reloaded
 }
 
 // ProgramConfig holds runtime program configuration (pin, auto-save, etc.)
@@ -42,4 +50,52 @@ type Config struct {
 	UseDownload            bool   // if true, enable download API (prepare-download, download, download page)
 	UseWebOutPath          string // path to Next.js static export output (default: web/out)
 	DoNotMakeSessionFolder bool   // if true, do not make any session folder, if meet same files
+	UseImageServeRoots     string // comma-separated base directories get-image may serve from (default: Steam userdata)
+	UseAuditLog            string // path of the transfer audit log (JSON lines); empty disables it
+	UseMaxUploadFiles      int    // max files accepted per prepare-upload request, 0 means no limit
+	UseMaxFileNameLength   int    // max bytes per received file name segment, default 255
+	SkipICMPProbe          bool   // if true, HTTP scan uses a TCP connect probe instead of ICMP (for networks that drop ping)
+	UseDataDir             string // root directory for config, uploads and audit log when given as relative paths
+	UseSessionTTL          int    // idle lifetime of upload sessions in seconds, default 3600
+	UseAutoAcceptSubnets   string // comma-separated CIDRs whose senders are auto-accepted without confirmation
+	UseFavoriteProbeInterval int  // seconds between probes of undiscovered favorites, 0 disables
+	UseSlowRequestThreshold int   // seconds after which an API request is logged as slow, 0 disables
+	UseGeneratePreviews    bool   // if true, generate thumbnail previews for image files in prepare-upload
+	UsePreviewMaxDimension int    // longest side of generated previews in pixels, default 40
+	UseIdleShutdown        int    // seconds without transfer activity before the app exits, 0 disables
+	UseDeviceNote          string // free-text note advertised to peers, overrides config note
+	UseMulticastTTL        int    // IP TTL of outgoing multicast announces
+	SkipMulticastLoopback  bool   // if true, outgoing multicast does not loop back to this host
+	SkipConfigWatch        bool   // if true, do not hot-reload the config file
+	UseSavePathTemplate    string // save path template for received files, empty keeps the session folder layout
+	UseReceivedFileMode    string // octal permission of received files, default 0644
+	UseReceivedDirMode     string // octal permission of directories created for received files, default 0755
+	UseScanInterval        int    // seconds between UDP announces and HTTP scans, default 30
+	UseUDPScanInterval     int    // seconds between UDP announces, 0 uses UseScanInterval
+	UseHTTPScanInterval    int    // seconds between HTTP scans, 0 uses UseScanInterval
+	UseQuietHours          string // local time window "HH:MM-HH:MM" with restricted receiving, empty disables
+	UseQuietHoursAction    string // reject or require-pin
+	UseQuietHoursPin       string // PIN asked for during quiet hours with require-pin, empty uses the configured PIN
+	UseParallelParts       int    // chunks of a chunked upload sent at once, default 1
+	UseWebDAV              bool   // if true, serve the upload folder over WebDAV at /webdav
+	UseSharedSecret        string // secret required by protected endpoints such as WebDAV, empty allows localhost only
+	UseAutoAcceptMaxSize   int64  // largest total bytes auto-accepted without confirmation, 0 means no limit
+	UseCommandShares       bool   // allow share-session entries that stream a command's stdout
+	UseLogStream           bool   // stream server logs over SSE at /api/self/v1/logs
+	UseMaxReceiveSessions  int    // concurrent receive sessions before senders get 409, 0 means no limit
+	UseGossip              bool   // exchange known device lists in register responses
+	UseIdentityUploadFolder bool  // save under a subfolder of the upload folder named after the self alias
+	UseEncryptionKeyFile   string // file holding a hex AES key; received files are stored encrypted with it
+	UseAutoScanConcurrency int    // concurrent HTTP scan workers of the periodic auto scan
+	UseScanNowConcurrency  int    // concurrent HTTP scan workers of scan-now
+	UseHistoryFile         string // file the per-device transfer history is kept in; empty keeps it in memory only
+	UseUploadStallTimeout  int    // seconds an incoming upload may receive no data before it is aborted; 0 disables
+	UseProtocolVersion     string // protocol version advertised to peers, overrides config version
+	UseUserAgent           string // User-Agent of outgoing requests; empty keeps Go's default
+	UseKeepPartialOnCancel bool   // keep the partial file of a cancelled upload as <name>.part
+	UsePartialMaxAge       int    // seconds after which unmodified kept partial files are deleted; 0 keeps them
+	UseMaxConcurrentDownloads int  // share downloads served at once before clients get 503, 0 means no limit
+	UseDownloadRateLimit   int64  // bytes per second each share download is capped at, 0 means no limit
+	UseHashCacheFile       string // file the SHA-256 cache of sent files is kept in; empty keeps it in memory only
+	UseMaxPreviewBytes     int    // largest file preview kept in prepare-upload and confirm notifications, 0 means no limit
 }
@@ -0,0 +1,39 @@
+package types
+
+import "time"
+
+// ConfigBundleVersion is the format version of ConfigBundle.
+const ConfigBundleVersion = 1
+
+// ConfigBundle is the portable device configuration used by export-config / import-config.
+// Machine-specific paths (certPath/keyPath) are not exported; their certificate is inlined instead.
+type ConfigBundle struct {
+	Version               int                   `json:"version"`
+	ExportedAt            time.Time             `json:"exportedAt"`
+	Alias                 string                `json:"alias"`
+	DeviceModel           string                `json:"deviceModel"`
+	DeviceType            string                `json:"deviceType"`
+	Fingerprint           string                `json:"fingerprint"`
+	Port                  int                   `json:"port"`
+	Protocol              string                `json:"protocol"`
+	Download              bool                  `json:"download"`
+	Announce              bool                  `json:"announce"`
+	Note                  string                `json:"note,omitempty"`
+	Capabilities          map[string]bool       `json:"capabilities,omitempty"`
+	Pin                   *string               `json:"pin,omitempty"`
+	AutoSave              *bool                 `json:"autoSave,omitempty"`
+	AutoSaveFromFavorites bool                  `json:"autoSaveFromFavorites"`
+	UploadFolder          string                `json:"uploadFolder,omitempty"`
+	FavoriteDevices       []FavoriteDeviceEntry `json:"favoriteDevices"`
+	BlockedFingerprints   []string              `json:"blockedFingerprints"`
+	// CertPEM and KeyPEM are only set when the export asked for the private key (includeKey=true)
+	CertPEM string `json:"certPEM,omitempty"`
+	KeyPEM  string `json:"keyPEM,omitempty"`
+}
+
+// ImportConfigResponse is returned by import-config.
+type ImportConfigResponse struct {
+	Fingerprint     string `json:"fingerprint"`
+	KeyImported     bool   `json:"keyImported"`
+	RestartRequired bool   `json:"restartRequired"` // identity/listener fields only apply after a restart
+}
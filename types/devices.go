@@ -31,9 +31,14 @@ type UserScanCurrentItem struct {
 // SelfNetworkInfo represents the local device's network information
 // including IP address and broadcast segment number
 type SelfNetworkInfo struct {
-	InterfaceName string `json:"interface_name"` // network interface name
-	IPAddress     string `json:"ip_address"`     // ip address
-	Number        string `json:"number"`         // number
-	NumberInt     int    `json:"number_int"`     // number int
+	InterfaceName     string `json:"interface_name"`     // network interface name
+	IPAddress         string `json:"ip_address"`         // ip address
+	Number            string `json:"number"`             // number
+	NumberInt         int    `json:"number_int"`         // number int
+	Index             int    `json:"index"`              // interface index
+	MTU               int    `json:"mtu"`                // maximum transmission unit
+	IsUp              bool   `json:"is_up"`              // interface is administratively up
+	IsLoopback        bool   `json:"is_loopback"`        // loopback interface
+	SupportsMulticast bool   `json:"supports_multicast"` // multicast capable, required for discovery
 }
 
@@ -0,0 +1,23 @@
+package types
+
+// CapabilityEcho enables POST /api/localsend/v2/echo, which reads and discards the body for speed tests.
+// Off unless set in the config capabilities, since it lets peers use this device's bandwidth.
+const CapabilityEcho = "echo"
+
+// EchoMaxBytes is the largest body /echo reads; the rest of a larger body is refused.
+const EchoMaxBytes = 1 << 30
+
+// EchoResult is returned by /echo.
+type EchoResult struct {
+	BytesReceived int64 `json:"bytesReceived"`
+	ElapsedMs     int64 `json:"elapsedMs"` // from the first handler call to the end of the body
+}
+
+// ThroughputResult is the outcome of a speed test against a peer's /echo.
+type ThroughputResult struct {
+	BytesSent  int64   `json:"bytesSent"`
+	DurationMs int64   `json:"durationMs"` // measured by the sender, including the response
+	MBps       float64 `json:"mbps"`       // megabytes (10^6) per second as seen by the sender
+	// ReceiverElapsedMs is the time the receiver spent reading the body
+	ReceiverElapsedMs int64 `json:"receiverElapsedMs"`
+}
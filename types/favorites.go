@@ -1,9 +1,12 @@
 package types
 
-// FavoriteDeviceEntry represents a favorite device with only fingerprint and alias
+// FavoriteDeviceEntry represents a favorite device by fingerprint and alias,
+// plus its last known address so it can be probed when not discovered.
 type FavoriteDeviceEntry struct {
 	Fingerprint string `yaml:"favorite_fingerprint" json:"favorite_fingerprint"`
 	Alias       string `yaml:"favorite_alias" json:"favorite_alias"`
+	LastIP      string `yaml:"favorite_last_ip,omitempty" json:"favorite_last_ip,omitempty"`
+	LastPort    int    `yaml:"favorite_last_port,omitempty" json:"favorite_last_port,omitempty"`
 }
 
 // FavoriteDevicesYamlFileConfig is used for YAML unmarshaling of favorites
@@ -11,6 +14,11 @@ type FavoriteDevicesYamlFileConfig struct {
 	FavoriteDevices []FavoriteDeviceEntry `yaml:"favoriteDevices"`
 }
 
+// UserBlocklistAddRequest represents the request body for blocking a device
+type UserBlocklistAddRequest struct {
+	Fingerprint string `json:"fingerprint"`
+}
+
 // UserFavoritesAddRequest represents the request body for adding a favorite device
 type UserFavoritesAddRequest struct {
 	Fingerprint string `json:"favorite_fingerprint"`
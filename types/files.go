@@ -19,9 +19,19 @@ type FileInfo struct {
 type FileInput struct {
 	ID       string `json:"id"`                // File ID
 	FileName string `json:"fileName"`          // File name (optional if fileUrl is provided)
-	Size     int64  `json:"size"`              // File size in bytes (optional if fileUrl is provided)
+	Size     *int64 `json:"size"`              // File size in bytes, 0 allowed for empty files (optional if fileUrl is provided)
 	FileType string `json:"fileType"`          // File type, e.g., "image/jpeg" (optional if fileUrl is provided)
 	SHA256   string `json:"sha256,omitempty"`  // SHA256 hash value (optional)
 	Preview  string `json:"preview,omitempty"` // Preview data (optional)
 	FileUrl  string `json:"fileUrl,omitempty"` // File URL (supports file:/// protocol, auto-reads file info)
+	// Command is an argv whose stdout is served instead of a file (share sessions only, needs -useCommandShares)
+	Command []string `json:"command,omitempty"`
+}
+
+// SizeValue returns the declared size, or 0 when none was provided.
+func (f FileInput) SizeValue() int64 {
+	if f.Size == nil {
+		return 0
+	}
+	return *f.Size
 }
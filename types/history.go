@@ -0,0 +1,31 @@
+package types
+
+// Transfer history results. Directions reuse AuditDirectionReceive and AuditDirectionSend.
+const (
+	HistoryResultSuccess   = "success"   // every file transferred
+	HistoryResultPartial   = "partial"   // some files failed
+	HistoryResultFailed    = "failed"    // no file transferred
+	HistoryResultCancelled = "cancelled" // the transfer was cancelled or rejected midway
+)
+
+// HistoryMaxFileNames caps how many file names a history entry keeps; Files still counts all of them.
+const HistoryMaxFileNames = 20
+
+// TransferHistoryEntry is one completed transfer with a peer device.
+type TransferHistoryEntry struct {
+	SessionId   string   `json:"sessionId"`
+	Direction   string   `json:"direction"` // AuditDirectionReceive | AuditDirectionSend
+	PeerAlias   string   `json:"peerAlias,omitempty"`
+	Files       int      `json:"files"`                 // files transferred successfully
+	FailedFiles int      `json:"failedFiles,omitempty"` // files that failed
+	FileNames   []string `json:"fileNames,omitempty"`   // names of transferred files, at most HistoryMaxFileNames
+	Bytes       int64    `json:"bytes"`                 // bytes of the files transferred successfully
+	Timestamp   string   `json:"timestamp"`             // RFC3339
+	Result      string   `json:"result"`                // HistoryResultXxx
+}
+
+// TransferHistoryResponse is returned by the self history endpoint, newest entry first
+type TransferHistoryResponse struct {
+	Fingerprint string                 `json:"fingerprint"`
+	Entries     []TransferHistoryEntry `json:"entries"`
+}
@@ -0,0 +1,56 @@
+package types
+
+import "fmt"
+
+// Kinds of IntegrityError
+const (
+	IntegrityKindSize = "size"
+	IntegrityKindHash = "hash"
+)
+
+// IntegrityError reports a received file whose size or SHA-256 differs from what prepare-upload declared.
+// Receivers answer it with 422 and the fields below next to "error".
+type IntegrityError struct {
+	Kind           string `json:"kind"` // size or hash
+	ExpectedSize   int64  `json:"expectedSize,omitempty"`
+	ActualSize     int64  `json:"actualSize,omitempty"`
+	ExpectedSHA256 string `json:"expectedSha256,omitempty"`
+	ActualSHA256   string `json:"actualSha256,omitempty"`
+}
+
+// NewSizeMismatchError returns an IntegrityError for a file of actual bytes where expected were declared.
+// When the body exceeded the declared size, actual is a lower bound: the excess is not read to the end.
+func NewSizeMismatchError(expected, actual int64) *IntegrityError {
+	return &IntegrityError{Kind: IntegrityKindSize, ExpectedSize: expected, ActualSize: actual}
+}
+
+// NewHashMismatchError returns an IntegrityError for a file whose SHA-256 differs from the declared one.
+func NewHashMismatchError(expected, actual string) *IntegrityError {
+	return &IntegrityError{Kind: IntegrityKindHash, ExpectedSHA256: expected, ActualSHA256: actual}
+}
+
+func (e *IntegrityError) Error() string {
+	if e.Kind == IntegrityKindSize {
+		switch {
+		case e.ActualSize < e.ExpectedSize:
+			return fmt.Sprintf("incomplete upload: expected %d bytes, got %d", e.ExpectedSize, e.ActualSize)
+		case e.ActualSize > e.ExpectedSize:
+			return fmt.Sprintf("upload exceeds declared size: expected %d bytes, got more", e.ExpectedSize)
+		}
+		return fmt.Sprintf("size mismatch: expected %d bytes, got %d", e.ExpectedSize, e.ActualSize)
+	}
+	return fmt.Sprintf("hash mismatch: expected %s, got %s", e.ExpectedSHA256, e.ActualSHA256)
+}
+
+// Details returns the fields sent next to "error" in a 422 response.
+func (e *IntegrityError) Details() map[string]any {
+	details := map[string]any{"kind": e.Kind}
+	if e.Kind == IntegrityKindSize {
+		details["expectedSize"] = e.ExpectedSize
+		details["actualSize"] = e.ActualSize
+	} else {
+		details["expectedSha256"] = e.ExpectedSHA256
+		details["actualSha256"] = e.ActualSHA256
+	}
+	return details
+}
@@ -0,0 +1,8 @@
+package types
+
+// LogEntry is one formatted log line sent by the logs stream
+type LogEntry struct {
+	Time  string `json:"time"`  // when the line was captured, RFC3339
+	Level string `json:"level"` // debug, info, warn, error or fatal
+	Line  string `json:"line"`  // the line as printed to the console, without colors
+}
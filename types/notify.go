@@ -16,10 +16,12 @@ const (
 	NotifyTypeDeviceUpdated    = "device_updated"
 	NotifyTypeInfo             = "info"
 	NotifyTypeTextReceived     = "text_received"
+	NotifyTypeShutdown         = "shutdown" // app is exiting (e.g. idle shutdown)
 )
 
 // Notification represents a notification message structure sent via Unix socket (e.g. to Decky).
 // Type: use NotifyTypeXxx constants. Data keys vary by type (sessionId, from, fileCount, files, etc.).
+// A notification too large for the socket is sent as a summary with data.payloadRef; see notify.GetNotifyPayload.
 type Notification struct {
 	Type       string         `json:"type,omitempty"`       // Notification type; use NotifyTypeXxx constants
 	Title      string         `json:"title,omitempty"`      // Notification title
@@ -9,6 +9,20 @@ const (
 	ScanModeMixed                 // Both UDP and HTTP scanning
 )
 
+// String returns the mode name used in API responses.
+func (m ScanMode) String() string {
+	switch m {
+	case ScanModeUDP:
+		return "udp"
+	case ScanModeHTTP:
+		return "http"
+	case ScanModeMixed:
+		return "mixed"
+	default:
+		return "unknown"
+	}
+}
+
 // ScanConfig holds the current scan configuration for scan-now API
 type ScanConfig struct {
 	Mode        ScanMode
@@ -17,3 +31,42 @@ type ScanConfig struct {
 	Timeout     int // UDP timeout in seconds (from config, default 500). 0 means no timeout
 	HTTPTimeout int // HTTP timeout in seconds, 60. 0 means use Timeout for backward compat
 }
+
+// ScanControlRequest is the body of POST /api/self/v1/scan-control
+type ScanControlRequest struct {
+	Action string `json:"action"` // "pause" or "resume"
+}
+
+// ScanControlStatus reports the current scan pause state
+type ScanControlStatus struct {
+	Paused        bool  `json:"paused"`        // true if scanning is currently skipped for any reason
+	UserPaused    bool  `json:"userPaused"`    // set via scan-control
+	TransferCount int32 `json:"transferCount"` // active transfers holding a pause
+}
+
+// ScanNowResult describes the scan run by scan-now
+type ScanNowResult struct {
+	Mode        string `json:"mode"`        // "http" for the HTTP sweep, otherwise the one-shot mode (udp, mixed)
+	ProbedCount int    `json:"probedCount"` // IPs probed by the HTTP sweep, 0 for UDP
+	DurationMs  int64  `json:"durationMs"`
+	TimedOut    bool   `json:"timedOut"` // the sweep hit its deadline before probing every IP
+}
+
+// UserScanNowResponse is returned by GET /api/self/v1/scan-now
+type UserScanNowResponse struct {
+	Devices     []UserScanCurrentItem `json:"devices"`
+	Mode        string                `json:"mode"`
+	ProbedCount int                   `json:"probedCount"`
+	DurationMs  int64                 `json:"durationMs"`
+	TimedOut    bool                  `json:"timedOut"`
+}
+
+// ScanStatus reports progress of an HTTP sweep
+type ScanStatus struct {
+	Running    bool   `json:"running"`
+	Total      int    `json:"total"`     // targets in this sweep
+	Probed     int    `json:"probed"`    // targets probed so far
+	Responded  int    `json:"responded"` // devices discovered so far
+	StartedAt  string `json:"startedAt,omitempty"`
+	FinishedAt string `json:"finishedAt,omitempty"`
+}
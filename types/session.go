@@ -8,6 +8,31 @@ type SessionUploadStats struct {
 	SuccessFiles  int
 	FailedFiles   int
 	FailedFileIds []string
+	SuccessBytes  int64    // total size of the files received successfully
+	SuccessNames  []string // names of the files received successfully, at most HistoryMaxFileNames
+}
+
+// UploadReceipt records the receiver-side outcome of a single file in a session
+type UploadReceipt struct {
+	SessionId   string `json:"sessionId"`
+	FileId      string `json:"fileId"`
+	Success     bool   `json:"success"`
+	CompletedAt string `json:"completedAt"` // RFC3339
+}
+
+// UploadChunkProgress tracks a chunked upload of a single file on the receiver side
+type UploadChunkProgress struct {
+	Total      int          // number of chunks announced by the sender
+	ChunkSize  int64        // size of every chunk except possibly the last
+	PartPath   string       // temporary file the chunks are written into
+	TargetPath string       // final path the part file is renamed to on completion
+	Received   map[int]bool // chunk indexes written so far
+}
+
+// UploadChunkStatus is returned by /upload-chunks so a sender can resume at chunk granularity
+type UploadChunkStatus struct {
+	Total    int   `json:"total"`
+	Received []int `json:"received"`
 }
 
 // SessionContext holds the context and cancel function for a session
@@ -21,3 +46,37 @@ type UserUploadSessionContext struct {
 	Ctx    context.Context
 	Cancel context.CancelFunc
 }
+
+// ReceivedFileRecord is what is kept about a saved file after its session ends
+type ReceivedFileRecord struct {
+	SavePath string
+	SHA256   string // hash announced by the sender, empty if none was given
+}
+
+// Verify-received statuses.
+const (
+	VerifyStatusPass       = "pass"
+	VerifyStatusFail       = "fail"
+	VerifyStatusMissing    = "missing"    // saved file no longer exists or cannot be read
+	VerifyStatusUnverified = "unverified" // no hash was recorded for the file
+)
+
+// VerifyReceivedFile is the per-file result of verify-received
+type VerifyReceivedFile struct {
+	FileId         string `json:"fileId"`
+	SavePath       string `json:"savePath"`
+	Status         string `json:"status"`
+	ExpectedSHA256 string `json:"expectedSha256,omitempty"`
+	ActualSHA256   string `json:"actualSha256,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// VerifyReceivedResponse is returned by verify-received
+type VerifyReceivedResponse struct {
+	SessionId  string               `json:"sessionId"`
+	Passed     int                  `json:"passed"`
+	Failed     int                  `json:"failed"`
+	Missing    int                  `json:"missing"`
+	Unverified int                  `json:"unverified"`
+	Files      []VerifyReceivedFile `json:"files"`
+}
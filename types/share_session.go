@@ -6,6 +6,8 @@ import "time"
 type ShareFileEntry struct {
 	FileInfo  FileInfo
 	LocalPath string // path on disk for serving
+	// CommandSource, when set, is run on each download and its stdout streamed; LocalPath is empty
+	CommandSource []string
 }
 
 // ShareSession represents a share session for the download API
@@ -15,13 +17,30 @@ type ShareSession struct {
 	CreatedAt  time.Time
 	Pin        string
 	AutoAccept bool
+	Persistent bool // exempt from ShareSessionTTL, files can be added/removed while open
+	// UploadDir holds the files uploaded with the session (multipart create-share-session); removed on close
+	UploadDir string
 }
 
-// CreateShareSessionRequest represents the request body for creating a share session
+// CreateShareSessionRequest represents the request body for creating a share session.
+// As multipart/form-data the fields are form values and the shared files are uploaded as "files".
 type CreateShareSessionRequest struct {
-	Files      map[string]FileInput `json:"files"`
-	Pin        string               `json:"pin,omitempty"`
-	AutoAccept bool                 `json:"autoAccept"`
+	Files      map[string]FileInput `json:"files" form:"-"`
+	Pin        string               `json:"pin,omitempty" form:"pin"`
+	AutoAccept bool                 `json:"autoAccept" form:"autoAccept"`
+	Persistent bool                 `json:"persistent,omitempty" form:"persistent"`
+	// Label names the share-uploads subfolder of uploaded files; the session id is used when empty
+	Label string `json:"label,omitempty" form:"label"`
+}
+
+// ShareSessionAddFilesRequest represents the request body for adding files to a share session
+type ShareSessionAddFilesRequest struct {
+	Files map[string]FileInput `json:"files"`
+}
+
+// ShareSessionRemoveFilesRequest represents the request body for removing files from a share session
+type ShareSessionRemoveFilesRequest struct {
+	FileIds []string `json:"fileIds"`
 }
 
 // CreateShareSessionResponse represents the response for create-share-session
@@ -29,3 +48,33 @@ type CreateShareSessionResponse struct {
 	SessionId   string `json:"sessionId"`
 	DownloadUrl string `json:"downloadUrl"`
 }
+
+// ShareSessionSummary describes an open share session for share-sessions listing
+type ShareSessionSummary struct {
+	SessionId    string    `json:"sessionId"`
+	FileCount    int       `json:"fileCount"`
+	TotalSize    int64     `json:"totalSize"` // sum of declared sizes; command-backed files count their hint only
+	PinProtected bool      `json:"pinProtected"`
+	AutoAccept   bool      `json:"autoAccept"`
+	Persistent   bool      `json:"persistent"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// DownloadManifestEntry describes one file of a share session in the download manifest
+type DownloadManifestEntry struct {
+	FileId   string `json:"fileId"`
+	FileName string `json:"fileName"`
+	Size     int64  `json:"size"`
+	FileType string `json:"fileType"`
+	SHA256   string `json:"sha256,omitempty"`
+	// ETag is what /download sends for the file, for If-Range on resumed downloads; empty for streamed command output
+	ETag string `json:"etag,omitempty"`
+}
+
+// DownloadManifest lists every file of a share session in a stable order, so a downloading client can
+// checkpoint progress and skip finished files when it resumes.
+type DownloadManifest struct {
+	SessionId string                  `json:"sessionId"`
+	TotalSize int64                   `json:"totalSize"`
+	Files     []DownloadManifestEntry `json:"files"`
+}
@@ -0,0 +1,14 @@
+package types
+
+// RuntimeStats holds in-memory counters since startup, returned by tool.Stats and the self stats endpoint.
+type RuntimeStats struct {
+	FilesReceived         int64 `json:"filesReceived"`         // files received successfully
+	FilesSent             int64 `json:"filesSent"`             // files sent successfully
+	Downloads             int64 `json:"downloads"`             // share downloads served, range requests included
+	BytesIn               int64 `json:"bytesIn"`               // bytes of the files received successfully
+	BytesOut              int64 `json:"bytesOut"`              // bytes of the files sent and downloads served
+	ActiveReceiveSessions int   `json:"activeReceiveSessions"` // receive sessions holding a slot
+	DevicesDiscovered     int64 `json:"devicesDiscovered"`     // devices newly added to the scan list
+	ScanCycles            int64 `json:"scanCycles"`            // HTTP scan sweeps completed
+	UptimeSeconds         int64 `json:"uptimeSeconds"`
+}
@@ -19,6 +19,8 @@ type PrepareUploadReverseProxyResp struct {
 	Info      DeviceInfoReverseMode `json:"info"`
 	SessionId string                `json:"sessionId"`
 	Files     map[string]FileInfo   `json:"files"`
+	// DownloadToken lets the client retry prepare-download/download without being confirmed again.
+	DownloadToken string `json:"downloadToken,omitempty"`
 }
 
 // Notify Worker, Upload_start Notify event
@@ -11,6 +11,41 @@ type UserPrepareUploadRequest struct {
 	UseFastSender         bool                 `json:"useFastSender,omitempty"`
 	UseFastSenderIPSuffex string               `json:"useFastSenderIPSuffex,omitempty"`
 	UseFastSenderIp       string               `json:"useFastSenderIp,omitempty"`
+	ZipBeforeSend         bool                 `json:"zipBeforeSend,omitempty"` // Folder mode: send all folder files as one ZIP
+	ZipLevel              string               `json:"zipLevel,omitempty"`      // zipBeforeSend compression: store (default), fast or best; compressed media is always stored
+	SkipSHA               bool                 `json:"skipSHA,omitempty"`       // Send without SHA-256 for speed; the receiver cannot verify integrity
+}
+
+// UserPrepareUploadResponse is returned by the self prepare-upload endpoint
+type UserPrepareUploadResponse struct {
+	SessionId string            `json:"sessionId"`
+	Files     map[string]string `json:"files"`
+	Warning   string            `json:"warning,omitempty"` // e.g. the receiver reported less free space than the session size
+}
+
+// Cancel reasons sent with /cancel and forwarded in the upload_cancelled notification.
+// Other peers may send free-form reasons or none at all.
+const (
+	CancelReasonUserCancelled = "user-cancelled"
+	CancelReasonTimeout       = "timeout"
+	CancelReasonError         = "error"
+)
+
+// MaxCancelReasonLength caps the reason stored for a cancelled session.
+const MaxCancelReasonLength = 128
+
+// CancelResult reports whether the remote side of a session acknowledged a cancel request
+type CancelResult struct {
+	Acknowledged bool `json:"acknowledged"` // remote answered with 2xx
+	Attempts     int  `json:"attempts"`
+}
+
+// UserCancelUploadResponse is returned by the self cancel endpoint for a sending session
+type UserCancelUploadResponse struct {
+	LocalCancelled bool   `json:"localCancelled"`
+	RemoteNotified bool   `json:"remoteNotified"` // false when only the local side was cancelled
+	Attempts       int    `json:"attempts,omitempty"`
+	RemoteError    string `json:"remoteError,omitempty"`
 }
 
 // UserUploadRequest represents the actual upload request
@@ -19,6 +54,7 @@ type UserUploadRequest struct {
 	FileId    string `json:"fileId"`
 	Token     string `json:"token"`
 	FileUrl   string `json:"fileUrl"`
+	ChunkSize int64  `json:"chunkSize,omitempty"` // Optional: send file:// sources in chunks of this many bytes
 }
 
 // UserUploadBatchRequest represents batch upload request
@@ -26,8 +62,9 @@ type UserUploadBatchRequest struct {
 	SessionId       string               `json:"sessionId"`
 	Files           []UserUploadFileItem `json:"files,omitempty"`
 	UseFolderUpload bool                 `json:"useFolderUpload,omitempty"`
-	FolderPath      string               `json:"folderPath,omitempty"`  // Single folder (backward compatible)
-	FolderPaths     []string             `json:"folderPaths,omitempty"` // Multiple folders
+	FolderPath      string               `json:"folderPath,omitempty"`    // Single folder (backward compatible)
+	FolderPaths     []string             `json:"folderPaths,omitempty"`   // Multiple folders
+	ZipBeforeSend   bool                 `json:"zipBeforeSend,omitempty"` // Folder mode: send the ZIP built by prepare-upload
 }
 
 // UserUploadFileItem represents a single file in batch upload
@@ -57,4 +94,35 @@ type UserUploadSession struct {
 	Target    UserScanCurrentItem
 	SessionId string
 	Tokens    map[string]string
+	ZipPaths  map[string]string   // fileId -> temp ZIP built for zipBeforeSend folder uploads, removed with the session
+	Files     map[string]FileInfo // fileId -> file info announced in prepare-upload, for audit records of raw uploads
+}
+
+// TransferStats is a snapshot of a sending session's throughput.
+type TransferStats struct {
+	SessionId      string  `json:"sessionId"`
+	BytesSent      int64   `json:"bytesSent"`
+	TotalBytes     int64   `json:"totalBytes"`     // 0 if unknown
+	BytesPerSecond float64 `json:"bytesPerSecond"` // averaged over the last few seconds
+	EtaSeconds     int64   `json:"etaSeconds"`     // -1 if unknown
+}
+
+// SendManifest is a local JSON file describing a whole send: every file in Files goes to TargetTo
+type SendManifest struct {
+	TargetTo string   `json:"targetTo"`      // fingerprint of a discovered device
+	Files    []string `json:"files"`         // local file paths
+	Pin      string   `json:"pin,omitempty"` // PIN of the receiver, if it asks for one
+}
+
+// UserSendManifestRequest is the body of the self send-manifest endpoint
+type UserSendManifestRequest struct {
+	ManifestPath string `json:"manifestPath"`
+}
+
+// UserSendManifestResponse summarizes a send-manifest run
+type UserSendManifestResponse struct {
+	SessionId   string                `json:"sessionId,omitempty"`
+	TargetAlias string                `json:"targetAlias"`
+	Result      UserUploadBatchResult `json:"result"`
+	NotAccepted []string              `json:"notAccepted,omitempty"` // paths the receiver did not accept
 }
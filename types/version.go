@@ -29,37 +29,58 @@ type VersionMessage struct {
 	Protocol    string `json:"protocol"`
 	Download    bool   `json:"download"`
 	Announce    bool   `json:"announce"`
+	// Capabilities advertises optional features (e.g. "resume") to peers. Unknown keys are ignored.
+	Capabilities map[string]bool `json:"capabilities,omitempty"`
+	// Note is an optional free-text label (e.g. "Living Room TV") to tell instances apart.
+	Note string `json:"note,omitempty"`
 }
 
 type VersionMessageHTTP struct {
-	Alias       string `json:"alias"`
-	Version     string `json:"version"`
-	DeviceModel string `json:"deviceModel"`
-	DeviceType  string `json:"deviceType"`
-	Fingerprint string `json:"fingerprint"`
-	Port        int    `json:"port"`
-	Protocol    string `json:"protocol"`
-	Download    bool   `json:"download"`
+	Alias        string          `json:"alias"`
+	Version      string          `json:"version"`
+	DeviceModel  string          `json:"deviceModel"`
+	DeviceType   string          `json:"deviceType"`
+	Fingerprint  string          `json:"fingerprint"`
+	Port         int             `json:"port"`
+	Protocol     string          `json:"protocol"`
+	Download     bool            `json:"download"`
+	Capabilities map[string]bool `json:"capabilities,omitempty"`
+	Note         string          `json:"note,omitempty"`
 }
 
 type CallbackVersionMessageHTTP struct {
-	Alias       string `json:"alias"`
-	Version     string `json:"version"`
-	DeviceModel string `json:"deviceModel"`
-	DeviceType  string `json:"deviceType"`
+	Alias        string          `json:"alias"`
+	Version      string          `json:"version"`
+	DeviceModel  string          `json:"deviceModel"`
+	DeviceType   string          `json:"deviceType"`
+	Fingerprint  string          `json:"fingerprint"`
+	Port         int             `json:"port,omitempty"`
+	Protocol     string          `json:"protocol,omitempty"`
+	Download     bool            `json:"download"`
+	Capabilities map[string]bool `json:"capabilities,omitempty"`
+	Note         string          `json:"note,omitempty"`
+	// Peers lists other known devices when gossip is enabled; clients that do not know it ignore it.
+	Peers []GossipPeer `json:"peers,omitempty"`
+}
+
+// GossipPeer is a device shared in a register response so peers can register with it directly
+type GossipPeer struct {
+	Ip          string `json:"ip"`
+	Port        int    `json:"port"`
+	Protocol    string `json:"protocol"`
 	Fingerprint string `json:"fingerprint"`
-	Port        int    `json:"port,omitempty"`
-	Protocol    string `json:"protocol,omitempty"`
-	Download    bool   `json:"download"`
 }
 
 type CallbackLegacyVersionMessageHTTP struct {
-	Alias       string `json:"alias"`
-	Version     string `json:"version"`
-	DeviceModel string `json:"deviceModel"`
-	DeviceType  string `json:"deviceType"`
-	Fingerprint string `json:"fingerprint"`
-	Download    bool   `json:"download"`
+	Alias        string          `json:"alias"`
+	Version      string          `json:"version"`
+	DeviceModel  string          `json:"deviceModel"`
+	DeviceType   string          `json:"deviceType"`
+	Fingerprint  string          `json:"fingerprint"`
+	Download     bool            `json:"download"`
+	Capabilities map[string]bool `json:"capabilities,omitempty"`
+	Note         string          `json:"note,omitempty"`
+	Peers        []GossipPeer    `json:"peers,omitempty"`
 }
 
 type V1InfoResponse struct {
@@ -76,4 +97,17 @@ type V2InfoResponse struct {
 	DeviceType  string `json:"deviceType"`
 	Fingerprint string `json:"fingerprint"`
 	Download    bool   `json:"download"`
+	Note        string `json:"note,omitempty"`
+	// Capabilities advertises optional features so probed peers (e.g. FastSender) see them too
+	Capabilities map[string]bool `json:"capabilities,omitempty"`
+}
+
+// CapabilityStorageInfo enables GET /api/localsend/v2/storage-info (free space of the upload folder).
+// Off unless set in the config capabilities, since it discloses disk usage to the network.
+const CapabilityStorageInfo = "storageInfo"
+
+// StorageInfo is returned by /storage-info.
+type StorageInfo struct {
+	Available uint64 `json:"available"` // bytes available to the receiver in its upload folder
+	Total     uint64 `json:"total"`     // size of the filesystem holding the upload folder
 }
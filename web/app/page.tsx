@@ -28,6 +28,7 @@ interface PrepareDownloadResponse {
   };
   sessionId: string;
   files: Record<string, FileInfo>;
+  downloadToken?: string;
 }
 
 const FILES_PAGE_SIZE = 10;
@@ -169,6 +170,9 @@ function DownloadContent() {
     const url = new URL("/api/localsend/v2/download", window.location.origin);
     url.searchParams.set("sessionId", data.sessionId);
     url.searchParams.set("fileId", fileId);
+    if (data.downloadToken) {
+      url.searchParams.set("token", data.downloadToken);
+    }
     return url.toString();
   };
 